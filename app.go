@@ -64,6 +64,31 @@ type Storage interface {
 	Close() error
 }
 
+// AtomicStorage is an optional extension of Storage for providers that can
+// update a key atomically. Middlewares use it when the configured storage
+// implements it, so that their state is correct when several instances or
+// prefork children share the storage.
+type AtomicStorage interface {
+	Storage
+
+	// Incr increments the integer value of the given key by delta and
+	// returns the new value. A key that does not exist is created with
+	// the value delta and the expiration exp, 0 means no expiration.
+	// The expiration of an existing key is not changed.
+	// The value is stored as decimal string, so Get returns e.g. "42".
+	Incr(key string, delta int64, exp time.Duration) (int64, error)
+
+	// CompareAndSwap sets the value of the given key to newVal with the
+	// expiration exp if its current value equals oldVal, and reports
+	// whether the value was swapped. A nil oldVal matches a key that does
+	// not exist, an empty newVal deletes the key.
+	CompareAndSwap(key string, oldVal, newVal []byte, exp time.Duration) (bool, error)
+
+	// SetIfAbsent stores the given value with the expiration exp if the
+	// key does not exist, and reports whether the value was stored.
+	SetIfAbsent(key string, val []byte, exp time.Duration) (bool, error)
+}
+
 // ErrorHandler defines a function that will process all errors
 // returned from any handlers in the stack
 //
@@ -108,14 +133,16 @@ type App struct {
 	mountFields *mountFields
 	// Route stack divided by HTTP methods
 	stack [][]*Route
-	// Route stack divided by HTTP methods and route prefixes
-	treeStack []map[string][]*Route
+	// Radix tree of the route stacks divided by route prefixes and HTTP methods
+	treeStack *routeNode
 	// custom binders
 	customBinders []CustomBinder
 	// customConstraints is a list of external constraints
 	customConstraints []CustomConstraint
 	// sendfiles stores configurations for handling ctx.SendFile operations
 	sendfiles []*sendFileStore
+	// HTTP/2 listeners whose connections are drained on shutdown
+	http2Listeners []*http2Listener
 	// App config
 	config Config
 	// Indicates if the value was explicitly configured
@@ -151,6 +178,14 @@ type Config struct { //nolint:govet // Aligning the struct fields is not necessa
 	// Default: false
 	CaseSensitive bool `json:"case_sensitive"`
 
+	// When set to true, OPTIONS requests for a registered path are answered automatically
+	// with "204 No Content" and an Allow header listing every method registered for that path,
+	// unless an OPTIONS route matches the path. OPTIONS is also listed in the Allow header
+	// of "405 Method Not Allowed" responses.
+	//
+	// Default: false
+	EnableAutoOptions bool `json:"enable_auto_options"`
+
 	// When set to true, this relinquishes the 0-allocation promise in certain
 	// cases in order to access the handler values (e.g. request bodies) in an
 	// immutable fashion so that these values are available even if you return
@@ -581,7 +616,6 @@ func New(config ...Config) *App {
 
 	// Create router stack
 	app.stack = make([][]*Route, len(app.config.RequestMethods))
-	app.treeStack = make([]map[string][]*Route, len(app.config.RequestMethods))
 
 	// Override colors
 	app.config.ColorScheme = defaultColors(app.config.ColorScheme)
@@ -667,6 +701,30 @@ func (app *App) Name(name string) Router {
 	return app
 }
 
+// Meta assigns a metadata value to the latest registered route, e.g. to describe it for the documentation.
+// The metadata is available in the Meta field of the routes returned by GetRoute and GetRoutes.
+func (app *App) Meta(key string, value any) Router {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+
+	for _, routes := range app.stack {
+		for _, route := range routes {
+			// The metadata of a Use route isn't assigned to the routes of its path and vice versa
+			isMethodValid := route.use == app.latestRoute.use && (route.Method == app.latestRoute.Method || app.latestRoute.use ||
+				(app.latestRoute.Method == MethodGet && route.Method == MethodHead))
+
+			if route.Path == app.latestRoute.Path && isMethodValid {
+				if route.Meta == nil {
+					route.Meta = make(map[string]any)
+				}
+				route.Meta[key] = value
+			}
+		}
+	}
+
+	return app
+}
+
 // GetRoute Get route by name
 func (app *App) GetRoute(name string) Route {
 	for _, routes := range app.stack {
@@ -928,7 +986,15 @@ func (app *App) ShutdownWithContext(ctx context.Context) error {
 	if app.server == nil {
 		return ErrNotRunning
 	}
-	return app.server.ShutdownWithContext(ctx)
+	err := app.server.ShutdownWithContext(ctx)
+	// The HTTP/2 connections are not tracked by the fasthttp server
+	for _, ln := range app.http2Listeners {
+		if h2Err := ln.shutdown(ctx); err == nil {
+			err = h2Err
+		}
+	}
+	app.http2Listeners = nil
+	return err
 }
 
 // Server returns the underlying fasthttp server
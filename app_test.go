@@ -194,6 +194,112 @@ func Test_App_MethodNotAllowed(t *testing.T) {
 	require.Equal(t, "GET, HEAD, POST, OPTIONS", resp.Header.Get(HeaderAllow))
 }
 
+func Test_App_MethodNotAllowed_Params(t *testing.T) {
+	t.Parallel()
+	app := New()
+
+	app.Get("/users/:id<int>", testEmptyHandler)
+	app.Delete("/users/:id", testEmptyHandler)
+	app.Put("/users/:name<alpha>", testEmptyHandler)
+
+	resp, err := app.Test(httptest.NewRequest(MethodPost, "/users/42", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusMethodNotAllowed, resp.StatusCode)
+	require.Equal(t, "GET, DELETE", resp.Header.Get(HeaderAllow))
+
+	resp, err = app.Test(httptest.NewRequest(MethodPost, "/users/john", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusMethodNotAllowed, resp.StatusCode)
+	require.Equal(t, "PUT, DELETE", resp.Header.Get(HeaderAllow))
+
+	resp, err = app.Test(httptest.NewRequest(MethodPost, "/groups/42", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusNotFound, resp.StatusCode)
+	require.Equal(t, "", resp.Header.Get(HeaderAllow))
+}
+
+func Test_App_AutoOptions(t *testing.T) {
+	t.Parallel()
+	app := New(Config{
+		EnableAutoOptions: true,
+	})
+
+	app.Get("/users/:id", testEmptyHandler)
+	app.Patch("/users/:id", testEmptyHandler)
+	app.Post("/orders", testEmptyHandler)
+	app.Options("/orders", func(c Ctx) error {
+		return c.SendString("custom options")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(MethodOptions, "/users/42", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusNoContent, resp.StatusCode)
+	require.Equal(t, "GET, PATCH, OPTIONS", resp.Header.Get(HeaderAllow))
+
+	// OPTIONS is listed for other methods as well
+	resp, err = app.Test(httptest.NewRequest(MethodPut, "/users/42", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusMethodNotAllowed, resp.StatusCode)
+	require.Equal(t, "GET, PATCH, OPTIONS", resp.Header.Get(HeaderAllow))
+
+	// a registered OPTIONS route takes precedence
+	resp, err = app.Test(httptest.NewRequest(MethodOptions, "/orders", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusOK, resp.StatusCode)
+	require.Equal(t, "", resp.Header.Get(HeaderAllow))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "custom options", string(body))
+
+	resp, err = app.Test(httptest.NewRequest(MethodGet, "/orders", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusMethodNotAllowed, resp.StatusCode)
+	require.Equal(t, "POST, OPTIONS", resp.Header.Get(HeaderAllow))
+
+	// unknown paths are not answered
+	resp, err = app.Test(httptest.NewRequest(MethodOptions, "/unknown", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusNotFound, resp.StatusCode)
+	require.Equal(t, "", resp.Header.Get(HeaderAllow))
+}
+
+func Test_App_AutoOptions_Disabled(t *testing.T) {
+	t.Parallel()
+	app := New()
+
+	app.Get("/users/:id", testEmptyHandler)
+
+	resp, err := app.Test(httptest.NewRequest(MethodOptions, "/users/42", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusMethodNotAllowed, resp.StatusCode)
+	require.Equal(t, "GET", resp.Header.Get(HeaderAllow))
+}
+
+func Test_App_AutoOptions_CustomCtx(t *testing.T) {
+	t.Parallel()
+	app := New(Config{
+		EnableAutoOptions: true,
+	})
+	app.NewCtxFunc(func(app *App) CustomCtx {
+		return &customCtx{
+			DefaultCtx: *NewDefaultCtx(app),
+		}
+	})
+
+	app.Get("/users/:id", testEmptyHandler)
+	app.Delete("/users/:id", testEmptyHandler)
+
+	resp, err := app.Test(httptest.NewRequest(MethodOptions, "/users/42", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusNoContent, resp.StatusCode)
+	require.Equal(t, "GET, DELETE, OPTIONS", resp.Header.Get(HeaderAllow))
+
+	resp, err = app.Test(httptest.NewRequest(MethodPost, "/users/42", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusMethodNotAllowed, resp.StatusCode)
+	require.Equal(t, "GET, DELETE, OPTIONS", resp.Header.Get(HeaderAllow))
+}
+
 func Test_App_Custom_Middleware_404_Should_Not_SetMethodNotAllowed(t *testing.T) {
 	t.Parallel()
 	app := New()
@@ -1669,6 +1775,54 @@ func Test_App_GetRoutes(t *testing.T) {
 	}
 }
 
+func Test_App_Route_Meta(t *testing.T) {
+	t.Parallel()
+	app := New()
+	handler := func(c Ctx) error {
+		return c.SendStatus(StatusOK)
+	}
+
+	app.Get("/users/:id", handler).Name("user").Meta("summary", "Get a user").Meta("tags", []string{"users"})
+	app.Post("/users", handler)
+
+	grp := app.Group("/admin").Meta("role", "admin").Meta("tags", []string{"admin"})
+	grp.Get("/stats", handler).Meta("summary", "Statistics")
+	sub := grp.Group("/audit").Meta("role", "auditor")
+	sub.Get("/log", handler)
+
+	route := app.GetRoute("user")
+	require.Equal(t, map[string]any{"summary": "Get a user", "tags": []string{"users"}}, route.Meta)
+
+	metas := make(map[string]map[string]any)
+	for _, r := range app.GetRoutes(true) {
+		metas[r.Path] = r.Meta
+	}
+	require.Nil(t, metas["/users"])
+	require.Equal(t, map[string]any{"role": "admin", "tags": []string{"admin"}, "summary": "Statistics"}, metas["/admin/stats"])
+	require.Equal(t, map[string]any{"role": "auditor", "tags": []string{"admin"}}, metas["/admin/audit/log"])
+
+	// the metadata of the group is not changed by the routes
+	grp.Get("/health", handler)
+	for _, r := range app.GetRoutes(true) {
+		if r.Path == "/admin/health" {
+			require.Equal(t, map[string]any{"role": "admin", "tags": []string{"admin"}}, r.Meta)
+		}
+	}
+
+	// the metadata of a Use route is not assigned to the routes of its path
+	app.Use("/users", handler).Meta("role", "user")
+	for _, r := range app.GetRoutes() {
+		if r.Path != "/users" {
+			continue
+		}
+		if r.IsMiddleware() {
+			require.Equal(t, map[string]any{"role": "user"}, r.Meta)
+		} else {
+			require.Nil(t, r.Meta)
+		}
+	}
+}
+
 func Test_Middleware_Route_Naming_With_Use(t *testing.T) {
 	t.Parallel()
 	named := "named"
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/khulnasoft/velocity/internal/tracecontext"
 	"github.com/khulnasoft/velocity/utils"
 	"github.com/valyala/fasthttp"
 )
@@ -119,6 +120,12 @@ func parserRequestHeader(c *Client, req *Request) error {
 		req.RawRequest.Header.AddBytesKV(key, value)
 	})
 
+	// Continue the trace of the request context, e.g. the span of the tracing middleware.
+	// An explicitly set traceparent header takes precedence.
+	if len(req.RawRequest.Header.Peek(tracecontext.HeaderTraceparent)) == 0 {
+		tracecontext.Inject(req.Context(), req.RawRequest.Header.Set)
+	}
+
 	// Set Content-Type and Accept headers based on the request body type.
 	switch req.bodyType {
 	case jsonBody:
@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -12,10 +13,17 @@ import (
 
 	"github.com/fxamacker/cbor/v2"
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/tracecontext"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+type traceCarrier tracecontext.SpanContext
+
+func (c traceCarrier) SpanContext() tracecontext.SpanContext {
+	return tracecontext.SpanContext(c)
+}
+
 func Test_Rand_String(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -215,6 +223,36 @@ func Test_Parser_Request_Header(t *testing.T) {
 		require.Equal(t, []byte("application/json"), req.RawRequest.Header.ContentType())
 	})
 
+	t.Run("trace context should be set", func(t *testing.T) {
+		t.Parallel()
+		client := New()
+
+		sc, ok := tracecontext.Parse("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "rojo=1")
+		require.True(t, ok)
+		req := AcquireRequest().
+			SetContext(tracecontext.ContextWith(context.Background(), traceCarrier(sc)))
+
+		err := parserRequestHeader(client, req)
+		require.NoError(t, err)
+		require.Equal(t, []byte(sc.Traceparent()), req.RawRequest.Header.Peek("traceparent"))
+		require.Equal(t, []byte("rojo=1"), req.RawRequest.Header.Peek("tracestate"))
+	})
+
+	t.Run("request traceparent header should not be overwritten", func(t *testing.T) {
+		t.Parallel()
+		client := New()
+
+		sc, ok := tracecontext.Parse("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "")
+		require.True(t, ok)
+		req := AcquireRequest().
+			SetContext(tracecontext.ContextWith(context.Background(), traceCarrier(sc))).
+			SetHeader("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+		err := parserRequestHeader(client, req)
+		require.NoError(t, err)
+		require.Equal(t, []byte("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"), req.RawRequest.Header.Peek("traceparent"))
+	})
+
 	t.Run("request header should be set", func(t *testing.T) {
 		t.Parallel()
 		client := New()
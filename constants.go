@@ -21,6 +21,7 @@ const (
 	MIMETextPlain       = "text/plain"
 	MIMETextJavaScript  = "text/javascript"
 	MIMETextCSS         = "text/css"
+	MIMETextEventStream = "text/event-stream"
 	MIMEApplicationXML  = "application/xml"
 	MIMEApplicationJSON = "application/json"
 	MIMEApplicationCBOR = "application/cbor"
@@ -284,6 +285,7 @@ const (
 	HeaderXRequestedWith                     = "X-Requested-With"
 	HeaderXRobotsTag                         = "X-Robots-Tag"
 	HeaderXUACompatible                      = "X-UA-Compatible"
+	HeaderXAccelBuffering                    = "X-Accel-Buffering"
 	HeaderAccessControlAllowPrivateNetwork   = "Access-Control-Allow-Private-Network"
 	HeaderAccessControlRequestPrivateNetwork = "Access-Control-Request-Private-Network"
 )
@@ -293,6 +295,7 @@ const (
 	NetworkTCP  = "tcp"
 	NetworkTCP4 = "tcp4"
 	NetworkTCP6 = "tcp6"
+	NetworkUnix = "unix"
 )
 
 // Compression types
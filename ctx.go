@@ -60,7 +60,7 @@ type DefaultCtx struct {
 	baseURI             string               // HTTP base uri
 	path                string               // HTTP path with the modifications by the configuration -> string copy from pathBuffer
 	detectionPath       string               // Route detection path                                  -> string copy from detectionPathBuffer
-	treePath            string               // Path for the search in the tree
+	treeNode            *routeNode           // Node of the route tree that contains the candidate routes for the detection path
 	pathOriginal        string               // Original HTTP path
 	pathBuffer          []byte               // HTTP path buffer
 	detectionPathBuffer []byte               // HTTP detectionPath buffer
@@ -1041,7 +1041,14 @@ func (c *DefaultCtx) Next() error {
 	// Did we execute all route handlers?
 	if c.indexHandler < len(c.route.Handlers) {
 		// Continue route stack
-		return c.route.Handlers[c.indexHandler](c)
+		return c.route.execute(c, c.indexHandler)
+	}
+
+	// The guards of a Use route are checked when it passes the request on
+	if c.route.use {
+		if allowed, err := c.route.allow(c); !allowed {
+			return err
+		}
 	}
 
 	// Continue handler stack
@@ -1837,13 +1844,9 @@ func (c *DefaultCtx) configDependentPaths() {
 	}
 	c.detectionPath = c.app.getString(c.detectionPathBuffer)
 
-	// Define the path for dividing routes into areas for fast tree detection, so that fewer routes need to be traversed,
-	// since the first three characters area select a list of routes
-	c.treePath = c.treePath[0:0]
-	const maxDetectionPaths = 3
-	if len(c.detectionPath) >= maxDetectionPaths {
-		c.treePath = c.detectionPath[:maxDetectionPaths]
-	}
+	// Look up the node of the route tree for the detection path once, so that only the routes
+	// whose constant prefix matches the path need to be traversed
+	c.treeNode = c.app.treeStack.lookup(c.detectionPath)
 }
 
 // IsProxyTrusted checks trustworthiness of remote ip.
@@ -1943,8 +1946,8 @@ func (c *DefaultCtx) getIndexRoute() int {
 	return c.indexRoute
 }
 
-func (c *DefaultCtx) getTreePath() string {
-	return c.treePath
+func (c *DefaultCtx) getTreeNode() *routeNode {
+	return c.treeNode
 }
 
 func (c *DefaultCtx) getDetectionPath() string {
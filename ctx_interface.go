@@ -19,7 +19,7 @@ type CustomCtx interface {
 	// Methods to use with next stack.
 	getMethodINT() int
 	getIndexRoute() int
-	getTreePath() string
+	getTreeNode() *routeNode
 	getDetectionPath() string
 	getPathOriginal() string
 	getValues() *[maxParams]string
@@ -291,6 +291,12 @@ type Ctx interface {
 	SendStream(stream io.Reader, size ...int) error
 	// SendStreamWriter sets response body stream writer
 	SendStreamWriter(streamWriter func(*bufio.Writer)) error
+	// SSE sets up the response for Server-Sent Events and calls the handler with the stream once the
+	// headers have been sent. The handler runs after the request handler returned, so it must not
+	// use the Ctx, values that are needed have to be copied before. The stream ends when the handler
+	// returns, its context is canceled when the client closes the connection or the server shuts down,
+	// so handlers that wait for events should select on it.
+	SSE(handler func(stream *SSEStream) error, config ...SSEConfig) error
 	// Set sets the response's HTTP header field to the specified key, value.
 	Set(key, val string)
 	setCanonical(key, val string)
@@ -341,7 +347,7 @@ type Ctx interface {
 	// Methods to use with next stack.
 	getMethodINT() int
 	getIndexRoute() int
-	getTreePath() string
+	getTreeNode() *routeNode
 	getDetectionPath() string
 	getPathOriginal() string
 	getValues() *[maxParams]string
@@ -14,6 +14,7 @@ type Group struct {
 	app         *App
 	parentGroup *Group
 	name        string
+	meta        map[string]any
 
 	Prefix          string
 	anyRouteDefined bool
@@ -45,6 +46,47 @@ func (grp *Group) Name(name string) Router {
 	return grp
 }
 
+// Meta Assign a metadata value to specific route or group itself.
+//
+// If this method is used before any route added to group, the value is inherited by all routes that are added to the group afterwards.
+// Otherwise, it'll be assigned to the latest route.
+func (grp *Group) Meta(key string, value any) Router {
+	if grp.anyRouteDefined {
+		grp.app.Meta(key, value)
+
+		return grp
+	}
+
+	grp.app.mutex.Lock()
+	if grp.meta == nil {
+		grp.meta = make(map[string]any)
+	}
+	grp.meta[key] = value
+	grp.app.mutex.Unlock()
+
+	return grp
+}
+
+// metadata returns a copy of the metadata of the group including the metadata of its parent groups
+func (grp *Group) metadata() map[string]any {
+	if grp == nil {
+		return nil
+	}
+
+	meta := grp.parentGroup.metadata()
+	if len(grp.meta) == 0 {
+		return meta
+	}
+	if meta == nil {
+		meta = make(map[string]any, len(grp.meta))
+	}
+	for key, value := range grp.meta {
+		meta[key] = value
+	}
+
+	return meta
+}
+
 // Use registers a middleware route that will match requests
 // with the provided prefix (which is optional and defaults to "/").
 // Also, you can pass another app instance as a sub-router along a routing path.
@@ -100,41 +100,29 @@ func (app *App) quoteString(raw string) string {
 	return quoted
 }
 
-// Scan stack if other methods match the request
+// Scan stack if other methods match the request and list them in the Allow header
 func (app *App) methodExist(c *DefaultCtx) bool {
-	var exists bool
+	var exists, optionsAllowed bool
+	// Scratch space for the params, so that the values of the context stay untouched
+	var values [maxParams]string
 
 	methods := app.config.RequestMethods
 	for i := 0; i < len(methods); i++ {
 		// Skip original method
-		if c.getMethodINT() == i {
+		if c.methodINT == i {
 			continue
 		}
-		// Reset stack index
-		c.setIndexRoute(-1)
-
-		tree, ok := c.App().treeStack[i][c.getTreePath()]
-		if !ok {
-			tree = c.App().treeStack[i][""]
-		}
-		// Get stack length
-		lenr := len(tree) - 1
-		// Loop over the route stack starting from previous index
-		for c.getIndexRoute() < lenr {
-			// Increment route index
-			c.setIndexRoute(c.getIndexRoute() + 1)
-			// Get *Route
-			route := tree[c.getIndexRoute()]
-			// Skip use routes
-			if route.use {
+		// Loop over the route stack of the method
+		for _, route := range c.treeNode.stack(i) {
+			// Skip use routes and mounted apps
+			if route.use || route.mount {
 				continue
 			}
 			// Check if it matches the request path
-			match := route.match(c.getDetectionPath(), c.Path(), c.getValues())
-			// No match, next route
-			if match {
+			if route.match(c.detectionPath, c.path, &values) {
 				// We matched
 				exists = true
+				optionsAllowed = optionsAllowed || methods[i] == MethodOptions
 				// Add method to Allow header
 				c.Append(HeaderAllow, methods[i])
 				// Break stack loop
@@ -142,43 +130,36 @@ func (app *App) methodExist(c *DefaultCtx) bool {
 			}
 		}
 	}
+	// OPTIONS requests are answered automatically for every registered path
+	if exists && !optionsAllowed && app.config.EnableAutoOptions {
+		c.Append(HeaderAllow, MethodOptions)
+	}
 	return exists
 }
 
-// Scan stack if other methods match the request
+// Scan stack if other methods match the request and list them in the Allow header
 func (app *App) methodExistCustom(c CustomCtx) bool {
-	var exists bool
+	var exists, optionsAllowed bool
+	// Scratch space for the params, so that the values of the context stay untouched
+	var values [maxParams]string
+
 	methods := app.config.RequestMethods
 	for i := 0; i < len(methods); i++ {
 		// Skip original method
 		if c.getMethodINT() == i {
 			continue
 		}
-		// Reset stack index
-		c.setIndexRoute(-1)
-
-		tree, ok := c.App().treeStack[i][c.getTreePath()]
-		if !ok {
-			tree = c.App().treeStack[i][""]
-		}
-		// Get stack length
-		lenr := len(tree) - 1
-		// Loop over the route stack starting from previous index
-		for c.getIndexRoute() < lenr {
-			// Increment route index
-			c.setIndexRoute(c.getIndexRoute() + 1)
-			// Get *Route
-			route := tree[c.getIndexRoute()]
-			// Skip use routes
-			if route.use {
+		// Loop over the route stack of the method
+		for _, route := range c.getTreeNode().stack(i) {
+			// Skip use routes and mounted apps
+			if route.use || route.mount {
 				continue
 			}
 			// Check if it matches the request path
-			match := route.match(c.getDetectionPath(), c.Path(), c.getValues())
-			// No match, next route
-			if match {
+			if route.match(c.getDetectionPath(), c.Path(), &values) {
 				// We matched
 				exists = true
+				optionsAllowed = optionsAllowed || methods[i] == MethodOptions
 				// Add method to Allow header
 				c.Append(HeaderAllow, methods[i])
 				// Break stack loop
@@ -186,6 +167,10 @@ func (app *App) methodExistCustom(c CustomCtx) bool {
 			}
 		}
 	}
+	// OPTIONS requests are answered automatically for every registered path
+	if exists && !optionsAllowed && app.config.EnableAutoOptions {
+		c.Append(HeaderAllow, MethodOptions)
+	}
 	return exists
 }
 
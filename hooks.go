@@ -13,6 +13,7 @@ type (
 	OnListenHandler    = func(ListenData) error
 	OnShutdownHandler  = func() error
 	OnForkHandler      = func(int) error
+	OnRestartHandler   = func(int) error
 	OnMountHandler     = func(*App) error
 )
 
@@ -29,6 +30,7 @@ type Hooks struct {
 	onListen    []OnListenHandler
 	onShutdown  []OnShutdownHandler
 	onFork      []OnForkHandler
+	onRestart   []OnRestartHandler
 	onMount     []OnMountHandler
 }
 
@@ -49,6 +51,7 @@ func newHooks(app *App) *Hooks {
 		onListen:    make([]OnListenHandler, 0),
 		onShutdown:  make([]OnShutdownHandler, 0),
 		onFork:      make([]OnForkHandler, 0),
+		onRestart:   make([]OnRestartHandler, 0),
 		onMount:     make([]OnMountHandler, 0),
 	}
 }
@@ -110,6 +113,15 @@ func (h *Hooks) OnFork(handler ...OnForkHandler) {
 	h.app.mutex.Unlock()
 }
 
+// OnRestart is a hook to execute user functions on a graceful restart, see ListenConfig.RestartSignal.
+// It is executed in the previous process with the pid of the new process once it is ready to serve,
+// before the open connections are drained.
+func (h *Hooks) OnRestart(handler ...OnRestartHandler) {
+	h.app.mutex.Lock()
+	h.onRestart = append(h.onRestart, handler...)
+	h.app.mutex.Unlock()
+}
+
 // OnMount is a hook to execute user function after mounting process.
 // The mount event is fired when sub-app is mounted on a parent app. The parent app is passed as a parameter.
 // It works for app and group mounting.
@@ -207,6 +219,14 @@ func (h *Hooks) executeOnForkHooks(pid int) {
 	}
 }
 
+func (h *Hooks) executeOnRestartHooks(pid int) {
+	for _, v := range h.onRestart {
+		if err := v(pid); err != nil {
+			log.Errorf("failed to call restart hook: %v", err)
+		}
+	}
+}
+
 func (h *Hooks) executeOnMountHooks(app *App) error {
 	for _, v := range h.onMount {
 		if err := v(app); err != nil {
@@ -0,0 +1,462 @@
+package velocity
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khulnasoft/velocity/utils"
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
+)
+
+// http2HandshakeTimeout limits the time to detect the protocol of a new connection
+const http2HandshakeTimeout = 10 * time.Second
+
+// http2Preface is the connection preface that starts every HTTP/2 connection
+var http2Preface = []byte(http2.ClientPreface)
+
+// serve serves the listener with fasthttp, connections that use HTTP/2 are served by an HTTP/2 server if it is enabled
+func (app *App) serve(ln net.Listener, cfg ListenConfig) error {
+	if !cfg.EnableHTTP2 {
+		return app.server.Serve(ln)
+	}
+
+	if tlsConfig := getTLSConfig(ln); tlsConfig != nil && !slices.Contains(tlsConfig.NextProtos, http2.NextProtoTLS) {
+		// Prefer HTTP/2 in the ALPN negotiation
+		if len(tlsConfig.NextProtos) == 0 {
+			tlsConfig.NextProtos = []string{"http/1.1"}
+		}
+		tlsConfig.NextProtos = append([]string{http2.NextProtoTLS}, tlsConfig.NextProtos...)
+	}
+
+	h2ln, err := app.newHTTP2Listener(ln)
+	if err != nil {
+		return err
+	}
+	return app.server.Serve(h2ln)
+}
+
+// http2Listener detects the protocol of accepted connections. HTTP/2 connections, negotiated via ALPN
+// or started with the preface (h2c with prior knowledge), are served by an HTTP/2 server,
+// the other connections are returned by Accept to the fasthttp server.
+type http2Listener struct {
+	net.Listener
+	err     error
+	conns   chan net.Conn
+	done    chan struct{}
+	h1      *http.Server
+	h2      *http2.Server
+	handler http.Handler
+	active  map[net.Conn]struct{} // The connections served by the HTTP/2 server
+	drained chan struct{}         // Closed when the last active connection is closed during the shutdown
+	once    sync.Once
+	stopped sync.Once
+	mu      sync.Mutex
+	closing bool
+}
+
+func (app *App) newHTTP2Listener(ln net.Listener) (*http2Listener, error) {
+	l := &http2Listener{
+		Listener: ln,
+		conns:    make(chan net.Conn),
+		done:     make(chan struct{}),
+		active:   make(map[net.Conn]struct{}),
+		h1: &http.Server{
+			ReadTimeout:  app.config.ReadTimeout,
+			WriteTimeout: app.config.WriteTimeout,
+			IdleTimeout:  app.config.IdleTimeout,
+		},
+		h2: &http2.Server{
+			IdleTimeout: app.config.IdleTimeout,
+		},
+		handler: app.http2Handler(),
+	}
+	// Registers the graceful shutdown of the HTTP/2 connections on the shutdown of the base server
+	if err := http2.ConfigureServer(l.h1, l.h2); err != nil {
+		return nil, fmt.Errorf("failed to configure HTTP/2: %w", err)
+	}
+
+	app.mutex.Lock()
+	app.http2Listeners = append(app.http2Listeners, l)
+	app.mutex.Unlock()
+
+	go l.acceptLoop()
+
+	return l, nil
+}
+
+// Accept returns the next connection that is not served by the HTTP/2 server
+func (l *http2Listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+// Close closes the listener and sends a GOAWAY frame to the HTTP/2 connections,
+// App.ShutdownWithContext waits for them to be closed
+func (l *http2Listener) Close() error {
+	err := net.ErrClosed
+	l.once.Do(func() {
+		l.stop(net.ErrClosed)
+		err = l.Listener.Close()
+		_ = l.h1.Shutdown(context.Background()) //nolint:errcheck // There are no listeners or connections tracked by the base server
+	})
+
+	return err
+}
+
+// stop makes Accept return the error
+func (l *http2Listener) stop(err error) {
+	l.stopped.Do(func() {
+		l.err = err
+		close(l.done)
+	})
+}
+
+// shutdown waits until the HTTP/2 connections are closed after the GOAWAY frame that Close sent,
+// the remaining connections are closed when the context is done
+func (l *http2Listener) shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	l.closing = true
+	if len(l.active) == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	if l.drained == nil {
+		l.drained = make(chan struct{})
+	}
+	drained := l.drained
+	l.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		for c := range l.active {
+			_ = c.Close() //nolint:errcheck // The connection is closed by force
+		}
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// track adds a connection that is served by the HTTP/2 server, it returns false during the shutdown
+func (l *http2Listener) track(c net.Conn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closing {
+		return false
+	}
+	l.active[c] = struct{}{}
+	return true
+}
+
+// untrack removes a connection that was closed by the HTTP/2 server
+func (l *http2Listener) untrack(c net.Conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.active, c)
+	if len(l.active) == 0 && l.drained != nil {
+		close(l.drained)
+		l.drained = nil
+	}
+}
+
+func (l *http2Listener) acceptLoop() {
+	// Temporary errors, e.g. when the file descriptors are exhausted, are retried with a backoff like net/http does
+	var delay time.Duration
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else {
+					delay *= 2
+				}
+				if delay > time.Second {
+					delay = time.Second
+				}
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+					continue
+				case <-l.done:
+					timer.Stop()
+					return
+				}
+			}
+			l.stop(err)
+			return
+		}
+		delay = 0
+
+		go l.dispatch(c)
+	}
+}
+
+// dispatch serves the connection with the HTTP/2 server or hands it over to fasthttp
+func (l *http2Listener) dispatch(c net.Conn) {
+	isHTTP2, conn, err := detectHTTP2(c)
+	if err != nil {
+		_ = c.Close() //nolint:errcheck // The connection is not usable
+		return
+	}
+
+	if isHTTP2 {
+		if !l.track(conn) {
+			_ = conn.Close() //nolint:errcheck // The server is shut down
+			return
+		}
+		defer l.untrack(conn)
+		l.h2.ServeConn(conn, &http2.ServeConnOpts{BaseConfig: l.h1, Handler: l.handler})
+		return
+	}
+
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		_ = conn.Close() //nolint:errcheck // The listener is closed
+	}
+}
+
+// detectHTTP2 completes the TLS handshake to check the negotiated protocol
+// or reads the start of a cleartext connection to check for the HTTP/2 preface
+func detectHTTP2(c net.Conn) (bool, net.Conn, error) {
+	if err := c.SetReadDeadline(time.Now().Add(http2HandshakeTimeout)); err != nil {
+		return false, nil, err
+	}
+	defer c.SetReadDeadline(time.Time{}) //nolint:errcheck // The deadline is reset by the servers as well
+
+	if tlsConn, ok := c.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			return false, nil, err
+		}
+		return tlsConn.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS, tlsConn, nil
+	}
+
+	// Read until the preface is complete or the bytes differ, HTTP/1.x requests differ in the first bytes
+	buf := make([]byte, len(http2Preface))
+	n := 0
+	for n < len(buf) && bytes.HasPrefix(http2Preface, buf[:n]) {
+		m, err := c.Read(buf[n:])
+		n += m
+		if err != nil {
+			if n == 0 || !errors.Is(err, io.EOF) {
+				return false, nil, err
+			}
+			break
+		}
+	}
+
+	conn := &peekedConn{Conn: c, peeked: buf[:n]}
+	return n == len(buf) && bytes.Equal(buf, http2Preface), conn, nil
+}
+
+// peekedConn returns the bytes that were read to detect the protocol before it reads from the connection
+type peekedConn struct {
+	net.Conn
+	peeked []byte
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	if len(c.peeked) > 0 {
+		n := copy(b, c.peeked)
+		c.peeked = c.peeked[n:]
+		return n, nil
+	}
+	return c.Conn.Read(b)
+}
+
+// errHTTP2Stream is returned when the connection of an HTTP/2 stream is used directly
+var errHTTP2Stream = errors.New("http2: the connection of a stream can't be used directly")
+
+// http2Conn provides the addresses of an HTTP/2 stream to the fasthttp.RequestCtx,
+// the connection itself is shared by all streams and owned by the HTTP/2 server
+type http2Conn struct {
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+func (*http2Conn) Read([]byte) (int, error) {
+	return 0, errHTTP2Stream
+}
+
+func (*http2Conn) Write([]byte) (int, error) {
+	return 0, errHTTP2Stream
+}
+
+func (*http2Conn) Close() error {
+	return nil
+}
+
+func (c *http2Conn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *http2Conn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (*http2Conn) SetDeadline(time.Time) error {
+	return nil
+}
+
+func (*http2Conn) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func (*http2Conn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+// http2TLSConn marks the fasthttp.RequestCtx of a stream that was received over TLS, see fasthttp.RequestCtx.IsTLS
+type http2TLSConn struct {
+	*http2Conn
+	state tls.ConnectionState
+}
+
+func (*http2TLSConn) Handshake() error {
+	return nil
+}
+
+func (c *http2TLSConn) ConnectionState() tls.ConnectionState {
+	return c.state
+}
+
+// Hop-by-hop headers that are not allowed in HTTP/2 responses
+var http2SkippedHeaders = map[string]bool{
+	HeaderConnection:       true,
+	HeaderKeepAlive:        true,
+	"Proxy-Connection":     true,
+	HeaderTransferEncoding: true,
+	HeaderUpgrade:          true,
+	HeaderTrailer:          true,
+	HeaderContentLength:    true,
+}
+
+// http2Handler converts the requests of the HTTP/2 server, so that they are handled like fasthttp requests.
+// The concurrent streams are limited by the Concurrency of the config like the connections of the fasthttp server,
+// the streams that exceed it are answered with 503 Service Unavailable.
+func (app *App) http2Handler() http.Handler {
+	var streams atomic.Int64
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if streams.Add(1) > int64(app.config.Concurrency) {
+			streams.Add(-1)
+			http.Error(w, utils.StatusMessage(StatusServiceUnavailable), StatusServiceUnavailable)
+			return
+		}
+		defer streams.Add(-1)
+
+		stream := &http2Conn{
+			localAddr:  addrOf(r.Context().Value(http.LocalAddrContextKey)),
+			remoteAddr: tcpAddr(r.RemoteAddr),
+		}
+		var conn net.Conn = stream
+		if r.TLS != nil {
+			conn = &http2TLSConn{http2Conn: stream, state: *r.TLS}
+		}
+
+		fctx := &fasthttp.RequestCtx{}
+		fctx.Init2(conn, app.server.Logger, false)
+		// The context of the request is canceled when the stream is reset by the client
+		fctx.SetUserValue(userContextKey, r.Context())
+
+		req := &fctx.Request
+		req.Header.SetMethod(r.Method)
+		req.SetRequestURI(r.RequestURI)
+		req.Header.SetProtocol(r.Proto)
+		req.Header.SetHost(r.Host)
+		for key, values := range r.Header {
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, int64(app.config.BodyLimit)+1))
+		if err != nil {
+			http.Error(w, utils.StatusMessage(StatusBadRequest), StatusBadRequest)
+			return
+		}
+		if len(body) > app.config.BodyLimit {
+			http.Error(w, utils.StatusMessage(StatusRequestEntityTooLarge), StatusRequestEntityTooLarge)
+			return
+		}
+		req.SetBodyRaw(body)
+
+		app.server.Handler(fctx)
+
+		resp := &fctx.Response
+		header := w.Header()
+		resp.Header.VisitAll(func(key, value []byte) {
+			if k := string(key); !http2SkippedHeaders[k] {
+				header.Add(k, string(value))
+			}
+		})
+		if app.config.ServerHeader != "" && header.Get(HeaderServer) == "" {
+			header.Set(HeaderServer, app.config.ServerHeader)
+		}
+		if !resp.IsBodyStream() {
+			header.Set(HeaderContentLength, strconv.Itoa(len(resp.Body())))
+		}
+		w.WriteHeader(resp.StatusCode())
+
+		if r.Method == MethodHead {
+			_ = resp.CloseBodyStream() //nolint:errcheck // The body is not sent
+			return
+		}
+		if resp.IsBodyStream() {
+			// Streams are flushed on every write, e.g. for Server-Sent Events
+			_ = resp.BodyWriteTo(&flushWriter{w: w}) //nolint:errcheck // The client is gone
+			return
+		}
+		_, _ = w.Write(resp.Body()) //nolint:errcheck // The client is gone
+	})
+}
+
+// flushWriter flushes every write to the client
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if f, ok := fw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
+
+// addrOf returns the address or an empty TCP address
+func addrOf(v any) net.Addr {
+	if addr, ok := v.(net.Addr); ok {
+		return addr
+	}
+	return &net.TCPAddr{}
+}
+
+// tcpAddr parses the remote address of a request
+func tcpAddr(addr string) net.Addr {
+	if tcp, err := net.ResolveTCPAddr("tcp", addr); err == nil {
+		return tcp
+	}
+	return &net.TCPAddr{}
+}
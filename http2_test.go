@@ -0,0 +1,284 @@
+package velocity
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity/internal/tlstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+func newHTTP2TestApp() *App {
+	app := New()
+	app.Get("/", func(c Ctx) error {
+		c.Cookie(&Cookie{Name: "session", Value: "abc"})
+		c.Set("X-Custom", c.Get("X-Custom"))
+		return c.SendString(c.Protocol() + " " + c.Scheme() + " " + c.IP())
+	})
+	app.Post("/echo", func(c Ctx) error {
+		return c.Send(c.Body())
+	})
+	app.Get("/sse", func(c Ctx) error {
+		return c.SSE(func(stream *SSEStream) error {
+			return stream.Send(SSEEvent{Data: "hello"})
+		})
+	})
+
+	return app
+}
+
+// startHTTP2TestApp serves the app with HTTP/2 enabled and returns the address
+func startHTTP2TestApp(t *testing.T, app *App, ln net.Listener) string {
+	t.Helper()
+	go func() {
+		assert.NoError(t, app.Listener(ln, ListenConfig{DisableStartupMessage: true, EnableHTTP2: true}))
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, app.Shutdown())
+	})
+
+	return ln.Addr().String()
+}
+
+// newH2CClient returns a client that uses cleartext HTTP/2 with prior knowledge
+func newH2CClient() *http.Client {
+	return &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+}
+
+// go test -run Test_Listen_HTTP2_TLS
+func Test_Listen_HTTP2_TLS(t *testing.T) {
+	t.Parallel()
+
+	serverTLSConf, clientTLSConf, err := tlstest.GetTLSConfigs()
+	require.NoError(t, err)
+	ln, err := tls.Listen(NetworkTCP4, "127.0.0.1:0", serverTLSConf)
+	require.NoError(t, err)
+	addr := startHTTP2TestApp(t, newHTTP2TestApp(), ln)
+
+	// HTTP/2 negotiated via ALPN
+	client := &http.Client{Transport: &http2.Transport{TLSClientConfig: clientTLSConf}}
+	req, err := http.NewRequestWithContext(context.Background(), MethodGet, "https://"+addr+"/", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Custom", "value")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close() //nolint:errcheck // test
+
+	require.Equal(t, 2, resp.ProtoMajor)
+	require.Equal(t, StatusOK, resp.StatusCode)
+	require.Equal(t, "value", resp.Header.Get("X-Custom"))
+	require.Equal(t, "session=abc; path=/; SameSite=Lax", resp.Header.Get(HeaderSetCookie))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/2.0 https 127.0.0.1", string(body))
+	require.Equal(t, []string{"h2", "http/1.1"}, serverTLSConf.NextProtos)
+
+	// HTTP/1.1 is still served
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLSConf}}
+	resp, err = client.Get("https://" + addr + "/") //nolint:noctx // test
+	require.NoError(t, err)
+	defer resp.Body.Close() //nolint:errcheck // test
+
+	require.Equal(t, 1, resp.ProtoMajor)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/1.1 https 127.0.0.1", string(body))
+}
+
+// go test -run Test_Listen_HTTP2_H2C
+func Test_Listen_HTTP2_H2C(t *testing.T) {
+	t.Parallel()
+
+	app := newHTTP2TestApp()
+	app.config.BodyLimit = 16
+	ln, err := net.Listen(NetworkTCP4, "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := startHTTP2TestApp(t, app, ln)
+
+	// HTTP/2 with prior knowledge
+	client := newH2CClient()
+
+	resp, err := client.Get("http://" + addr + "/") //nolint:noctx // test
+	require.NoError(t, err)
+	defer resp.Body.Close() //nolint:errcheck // test
+	require.Equal(t, 2, resp.ProtoMajor)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/2.0 http 127.0.0.1", string(body))
+
+	resp, err = client.Post("http://"+addr+"/echo", MIMETextPlain, strings.NewReader("ping")) //nolint:noctx // test
+	require.NoError(t, err)
+	defer resp.Body.Close() //nolint:errcheck // test
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(body))
+	require.Equal(t, "4", resp.Header.Get(HeaderContentLength))
+
+	resp, err = client.Post("http://"+addr+"/echo", MIMETextPlain, strings.NewReader(strings.Repeat("a", 17))) //nolint:noctx // test
+	require.NoError(t, err)
+	defer resp.Body.Close() //nolint:errcheck // test
+	require.Equal(t, StatusRequestEntityTooLarge, resp.StatusCode)
+
+	// streamed responses are flushed
+	resp, err = client.Get("http://" + addr + "/sse") //nolint:noctx // test
+	require.NoError(t, err)
+	defer resp.Body.Close() //nolint:errcheck // test
+	require.Equal(t, MIMETextEventStream, resp.Header.Get(HeaderContentType))
+	events, err := ReadSSEEvents(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, []SSEEvent{{Data: "hello"}}, events)
+
+	// HTTP/1.1 is still served, also requests that are shorter than the preface
+	conn, err := net.Dial(NetworkTCP4, addr)
+	require.NoError(t, err)
+	defer conn.Close() //nolint:errcheck // test
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+	require.NoError(t, err)
+	h1resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	defer h1resp.Body.Close() //nolint:errcheck // test
+	body, err = io.ReadAll(h1resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/1.0 http 127.0.0.1", string(body))
+}
+
+// go test -run Test_Listen_HTTP2_Shutdown
+func Test_Listen_HTTP2_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	app := New()
+	started, release := make(chan struct{}), make(chan struct{})
+	app.Get("/", func(c Ctx) error {
+		close(started)
+		<-release
+		return c.SendString("done")
+	})
+	ln, err := net.Listen(NetworkTCP4, "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := startHTTP2TestApp(t, app, ln)
+
+	type result struct {
+		err  error
+		body string
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := newH2CClient().Get("http://" + addr + "/") //nolint:noctx // test
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close() //nolint:errcheck // test
+		body, err := io.ReadAll(resp.Body)
+		results <- result{err: err, body: string(body)}
+	}()
+	<-started
+
+	// The shutdown waits for the running stream
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- app.ShutdownWithTimeout(5 * time.Second)
+	}()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("shutdown returned before the stream ended: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	res := <-results
+	require.NoError(t, res.err)
+	require.Equal(t, "done", res.body)
+	require.NoError(t, <-shutdown)
+}
+
+// go test -run Test_Listen_HTTP2_ShutdownTimeout
+func Test_Listen_HTTP2_ShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	app := New()
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	app.Get("/", func(c Ctx) error {
+		close(started)
+		<-release
+		return c.SendString("done")
+	})
+	ln, err := net.Listen(NetworkTCP4, "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := startHTTP2TestApp(t, app, ln)
+
+	failed := make(chan error, 1)
+	go func() {
+		resp, err := newH2CClient().Get("http://" + addr + "/") //nolint:noctx // test
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			_ = resp.Body.Close() //nolint:errcheck // test
+		}
+		failed <- err
+	}()
+	<-started
+
+	// The connection is closed by force when the deadline is exceeded
+	require.ErrorIs(t, app.ShutdownWithTimeout(100*time.Millisecond), context.DeadlineExceeded)
+	require.Error(t, <-failed)
+}
+
+// go test -run Test_Listen_HTTP2_Concurrency
+func Test_Listen_HTTP2_Concurrency(t *testing.T) {
+	t.Parallel()
+
+	app := New(Config{Concurrency: 1})
+	started, release := make(chan struct{}), make(chan struct{})
+	app.Get("/", func(c Ctx) error {
+		close(started)
+		<-release
+		return c.SendString("done")
+	})
+	app.Get("/other", func(c Ctx) error {
+		return c.SendString("other")
+	})
+	ln, err := net.Listen(NetworkTCP4, "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := startHTTP2TestApp(t, app, ln)
+	client := newH2CClient()
+
+	done := make(chan error, 1)
+	go func() {
+		resp, err := client.Get("http://" + addr + "/") //nolint:noctx // test
+		if err == nil {
+			err = resp.Body.Close()
+		}
+		done <- err
+	}()
+	<-started
+
+	// The stream exceeds the limit
+	resp, err := client.Get("http://" + addr + "/other") //nolint:noctx // test
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, StatusServiceUnavailable, resp.StatusCode)
+
+	close(release)
+	require.NoError(t, <-done)
+	resp, err = client.Get("http://" + addr + "/other") //nolint:noctx // test
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, StatusOK, resp.StatusCode)
+}
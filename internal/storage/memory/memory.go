@@ -3,12 +3,18 @@
 package memory
 
 import (
+	"bytes"
+	"errors"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/khulnasoft/velocity/utils"
 )
 
+// ErrNotInteger is returned by Incr if the value of the key is not an integer
+var ErrNotInteger = errors.New("memory: value is not an integer")
+
 // Storage interface that is implemented by storage providers
 type Storage struct {
 	db         map[string]entry
@@ -76,6 +82,87 @@ func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
 	return nil
 }
 
+// Incr increments the integer value of key by delta, a new key gets the expiration
+func (s *Storage) Incr(key string, delta int64, exp time.Duration) (int64, error) {
+	if len(key) == 0 {
+		return 0, nil
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	e, ok := s.lookup(key)
+	if !ok {
+		s.db[utils.CopyString(key)] = entry{data: strconv.AppendInt(nil, delta, 10), expiry: expiry(exp)}
+		return delta, nil
+	}
+
+	n, err := strconv.ParseInt(utils.UnsafeString(e.data), 10, 64)
+	if err != nil {
+		return 0, ErrNotInteger
+	}
+	n += delta
+	s.db[utils.CopyString(key)] = entry{data: strconv.AppendInt(nil, n, 10), expiry: e.expiry}
+	return n, nil
+}
+
+// CompareAndSwap sets the value of key to newVal if the value equals oldVal, a nil oldVal matches a missing key
+func (s *Storage) CompareAndSwap(key string, oldVal, newVal []byte, exp time.Duration) (bool, error) {
+	if len(key) == 0 {
+		return false, nil
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	e, ok := s.lookup(key)
+	if oldVal == nil && ok || oldVal != nil && (!ok || !bytes.Equal(e.data, oldVal)) {
+		return false, nil
+	}
+
+	// An empty value deletes the key
+	if len(newVal) == 0 {
+		delete(s.db, key)
+		return true, nil
+	}
+	s.db[utils.CopyString(key)] = entry{data: newVal, expiry: expiry(exp)}
+	return true, nil
+}
+
+// SetIfAbsent stores the value if key does not exist
+func (s *Storage) SetIfAbsent(key string, val []byte, exp time.Duration) (bool, error) {
+	// Ain't Nobody Got Time For That
+	if len(key) == 0 || len(val) == 0 {
+		return false, nil
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.lookup(key); ok {
+		return false, nil
+	}
+	s.db[utils.CopyString(key)] = entry{data: val, expiry: expiry(exp)}
+	return true, nil
+}
+
+// lookup returns the entry of key if it exists and is not expired, s.mux has to be held
+func (s *Storage) lookup(key string) (entry, bool) {
+	e, ok := s.db[key]
+	if !ok || e.expiry != 0 && e.expiry <= utils.Timestamp() {
+		return entry{}, false
+	}
+	return e, true
+}
+
+// expiry returns the timestamp when an entry with the expiration expires
+func expiry(exp time.Duration) uint32 {
+	if exp == 0 {
+		return 0
+	}
+	return uint32(exp.Seconds()) + utils.Timestamp()
+}
+
 // Delete key by key
 func (s *Storage) Delete(key string) error {
 	// Ain't Nobody Got Time For That
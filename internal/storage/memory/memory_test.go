@@ -1,12 +1,25 @@
 package memory
 
 import (
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/storage/storagetest"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// go test -run Test_Storage_Memory_Conformance
+func Test_Storage_Memory_Conformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestStorage(t, func() velocity.Storage {
+		return New()
+	})
+}
+
 func Test_Storage_Memory_Set(t *testing.T) {
 	t.Parallel()
 	var (
@@ -202,6 +215,136 @@ func Test_Storage_Memory_Conn(t *testing.T) {
 	require.NotNil(t, testStore.Conn())
 }
 
+// The memory storage is the reference implementation of the atomic operations
+var _ velocity.AtomicStorage = (*Storage)(nil)
+
+func Test_Storage_Memory_Incr(t *testing.T) {
+	t.Parallel()
+	testStore := New()
+
+	n, err := testStore.Incr("hits", 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	n, err = testStore.Incr("hits", 5, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(6), n)
+
+	n, err = testStore.Incr("hits", -7, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), n)
+
+	val, err := testStore.Get("hits")
+	require.NoError(t, err)
+	require.Equal(t, []byte("-1"), val)
+
+	require.NoError(t, testStore.Set("name", []byte("john"), 0))
+	_, err = testStore.Incr("name", 1, 0)
+	require.ErrorIs(t, err, ErrNotInteger)
+}
+
+func Test_Storage_Memory_Incr_Expiration(t *testing.T) {
+	t.Parallel()
+	testStore := New()
+
+	_, err := testStore.Incr("hits", 1, 2*time.Second)
+	require.NoError(t, err)
+
+	// the expiration of an existing key is kept
+	time.Sleep(500 * time.Millisecond)
+	n, err := testStore.Incr("hits", 1, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	// expire + timestamp update interval
+	time.Sleep(2500 * time.Millisecond)
+	n, err = testStore.Incr("hits", 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+}
+
+func Test_Storage_Memory_Incr_Concurrent(t *testing.T) {
+	t.Parallel()
+	testStore := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := testStore.Incr("hits", 1, 0)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	val, err := testStore.Get("hits")
+	require.NoError(t, err)
+	require.Equal(t, []byte("100"), val)
+}
+
+func Test_Storage_Memory_CompareAndSwap(t *testing.T) {
+	t.Parallel()
+	testStore := New()
+
+	// nil matches a missing key
+	swapped, err := testStore.CompareAndSwap("john", nil, []byte("doe"), 0)
+	require.NoError(t, err)
+	require.True(t, swapped)
+
+	swapped, err = testStore.CompareAndSwap("john", nil, []byte("smith"), 0)
+	require.NoError(t, err)
+	require.False(t, swapped)
+
+	swapped, err = testStore.CompareAndSwap("john", []byte("smith"), []byte("wick"), 0)
+	require.NoError(t, err)
+	require.False(t, swapped)
+
+	swapped, err = testStore.CompareAndSwap("john", []byte("doe"), []byte("wick"), 0)
+	require.NoError(t, err)
+	require.True(t, swapped)
+
+	val, err := testStore.Get("john")
+	require.NoError(t, err)
+	require.Equal(t, []byte("wick"), val)
+
+	// an empty value deletes the key
+	swapped, err = testStore.CompareAndSwap("john", []byte("wick"), nil, 0)
+	require.NoError(t, err)
+	require.True(t, swapped)
+
+	val, err = testStore.Get("john")
+	require.NoError(t, err)
+	require.Nil(t, val)
+
+	swapped, err = testStore.CompareAndSwap("john", []byte("wick"), nil, 0)
+	require.NoError(t, err)
+	require.False(t, swapped)
+}
+
+func Test_Storage_Memory_SetIfAbsent(t *testing.T) {
+	t.Parallel()
+	testStore := New()
+
+	set, err := testStore.SetIfAbsent("john", []byte("doe"), 1*time.Second)
+	require.NoError(t, err)
+	require.True(t, set)
+
+	set, err = testStore.SetIfAbsent("john", []byte("wick"), 0)
+	require.NoError(t, err)
+	require.False(t, set)
+
+	val, err := testStore.Get("john")
+	require.NoError(t, err)
+	require.Equal(t, []byte("doe"), val)
+
+	// an expired key is absent, expire + timestamp update interval
+	time.Sleep(2 * time.Second)
+	set, err = testStore.SetIfAbsent("john", []byte("wick"), 0)
+	require.NoError(t, err)
+	require.True(t, set)
+}
+
 // Benchmarks for Set operation
 func Benchmark_Memory_Set(b *testing.B) {
 	testStore := New()
@@ -362,3 +505,9 @@ func Benchmark_Memory_SetAndDelete_Asserted_Parallel(b *testing.B) {
 		}
 	})
 }
+
+func Benchmark_Memory_Conformance(b *testing.B) {
+	storagetest.BenchmarkStorage(b, func() velocity.Storage {
+		return New()
+	})
+}
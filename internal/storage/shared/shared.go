@@ -0,0 +1,78 @@
+// Package shared updates the state of the middlewares in a storage that several instances
+// of an app may share.
+//
+// A read-modify-write with Get and Set loses the updates of the other instances that write
+// the same key in the meantime. If the storage implements velocity.AtomicStorage, the state
+// is updated with its atomic operations instead, which are consistent across the instances.
+// Otherwise the updates are only serialized within the instance.
+package shared
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// Atomic returns the atomic operations of the storage, nil if it doesn't support them
+func Atomic(storage velocity.Storage) velocity.AtomicStorage {
+	atomic, _ := storage.(velocity.AtomicStorage) //nolint:errcheck // nil if not supported
+	return atomic
+}
+
+// Updater updates the values of a storage with a read-modify-write
+type Updater struct {
+	storage velocity.Storage
+	atomic  velocity.AtomicStorage
+	mux     sync.Mutex
+}
+
+// NewUpdater returns an Updater for the storage
+func NewUpdater(storage velocity.Storage) *Updater {
+	return &Updater{storage: storage, atomic: Atomic(storage)}
+}
+
+// Update calls fn with the value of the key, which is nil if the key does not exist, and
+// stores the value that it returns with the expiration, an empty value deletes the key.
+// fn is called again if the value was changed by another instance in the meantime,
+// an error of fn is returned as is.
+func (u *Updater) Update(key string, fn func(old []byte) ([]byte, time.Duration, error)) error {
+	if u.atomic == nil {
+		u.mux.Lock()
+		defer u.mux.Unlock()
+	}
+
+	for {
+		old, err := u.storage.Get(key)
+		if err != nil {
+			return fmt.Errorf("failed to get value: %w", err)
+		}
+		val, exp, err := fn(old)
+		if err != nil {
+			return err
+		}
+		if old == nil && len(val) == 0 {
+			return nil
+		}
+
+		if u.atomic == nil {
+			if len(val) == 0 {
+				err = u.storage.Delete(key)
+			} else {
+				err = u.storage.Set(key, val, exp)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to set value: %w", err)
+			}
+			return nil
+		}
+		swapped, err := u.atomic.CompareAndSwap(key, old, val, exp)
+		if err != nil {
+			return fmt.Errorf("failed to set value: %w", err)
+		}
+		if swapped {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package shared
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
+	"github.com/khulnasoft/velocity/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+// incr increments the integer value of the key
+func incr(u *Updater, key string) error {
+	return u.Update(key, func(old []byte) ([]byte, time.Duration, error) {
+		n, _ := strconv.Atoi(string(old)) //nolint:errcheck // 0 if not set
+		return []byte(strconv.Itoa(n + 1)), time.Minute, nil
+	})
+}
+
+// go test -run Test_Updater_Concurrent
+func Test_Updater_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	// The instances that share an atomic storage don't lose updates,
+	// a plain storage is only consistent within an instance
+	atomicStorage := memory.New()
+	plain := storagetest.NonAtomic(memory.New())
+	tests := map[string]struct {
+		storage  velocity.Storage
+		updaters []*Updater
+	}{
+		"atomic": {atomicStorage, []*Updater{NewUpdater(atomicStorage), NewUpdater(atomicStorage)}},
+		"plain":  {plain, []*Updater{NewUpdater(plain)}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var wg sync.WaitGroup
+			for _, u := range tt.updaters {
+				for i := 0; i < 10; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for j := 0; j < 10; j++ {
+							require.NoError(t, incr(u, "count"))
+						}
+					}()
+				}
+			}
+			wg.Wait()
+
+			raw, err := tt.storage.Get("count")
+			require.NoError(t, err)
+			require.Equal(t, strconv.Itoa(len(tt.updaters)*100), string(raw))
+		})
+	}
+}
+
+// go test -run Test_Updater_Delete
+func Test_Updater_Delete(t *testing.T) {
+	t.Parallel()
+
+	for _, storage := range []velocity.Storage{memory.New(), storagetest.NonAtomic(memory.New())} {
+		u := NewUpdater(storage)
+		require.NoError(t, incr(u, "john"))
+
+		// An empty value deletes the key
+		require.NoError(t, u.Update("john", func(old []byte) ([]byte, time.Duration, error) {
+			require.Equal(t, "1", string(old))
+			return nil, 0, nil
+		}))
+		raw, err := storage.Get("john")
+		require.NoError(t, err)
+		require.Nil(t, raw)
+	}
+}
+
+// go test -run Test_Updater_Error
+func Test_Updater_Error(t *testing.T) {
+	t.Parallel()
+
+	errDecode := errors.New("failed to decode")
+	storage := memory.New()
+	u := NewUpdater(storage)
+	require.NoError(t, incr(u, "john"))
+
+	// The value is kept if fn fails
+	err := u.Update("john", func([]byte) ([]byte, time.Duration, error) {
+		return nil, 0, errDecode
+	})
+	require.ErrorIs(t, err, errDecode)
+	raw, err := storage.Get("john")
+	require.NoError(t, err)
+	require.Equal(t, "1", string(raw))
+}
@@ -0,0 +1,226 @@
+// Package tracecontext implements the W3C Trace Context headers, it is shared by the tracing middleware
+// and the client, so that outbound requests continue the trace of the server span in their context
+package tracecontext
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// Header names of the W3C Trace Context
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+const (
+	version         = "00"
+	traceparentLen  = 55
+	maxStateMembers = 32
+	maxStateLen     = 512
+
+	// FlagSampled is the trace flag that marks a trace as sampled
+	FlagSampled byte = 0x01
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// IsValid reports whether the id is not all zeros
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// String returns the lowercase hex encoding of the id
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// IsValid reports whether the id is not all zeros
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// String returns the lowercase hex encoding of the id
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext is the part of a span that is propagated to other services
+type SpanContext struct {
+	TraceState string
+	TraceID    TraceID
+	SpanID     SpanID
+	TraceFlags byte
+	// Remote is true if the span context was received from another service
+	Remote bool
+}
+
+// IsValid reports whether the trace and span ids are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled reports whether the sampled flag is set
+func (sc SpanContext) IsSampled() bool {
+	return sc.TraceFlags&FlagSampled != 0
+}
+
+// Traceparent formats the span context as traceparent header value
+func (sc SpanContext) Traceparent() string {
+	var b [traceparentLen]byte
+	copy(b[:], version)
+	b[2] = '-'
+	hex.Encode(b[3:35], sc.TraceID[:])
+	b[35] = '-'
+	hex.Encode(b[36:52], sc.SpanID[:])
+	b[52] = '-'
+	hex.Encode(b[53:55], []byte{sc.TraceFlags})
+	return string(b[:])
+}
+
+// Parse parses the traceparent and tracestate header values. The tracestate is dropped if it is malformed,
+// ok is false if the traceparent is missing or malformed.
+func Parse(traceparent, tracestate string) (SpanContext, bool) {
+	var sc SpanContext
+
+	// Future versions may append fields, the known ones have to be parsed the same way
+	if len(traceparent) < traceparentLen || (len(traceparent) > traceparentLen && traceparent[traceparentLen] != '-') {
+		return sc, false
+	}
+	v := traceparent[:2]
+	if !isLowerHex(v) || v == "ff" || (v == version && len(traceparent) != traceparentLen) {
+		return sc, false
+	}
+	if traceparent[2] != '-' || traceparent[35] != '-' || traceparent[52] != '-' {
+		return sc, false
+	}
+
+	if !decode(sc.TraceID[:], traceparent[3:35]) || !decode(sc.SpanID[:], traceparent[36:52]) {
+		return sc, false
+	}
+	var flags [1]byte
+	if !decode(flags[:], traceparent[53:55]) {
+		return sc, false
+	}
+	sc.TraceFlags = flags[0]
+	if !sc.IsValid() {
+		return sc, false
+	}
+
+	sc.TraceState = parseTracestate(tracestate)
+	sc.Remote = true
+	return sc, true
+}
+
+// parseTracestate returns the tracestate without empty members, or an empty string if it is malformed
+func parseTracestate(tracestate string) string {
+	if tracestate == "" || len(tracestate) > maxStateLen {
+		return ""
+	}
+
+	members := make([]string, 0, strings.Count(tracestate, ",")+1)
+	for _, member := range strings.Split(tracestate, ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+		key, value, found := strings.Cut(member, "=")
+		if !found || key == "" || value == "" || strings.ContainsAny(key, " \t") {
+			return ""
+		}
+		members = append(members, member)
+	}
+	if len(members) > maxStateMembers {
+		return ""
+	}
+	return strings.Join(members, ",")
+}
+
+// NewTraceID returns a random trace id
+func NewTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:]) //nolint:errcheck // never returns an error
+	}
+	return id
+}
+
+// NewSpanID returns a random span id
+func NewSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:]) //nolint:errcheck // never returns an error
+	}
+	return id
+}
+
+// Carrier is a value in a context that carries a span context, e.g. the span of the tracing middleware
+type Carrier interface {
+	SpanContext() SpanContext
+}
+
+// The contextKey type is unexported to prevent collisions with context keys defined in
+// other packages.
+type contextKey struct{}
+
+// ContextWith returns a copy of the context that carries the span context
+func ContextWith(ctx context.Context, carrier Carrier) context.Context {
+	return context.WithValue(ctx, contextKey{}, carrier)
+}
+
+// CarrierFromContext returns the carrier of the context, or nil
+func CarrierFromContext(ctx context.Context) Carrier {
+	if ctx == nil {
+		return nil
+	}
+	carrier, _ := ctx.Value(contextKey{}).(Carrier) //nolint:errcheck // nil if not set
+	return carrier
+}
+
+// FromContext returns the span context of the context
+func FromContext(ctx context.Context) (SpanContext, bool) {
+	carrier := CarrierFromContext(ctx)
+	if carrier == nil {
+		return SpanContext{}, false
+	}
+	sc := carrier.SpanContext()
+	return sc, sc.IsValid()
+}
+
+// Inject sets the traceparent and tracestate headers of the span context in the context.
+// Nothing is set if the context carries no span context.
+func Inject(ctx context.Context, set func(key, value string)) {
+	sc, ok := FromContext(ctx)
+	if !ok {
+		return
+	}
+	set(HeaderTraceparent, sc.Traceparent())
+	if sc.TraceState != "" {
+		set(HeaderTracestate, sc.TraceState)
+	}
+}
+
+func decode(dst []byte, s string) bool {
+	if !isLowerHex(s) {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// isLowerHex reports whether s only contains lowercase hex digits, uppercase is invalid in the traceparent
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
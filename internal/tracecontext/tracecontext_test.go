@@ -0,0 +1,96 @@
+package tracecontext
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// go test -run Test_Parse
+func Test_Parse(t *testing.T) {
+	t.Parallel()
+
+	sc, ok := Parse("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "rojo=00f067aa0ba902b7")
+	require.True(t, ok)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	require.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	require.True(t, sc.IsSampled())
+	require.True(t, sc.Remote)
+	require.Equal(t, "rojo=00f067aa0ba902b7", sc.TraceState)
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+
+	// future versions can append fields
+	sc, ok = Parse("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra", "")
+	require.True(t, ok)
+	require.False(t, sc.IsSampled())
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", sc.Traceparent())
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"0g-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0x",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.extra",
+	}
+	for _, traceparent := range invalid {
+		_, ok := Parse(traceparent, "")
+		require.False(t, ok, traceparent)
+	}
+}
+
+// go test -run Test_parseTracestate
+func Test_parseTracestate(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "a=1,b=2", parseTracestate(" a=1 ,, b=2"))
+	require.Empty(t, parseTracestate("a=1,b"))
+	require.Empty(t, parseTracestate("a b=1"))
+	require.Empty(t, parseTracestate(strings.Repeat("a=1,", 33)))
+	require.Empty(t, parseTracestate("a="+strings.Repeat("1", maxStateLen)))
+}
+
+// go test -run Test_NewID
+func Test_NewID(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, NewTraceID().IsValid())
+	require.True(t, NewSpanID().IsValid())
+	require.NotEqual(t, NewTraceID(), NewTraceID())
+	require.NotEqual(t, NewSpanID(), NewSpanID())
+}
+
+type carrier SpanContext
+
+func (c carrier) SpanContext() SpanContext {
+	return SpanContext(c)
+}
+
+// go test -run Test_Inject
+func Test_Inject(t *testing.T) {
+	t.Parallel()
+
+	headers := make(map[string]string)
+	set := func(key, value string) {
+		headers[key] = value
+	}
+
+	Inject(context.Background(), set)
+	require.Empty(t, headers)
+
+	sc, ok := Parse("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "")
+	require.True(t, ok)
+	Inject(ContextWith(context.Background(), carrier(sc)), set)
+	require.Equal(t, map[string]string{HeaderTraceparent: sc.Traceparent()}, headers)
+
+	sc.TraceState = "rojo=1"
+	Inject(ContextWith(context.Background(), carrier(sc)), set)
+	require.Equal(t, "rojo=1", headers[HeaderTracestate])
+}
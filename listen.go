@@ -35,7 +35,8 @@ var figletVelocityText = `
 /_/   /_/_.___/\___/_/          %s`
 
 const (
-	globalIpv4Addr = "0.0.0.0"
+	globalIpv4Addr            = "0.0.0.0"
+	defaultUnixSocketFileMode = 0o770
 )
 
 // ListenConfig is a struct to customize startup of Velocity.
@@ -77,8 +78,9 @@ type ListenConfig struct {
 	// Default: nil
 	AutoCertManager *autocert.Manager `json:"auto_cert_manager"`
 
-	// Known networks are "tcp", "tcp4" (IPv4-only), "tcp6" (IPv6-only)
-	// WARNING: When prefork is set to true, only "tcp4" and "tcp6" can be chosen.
+	// Known networks are "tcp", "tcp4" (IPv4-only), "tcp6" (IPv6-only) and "unix".
+	// For "unix" the address is the path of the socket, paths that start with "@" are abstract sockets (Linux only).
+	// WARNING: When prefork is set to true, only "tcp4", "tcp6" and "unix" can be chosen.
 	//
 	// Default: NetworkTCP4
 	ListenerNetwork string `json:"listener_network"`
@@ -108,6 +110,11 @@ type ListenConfig struct {
 	// Default: 10 * time.Second
 	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
 
+	// UnixSocketFileMode is the file mode of the socket when ListenerNetwork is "unix".
+	//
+	// Default: 0o770
+	UnixSocketFileMode os.FileMode `json:"unix_socket_file_mode"`
+
 	// TLSMinVersion allows to set TLS minimum version.
 	//
 	// Default: tls.VersionTLS12
@@ -128,14 +135,39 @@ type ListenConfig struct {
 	//
 	// Default: false
 	EnablePrintRoutes bool `json:"enable_print_routes"`
+
+	// When set to true, HTTP/2 is served in addition to HTTP/1.1. With TLS it is negotiated
+	// via ALPN, without TLS clients can use cleartext HTTP/2 (h2c) with prior knowledge.
+	// The requests are handled by the same handlers, hijacking the connection is not supported for HTTP/2.
+	//
+	// Default: false
+	EnableHTTP2 bool `json:"enable_http2"`
+
+	// When set to true, the listener is inherited from the service manager via socket activation
+	// (LISTEN_FDS and LISTEN_PID, see sd_listen_fds(3)) instead of being created, the address is ignored then.
+	// The first passed socket is used. Without activation the app listens on the address as usual.
+	// With prefork, the children share the inherited socket.
+	//
+	// Default: false
+	EnableSocketActivation bool `json:"enable_socket_activation"`
+
+	// RestartSignal enables graceful restarts, e.g. for binary upgrades, with the given signal like syscall.SIGUSR2.
+	// On the signal, the listener is passed to a new process of the executable. Once the new process
+	// is ready to serve, the OnRestart hooks are executed and the current process shuts down
+	// with ShutdownTimeout after the open connections are drained, then Listen returns.
+	// It is not supported with prefork, custom listeners and on Windows.
+	//
+	// Default: nil
+	RestartSignal os.Signal `json:"restart_signal"`
 }
 
 // listenConfigDefault is a function to set default values of ListenConfig.
 func listenConfigDefault(config ...ListenConfig) ListenConfig {
 	if len(config) < 1 {
 		return ListenConfig{
-			TLSMinVersion:   tls.VersionTLS12,
-			ListenerNetwork: NetworkTCP4,
+			TLSMinVersion:      tls.VersionTLS12,
+			ListenerNetwork:    NetworkTCP4,
+			UnixSocketFileMode: defaultUnixSocketFileMode,
 			OnShutdownError: func(err error) {
 				log.Fatalf("shutdown: %v", err) //nolint:revive // It's an option
 			},
@@ -148,6 +180,10 @@ func listenConfigDefault(config ...ListenConfig) ListenConfig {
 		cfg.ListenerNetwork = NetworkTCP4
 	}
 
+	if cfg.UnixSocketFileMode == 0 {
+		cfg.UnixSocketFileMode = defaultUnixSocketFileMode
+	}
+
 	if cfg.OnShutdownError == nil {
 		cfg.OnShutdownError = func(err error) {
 			log.Fatalf("shutdown: %v", err) //nolint:revive // It's an option
@@ -228,6 +264,9 @@ func (app *App) Listen(addr string, config ...ListenConfig) error {
 
 	// Start prefork
 	if cfg.EnablePrefork {
+		if cfg.RestartSignal != nil {
+			log.Warn("Graceful restart isn't supported with prefork.")
+		}
 		return app.prefork(addr, tlsConfig, cfg)
 	}
 
@@ -237,6 +276,11 @@ func (app *App) Listen(addr string, config ...ListenConfig) error {
 		return fmt.Errorf("failed to listen: %w", err)
 	}
 
+	// Unix sockets, e.g. inherited via socket activation, are shown with their path
+	if ln.Addr().Network() == NetworkUnix {
+		cfg.ListenerNetwork = NetworkUnix
+	}
+
 	// prepare the server for the start
 	app.startupProcess()
 
@@ -253,7 +297,17 @@ func (app *App) Listen(addr string, config ...ListenConfig) error {
 		}
 	}
 
-	return app.server.Serve(ln)
+	// Hand over from the previous process after a graceful restart
+	if err := notifyRestartReady(); err != nil {
+		return err
+	}
+	if cfg.RestartSignal != nil {
+		wait := app.watchRestartSignal(ln, cfg)
+		// Returns after the connections are drained
+		defer wait()
+	}
+
+	return app.serve(ln, cfg)
 }
 
 // Listener serves HTTP requests from the given listener.
@@ -269,6 +323,11 @@ func (app *App) Listener(ln net.Listener, config ...ListenConfig) error {
 		go app.gracefulShutdown(ctx, cfg)
 	}
 
+	// Unix sockets, e.g. inherited via socket activation, are shown with their path
+	if ln.Addr().Network() == NetworkUnix {
+		cfg.ListenerNetwork = NetworkUnix
+	}
+
 	// prepare the server for the start
 	app.startupProcess()
 
@@ -290,26 +349,20 @@ func (app *App) Listener(ln net.Listener, config ...ListenConfig) error {
 		log.Warn("Prefork isn't supported for custom listeners.")
 	}
 
-	return app.server.Serve(ln)
+	return app.serve(ln, cfg)
 }
 
 // Create listener function.
 func (*App) createListener(addr string, tlsConfig *tls.Config, cfg ListenConfig) (net.Listener, error) {
-	var listener net.Listener
-	var err error
-
-	if tlsConfig != nil {
-		listener, err = tls.Listen(cfg.ListenerNetwork, addr, tlsConfig)
-	} else {
-		listener, err = net.Listen(cfg.ListenerNetwork, addr)
-	}
-
-	// Check for error before using the listener
+	listener, err := listen(addr, cfg)
 	if err != nil {
-		// Wrap the error from tls.Listen/net.Listen
 		return nil, fmt.Errorf("failed to listen: %w", err)
 	}
 
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
 	if cfg.ListenerAddrFunc != nil {
 		cfg.ListenerAddrFunc(listener.Addr())
 	}
@@ -332,7 +385,9 @@ func (app *App) printMessages(cfg ListenConfig, ln net.Listener) {
 // prepareListenData create an slice of ListenData
 func (*App) prepareListenData(addr string, isTLS bool, cfg ListenConfig) ListenData { //revive:disable-line:flag-parameter // Accepting a bool param named isTLS if fine here
 	host, port := parseAddr(addr)
-	if host == "" {
+	if cfg.ListenerNetwork == NetworkUnix {
+		host, port = addr, ""
+	} else if host == "" {
 		if cfg.ListenerNetwork == NetworkTCP6 {
 			host = "[::1]"
 		} else {
@@ -358,7 +413,9 @@ func (app *App) startupMessage(addr string, isTLS bool, pids string, cfg ListenC
 	colors := app.config.ColorScheme
 
 	host, port := parseAddr(addr)
-	if host == "" {
+	if cfg.ListenerNetwork == NetworkUnix {
+		host, port = addr, ""
+	} else if host == "" {
 		if cfg.ListenerNetwork == NetworkTCP6 {
 			host = "[::1]"
 		} else {
@@ -389,7 +446,12 @@ func (app *App) startupMessage(addr string, isTLS bool, pids string, cfg ListenC
 	fmt.Fprintf(out, "%s\n", fmt.Sprintf(figletVelocityText, colors.Red+"v"+Version+colors.Reset)) //nolint:errcheck,revive // ignore error
 	fmt.Fprintf(out, strings.Repeat("-", 50)+"\n")                                                 //nolint:errcheck,revive,govet // ignore error
 
-	if host == "0.0.0.0" {
+	if cfg.ListenerNetwork == NetworkUnix {
+		//nolint:errcheck,revive // ignore error
+		fmt.Fprintf(out,
+			"%sINFO%s Server started on: \t%s%s://unix:%s%s\n",
+			colors.Green, colors.Reset, colors.Blue, scheme, host, colors.Reset)
+	} else if host == "0.0.0.0" {
 		//nolint:errcheck,revive // ignore error
 		fmt.Fprintf(out,
 			"%sINFO%s Server started on: \t%s%s://127.0.0.1:%s%s (bound on host 0.0.0.0 and port %s)\n",
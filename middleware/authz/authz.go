@@ -0,0 +1,149 @@
+// Package authz provides a middleware that authorizes requests by the roles and scopes of
+// the principal that an authentication middleware stored in the context. The requirements
+// of the routes can be declared in their metadata, so that the policy table of an app can
+// be audited.
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/middleware/jwtauth"
+)
+
+// The contextKey type is unexported to prevent collisions with context keys defined in
+// other packages.
+type contextKey int
+
+// The keys for the principal and the config in context
+const (
+	principalKey contextKey = iota
+	configKey
+)
+
+// Principal is the authenticated user or client of a request
+type Principal struct {
+	Subject string
+	Roles   []string
+	Scopes  []string
+}
+
+// Extractor returns the principal of the request, false if the request is not authenticated
+type Extractor func(c velocity.Ctx) (Principal, bool)
+
+var (
+	// ErrUnauthenticated is passed to the ErrorHandler if the request has no principal
+	ErrUnauthenticated = errors.New("authz: request is not authenticated")
+	// ErrForbidden is matched by a *ForbiddenError with errors.Is
+	ErrForbidden = errors.New("authz: insufficient roles or scopes")
+)
+
+// ForbiddenError is passed to the ErrorHandler if the principal lacks a required role or scope
+type ForbiddenError struct {
+	Required Policy // The roles and scopes required by the route
+	Missing  Policy // The roles and scopes the principal lacks
+}
+
+// Error returns the missing roles and scopes
+func (e *ForbiddenError) Error() string {
+	var missing []string
+	if len(e.Missing.Roles) > 0 {
+		missing = append(missing, "role "+strings.Join(e.Missing.Roles, ", "))
+	}
+	if len(e.Missing.Scopes) > 0 {
+		missing = append(missing, "scope "+strings.Join(e.Missing.Scopes, ", "))
+	}
+	return fmt.Sprintf("missing %s", strings.Join(missing, " and "))
+}
+
+// Is reports whether the target is ErrForbidden
+func (*ForbiddenError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// New creates a new middleware handler, which sets the config with which the policies of the
+// routes are checked for the requests that pass it. The policies in the metadata of the routes
+// are enforced by the router without it as well, with the default config.
+func New(config ...Config) velocity.Handler {
+	// Init config
+	cfg := configDefault(config...)
+
+	// Return middleware handler
+	return func(c velocity.Ctx) error {
+		c.Locals(configKey, &cfg)
+		return c.Next()
+	}
+}
+
+// Require returns a policy that requires all the roles, for the metadata of a route or group
+//
+//	app.Group("/admin").Meta(authz.MetaKey, authz.Require("admin"))
+func Require(roles ...string) Policy {
+	return Policy{Roles: slices.Clone(roles)}
+}
+
+// RequireScopes returns a policy that requires all the scopes, for the metadata of a route or group
+//
+//	app.Post("/orders", createOrder).Meta(authz.MetaKey, authz.RequireScopes("orders:write"))
+func RequireScopes(scopes ...string) Policy {
+	return Policy{Scopes: slices.Clone(scopes)}
+}
+
+// SetPrincipal stores the principal in the context, e.g. in the SuccessHandler of an
+// authentication middleware, so that DefaultExtractor finds it
+func SetPrincipal(c velocity.Ctx, principal Principal) {
+	c.Locals(principalKey, principal)
+}
+
+// PrincipalFromContext returns the principal from the context.
+// returns false if the principal does not exist
+func PrincipalFromContext(c velocity.Ctx) (Principal, bool) {
+	principal, ok := c.Locals(principalKey).(Principal)
+	return principal, ok
+}
+
+// DefaultExtractor returns the principal that is stored with SetPrincipal or, if there is
+// none, the principal of the JWT that is validated by the jwtauth middleware, with the
+// roles of the "roles" claim
+func DefaultExtractor(c velocity.Ctx) (Principal, bool) {
+	if principal, ok := PrincipalFromContext(c); ok {
+		return principal, true
+	}
+	return FromJWT("roles")(c)
+}
+
+// FromLocals creates an extractor that returns the principal stored in Locals with the
+// key, the value must be a Principal or a *Principal
+func FromLocals(key any) Extractor {
+	return func(c velocity.Ctx) (Principal, bool) {
+		switch principal := c.Locals(key).(type) {
+		case Principal:
+			return principal, true
+		case *Principal:
+			if principal != nil {
+				return *principal, true
+			}
+		}
+		return Principal{}, false
+	}
+}
+
+// FromJWT creates an extractor that returns the principal of the JWT that is validated by
+// the jwtauth middleware. The subject is the "sub" claim, the scopes are the "scope" or
+// "scp" claim and the roles are the claim with the given name.
+func FromJWT(rolesClaim string) Extractor {
+	return func(c velocity.Ctx) (Principal, bool) {
+		claims := jwtauth.ClaimsFromContext(c)
+		if claims == nil {
+			return Principal{}, false
+		}
+		return Principal{
+			Subject: claims.Subject(),
+			Roles:   claims.Strings(rolesClaim),
+			Scopes:  claims.Scopes(),
+		}, true
+	}
+}
@@ -0,0 +1,393 @@
+package authz
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/middleware/jwtauth"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// authenticate is a test authentication middleware that reads the roles and scopes of the
+// principal from the X-Roles and X-Scopes headers, a request without them is not authenticated
+func authenticate(c velocity.Ctx) error {
+	roles, scopes := c.Get("X-Roles"), c.Get("X-Scopes")
+	if roles != "" || scopes != "" {
+		SetPrincipal(c, Principal{Subject: "alice", Roles: strings.Fields(roles), Scopes: strings.Fields(scopes)})
+	}
+	return c.Next()
+}
+
+func handler(c velocity.Ctx) error {
+	return c.SendString("OK")
+}
+
+// request sends a request with the roles and scopes and returns the status and the WWW-Authenticate header
+func request(t *testing.T, app *velocity.App, path, roles, scopes string) (int, string) {
+	t.Helper()
+
+	req := httptest.NewRequest(velocity.MethodGet, path, nil)
+	if roles != "" {
+		req.Header.Set("X-Roles", roles)
+	}
+	if scopes != "" {
+		req.Header.Set("X-Scopes", scopes)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp.StatusCode, resp.Header.Get(velocity.HeaderWWWAuthenticate)
+}
+
+// go test -run Test_Authz_Require
+func Test_Authz_Require(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(authenticate)
+	admin := app.Group("/admin").Meta(MetaKey, Require("admin"))
+	admin.Get("/users", handler)
+	admin.Delete("/users/:id", handler).Meta(MetaKey, Policy{Roles: []string{"admin"}, Scopes: []string{"users:delete"}})
+	app.Get("/public", handler)
+
+	tests := []struct {
+		name, path, roles, scopes, challenge string
+		status                               int
+	}{
+		{name: "public", path: "/public", status: velocity.StatusOK},
+		{name: "unauthenticated", path: "/admin/users", status: velocity.StatusUnauthorized, challenge: "Bearer"},
+		{name: "missing role", path: "/admin/users", roles: "user", status: velocity.StatusForbidden, challenge: `Bearer error="insufficient_scope", error_description="missing role admin"`},
+		{name: "role", path: "/admin/users", roles: "user admin", status: velocity.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			status, challenge := request(t, app, tt.path, tt.roles, tt.scopes)
+			require.Equal(t, tt.status, status)
+			require.Equal(t, tt.challenge, challenge)
+		})
+	}
+
+	req := httptest.NewRequest(velocity.MethodDelete, "/admin/users/1", nil)
+	req.Header.Set("X-Roles", "admin")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusForbidden, resp.StatusCode)
+	require.Equal(t, `Bearer error="insufficient_scope", error_description="missing scope users:delete", scope="users:delete"`, resp.Header.Get(velocity.HeaderWWWAuthenticate))
+
+	req = httptest.NewRequest(velocity.MethodDelete, "/admin/users/1", nil)
+	req.Header.Set("X-Roles", "admin")
+	req.Header.Set("X-Scopes", "users:read users:delete")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+}
+
+// go test -run Test_Authz_Config
+func Test_Authz_Config(t *testing.T) {
+	t.Parallel()
+
+	type user struct{ role string }
+
+	var handlerErr error
+	app := velocity.New()
+	app.Use(func(c velocity.Ctx) error {
+		if role := c.Get("X-Roles"); role != "" {
+			c.Locals("user", user{role: role})
+		}
+		return c.Next()
+	})
+	// The config of a global authz handler is used for the policies of the routes
+	app.Use(New(Config{
+		Extractor: func(c velocity.Ctx) (Principal, bool) {
+			u, ok := c.Locals("user").(user)
+			return Principal{Roles: []string{u.role}}, ok
+		},
+		ErrorHandler: func(c velocity.Ctx, err error) error {
+			handlerErr = err
+			return c.SendStatus(velocity.StatusTeapot)
+		},
+	}))
+	app.Get("/", handler).Meta(MetaKey, Policy{Roles: []string{"editor"}, Scopes: []string{"write"}})
+
+	status, _ := request(t, app, "/", "", "")
+	require.Equal(t, velocity.StatusTeapot, status)
+	require.ErrorIs(t, handlerErr, ErrUnauthenticated)
+
+	status, _ = request(t, app, "/", "viewer", "")
+	require.Equal(t, velocity.StatusTeapot, status)
+	require.ErrorIs(t, handlerErr, ErrForbidden)
+	var forbidden *ForbiddenError
+	require.ErrorAs(t, handlerErr, &forbidden)
+	require.Equal(t, Policy{Roles: []string{"editor"}, Scopes: []string{"write"}}, forbidden.Required)
+	require.Equal(t, Policy{Roles: []string{"editor"}, Scopes: []string{"write"}}, forbidden.Missing)
+	require.Equal(t, "missing role editor and scope write", forbidden.Error())
+}
+
+// go test -run Test_Authz_FromLocals
+func Test_Authz_FromLocals(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(func(c velocity.Ctx) error {
+		switch c.Query("type") {
+		case "value":
+			c.Locals("principal", Principal{Roles: []string{"admin"}})
+		case "pointer":
+			c.Locals("principal", &Principal{Roles: []string{"admin"}})
+		case "nil":
+			c.Locals("principal", (*Principal)(nil))
+		case "other":
+			c.Locals("principal", "admin")
+		}
+		return c.Next()
+	})
+	app.Get("/", handler, New(Config{Extractor: FromLocals("principal")})).Meta(MetaKey, Require("admin"))
+
+	for typ, want := range map[string]int{
+		"value":   velocity.StatusOK,
+		"pointer": velocity.StatusOK,
+		"nil":     velocity.StatusUnauthorized,
+		"other":   velocity.StatusUnauthorized,
+		"":        velocity.StatusUnauthorized,
+	} {
+		status, _ := request(t, app, "/?type="+typ, "", "")
+		require.Equal(t, want, status, typ)
+	}
+}
+
+// go test -run Test_Authz_FromJWT
+func Test_Authz_FromJWT(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	sign := func(claims string) string {
+		input := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+		mac := hmac.New(sha256.New, secret)
+		_, _ = mac.Write([]byte(input)) //nolint:errcheck // hash.Write never fails
+		return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	app := velocity.New()
+	app.Use(jwtauth.New(jwtauth.Config{Keys: []jwtauth.Key{{Key: secret}}}))
+	app.Get("/orders", func(c velocity.Ctx) error {
+		principal, ok := PrincipalFromContext(c)
+		require.True(t, ok)
+		return c.SendString(principal.Subject)
+	}).Meta(MetaKey, RequireScopes("orders:read"))
+	app.Get("/admin", handler).Meta(MetaKey, Require("admin"))
+
+	tests := []struct {
+		path, claims string
+		status       int
+	}{
+		{path: "/orders", claims: `{"sub":"alice","scope":"orders:read orders:write"}`, status: velocity.StatusOK},
+		{path: "/orders", claims: `{"sub":"alice","scp":["orders:write"]}`, status: velocity.StatusForbidden},
+		{path: "/admin", claims: `{"sub":"alice","roles":["admin"]}`, status: velocity.StatusOK},
+		{path: "/admin", claims: `{"sub":"alice","roles":"user"}`, status: velocity.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(velocity.MethodGet, tt.path, nil)
+		req.Header.Set(velocity.HeaderAuthorization, "Bearer "+sign(tt.claims))
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, tt.status, resp.StatusCode, tt.claims)
+		if tt.status == velocity.StatusOK && tt.path == "/orders" {
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "alice", string(body))
+		}
+	}
+}
+
+// go test -run Test_Authz_Meta
+func Test_Authz_Meta(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(authenticate)
+	// The policies are enforced without an authz handler, by a group, a Use route and a route
+	app.Group("/admin").Meta(MetaKey, Require("admin")).Get("/users", handler)
+	app.Use("/billing", func(c velocity.Ctx) error {
+		return c.Next()
+	}).Meta(MetaKey, Require("billing"))
+	app.Get("/billing/invoices", handler)
+	app.Get("/orders", handler).Meta(MetaKey, Policy{Roles: []string{"user"}, Scopes: []string{"orders:read"}})
+	// An empty policy requires a principal
+	app.Get("/profile", handler).Meta(MetaKey, Policy{})
+
+	tests := []struct {
+		path   string
+		roles  string
+		scopes string
+		status int
+	}{
+		{path: "/admin/users", status: velocity.StatusUnauthorized},
+		{path: "/admin/users", roles: "admin", status: velocity.StatusOK},
+		{path: "/admin/users", roles: "user", status: velocity.StatusForbidden},
+		{path: "/billing/invoices", roles: "billing", status: velocity.StatusOK},
+		{path: "/billing/invoices", roles: "user", status: velocity.StatusForbidden},
+		{path: "/orders", roles: "user", scopes: "orders:read", status: velocity.StatusOK},
+		{path: "/orders", roles: "user", status: velocity.StatusForbidden},
+		{path: "/orders", scopes: "orders:read", status: velocity.StatusForbidden},
+		{path: "/profile", status: velocity.StatusUnauthorized},
+		{path: "/profile", roles: "user", status: velocity.StatusOK},
+	}
+	for _, tt := range tests {
+		status, _ := request(t, app, tt.path, tt.roles, tt.scopes)
+		require.Equal(t, tt.status, status, tt.path+" "+tt.roles+" "+tt.scopes)
+	}
+}
+
+// go test -run Test_Authz_PolicyOf
+func Test_Authz_PolicyOf(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(authenticate)
+	admin := app.Group("/admin").Meta(MetaKey, Policy{Roles: []string{"admin"}})
+	admin.Use(New())
+	admin.Get("/users", handler)
+	app.Get("/orders", handler).Meta(MetaKey, Policy{Roles: []string{"user"}, Scopes: []string{"orders:read"}})
+
+	policies := make(map[string]Policy)
+	for _, route := range app.GetRoutes() {
+		if policy, ok := PolicyOf(route); ok {
+			method := route.Method
+			if route.IsMiddleware() {
+				method = "USE"
+			}
+			policies[method+" "+route.Path] = policy
+		}
+	}
+	require.Equal(t, map[string]Policy{
+		"USE /admin":       {Roles: []string{"admin"}},
+		"GET /admin/users": {Roles: []string{"admin"}},
+		"GET /orders":      {Roles: []string{"user"}, Scopes: []string{"orders:read"}},
+	}, policies)
+
+	_, ok := PolicyOf(velocity.Route{Handlers: []velocity.Handler{handler, authenticate}})
+	require.False(t, ok)
+}
+
+// go test -run Test_Authz_Table
+func Test_Authz_Table(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(authenticate)
+	app.Get("/health", handler)
+	api := app.Group("/api").Meta(MetaKey, RequireScopes("api"))
+	api.Use(New())
+	api.Get("/orders", handler).Name("orders")
+	api.Post("/admin/users", handler).Meta(MetaKey, Require("admin", "owner"))
+	// A Use route that is registered after a route doesn't apply to it
+	app.Use("/health", New()).Meta(MetaKey, Require("ops"))
+	app.Use("/api/admin", New()).Meta(MetaKey, Require("admin"))
+	app.Get("/apifoo", handler)
+	app.Get("/profile", handler).Meta(MetaKey, Policy{})
+
+	require.Equal(t, []RoutePolicy{
+		{Method: velocity.MethodGet, Path: "/health"},
+		{Method: velocity.MethodGet, Path: "/api/orders", Name: "orders", Authenticated: true, Policy: Policy{Scopes: []string{"api"}}},
+		{Method: velocity.MethodGet, Path: "/apifoo"},
+		{Method: velocity.MethodGet, Path: "/profile", Authenticated: true},
+		{Method: velocity.MethodPost, Path: "/api/admin/users", Authenticated: true, Policy: Policy{Scopes: []string{"api"}, Roles: []string{"admin", "owner"}}},
+	}, Table(app))
+}
+
+// go test -run Test_Authz_Table_Enforced
+func Test_Authz_Table_Enforced(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(authenticate)
+	app.Use(New())
+	app.Get("/health", handler)
+	// A route with a policy and without an authz handler
+	app.Get("/reports", handler).Meta(MetaKey, Require("analyst"))
+	admin := app.Group("/admin", func(c velocity.Ctx) error {
+		return c.Next()
+	}).Meta(MetaKey, Require("admin"))
+	admin.Get("/users", handler)
+	admin.Delete("/users/:id", handler).Meta(MetaKey, RequireScopes("users:delete"))
+	app.Use("/billing", New()).Meta(MetaKey, Require("billing"))
+	app.Post("/billing/invoices", handler).Meta(MetaKey, RequireScopes("invoices:write"))
+	app.Get("/profile", handler).Meta(MetaKey, Policy{})
+
+	send := func(rp RoutePolicy, roles, scopes []string) int {
+		t.Helper()
+		req := httptest.NewRequest(rp.Method, strings.ReplaceAll(rp.Path, ":id", "1"), nil)
+		if roles != nil || scopes != nil {
+			// A principal is authenticated even without roles and scopes
+			req.Header.Set("X-Roles", strings.Join(append([]string{"user"}, roles...), " "))
+			req.Header.Set("X-Scopes", strings.Join(scopes, " "))
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	table := Table(app)
+	require.Len(t, table, 6)
+	for _, rp := range table {
+		route := rp.Method + " " + rp.Path
+		if !rp.Authenticated {
+			require.True(t, rp.empty(), route)
+			require.Equal(t, velocity.StatusOK, send(rp, nil, nil), route)
+			continue
+		}
+		require.Equal(t, velocity.StatusUnauthorized, send(rp, nil, nil), route)
+		require.Equal(t, velocity.StatusOK, send(rp, rp.Roles, append(rp.Scopes, "other")), route)
+		for i := range rp.Roles {
+			roles := slices.Delete(slices.Clone(rp.Roles), i, i+1)
+			require.Equal(t, velocity.StatusForbidden, send(rp, roles, rp.Scopes), route+" without role "+rp.Roles[i])
+		}
+		for i := range rp.Scopes {
+			scopes := slices.Delete(slices.Clone(rp.Scopes), i, i+1)
+			require.Equal(t, velocity.StatusForbidden, send(rp, rp.Roles, scopes), route+" without scope "+rp.Scopes[i])
+		}
+	}
+}
+
+// go test -run Test_Authz_DefaultErrorHandler
+func Test_Authz_DefaultErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Get("/", func(c velocity.Ctx) error {
+		return DefaultErrorHandler(c, errors.New("unknown"))
+	})
+	status, challenge := request(t, app, "/", "", "")
+	require.Equal(t, velocity.StatusUnauthorized, status)
+	require.Equal(t, "Bearer", challenge)
+}
+
+// go test -v -run=^$ -bench=Benchmark_Authz -benchmem -count=4
+func Benchmark_Authz(b *testing.B) {
+	app := velocity.New()
+	app.Use(authenticate)
+	app.Get("/", handler).Meta(MetaKey, Policy{Roles: []string{"admin"}, Scopes: []string{"read", "write"}})
+	h := app.Handler()
+
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.Header.SetMethod(velocity.MethodGet)
+	fctx.Request.SetRequestURI("/")
+	fctx.Request.Header.Set("X-Roles", "user admin")
+	fctx.Request.Header.Set("X-Scopes", "read write")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h(fctx)
+	}
+	require.Equal(b, velocity.StatusOK, fctx.Response.StatusCode())
+}
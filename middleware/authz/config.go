@@ -0,0 +1,65 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// ErrorHandler defines a function which is executed if the request has no principal
+	// or the principal lacks a required role or scope.
+	// Optional. Default: DefaultErrorHandler
+	ErrorHandler velocity.ErrorHandler
+
+	// Extractor returns the principal of the request, which is stored in Locals by an
+	// authentication middleware.
+	// Optional. Default: DefaultExtractor
+	Extractor Extractor
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	ErrorHandler: DefaultErrorHandler,
+	Extractor:    DefaultExtractor,
+}
+
+// DefaultErrorHandler responds with 401 if the request has no principal and with 403 and
+// the insufficient_scope error of RFC 6750 if the principal lacks a role or scope
+func DefaultErrorHandler(c velocity.Ctx, err error) error {
+	var forbidden *ForbiddenError
+	if errors.As(err, &forbidden) {
+		challenge := fmt.Sprintf("Bearer error=%q, error_description=%q", "insufficient_scope", forbidden.Error())
+		if len(forbidden.Required.Scopes) > 0 {
+			challenge += fmt.Sprintf(", scope=%q", strings.Join(forbidden.Required.Scopes, " "))
+		}
+		c.Set(velocity.HeaderWWWAuthenticate, challenge)
+		return c.Status(velocity.StatusForbidden).SendString(velocity.ErrForbidden.Message)
+	}
+	c.Set(velocity.HeaderWWWAuthenticate, "Bearer")
+	return c.Status(velocity.StatusUnauthorized).SendString(velocity.ErrUnauthorized.Message)
+}
+
+// Helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = ConfigDefault.ErrorHandler
+	}
+	if cfg.Extractor == nil {
+		cfg.Extractor = ConfigDefault.Extractor
+	}
+
+	return cfg
+}
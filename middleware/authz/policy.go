@@ -0,0 +1,134 @@
+package authz
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// Policy holds the roles and scopes that are required
+type Policy struct {
+	Roles  []string `json:"roles,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// MetaKey is the key of the Policy in the metadata of a route, the router enforces the policy
+// with the config of the latest authz handler the request passed
+//
+//	app.Get("/orders", listOrders).Meta(authz.MetaKey, authz.Policy{
+//		Scopes: []string{"orders:read"},
+//	})
+const MetaKey = "authz"
+
+// RoutePolicy is the policy of a route in the policy table
+type RoutePolicy struct {
+	Method        string `json:"method"`
+	Path          string `json:"path"`
+	Name          string `json:"name"`
+	Authenticated bool   `json:"authenticated"` // Whether the route requires a principal
+	Policy
+}
+
+// Allow checks the policy for the request, so that the router enforces the policy in the
+// metadata of a route. The request must have a principal with all the roles and scopes of
+// the policy, which is stored in the context then.
+func (p Policy) Allow(c velocity.Ctx) (bool, error) {
+	cfg, ok := c.Locals(configKey).(*Config)
+	if !ok {
+		cfg = &ConfigDefault
+	}
+
+	principal, ok := cfg.Extractor(c)
+	if !ok {
+		return false, cfg.ErrorHandler(c, ErrUnauthenticated)
+	}
+	if missing := p.missing(principal); !missing.empty() {
+		return false, cfg.ErrorHandler(c, &ForbiddenError{Required: p, Missing: missing})
+	}
+
+	c.Locals(principalKey, principal)
+	return true, nil
+}
+
+// PolicyOf returns the policy in the metadata of the route, e.g. of a route returned by
+// GetRoutes. The metadata of a group is part of the routes that are added to it afterwards.
+// returns false if the route has no policy
+func PolicyOf(route velocity.Route) (Policy, bool) {
+	policy, ok := route.Meta[MetaKey].(Policy)
+	return policy, ok
+}
+
+// Table returns the policy table of the app, with the policy of every route including the
+// policies of the Use routes and groups that are registered before it and match its path.
+// Routes without a policy are included with an empty policy and Authenticated false, so
+// that they can be audited.
+func Table(app *velocity.App) []RoutePolicy {
+	var table []RoutePolicy
+	for _, routes := range app.Stack() {
+		var inherited []velocity.Route
+		for _, route := range routes {
+			if route.IsMiddleware() {
+				inherited = append(inherited, *route)
+				continue
+			}
+			entry := RoutePolicy{Method: route.Method, Path: route.Path, Name: route.Name}
+			for _, use := range inherited {
+				if !matchesPrefix(route.Path, use.Path) {
+					continue
+				}
+				if p, ok := PolicyOf(use); ok {
+					entry.Policy, entry.Authenticated = entry.merge(p), true
+				}
+			}
+			if p, ok := PolicyOf(*route); ok {
+				entry.Policy, entry.Authenticated = entry.merge(p), true
+			}
+			table = append(table, entry)
+		}
+	}
+	return table
+}
+
+// matchesPrefix reports whether a Use route with the prefix matches the path
+func matchesPrefix(path, prefix string) bool {
+	prefix = strings.TrimRight(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// merge returns the union of both policies
+func (p Policy) merge(other Policy) Policy {
+	return Policy{Roles: union(p.Roles, other.Roles), Scopes: union(p.Scopes, other.Scopes)}
+}
+
+// missing returns the roles and scopes of the policy that the principal lacks
+func (p Policy) missing(principal Principal) Policy {
+	var missing Policy
+	for _, role := range p.Roles {
+		if !slices.Contains(principal.Roles, role) {
+			missing.Roles = append(missing.Roles, role)
+		}
+	}
+	for _, scope := range p.Scopes {
+		if !slices.Contains(principal.Scopes, scope) {
+			missing.Scopes = append(missing.Scopes, scope)
+		}
+	}
+	return missing
+}
+
+// empty reports whether the policy requires nothing
+func (p Policy) empty() bool {
+	return len(p.Roles) == 0 && len(p.Scopes) == 0
+}
+
+// union returns the values of a followed by the values of b that are not in a
+func union(a, b []string) []string {
+	result := slices.Clone(a)
+	for _, v := range b {
+		if !slices.Contains(result, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -23,6 +23,11 @@ func New(config Config) velocity.Handler {
 	// Set default config
 	cfg := configDefault(config)
 
+	var counter *lockout
+	if cfg.MaxFailures > 0 {
+		counter = newLockout(&cfg)
+	}
+
 	// Return new handler
 	return func(c velocity.Ctx) error {
 		// Don't execute middleware if Next returns true
@@ -58,13 +63,27 @@ func New(config Config) velocity.Handler {
 		username := creds[:index]
 		password := creds[index+1:]
 
+		// Don't check the credentials of a locked username
+		var failed int
+		if counter != nil {
+			if failed = counter.failures(username); failed >= cfg.MaxFailures {
+				return cfg.Unauthorized(c)
+			}
+		}
+
 		if cfg.Authorizer(username, password) {
+			if failed > 0 {
+				counter.reset(username)
+			}
 			c.Locals(usernameKey, username)
 			c.Locals(passwordKey, password)
 			return c.Next()
 		}
 
 		// Authentication failed
+		if counter != nil {
+			counter.fail(c, username)
+		}
 		return cfg.Unauthorized(c)
 	}
 }
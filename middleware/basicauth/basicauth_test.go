@@ -5,11 +5,16 @@ import (
 	"fmt"
 	"io"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/khulnasoft/velocity"
 	"github.com/stretchr/testify/require"
 	"github.com/valyala/fasthttp"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // go test -run Test_BasicAuth_Next
@@ -91,6 +96,240 @@ func Test_Middleware_BasicAuth(t *testing.T) {
 	}
 }
 
+// login sends a request with the credentials and returns the status code
+func login(t *testing.T, app *velocity.App, username, password string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set(velocity.HeaderAuthorization, "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp.StatusCode
+}
+
+func newApp(config Config) *velocity.App {
+	app := velocity.New()
+	app.Use(New(config))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString(UsernameFromContext(c))
+	})
+	return app
+}
+
+// go test -run Test_BasicAuth_HashedUsers
+func Test_BasicAuth_HashedUsers(t *testing.T) {
+	t.Parallel()
+
+	app := newApp(Config{
+		Users: map[string]string{
+			"john":  bcryptHash(t, "doe"),
+			"jane":  argon2idHash(t, "roe"),
+			"admin": "123456",
+		},
+	})
+
+	require.Equal(t, velocity.StatusOK, login(t, app, "john", "doe"))
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "john", "roe"))
+	require.Equal(t, velocity.StatusOK, login(t, app, "jane", "roe"))
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "jane", "doe"))
+	require.Equal(t, velocity.StatusOK, login(t, app, "admin", "123456"))
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "nobody", "123456"))
+}
+
+// go test -run Test_BasicAuth_UnsupportedHash
+func Test_BasicAuth_UnsupportedHash(t *testing.T) {
+	t.Parallel()
+
+	for _, hash := range []string{"$apr1$salt$Zkm0QzJFZm0lqCtFxS3v1.", "{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", "$6$salt"} {
+		require.PanicsWithError(t, "unable to load users: basicauth: user \"john\": "+checkHash(hash).Error(), func() {
+			New(Config{Users: map[string]string{"john": hash}})
+		}, hash)
+	}
+}
+
+// go test -run Test_BasicAuth_UnknownUser
+func Test_BasicAuth_UnknownUser(t *testing.T) {
+	t.Parallel()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("doe"), bcrypt.DefaultCost)
+	require.NoError(t, err)
+	cfg := configDefault(Config{Users: map[string]string{"john": string(hash)}})
+
+	// The password of an unknown user is verified against a hash with the same cost
+	measure := func(user string) time.Duration {
+		start := time.Now()
+		require.False(t, cfg.Authorizer(user, "roe"))
+		return time.Since(start)
+	}
+	measure("nobody")
+	known, unknown := measure("john"), measure("nobody")
+	require.Greater(t, unknown, known/4)
+}
+
+// go test -run Test_BasicAuth_UsersFile
+func Test_BasicAuth_UsersFile(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), ".htpasswd")
+	write := func(content string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+		require.NoError(t, os.Chtimes(file, modTime, modTime))
+	}
+	start := time.Now().Add(-time.Hour)
+	write("john:"+bcryptHash(t, "doe")+"\n", start)
+
+	app := newApp(Config{
+		Users:          map[string]string{"admin": "123456", "john": "overridden"},
+		UsersFile:      file,
+		ReloadInterval: time.Nanosecond,
+	})
+	require.Equal(t, velocity.StatusOK, login(t, app, "john", "doe"))
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "john", "overridden"))
+	require.Equal(t, velocity.StatusOK, login(t, app, "admin", "123456"))
+
+	// The changed file is reloaded
+	write("jane:"+bcryptHash(t, "roe")+"\n", start.Add(time.Minute))
+	require.Equal(t, velocity.StatusOK, login(t, app, "jane", "roe"))
+	require.Equal(t, velocity.StatusOK, login(t, app, "john", "overridden"))
+
+	// An invalid file is ignored
+	write("jane:roe\n", start.Add(2*time.Minute))
+	require.Equal(t, velocity.StatusOK, login(t, app, "jane", "roe"))
+
+	// A missing file is ignored
+	require.NoError(t, os.Remove(file))
+	require.Equal(t, velocity.StatusOK, login(t, app, "jane", "roe"))
+
+	// The reload can be disabled
+	write("john:"+bcryptHash(t, "doe")+"\n", start)
+	app = newApp(Config{UsersFile: file, ReloadInterval: -1})
+	write("jane:"+bcryptHash(t, "roe")+"\n", start.Add(time.Minute))
+	require.Equal(t, velocity.StatusOK, login(t, app, "john", "doe"))
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "jane", "roe"))
+
+	require.PanicsWithError(t, "unable to load users: basicauth: htpasswd line 1: basicauth: unsupported password hash", func() {
+		write("jane:roe\n", start)
+		New(Config{UsersFile: file})
+	})
+	require.Panics(t, func() {
+		New(Config{UsersFile: filepath.Join(t.TempDir(), "missing")})
+	})
+}
+
+// go test -run Test_BasicAuth_UserLookup
+func Test_BasicAuth_UserLookup(t *testing.T) {
+	t.Parallel()
+
+	hash := bcryptHash(t, "doe")
+	app := newApp(Config{
+		Users: map[string]string{"admin": "123456"},
+		UserLookup: func(username string) (string, bool) {
+			if username == "john" {
+				return hash, true
+			}
+			return "", false
+		},
+	})
+
+	require.Equal(t, velocity.StatusOK, login(t, app, "john", "doe"))
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "john", "123456"))
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "admin", "123456"))
+}
+
+// go test -run Test_BasicAuth_Lockout
+func Test_BasicAuth_Lockout(t *testing.T) {
+	t.Parallel()
+
+	var locked []string
+	app := newApp(Config{
+		Users:       map[string]string{"john": "doe", "jane": "roe"},
+		MaxFailures: 3,
+		OnLockout: func(_ velocity.Ctx, username string) {
+			locked = append(locked, username)
+		},
+	})
+
+	// A successful login resets the failures
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "john", "wrong"))
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "john", "wrong"))
+	require.Equal(t, velocity.StatusOK, login(t, app, "john", "doe"))
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "john", "wrong"))
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "john", "wrong"))
+	require.Equal(t, velocity.StatusOK, login(t, app, "john", "doe"))
+	require.Empty(t, locked)
+
+	// The username is locked after the failures, even for the correct password
+	for i := 0; i < 4; i++ {
+		require.Equal(t, velocity.StatusUnauthorized, login(t, app, "john", "wrong"))
+	}
+	require.Equal(t, []string{"john"}, locked)
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "john", "doe"))
+
+	// Other usernames aren't locked
+	require.Equal(t, velocity.StatusOK, login(t, app, "jane", "roe"))
+}
+
+// go test -run Test_BasicAuth_Lockout_Storage
+func Test_BasicAuth_Lockout_Storage(t *testing.T) {
+	t.Parallel()
+
+	store := &expStorage{data: make(map[string][]byte)}
+	app := newApp(Config{
+		Users:           map[string]string{"john": "doe"},
+		MaxFailures:     2,
+		LockoutDuration: time.Minute,
+		Storage:         store,
+	})
+
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "john", "wrong"))
+	require.Equal(t, "1", string(store.data["basicauth_failures_john"]))
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "john", "wrong"))
+	require.Equal(t, velocity.StatusUnauthorized, login(t, app, "john", "doe"))
+	require.Equal(t, time.Minute, store.exp["basicauth_failures_john"])
+
+	// The lockout ends when the failures expire
+	require.NoError(t, store.Delete("basicauth_failures_john"))
+	require.Equal(t, velocity.StatusOK, login(t, app, "john", "doe"))
+}
+
+// expStorage records the expirations of the values, it doesn't implement velocity.AtomicStorage
+type expStorage struct {
+	data map[string][]byte
+	exp  map[string]time.Duration
+	mu   sync.Mutex
+}
+
+func (s *expStorage) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[key], nil
+}
+
+func (s *expStorage) Set(key string, val []byte, exp time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.exp == nil {
+		s.exp = make(map[string]time.Duration)
+	}
+	s.data[key], s.exp[key] = val, exp
+	return nil
+}
+
+func (s *expStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+func (*expStorage) Reset() error {
+	return nil
+}
+
+func (*expStorage) Close() error {
+	return nil
+}
+
 // go test -v -run=^$ -bench=Benchmark_Middleware_BasicAuth -benchmem -count=4
 func Benchmark_Middleware_BasicAuth(b *testing.B) {
 	app := velocity.New()
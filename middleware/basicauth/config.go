@@ -1,10 +1,10 @@
 package basicauth
 
 import (
-	"crypto/subtle"
+	"fmt"
+	"time"
 
 	"github.com/khulnasoft/velocity"
-	"github.com/khulnasoft/velocity/utils"
 )
 
 // Config defines the config for middleware.
@@ -14,7 +14,8 @@ type Config struct {
 	// Optional. Default: nil
 	Next func(c velocity.Ctx) bool
 
-	// Users defines the allowed credentials
+	// Users defines the allowed credentials. The passwords may be bcrypt,
+	// argon2id or SHA-512-crypt hashes, see VerifyPassword.
 	//
 	// Required. Default: map[string]string{}
 	Users map[string]string
@@ -28,6 +29,25 @@ type Config struct {
 	// Optional. Default: nil.
 	Authorizer func(string, string) bool
 
+	// UserLookup returns the password or password hash of a user, e.g.
+	// from a database. It is used if no Authorizer is set and overrides
+	// Users and UsersFile.
+	//
+	// Optional. Default: nil
+	UserLookup func(username string) (string, bool)
+
+	// OnLockout is called when a username is locked after MaxFailures
+	// failed logins, e.g. to alert.
+	//
+	// Optional. Default: nil
+	OnLockout func(c velocity.Ctx, username string)
+
+	// Storage stores the failed logins of the usernames if MaxFailures is set.
+	//
+	// Optional. Default: an in memory store for this process only, which holds
+	// the failures of up to 100,000 usernames
+	Storage velocity.Storage
+
 	// Unauthorized defines the response body for unauthorized responses.
 	// By default it will return with a 401 Unauthorized and the correct WWW-Auth header
 	//
@@ -40,15 +60,41 @@ type Config struct {
 	//
 	// Optional. Default: "Restricted".
 	Realm string
+
+	// UsersFile is the path of an htpasswd file with the users in
+	// addition to Users. It is reloaded if it changes.
+	//
+	// Optional. Default: ""
+	UsersFile string
+
+	// ReloadInterval is the interval in which the UsersFile is checked
+	// for changes, a negative value disables the reload.
+	//
+	// Optional. Default: 10 * time.Second
+	ReloadInterval time.Duration
+
+	// LockoutDuration is the time a username is locked after MaxFailures
+	// failed logins. The failures are forgotten after the same time.
+	//
+	// Optional. Default: 15 * time.Minute
+	LockoutDuration time.Duration
+
+	// MaxFailures is the number of consecutive failed logins after which
+	// a username is locked, 0 disables the lockout.
+	//
+	// Optional. Default: 0
+	MaxFailures int
 }
 
 // ConfigDefault is the default config
 var ConfigDefault = Config{
-	Next:         nil,
-	Users:        map[string]string{},
-	Realm:        "Restricted",
-	Authorizer:   nil,
-	Unauthorized: nil,
+	Next:            nil,
+	Users:           map[string]string{},
+	Realm:           "Restricted",
+	Authorizer:      nil,
+	Unauthorized:    nil,
+	ReloadInterval:  10 * time.Second,
+	LockoutDuration: 15 * time.Minute,
 }
 
 // Helper function to set default values
@@ -71,10 +117,33 @@ func configDefault(config ...Config) Config {
 	if cfg.Realm == "" {
 		cfg.Realm = ConfigDefault.Realm
 	}
+	if cfg.ReloadInterval == 0 {
+		cfg.ReloadInterval = ConfigDefault.ReloadInterval
+	}
+	if cfg.LockoutDuration <= 0 {
+		cfg.LockoutDuration = ConfigDefault.LockoutDuration
+	}
+	guard := &timingGuard{}
+	if cfg.UserLookup == nil {
+		users, err := newUserStore(cfg.Users, cfg.UsersFile, cfg.ReloadInterval)
+		if err != nil {
+			panic(fmt.Errorf("unable to load users: %w", err))
+		}
+		cfg.UserLookup = users.lookup
+		if hash, ok := users.sample(); ok {
+			guard.observe(hash)
+		}
+	}
 	if cfg.Authorizer == nil {
 		cfg.Authorizer = func(user, pass string) bool {
-			userPwd, exist := cfg.Users[user]
-			return exist && subtle.ConstantTimeCompare(utils.UnsafeBytes(userPwd), utils.UnsafeBytes(pass)) == 1
+			hash, exist := cfg.UserLookup(user)
+			if !exist {
+				// Unknown users take as long as wrong passwords, so that they can't be told apart
+				guard.verify(pass)
+				return false
+			}
+			guard.observe(hash)
+			return VerifyPassword(hash, pass)
 		}
 	}
 	if cfg.Unauthorized == nil {
@@ -0,0 +1,344 @@
+package basicauth
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/khulnasoft/velocity/utils"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnsupportedHash is returned if a password hash has an unsupported format, e.g. the MD5 based
+// "$apr1$" format of htpasswd
+var ErrUnsupportedHash = errors.New("basicauth: unsupported password hash")
+
+const (
+	sha512CryptPrefix        = "$6$"
+	sha512CryptRoundsPrefix  = "rounds="
+	sha512CryptDefaultRounds = 5000
+	sha512CryptMinRounds     = 1000
+	sha512CryptMaxRounds     = 999999999
+	sha512CryptMaxSalt       = 16
+	argon2idPrefix           = "$argon2id$"
+)
+
+// VerifyPassword reports whether the password matches the hash. The hash is a bcrypt ("$2a$",
+// "$2b$" or "$2y$"), argon2id ("$argon2id$") or SHA-512-crypt ("$6$") hash, any other value is
+// compared as plaintext. Other hashes, values starting with "$" or "{" like "$apr1$" or "{SHA}",
+// never match, so that they can't be used as passwords. All comparisons take constant time.
+func VerifyPassword(hash, password string) bool {
+	switch {
+	case isBcrypt(hash):
+		return bcrypt.CompareHashAndPassword(utils.UnsafeBytes(hash), utils.UnsafeBytes(password)) == nil
+	case strings.HasPrefix(hash, argon2idPrefix):
+		return verifyArgon2id(hash, password)
+	case strings.HasPrefix(hash, sha512CryptPrefix):
+		return verifySHA512Crypt(hash, password)
+	case isHash(hash):
+		return false
+	default:
+		return subtle.ConstantTimeCompare(utils.UnsafeBytes(hash), utils.UnsafeBytes(password)) == 1
+	}
+}
+
+// checkHash returns ErrUnsupportedHash if the hash is not a well-formed bcrypt, argon2id or
+// SHA-512-crypt hash
+func checkHash(hash string) error {
+	switch {
+	case isBcrypt(hash):
+		if _, err := bcrypt.Cost(utils.UnsafeBytes(hash)); err != nil {
+			return fmt.Errorf("%w: %w", ErrUnsupportedHash, err)
+		}
+	case strings.HasPrefix(hash, argon2idPrefix):
+		if _, err := parseArgon2id(hash); err != nil {
+			return err
+		}
+	case strings.HasPrefix(hash, sha512CryptPrefix):
+		if _, _, _, ok := parseSHA512Crypt(hash); !ok {
+			return fmt.Errorf("%w: malformed SHA-512-crypt hash", ErrUnsupportedHash)
+		}
+	default:
+		return ErrUnsupportedHash
+	}
+	return nil
+}
+
+// isHash reports whether the value looks like a hash instead of a plaintext password
+func isHash(hash string) bool {
+	return strings.HasPrefix(hash, "$") || strings.HasPrefix(hash, "{")
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// argon2idParams are the parameters of an argon2id hash
+type argon2idParams struct {
+	salt    []byte
+	key     []byte
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// parseArgon2id parses a hash in the format $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+func parseArgon2id(hash string) (argon2idParams, error) {
+	var params argon2idParams
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[2] != "v=19" {
+		return params, fmt.Errorf("%w: malformed argon2id hash", ErrUnsupportedHash)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, fmt.Errorf("%w: malformed argon2id parameters: %w", ErrUnsupportedHash, err)
+	}
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, fmt.Errorf("%w: malformed argon2id salt: %w", ErrUnsupportedHash, err)
+	}
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return params, fmt.Errorf("%w: malformed argon2id key: %w", ErrUnsupportedHash, err)
+	}
+	if params.time == 0 || params.threads == 0 || len(params.key) == 0 {
+		return params, fmt.Errorf("%w: invalid argon2id parameters", ErrUnsupportedHash)
+	}
+	return params, nil
+}
+
+func verifyArgon2id(hash, password string) bool {
+	params, err := parseArgon2id(hash)
+	if err != nil {
+		return false
+	}
+	key := argon2.IDKey(utils.UnsafeBytes(password), params.salt, params.time, params.memory, params.threads, uint32(len(params.key))) //nolint:gosec // The length of a decoded key fits into an uint32
+	return subtle.ConstantTimeCompare(key, params.key) == 1
+}
+
+// parseSHA512Crypt returns the salt and the rounds of a SHA-512-crypt hash and whether the
+// rounds are given explicitly
+func parseSHA512Crypt(hash string) (salt string, rounds int, explicit, ok bool) {
+	rest := strings.TrimPrefix(hash, sha512CryptPrefix)
+	rounds = sha512CryptDefaultRounds
+	if strings.HasPrefix(rest, sha512CryptRoundsPrefix) {
+		value, after, found := strings.Cut(rest[len(sha512CryptRoundsPrefix):], "$")
+		if !found {
+			return "", 0, false, false
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", 0, false, false
+		}
+		rounds, explicit, rest = min(max(n, sha512CryptMinRounds), sha512CryptMaxRounds), true, after
+	}
+	salt, _, found := strings.Cut(rest, "$")
+	if !found {
+		return "", 0, false, false
+	}
+	return salt[:min(len(salt), sha512CryptMaxSalt)], rounds, explicit, true
+}
+
+func verifySHA512Crypt(hash, password string) bool {
+	salt, rounds, explicit, ok := parseSHA512Crypt(hash)
+	if !ok {
+		return false
+	}
+	computed := sha512Crypt(utils.UnsafeBytes(password), []byte(salt), rounds, explicit)
+	return subtle.ConstantTimeCompare([]byte(computed), utils.UnsafeBytes(hash)) == 1
+}
+
+// sha512Crypt computes a SHA-512-crypt hash as specified in https://www.akkadia.org/drepper/SHA-crypt.txt
+func sha512Crypt(password, salt []byte, rounds int, explicitRounds bool) string {
+	// Digest B of password, salt, password
+	alternate := sha512.New()
+	alternate.Write(password)
+	alternate.Write(salt)
+	alternate.Write(password)
+	b := alternate.Sum(nil)
+
+	// Digest A
+	digest := sha512.New()
+	digest.Write(password)
+	digest.Write(salt)
+	digest.Write(repeat(b, len(password)))
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			digest.Write(b)
+		} else {
+			digest.Write(password)
+		}
+	}
+	a := digest.Sum(nil)
+
+	// Byte sequences P and S
+	dp := sha512.New()
+	for range password {
+		dp.Write(password)
+	}
+	p := repeat(dp.Sum(nil), len(password))
+
+	ds := sha512.New()
+	for i := 0; i < 16+int(a[0]); i++ {
+		ds.Write(salt)
+	}
+	s := repeat(ds.Sum(nil), len(salt))
+
+	// Rounds
+	c := a
+	for i := 0; i < rounds; i++ {
+		round := sha512.New()
+		if i&1 != 0 {
+			round.Write(p)
+		} else {
+			round.Write(c)
+		}
+		if i%3 != 0 {
+			round.Write(s)
+		}
+		if i%7 != 0 {
+			round.Write(p)
+		}
+		if i&1 != 0 {
+			round.Write(c)
+		} else {
+			round.Write(p)
+		}
+		c = round.Sum(c[:0])
+	}
+
+	var sb strings.Builder
+	sb.WriteString(sha512CryptPrefix)
+	if explicitRounds {
+		sb.WriteString(sha512CryptRoundsPrefix)
+		sb.WriteString(strconv.Itoa(rounds))
+		sb.WriteByte('$')
+	}
+	sb.Write(salt)
+	sb.WriteByte('$')
+	for _, group := range sha512CryptOrder {
+		encode24(&sb, c[group[0]], c[group[1]], c[group[2]], 4)
+	}
+	encode24(&sb, 0, 0, c[63], 2)
+	return sb.String()
+}
+
+// sha512CryptOrder is the order of the bytes of the digest in the encoded hash
+var sha512CryptOrder = [21][3]int{
+	{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4}, {47, 5, 26}, {6, 27, 48},
+	{28, 49, 7}, {50, 8, 29}, {9, 30, 51}, {31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13},
+	{56, 14, 35}, {15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19}, {62, 20, 41},
+}
+
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// encode24 writes n characters of the crypt base64 encoding of the 24 bits
+func encode24(sb *strings.Builder, b2, b1, b0 byte, n int) {
+	w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for ; n > 0; n-- {
+		sb.WriteByte(cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
+
+// repeat returns the digest repeated to the length n
+func repeat(digest []byte, n int) []byte {
+	out := make([]byte, 0, n)
+	for n > len(digest) {
+		out = append(out, digest...)
+		n -= len(digest)
+	}
+	return append(out, digest[:n]...)
+}
+
+// timingGuard verifies the passwords of unknown users against a dummy hash with the algorithm and
+// the cost of the hashes of the known users, so that a lookup miss takes as long as a wrong password
+type timingGuard struct {
+	ref   atomic.Pointer[string] // The hash of a known user
+	dummy atomic.Pointer[dummyHash]
+}
+
+// dummyHash is a hash of a random password with the parameters of a reference hash
+type dummyHash struct {
+	params string
+	hash   string
+}
+
+// observe adopts the algorithm and the cost of the hash of a known user for the dummy hash
+func (g *timingGuard) observe(hash string) {
+	if ref := g.ref.Load(); ref != nil && hashParams(*ref) == hashParams(hash) {
+		return
+	}
+	g.ref.Store(&hash)
+}
+
+// verify verifies the password against the dummy hash, which never matches
+func (g *timingGuard) verify(password string) {
+	var ref string
+	if r := g.ref.Load(); r != nil {
+		ref = *r
+	}
+	params := hashParams(ref)
+	dummy := g.dummy.Load()
+	if dummy == nil || dummy.params != params {
+		dummy = &dummyHash{params: params, hash: newDummyHash(ref)}
+		g.dummy.Store(dummy)
+	}
+	VerifyPassword(dummy.hash, password)
+}
+
+// hashParams returns the algorithm and the cost of the hash, two hashes with the same
+// parameters take the same time to verify. An empty hash stands for the default bcrypt cost.
+func hashParams(hash string) string {
+	switch {
+	case hash == "":
+		return ""
+	case isBcrypt(hash):
+		// $2a$10$
+		return hash[:min(len(hash), 7)]
+	case strings.HasPrefix(hash, argon2idPrefix):
+		// $argon2id$v=19$m=65536,t=3,p=4$ and the length of the salt and the key
+		if parts := strings.SplitN(hash, "$", 5); len(parts) == 5 {
+			return strings.Join(parts[:4], "$") + "$" + strconv.Itoa(len(parts[4]))
+		}
+	case strings.HasPrefix(hash, sha512CryptPrefix):
+		if salt, rounds, _, ok := parseSHA512Crypt(hash); ok {
+			return sha512CryptPrefix + strconv.Itoa(rounds) + "$" + strconv.Itoa(len(salt))
+		}
+	}
+	return "plaintext"
+}
+
+// newDummyHash returns a hash of a random password with the parameters of the reference hash
+func newDummyHash(ref string) string {
+	password := make([]byte, 16)
+	_, _ = rand.Read(password) //nolint:errcheck // crypto/rand never fails
+
+	switch {
+	case ref == "" || isBcrypt(ref):
+		cost, err := bcrypt.Cost(utils.UnsafeBytes(ref))
+		if err != nil {
+			cost = bcrypt.DefaultCost
+		}
+		if hash, err := bcrypt.GenerateFromPassword(password, cost); err == nil {
+			return string(hash)
+		}
+	case strings.HasPrefix(ref, argon2idPrefix):
+		if params, err := parseArgon2id(ref); err == nil {
+			key := argon2.IDKey(password, params.salt, params.time, params.memory, params.threads, uint32(len(params.key))) //nolint:gosec // The length of a decoded key fits into an uint32
+			return fmt.Sprintf("%sv=19$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, params.memory, params.time, params.threads,
+				base64.RawStdEncoding.EncodeToString(params.salt), base64.RawStdEncoding.EncodeToString(key))
+		}
+	case strings.HasPrefix(ref, sha512CryptPrefix):
+		if salt, rounds, explicit, ok := parseSHA512Crypt(ref); ok {
+			return sha512Crypt(password, []byte(salt), rounds, explicit)
+		}
+	}
+	return hex.EncodeToString(password)
+}
@@ -0,0 +1,150 @@
+package basicauth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptHash returns a bcrypt hash of the password with the minimum cost
+func bcryptHash(t testing.TB, password string) string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	return string(hash)
+}
+
+// argon2idHash returns an argon2id hash of the password in the PHC string format
+func argon2idHash(t testing.TB, password string) string {
+	t.Helper()
+
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	require.NoError(t, err)
+	key := argon2.IDKey([]byte(password), salt, 1, 1024, 1, 32)
+	return fmt.Sprintf("$argon2id$v=19$m=1024,t=1,p=1$%s$%s", base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// go test -run Test_VerifyPassword
+func Test_VerifyPassword(t *testing.T) {
+	t.Parallel()
+
+	bcryptSecret := bcryptHash(t, "secret")
+	argon2idSecret := argon2idHash(t, "secret")
+
+	tests := []struct {
+		name, hash, password string
+		valid                bool
+	}{
+		{name: "bcrypt", hash: bcryptSecret, password: "secret", valid: true},
+		{name: "bcrypt wrong", hash: bcryptSecret, password: "Secret"},
+		{name: "bcrypt 2y", hash: "$2y$" + bcryptSecret[4:], password: "secret", valid: true},
+		{name: "argon2id", hash: argon2idSecret, password: "secret", valid: true},
+		{name: "argon2id wrong", hash: argon2idSecret, password: "secret2"},
+		{name: "argon2id malformed", hash: "$argon2id$v=19$m=1024$c2FsdA$a2V5", password: "secret"},
+		// Test vectors of https://www.akkadia.org/drepper/SHA-crypt.txt
+		{name: "sha512", hash: "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1", password: "Hello world!", valid: true},
+		{name: "sha512 rounds", hash: "$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v.", password: "Hello world!", valid: true},
+		{name: "sha512 1000 rounds", hash: "$6$rounds=1000$roundstoolow$kUMsbe306n21p9R.FRkW3IGn.S9NPN0x50YhH1xhLsPuWGsUSklZt58jaTfF4ZEQpyUNGc0dqbpBYYBaHHrsX.", password: "the minimum number is still observed", valid: true},
+		// openssl passwd -6 -salt velocitysalt secret
+		{name: "sha512 openssl", hash: "$6$velocitysalt$Pp.J8SJa4DLwdjTVg0SGqbRJDCuyJbOrOhVW.6TpOCro6O6XNZFcaAHyql7PPqVguwHWc8hh5lhz.0eR0U8ff.", password: "secret", valid: true},
+		{name: "sha512 wrong", hash: "$6$velocitysalt$Pp.J8SJa4DLwdjTVg0SGqbRJDCuyJbOrOhVW.6TpOCro6O6XNZFcaAHyql7PPqVguwHWc8hh5lhz.0eR0U8ff.", password: "secret "},
+		{name: "sha512 malformed", hash: "$6$velocitysalt", password: "secret"},
+		{name: "plaintext", hash: "secret", password: "secret", valid: true},
+		{name: "plaintext wrong", hash: "secret", password: "secret2"},
+		// Unsupported hashes are not compared as plaintext, a leaked hash is not a password
+		{name: "apr1", hash: "$apr1$salt$Zkm0QzJFZm0lqCtFxS3v1.", password: "$apr1$salt$Zkm0QzJFZm0lqCtFxS3v1."},
+		{name: "sha", hash: "{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", password: "{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.valid, VerifyPassword(tt.hash, tt.password))
+		})
+	}
+}
+
+// go test -run Test_newDummyHash
+func Test_newDummyHash(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name, ref, params string
+	}{
+		{name: "default", ref: "", params: "$2a$10$"},
+		{name: "bcrypt", ref: bcryptHash(t, "secret"), params: "$2a$04$"},
+		{name: "argon2id", ref: argon2idHash(t, "secret"), params: "$argon2id$v=19$m=1024,t=1,p=1$66"},
+		{name: "sha512", ref: "$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v.", params: "$6$10000$16"},
+		{name: "plaintext", ref: "secret", params: "plaintext"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dummy := newDummyHash(tt.ref)
+			require.Equal(t, tt.params, hashParams(dummy))
+			require.NotEqual(t, tt.ref, dummy)
+			require.False(t, VerifyPassword(dummy, "secret"))
+		})
+	}
+}
+
+// go test -run Test_ParseHtpasswd
+func Test_ParseHtpasswd(t *testing.T) {
+	t.Parallel()
+
+	bcryptSecret := bcryptHash(t, "secret")
+	argon2idSecret := argon2idHash(t, "secret")
+	sha512Secret := "$6$velocitysalt$Pp.J8SJa4DLwdjTVg0SGqbRJDCuyJbOrOhVW.6TpOCro6O6XNZFcaAHyql7PPqVguwHWc8hh5lhz.0eR0U8ff."
+
+	users, err := ParseHtpasswd([]byte(strings.Join([]string{
+		"# users of the admin area",
+		"john:" + bcryptSecret,
+		"",
+		"  jane:" + argon2idSecret + "  \r",
+		"ops:" + sha512Secret,
+	}, "\n")))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"john": bcryptSecret, "jane": argon2idSecret, "ops": sha512Secret}, users)
+
+	for _, data := range []string{
+		"john",
+		":" + bcryptSecret,
+		"john:",
+		"john:doe",
+		"john:$apr1$salt$hash",
+		"john:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=",
+		"john:$2a$04$short",
+		"john:$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5",
+	} {
+		_, err := ParseHtpasswd([]byte("# comment\n" + data))
+		require.ErrorContains(t, err, "htpasswd line 2", data)
+	}
+	_, err = ParseHtpasswd([]byte("john:$apr1$salt$hash"))
+	require.ErrorIs(t, err, ErrUnsupportedHash)
+}
+
+// go test -v -run=^$ -bench=Benchmark_VerifyPassword -benchmem -count=4
+func Benchmark_VerifyPassword(b *testing.B) {
+	hashes := map[string]string{
+		"bcrypt":   bcryptHash(b, "secret"),
+		"argon2id": argon2idHash(b, "secret"),
+		"sha512":   "$6$velocitysalt$Pp.J8SJa4DLwdjTVg0SGqbRJDCuyJbOrOhVW.6TpOCro6O6XNZFcaAHyql7PPqVguwHWc8hh5lhz.0eR0U8ff.",
+	}
+	for name, hash := range hashes {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if !VerifyPassword(hash, "secret") {
+					b.Fatal("password doesn't match")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package basicauth
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khulnasoft/velocity/log"
+)
+
+// ParseHtpasswd parses the content of an htpasswd file with a "username:hash" line per user.
+// Empty lines and lines starting with "#" are skipped. The hashes must be bcrypt, argon2id or
+// SHA-512-crypt hashes, see VerifyPassword.
+func ParseHtpasswd(data []byte) (map[string]string, error) {
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		username, hash, found := strings.Cut(text, ":")
+		if !found || username == "" || hash == "" {
+			return nil, fmt.Errorf("basicauth: htpasswd line %d: expected username:hash", line)
+		}
+		if err := checkHash(hash); err != nil {
+			return nil, fmt.Errorf("basicauth: htpasswd line %d: %w", line, err)
+		}
+		users[username] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("basicauth: failed to read htpasswd: %w", err)
+	}
+	return users, nil
+}
+
+// userStore holds the users of the Users map and the UsersFile, the file is reloaded if it changes
+type userStore struct {
+	modTime  time.Time
+	users    atomic.Pointer[map[string]string]
+	static   map[string]string
+	file     string
+	checked  atomic.Int64 // Unix nanoseconds of the last check of the file
+	size     int64
+	interval time.Duration
+	mu       sync.Mutex
+}
+
+func newUserStore(static map[string]string, file string, interval time.Duration) (*userStore, error) {
+	// A hash in an unsupported format would never match, see VerifyPassword
+	for username, hash := range static {
+		if !isHash(hash) {
+			continue
+		}
+		if err := checkHash(hash); err != nil {
+			return nil, fmt.Errorf("basicauth: user %q: %w", username, err)
+		}
+	}
+
+	s := &userStore{static: static, file: file, interval: interval}
+	s.users.Store(&static)
+	if file != "" {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// lookup returns the password or hash of the user
+func (s *userStore) lookup(username string) (string, bool) {
+	if s.file != "" && s.interval > 0 {
+		s.reload()
+	}
+	hash, ok := (*s.users.Load())[username]
+	return hash, ok
+}
+
+// sample returns the hash of the first user by name, false if there are no users
+func (s *userStore) sample() (string, bool) {
+	users := *s.users.Load()
+	if len(users) == 0 {
+		return "", false
+	}
+	return users[slices.Min(slices.Collect(maps.Keys(users)))], true
+}
+
+// reload loads the file again if it is changed, it is checked at most once per interval
+func (s *userStore) reload() {
+	now := time.Now().UnixNano()
+	checked := s.checked.Load()
+	if now-checked < int64(s.interval) || !s.checked.CompareAndSwap(checked, now) {
+		return
+	}
+	if err := s.load(); err != nil {
+		// Keep the current users, e.g. while the file is replaced
+		log.Errorf("basicauth: failed to reload %s: %v", s.file, err)
+	}
+}
+
+// load loads the file if its modification time or size is changed
+func (s *userStore) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.file)
+	if err != nil {
+		return fmt.Errorf("basicauth: failed to load users: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		return fmt.Errorf("basicauth: failed to load users: %w", err)
+	}
+	fileUsers, err := ParseHtpasswd(data)
+	if err != nil {
+		return err
+	}
+
+	// The users of the file override the users of the map
+	users := maps.Clone(s.static)
+	if users == nil {
+		users = make(map[string]string, len(fileUsers))
+	}
+	maps.Copy(users, fileUsers)
+	s.users.Store(&users)
+	s.modTime, s.size = info.ModTime(), info.Size()
+	return nil
+}
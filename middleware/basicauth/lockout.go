@@ -0,0 +1,86 @@
+package basicauth
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/shared"
+	"github.com/khulnasoft/velocity/storage/memory"
+)
+
+const (
+	lockoutKeyPrefix = "basicauth_failures_"
+
+	// lockoutMaxEntries is the number of usernames whose failures the default storage holds
+	lockoutMaxEntries = 100_000
+)
+
+// lockout counts the consecutive failed logins of the usernames
+type lockout struct {
+	storage  velocity.Storage
+	atomic   velocity.AtomicStorage
+	onLock   func(velocity.Ctx, string)
+	duration time.Duration
+	max      int
+	mu       sync.Mutex
+}
+
+func newLockout(cfg *Config) *lockout {
+	l := &lockout{storage: cfg.Storage, onLock: cfg.OnLockout, duration: cfg.LockoutDuration, max: cfg.MaxFailures}
+	if l.storage == nil {
+		// Every failure of an unknown username adds a key, so the default storage is bounded.
+		// The least frequently failing usernames are evicted first, those of a flood of
+		// random usernames before the one under attack.
+		l.storage = memory.New(memory.Config{MaxEntries: lockoutMaxEntries, Policy: memory.LFU})
+	}
+	l.atomic = shared.Atomic(l.storage)
+	return l
+}
+
+// failures returns the number of failed logins of the username
+func (l *lockout) failures(username string) int {
+	raw, err := l.storage.Get(lockoutKeyPrefix + username)
+	if err != nil || raw == nil {
+		return 0
+	}
+	n, err := strconv.Atoi(string(raw))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// fail counts a failed login of the username. The failures expire after the lockout duration,
+// which starts again when the username is locked.
+func (l *lockout) fail(c velocity.Ctx, username string) {
+	key := lockoutKeyPrefix + username
+	var n int
+	if l.atomic != nil {
+		count, err := l.atomic.Incr(key, 1, l.duration)
+		if err != nil {
+			return
+		}
+		n = int(count)
+	} else {
+		l.mu.Lock()
+		n = l.failures(username) + 1
+		_ = l.storage.Set(key, []byte(strconv.Itoa(n)), l.duration) //nolint:errcheck // A failed count only weakens the lockout
+		l.mu.Unlock()
+	}
+	if n != l.max {
+		return
+	}
+
+	// Lock the username for the full duration
+	_ = l.storage.Set(key, []byte(strconv.Itoa(n)), l.duration) //nolint:errcheck // A failed count only weakens the lockout
+	if l.onLock != nil {
+		l.onLock(c, username)
+	}
+}
+
+// reset resets the failures of the username after a successful login
+func (l *lockout) reset(username string) {
+	_ = l.storage.Delete(lockoutKeyPrefix + username) //nolint:errcheck // The failures expire anyway
+}
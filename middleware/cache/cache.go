@@ -11,7 +11,9 @@ import (
 	"time"
 
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/log"
 	"github.com/khulnasoft/velocity/utils"
+	"github.com/valyala/fasthttp"
 )
 
 // timestampUpdatePeriod is the period which is used to check the cache expiration.
@@ -35,6 +37,19 @@ const (
 	noStore = "no-store"
 )
 
+// The contextKey type is unexported to prevent collisions with context keys defined in
+// other packages.
+type contextKey int
+
+// The keys for the values in context
+const (
+	// revalidateKey marks the copy of a request that revalidates a stale response in the background,
+	// the value is the revalidation marker of the cache that started it
+	revalidateKey contextKey = iota
+	// tagsKey are the tags that a handler attached to its response, see Tag
+	tagsKey
+)
+
 var ignoreHeaders = map[string]any{
 	"Connection":          nil,
 	"Keep-Alive":          nil,
@@ -65,6 +80,19 @@ var cacheableStatusCodes = map[int]bool{
 
 // New creates a new middleware handler
 func New(config ...Config) velocity.Handler {
+	handler, _ := newCache(false, config...)
+	return handler
+}
+
+// NewWithPurger creates a new middleware handler and a Purger for its cached responses.
+// Only this handler maintains the indexes that the Purger needs, which costs an update
+// of the storage per index for every stored response.
+func NewWithPurger(config ...Config) (velocity.Handler, *Purger) {
+	return newCache(true, config...)
+}
+
+// newCache creates the middleware handler, the indexes for the Purger are only maintained if purgeable
+func newCache(purgeable bool, config ...Config) (velocity.Handler, *Purger) {
 	// Set default config
 	cfg := configDefault(config...)
 
@@ -72,7 +100,7 @@ func New(config ...Config) velocity.Handler {
 	if int(cfg.Expiration.Seconds()) < 0 {
 		return func(c velocity.Ctx) error {
 			return c.Next()
-		}
+		}, &Purger{}
 	}
 
 	var (
@@ -82,6 +110,11 @@ func New(config ...Config) velocity.Handler {
 	)
 	// Create manager to simplify storage operations ( see manager.go )
 	manager := newManager(cfg.Storage)
+	// Create tag indexes for purging ( see purge.go )
+	var index *indexes
+	if purgeable {
+		index = newIndexes(manager)
+	}
 	// Create indexed heap for tracking expirations ( see heap.go )
 	heap := &indexedHeap{}
 	// count stored bytes (sizes of response bodies)
@@ -104,8 +137,483 @@ func New(config ...Config) velocity.Handler {
 		}
 	}
 
+	// Delete entry and remove it from the heap
+	deleteEntry := func(key string, e *item) {
+		deleteKey(key)
+		// The Vary index of an entry is not stored in the heap
+		if cfg.MaxBytes > 0 && len(e.vary) == 0 {
+			_, size := heap.remove(e.heapidx)
+			storedBytes -= size
+		}
+	}
+
+	// Make room for a body of the size, returns false if it won't fit into cache
+	makeRoom := func(bodySize uint) bool {
+		if cfg.MaxBytes == 0 {
+			return true
+		}
+		if bodySize > cfg.MaxBytes {
+			return false
+		}
+		// Remove oldest to make room for new
+		for storedBytes+bodySize > cfg.MaxBytes {
+			key, size := heap.removeFirst()
+			deleteKey(key)
+			storedBytes -= size
+		}
+		return true
+	}
+
+	// Store entry, it is released afterwards
+	storeEntry := func(key string, e *item, expiration time.Duration) {
+		// Store entry in heap
+		if cfg.MaxBytes > 0 && len(e.vary) == 0 {
+			bodySize := uint(len(e.body))
+			e.heapidx = heap.put(key, e.exp, bodySize)
+			storedBytes += bodySize
+		}
+
+		// For external Storage we store raw body separated
+		if cfg.Storage != nil {
+			manager.setRaw(key+"_body", e.body, expiration)
+			// avoid body msgp encoding
+			e.body = nil
+			manager.set(key, e, expiration)
+			manager.release(e)
+		} else {
+			// Store entry in memory
+			manager.set(key, e, expiration)
+		}
+	}
+
+	// Delete the entries with the keys
+	purge := func(keys []string) {
+		mux.Lock()
+		defer mux.Unlock()
+		for _, key := range keys {
+			if e := manager.get(key); e != nil && e.exp != 0 {
+				deleteEntry(key, e)
+			}
+		}
+	}
+
+	// Add the stored entry to the indexes of its tags, its route and its path segment. It is
+	// called after the lock is released, an entry that can't be indexed is deleted again,
+	// because it couldn't be purged.
+	indexEntry := func(c velocity.Ctx, key string, expiration time.Duration) {
+		if index == nil {
+			return
+		}
+		shard := prefixShard(key)
+		names := []string{routeIndex + c.Route().Path, prefixIndex + shard}
+		tags, _ := c.Locals(tagsKey).([]string) //nolint:errcheck // nil if not set
+		for _, tag := range tags {
+			names = append(names, tagIndex+tag)
+		}
+		exp := uint64(time.Now().Add(expiration).Unix()) //nolint:gosec // Not a concern
+		err := index.add(key, exp, names)
+		if err == nil {
+			err = index.add(shard, exp, []string{shardsIndex})
+		}
+		if err != nil {
+			log.Errorf("cache: failed to index %s: %v", key, err)
+			purge([]string{key})
+		}
+	}
+
+	// Set response from entry with body
+	applyEntry := func(c velocity.Ctx, e *item) {
+		// Set response headers from cache
+		c.Response().SetBodyRaw(e.body)
+		c.Response().SetStatusCode(e.status)
+		c.Response().Header.SetContentTypeBytes(e.ctype)
+		if len(e.cencoding) > 0 {
+			c.Response().Header.SetBytesV(velocity.HeaderContentEncoding, e.cencoding)
+		}
+		for k, v := range e.headers {
+			c.Response().Header.SetBytesV(k, v)
+		}
+	}
+
+	// Set response from cache entry
+	setResponse := func(c velocity.Ctx, key string, e *item) {
+		// Separate body value to avoid msgp serialization
+		// We can store raw bytes with Storage 👍
+		if cfg.Storage != nil {
+			e.body = manager.getRaw(key + "_body")
+		}
+		applyEntry(c, e)
+	}
+
+	// Requests that run the handler for a missing key, the concurrent misses of the key wait for them
+	flights := make(map[string]*flight)
+
+	// Join the request that runs the handler for the key, returns the flight of this request if
+	// it has to run the handler, or the landed flight of the other request. Both are nil if
+	// the wait timed out.
+	join := func(key string) (*flight, *flight) {
+		mux.Lock()
+		f, ok := flights[key]
+		if !ok {
+			f = &flight{done: make(chan struct{})}
+			flights[key] = f
+			mux.Unlock()
+			return f, nil
+		}
+		mux.Unlock()
+
+		timer := time.NewTimer(cfg.CoalesceTimeout)
+		defer timer.Stop()
+		select {
+		case <-f.done:
+			return nil, f
+		case <-timer.C:
+			return nil, nil
+		}
+	}
+
+	// Land the flight of the key, the waiting requests receive its response
+	land := func(key string, f *flight) {
+		mux.Lock()
+		delete(flights, key)
+		mux.Unlock()
+		close(f.done)
+	}
+
+	// Create entry from response
+	newEntry := func(c velocity.Ctx) *item {
+		e := manager.acquire()
+		// Cache response
+		e.body = utils.CopyBytes(c.Response().Body())
+		e.status = c.Response().StatusCode()
+		e.ctype = utils.CopyBytes(c.Response().Header.ContentType())
+		e.cencoding = utils.CopyBytes(c.Response().Header.Peek(velocity.HeaderContentEncoding))
+
+		// Store all response headers
+		// (more: https://datatracker.ietf.org/doc/html/rfc2616#section-13.5.1)
+		if cfg.StoreResponseHeaders || cfg.RFC9111 {
+			e.headers = make(map[string][]byte)
+			c.Response().Header.VisitAll(
+				func(key, value []byte) {
+					// create real copy
+					keyS := string(key)
+					if _, ok := ignoreHeaders[keyS]; !ok {
+						e.headers[keyS] = utils.CopyBytes(value)
+					}
+				},
+			)
+		}
+		return e
+	}
+
+	// Revalidate the stale response of the request in the background with a copy of the request.
+	// The copy runs through all handlers of the app, so that the middleware before the cache,
+	// e.g. recover or authentication, handles it as well. The cache recognizes the copy by the
+	// marker in its Locals and fetches the response instead of serving the stale one.
+	revalidating := make(map[string]struct{})
+	marker := new(byte)
+	revalidateInBackground := func(c velocity.Ctx, key string) {
+		if _, ok := revalidating[key]; ok {
+			return
+		}
+		// Don't start revalidations while the server shuts down
+		select {
+		case <-c.RequestCtx().Done():
+			return
+		default:
+		}
+		revalidating[key] = struct{}{}
+
+		handler := c.App().Handler()
+		fctx := &fasthttp.RequestCtx{}
+		fctx.Init(&c.RequestCtx().Request, c.RequestCtx().RemoteAddr(), nil)
+		fctx.SetUserValue(revalidateKey, marker)
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Errorf("cache: failed to revalidate %s: %v", key, r)
+				}
+				mux.Lock()
+				delete(revalidating, key)
+				mux.Unlock()
+			}()
+			// The stale response is kept if the revalidation fails
+			handler(fctx)
+		}()
+	}
+
+	// Cache responses with the semantics of a shared cache ( see rfc9111.go )
+	sharedCache := func(c velocity.Ctx) error {
+		requestMethod := c.Method()
+
+		// Only cache selected methods
+		if !slices.Contains(cfg.Methods, requestMethod) {
+			c.Set(cfg.CacheHeader, cacheUnreachable)
+			if err := c.Next(); err != nil {
+				return err
+			}
+			// A successful unsafe request invalidates the cached responses of its target ( RFC 9111 section 4.4 )
+			if !velocity.IsMethodSafe(requestMethod) && c.Response().StatusCode() < velocity.StatusBadRequest {
+				key := cfg.KeyGenerator(c)
+				mux.Lock()
+				for _, method := range cfg.Methods {
+					if e := manager.get(key + "_" + method); e != nil && e.exp != 0 {
+						deleteEntry(key+"_"+method, e)
+					}
+				}
+				mux.Unlock()
+			}
+			return nil
+		}
+
+		reqCC := parseCacheControl(c.Get(velocity.HeaderCacheControl))
+		revalidate := c.Locals(revalidateKey) == marker
+
+		// Get key from request
+		primaryKey := cfg.KeyGenerator(c) + "_" + requestMethod
+		key := primaryKey
+
+		// Lock entry
+		mux.Lock()
+
+		// Get timestamp
+		ts := atomic.LoadUint64(&timestamp)
+
+		// Get entry, the responses that vary are stored by the values of the request headers
+		e := manager.get(key)
+		if e != nil && e.exp != 0 && len(e.vary) > 0 {
+			key += varyKey(c, e.vary)
+			e = manager.get(key)
+		}
+		// A missing entry of an external storage has no expiration
+		if e != nil && e.exp == 0 {
+			e = nil
+		}
+
+		// Invalidate cache if requested
+		if e != nil && cfg.CacheInvalidator != nil && cfg.CacheInvalidator(c) {
+			deleteEntry(key, e)
+			e = nil
+		}
+
+		if e != nil && !revalidate {
+			age := e.age + ts - min(e.date, ts)
+			// The client can require a revalidation or a fresher response
+			mustRevalidate := reqCC.noCache || (reqCC.maxAge >= 0 && age > uint64(reqCC.maxAge))
+
+			// Serve fresh response, or the stale response while it is revalidated in the background
+			if !mustRevalidate && (ts < e.exp || ts < e.exp+e.swr) {
+				setResponse(c, key, e)
+				c.Set(velocity.HeaderAge, strconv.FormatUint(age, 10))
+				if ts < e.exp {
+					c.Set(cfg.CacheHeader, cacheHit)
+				} else {
+					c.Set(cfg.CacheHeader, cacheStale)
+					revalidateInBackground(c, key)
+				}
+				mux.Unlock()
+				return nil
+			}
+		}
+
+		// make sure we're not blocking concurrent requests - do unlock
+		mux.Unlock()
+
+		// The client only wants a stored response
+		if reqCC.onlyIfCached {
+			c.Set(cfg.CacheHeader, cacheMiss)
+			return c.SendStatus(velocity.StatusGatewayTimeout)
+		}
+
+		// Wait for the response of a concurrent miss of the key
+		var f *flight
+		if cfg.Coalesce {
+			var landed *flight
+			if f, landed = join(primaryKey); landed.received(c) {
+				applyEntry(c, landed.e)
+				now := atomic.LoadUint64(&timestamp)
+				c.Set(velocity.HeaderAge, strconv.FormatUint(landed.e.age+now-min(landed.e.date, now), 10))
+				c.Set(cfg.CacheHeader, cacheHit)
+				return nil
+			}
+			if f != nil {
+				defer land(primaryKey, f)
+			}
+		}
+
+		// Revalidate the stale response with its validators instead of the ones of the client
+		conditional := e != nil && (len(e.etag) > 0 || len(e.lmodified) > 0)
+		if conditional {
+			c.Request().Header.Del(velocity.HeaderIfNoneMatch)
+			c.Request().Header.Del(velocity.HeaderIfModifiedSince)
+			if len(e.etag) > 0 {
+				c.Request().Header.SetBytesV(velocity.HeaderIfNoneMatch, e.etag)
+			}
+			if len(e.lmodified) > 0 {
+				c.Request().Header.SetBytesV(velocity.HeaderIfModifiedSince, e.lmodified)
+			}
+		}
+
+		// Continue stack
+		err := c.Next()
+
+		// Serve the stale response if the handler fails ( stale-if-error )
+		if e != nil && (err != nil || c.Response().StatusCode() >= velocity.StatusInternalServerError) {
+			now := atomic.LoadUint64(&timestamp)
+			if now < e.exp+e.sie {
+				mux.Lock()
+				setResponse(c, key, e)
+				mux.Unlock()
+				c.Set(velocity.HeaderAge, strconv.FormatUint(e.age+now-min(e.date, now), 10))
+				c.Set(cfg.CacheHeader, cacheStale)
+				return nil
+			}
+		}
+
+		// return err to Velocity if exist
+		if err != nil {
+			return err
+		}
+
+		cacheStatus := cacheMiss
+
+		// The stale response is still valid, update it with the headers of the 304 response
+		// ( RFC 9111 section 4.3.4 )
+		if conditional && c.Response().StatusCode() == velocity.StatusNotModified {
+			updated := make(map[string][]byte)
+			c.Response().Header.VisitAll(func(key, value []byte) {
+				keyS := string(key)
+				if _, ok := ignoreHeaders[keyS]; !ok && keyS != velocity.HeaderContentLength {
+					updated[keyS] = utils.CopyBytes(value)
+				}
+			})
+			mux.Lock()
+			setResponse(c, key, e)
+			mux.Unlock()
+			for k, v := range updated {
+				c.Response().Header.SetBytesV(k, v)
+			}
+			cacheStatus = cacheRevalidated
+		}
+
+		// The stored entry is indexed after the lock is released
+		var (
+			indexed           bool
+			indexedExpiration time.Duration
+		)
+		defer func() {
+			if indexed {
+				indexEntry(c, key, indexedExpiration)
+			}
+		}()
+
+		// lock entry back and unlock on finish
+		mux.Lock()
+		defer mux.Unlock()
+
+		// Get timestamp
+		ts = atomic.LoadUint64(&timestamp)
+
+		resCC := parseCacheControl(string(c.Response().Header.Peek(velocity.HeaderCacheControl)))
+		lifetime, explicit := freshness(c.Response(), resCC, ts)
+		vary := parseVary(string(c.Response().Header.Peek(velocity.HeaderVary)))
+
+		// The stored response is replaced by one that can't be stored
+		uncacheable := func() error {
+			if e != nil {
+				if old := manager.get(key); old != nil && old.exp != 0 {
+					deleteEntry(key, old)
+				}
+			}
+			c.Set(cfg.CacheHeader, cacheUnreachable)
+			return nil
+		}
+
+		// Don't cache response if it's not storable or Next returns true
+		if reqCC.noStore || !storable(c, resCC, explicit) || slices.Contains(vary, "*") ||
+			(cfg.Next != nil && cfg.Next(c)) {
+			return uncacheable()
+		}
+
+		// Calculate heuristic freshness lifetime by the expiration or other setting
+		if !explicit {
+			expiration := cfg.Expiration
+			if cfg.ExpirationGenerator != nil {
+				expiration = cfg.ExpirationGenerator(c, &cfg)
+			}
+			lifetime = uint64(expiration.Seconds())
+		}
+
+		e = newEntry(c)
+		delete(e.headers, velocity.HeaderAge)
+		delete(e.headers, cfg.CacheHeader)
+		e.etag = utils.CopyBytes(c.Response().Header.Peek(velocity.HeaderETag))
+		e.lmodified = utils.CopyBytes(c.Response().Header.Peek(velocity.HeaderLastModified))
+		e.date = ts
+		e.age = initialAge(c.Response(), ts)
+		// A response with no-cache must be revalidated before every use
+		if resCC.noCache {
+			lifetime = 0
+		}
+		e.exp = ts + lifetime - min(e.age, lifetime)
+		// A response with must-revalidate must not be served stale
+		if !resCC.mustRevalidate {
+			e.swr = uint64(max(resCC.staleWhileRevalidate, 0))
+			e.sie = uint64(max(resCC.staleIfError, 0))
+		}
+		expiration := retention(e, cfg.Expiration)
+
+		// Nothing to serve or revalidate
+		if expiration <= 0 {
+			manager.release(e)
+			c.Set(cfg.CacheHeader, cacheUnreachable)
+			return nil
+		}
+
+		// Share the response with the waiting requests, even if it won't fit into cache
+		if f != nil && ts < e.exp {
+			f.share(c, e, vary)
+		}
+
+		// Don't try to cache if body won't fit into cache
+		if !makeRoom(uint(len(e.body))) {
+			manager.release(e)
+			return uncacheable()
+		}
+
+		// Responses that vary are stored with an index of the request headers they vary on
+		key = primaryKey
+		if len(vary) > 0 {
+			index := manager.acquire()
+			index.vary = vary
+			index.exp = e.exp
+			index.date = ts
+			if old := manager.get(key); old != nil && old.exp != 0 {
+				deleteEntry(key, old)
+			}
+			storeEntry(key, index, expiration)
+			key += varyKey(c, vary)
+		}
+
+		// Replace the stored response
+		if old := manager.get(key); old != nil && old.exp != 0 {
+			deleteEntry(key, old)
+		}
+		storeEntry(key, e, expiration)
+		indexed, indexedExpiration = true, expiration
+
+		c.Set(cfg.CacheHeader, cacheStatus)
+
+		// Finish response
+		return nil
+	}
+
 	// Return new handler
-	return func(c velocity.Ctx) error {
+	handler := func(c velocity.Ctx) error {
+		if cfg.RFC9111 {
+			return sharedCache(c)
+		}
+
 		// Refrain from caching
 		if hasRequestDirective(c, noStore) {
 			return c.Next()
@@ -141,27 +649,9 @@ func New(config ...Config) velocity.Handler {
 
 			// Check if entry is expired
 			if e.exp != 0 && ts >= e.exp {
-				deleteKey(key)
-				if cfg.MaxBytes > 0 {
-					_, size := heap.remove(e.heapidx)
-					storedBytes -= size
-				}
+				deleteEntry(key, e)
 			} else if e.exp != 0 && !hasRequestDirective(c, noCache) {
-				// Separate body value to avoid msgp serialization
-				// We can store raw bytes with Storage 👍
-				if cfg.Storage != nil {
-					e.body = manager.getRaw(key + "_body")
-				}
-				// Set response headers from cache
-				c.Response().SetBodyRaw(e.body)
-				c.Response().SetStatusCode(e.status)
-				c.Response().Header.SetContentTypeBytes(e.ctype)
-				if len(e.cencoding) > 0 {
-					c.Response().Header.SetBytesV(velocity.HeaderContentEncoding, e.cencoding)
-				}
-				for k, v := range e.headers {
-					c.Response().Header.SetBytesV(k, v)
-				}
+				setResponse(c, key, e)
 				// Set Cache-Control header if enabled
 				if cfg.CacheControl {
 					maxAge := strconv.FormatUint(e.exp-ts, 10)
@@ -180,6 +670,25 @@ func New(config ...Config) velocity.Handler {
 		// make sure we're not blocking concurrent requests - do unlock
 		mux.Unlock()
 
+		// Wait for the response of a concurrent miss of the key
+		var f *flight
+		if cfg.Coalesce {
+			var landed *flight
+			if f, landed = join(key); landed.received(c) {
+				applyEntry(c, landed.e)
+				// Set Cache-Control header if enabled
+				if ts := atomic.LoadUint64(&timestamp); cfg.CacheControl && landed.e.exp > ts {
+					maxAge := strconv.FormatUint(landed.e.exp-ts, 10)
+					c.Set(velocity.HeaderCacheControl, "public, max-age="+maxAge)
+				}
+				c.Set(cfg.CacheHeader, cacheHit)
+				return nil
+			}
+			if f != nil {
+				defer land(key, f)
+			}
+		}
+
 		// Continue stack, return err to Velocity if exist
 		if err := c.Next(); err != nil {
 			return err
@@ -191,6 +700,17 @@ func New(config ...Config) velocity.Handler {
 			return nil
 		}
 
+		// The stored entry is indexed after the lock is released
+		var (
+			indexed           bool
+			indexedExpiration time.Duration
+		)
+		defer func() {
+			if indexed {
+				indexEntry(c, key, indexedExpiration)
+			}
+		}()
+
 		// lock entry back and unlock on finish
 		mux.Lock()
 		defer mux.Unlock()
@@ -201,43 +721,7 @@ func New(config ...Config) velocity.Handler {
 			return nil
 		}
 
-		// Don't try to cache if body won't fit into cache
-		bodySize := uint(len(c.Response().Body()))
-		if cfg.MaxBytes > 0 && bodySize > cfg.MaxBytes {
-			c.Set(cfg.CacheHeader, cacheUnreachable)
-			return nil
-		}
-
-		// Remove oldest to make room for new
-		if cfg.MaxBytes > 0 {
-			for storedBytes+bodySize > cfg.MaxBytes {
-				key, size := heap.removeFirst()
-				deleteKey(key)
-				storedBytes -= size
-			}
-		}
-
-		e = manager.acquire()
-		// Cache response
-		e.body = utils.CopyBytes(c.Response().Body())
-		e.status = c.Response().StatusCode()
-		e.ctype = utils.CopyBytes(c.Response().Header.ContentType())
-		e.cencoding = utils.CopyBytes(c.Response().Header.Peek(velocity.HeaderContentEncoding))
-
-		// Store all response headers
-		// (more: https://datatracker.ietf.org/doc/html/rfc2616#section-13.5.1)
-		if cfg.StoreResponseHeaders {
-			e.headers = make(map[string][]byte)
-			c.Response().Header.VisitAll(
-				func(key, value []byte) {
-					// create real copy
-					keyS := string(key)
-					if _, ok := ignoreHeaders[keyS]; !ok {
-						e.headers[keyS] = utils.CopyBytes(value)
-					}
-				},
-			)
-		}
+		e = newEntry(c)
 
 		// default cache expiration
 		expiration := cfg.Expiration
@@ -247,29 +731,28 @@ func New(config ...Config) velocity.Handler {
 		}
 		e.exp = ts + uint64(expiration.Seconds())
 
-		// Store entry in heap
-		if cfg.MaxBytes > 0 {
-			e.heapidx = heap.put(key, e.exp, bodySize)
-			storedBytes += bodySize
+		// Share the response with the waiting requests, even if it won't fit into cache
+		if f != nil {
+			f.share(c, e, nil)
 		}
 
-		// For external Storage we store raw body separated
-		if cfg.Storage != nil {
-			manager.setRaw(key+"_body", e.body, expiration)
-			// avoid body msgp encoding
-			e.body = nil
-			manager.set(key, e, expiration)
+		// Don't try to cache if body won't fit into cache
+		if !makeRoom(uint(len(e.body))) {
 			manager.release(e)
-		} else {
-			// Store entry in memory
-			manager.set(key, e, expiration)
+			c.Set(cfg.CacheHeader, cacheUnreachable)
+			return nil
 		}
 
+		storeEntry(key, e, expiration)
+		indexed, indexedExpiration = true, expiration
+
 		c.Set(cfg.CacheHeader, cacheMiss)
 
 		// Finish response
 		return nil
 	}
+
+	return handler, &Purger{index: index, purge: purge}
 }
 
 // Check if request has directive
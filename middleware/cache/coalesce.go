@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"github.com/khulnasoft/velocity"
+)
+
+// flight is a request that runs the handler for a missing key, see Config.Coalesce
+type flight struct {
+	// closed when the request landed
+	done chan struct{}
+	// the response to share with the waiting requests, nil if it can't be cached
+	e *item
+	// the request headers the response varies on and their values
+	vary    []string
+	varyKey string
+}
+
+// share shares a copy of the entry of the response with the waiting requests
+func (f *flight) share(c velocity.Ctx, e *item, vary []string) {
+	shared := *e
+	f.e = &shared
+	f.vary = vary
+	f.varyKey = varyKey(c, vary)
+}
+
+// received reports whether the landed flight has a response for the request
+func (f *flight) received(c velocity.Ctx) bool {
+	return f != nil && f.e != nil && varyKey(c, f.vary) == f.varyKey
+}
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// coalesceResult is the response of a concurrent request
+type coalesceResult struct {
+	err    error
+	body   string
+	status string
+}
+
+// concurrentRequests sends n concurrent requests with the header values and returns their responses
+func concurrentRequests(t *testing.T, app *velocity.App, n int, header ...string) []coalesceResult {
+	t.Helper()
+
+	results := make([]coalesceResult, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+			if len(header) > 0 {
+				req.Header.Set(header[0], header[1+i%(len(header)-1)])
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			body, err := io.ReadAll(resp.Body)
+			results[i] = coalesceResult{body: string(body), status: resp.Header.Get("X-Cache"), err: err}
+		}(i)
+	}
+	wg.Wait()
+	for _, result := range results {
+		require.NoError(t, result.err)
+	}
+	return results
+}
+
+// go test -run Test_Cache_Coalesce
+func Test_Cache_Coalesce(t *testing.T) {
+	t.Parallel()
+
+	for name, cfg := range map[string]Config{
+		"memory":   {Coalesce: true},
+		"storage":  {Coalesce: true, Storage: memory.New()},
+		"maxbytes": {Coalesce: true, MaxBytes: 1},
+		"rfc9111":  {Coalesce: true, RFC9111: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			app := velocity.New()
+			app.Use(New(cfg))
+
+			var count atomic.Int32
+			app.Get("/", func(c velocity.Ctx) error {
+				time.Sleep(100 * time.Millisecond)
+				c.Set(velocity.HeaderCacheControl, "max-age=60")
+				return c.SendString("response " + strconv.Itoa(int(count.Add(1))))
+			})
+
+			results := concurrentRequests(t, app, 10)
+			require.Equal(t, int32(1), count.Load())
+
+			statuses := make(map[string]int)
+			for _, result := range results {
+				require.Equal(t, "response 1", result.body)
+				statuses[result.status]++
+			}
+			// The response doesn't fit into MaxBytes, but it is shared with the waiting requests
+			if cfg.MaxBytes > 0 {
+				require.Equal(t, map[string]int{cacheUnreachable: 1, cacheHit: 9}, statuses)
+			} else {
+				require.Equal(t, map[string]int{cacheMiss: 1, cacheHit: 9}, statuses)
+			}
+		})
+	}
+}
+
+// go test -run Test_Cache_Coalesce_Uncacheable
+func Test_Cache_Coalesce_Uncacheable(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{Coalesce: true}))
+
+	var count atomic.Int32
+	app.Get("/", func(c velocity.Ctx) error {
+		time.Sleep(50 * time.Millisecond)
+		count.Add(1)
+		return c.SendStatus(velocity.StatusInternalServerError)
+	})
+
+	// The waiting requests run the handler themselves
+	results := concurrentRequests(t, app, 5)
+	require.Equal(t, int32(5), count.Load())
+	for _, result := range results {
+		require.Equal(t, cacheUnreachable, result.status)
+	}
+}
+
+// go test -run Test_Cache_Coalesce_Timeout
+func Test_Cache_Coalesce_Timeout(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{Coalesce: true, CoalesceTimeout: 10 * time.Millisecond}))
+
+	var count atomic.Int32
+	app.Get("/", func(c velocity.Ctx) error {
+		time.Sleep(200 * time.Millisecond)
+		return c.SendString("response " + strconv.Itoa(int(count.Add(1))))
+	})
+
+	// The waiting requests run the handler themselves after the timeout
+	concurrentRequests(t, app, 5)
+	require.Equal(t, int32(5), count.Load())
+}
+
+// go test -run Test_Cache_Coalesce_Vary
+func Test_Cache_Coalesce_Vary(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{Coalesce: true, RFC9111: true}))
+
+	app.Get("/", func(c velocity.Ctx) error {
+		time.Sleep(100 * time.Millisecond)
+		c.Set(velocity.HeaderCacheControl, "max-age=60")
+		c.Set(velocity.HeaderVary, velocity.HeaderAcceptLanguage)
+		return c.SendString(c.Get(velocity.HeaderAcceptLanguage))
+	})
+
+	// The waiting requests only receive the response if it was selected by the same header values
+	results := concurrentRequests(t, app, 10, velocity.HeaderAcceptLanguage, "en", "de")
+	for i, result := range results {
+		require.Equal(t, []string{"en", "de"}[i%2], result.body)
+	}
+}
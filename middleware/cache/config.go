@@ -70,6 +70,29 @@ type Config struct {
 	//
 	// Default: false
 	StoreResponseHeaders bool
+
+	// Coalesce collapses the concurrent misses of a key into one execution of the handler.
+	// The other requests wait for its response and receive it if it can be cached, even if
+	// it is evicted or doesn't fit into MaxBytes. Otherwise, or if they waited longer than
+	// CoalesceTimeout, they run the handler themselves.
+	//
+	// Optional. Default: false
+	Coalesce bool
+
+	// CoalesceTimeout is the maximum time that a request waits for the response of a concurrent miss
+	//
+	// Optional. Default: 5 * time.Second
+	CoalesceTimeout time.Duration
+
+	// RFC9111 caches the responses with the semantics of a shared cache, see RFC 9111.
+	// The freshness is derived from the s-maxage, max-age and Expires headers of the response,
+	// Expiration and ExpirationGenerator are only used if it has none of them. Responses with
+	// private or no-store are not stored, Vary is respected and stale responses are revalidated
+	// with If-None-Match and If-Modified-Since, or served with stale-while-revalidate and
+	// stale-if-error. The response headers are always stored and CacheControl is ignored.
+	//
+	// Optional. Default: false
+	RFC9111 bool
 }
 
 // ConfigDefault is the default config
@@ -87,6 +110,8 @@ var ConfigDefault = Config{
 	Storage:              nil,
 	MaxBytes:             0,
 	Methods:              []string{velocity.MethodGet, velocity.MethodHead},
+	Coalesce:             false,
+	CoalesceTimeout:      5 * time.Second,
 }
 
 // Helper function to set default values
@@ -115,5 +140,8 @@ func configDefault(config ...Config) Config {
 	if len(cfg.Methods) == 0 {
 		cfg.Methods = ConfigDefault.Methods
 	}
+	if cfg.CoalesceTimeout <= 0 {
+		cfg.CoalesceTimeout = ConfigDefault.CoalesceTimeout
+	}
 	return cfg
 }
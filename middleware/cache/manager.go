@@ -16,12 +16,26 @@ type item struct {
 	body      []byte
 	ctype     []byte
 	cencoding []byte
-	status    int
-	exp       uint64
+	// validators of the response, see Config.RFC9111
+	etag      []byte
+	lmodified []byte
+	// header names of the Vary index of an entry, set instead of the response
+	vary   []string
+	status int
+	exp    uint64
+	// the time the response was stored at and its age at that time
+	date uint64
+	age  uint64
+	// seconds after exp in which the response may be served stale
+	swr uint64
+	sie uint64
 	// used for finding the item in an indexed heap
 	heapidx int
 }
 
+// tagEntries are the keys of the entries of a tag index with the timestamp when they expire
+type tagEntries map[string]uint64
+
 //msgp:ignore manager
 type manager struct {
 	pool    sync.Pool
@@ -64,6 +78,13 @@ func (m *manager) release(e *item) {
 	e.status = 0
 	e.exp = 0
 	e.headers = nil
+	e.etag = nil
+	e.lmodified = nil
+	e.vary = nil
+	e.date = 0
+	e.age = 0
+	e.swr = 0
+	e.sie = 0
 	m.pool.Put(e)
 }
 
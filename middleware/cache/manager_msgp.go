@@ -72,6 +72,37 @@ func (z *item) DecodeMsg(dc *msgp.Reader) (err error) {
 				err = msgp.WrapError(err, "cencoding")
 				return
 			}
+		case "etag":
+			z.etag, err = dc.ReadBytes(z.etag)
+			if err != nil {
+				err = msgp.WrapError(err, "etag")
+				return
+			}
+		case "lmodified":
+			z.lmodified, err = dc.ReadBytes(z.lmodified)
+			if err != nil {
+				err = msgp.WrapError(err, "lmodified")
+				return
+			}
+		case "vary":
+			var zb0003 uint32
+			zb0003, err = dc.ReadArrayHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "vary")
+				return
+			}
+			if cap(z.vary) >= int(zb0003) {
+				z.vary = (z.vary)[:zb0003]
+			} else {
+				z.vary = make([]string, zb0003)
+			}
+			for za0003 := range z.vary {
+				z.vary[za0003], err = dc.ReadString()
+				if err != nil {
+					err = msgp.WrapError(err, "vary", za0003)
+					return
+				}
+			}
 		case "status":
 			z.status, err = dc.ReadInt()
 			if err != nil {
@@ -84,6 +115,30 @@ func (z *item) DecodeMsg(dc *msgp.Reader) (err error) {
 				err = msgp.WrapError(err, "exp")
 				return
 			}
+		case "date":
+			z.date, err = dc.ReadUint64()
+			if err != nil {
+				err = msgp.WrapError(err, "date")
+				return
+			}
+		case "age":
+			z.age, err = dc.ReadUint64()
+			if err != nil {
+				err = msgp.WrapError(err, "age")
+				return
+			}
+		case "swr":
+			z.swr, err = dc.ReadUint64()
+			if err != nil {
+				err = msgp.WrapError(err, "swr")
+				return
+			}
+		case "sie":
+			z.sie, err = dc.ReadUint64()
+			if err != nil {
+				err = msgp.WrapError(err, "sie")
+				return
+			}
 		case "heapidx":
 			z.heapidx, err = dc.ReadInt()
 			if err != nil {
@@ -103,9 +158,9 @@ func (z *item) DecodeMsg(dc *msgp.Reader) (err error) {
 
 // EncodeMsg implements msgp.Encodable
 func (z *item) EncodeMsg(en *msgp.Writer) (err error) {
-	// map header, size 7
+	// map header, size 14
 	// write "headers"
-	err = en.Append(0x87, 0xa7, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73)
+	err = en.Append(0x8e, 0xa7, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73)
 	if err != nil {
 		return
 	}
@@ -156,6 +211,43 @@ func (z *item) EncodeMsg(en *msgp.Writer) (err error) {
 		err = msgp.WrapError(err, "cencoding")
 		return
 	}
+	// write "etag"
+	err = en.Append(0xa4, 0x65, 0x74, 0x61, 0x67)
+	if err != nil {
+		return
+	}
+	err = en.WriteBytes(z.etag)
+	if err != nil {
+		err = msgp.WrapError(err, "etag")
+		return
+	}
+	// write "lmodified"
+	err = en.Append(0xa9, 0x6c, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64)
+	if err != nil {
+		return
+	}
+	err = en.WriteBytes(z.lmodified)
+	if err != nil {
+		err = msgp.WrapError(err, "lmodified")
+		return
+	}
+	// write "vary"
+	err = en.Append(0xa4, 0x76, 0x61, 0x72, 0x79)
+	if err != nil {
+		return
+	}
+	err = en.WriteArrayHeader(uint32(len(z.vary)))
+	if err != nil {
+		err = msgp.WrapError(err, "vary")
+		return
+	}
+	for za0003 := range z.vary {
+		err = en.WriteString(z.vary[za0003])
+		if err != nil {
+			err = msgp.WrapError(err, "vary", za0003)
+			return
+		}
+	}
 	// write "status"
 	err = en.Append(0xa6, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73)
 	if err != nil {
@@ -176,6 +268,46 @@ func (z *item) EncodeMsg(en *msgp.Writer) (err error) {
 		err = msgp.WrapError(err, "exp")
 		return
 	}
+	// write "date"
+	err = en.Append(0xa4, 0x64, 0x61, 0x74, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteUint64(z.date)
+	if err != nil {
+		err = msgp.WrapError(err, "date")
+		return
+	}
+	// write "age"
+	err = en.Append(0xa3, 0x61, 0x67, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteUint64(z.age)
+	if err != nil {
+		err = msgp.WrapError(err, "age")
+		return
+	}
+	// write "swr"
+	err = en.Append(0xa3, 0x73, 0x77, 0x72)
+	if err != nil {
+		return
+	}
+	err = en.WriteUint64(z.swr)
+	if err != nil {
+		err = msgp.WrapError(err, "swr")
+		return
+	}
+	// write "sie"
+	err = en.Append(0xa3, 0x73, 0x69, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteUint64(z.sie)
+	if err != nil {
+		err = msgp.WrapError(err, "sie")
+		return
+	}
 	// write "heapidx"
 	err = en.Append(0xa7, 0x68, 0x65, 0x61, 0x70, 0x69, 0x64, 0x78)
 	if err != nil {
@@ -192,9 +324,9 @@ func (z *item) EncodeMsg(en *msgp.Writer) (err error) {
 // MarshalMsg implements msgp.Marshaler
 func (z *item) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 7
+	// map header, size 14
 	// string "headers"
-	o = append(o, 0x87, 0xa7, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73)
+	o = append(o, 0x8e, 0xa7, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73)
 	o = msgp.AppendMapHeader(o, uint32(len(z.headers)))
 	for za0001, za0002 := range z.headers {
 		o = msgp.AppendString(o, za0001)
@@ -209,12 +341,36 @@ func (z *item) MarshalMsg(b []byte) (o []byte, err error) {
 	// string "cencoding"
 	o = append(o, 0xa9, 0x63, 0x65, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67)
 	o = msgp.AppendBytes(o, z.cencoding)
+	// string "etag"
+	o = append(o, 0xa4, 0x65, 0x74, 0x61, 0x67)
+	o = msgp.AppendBytes(o, z.etag)
+	// string "lmodified"
+	o = append(o, 0xa9, 0x6c, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64)
+	o = msgp.AppendBytes(o, z.lmodified)
+	// string "vary"
+	o = append(o, 0xa4, 0x76, 0x61, 0x72, 0x79)
+	o = msgp.AppendArrayHeader(o, uint32(len(z.vary)))
+	for za0003 := range z.vary {
+		o = msgp.AppendString(o, z.vary[za0003])
+	}
 	// string "status"
 	o = append(o, 0xa6, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73)
 	o = msgp.AppendInt(o, z.status)
 	// string "exp"
 	o = append(o, 0xa3, 0x65, 0x78, 0x70)
 	o = msgp.AppendUint64(o, z.exp)
+	// string "date"
+	o = append(o, 0xa4, 0x64, 0x61, 0x74, 0x65)
+	o = msgp.AppendUint64(o, z.date)
+	// string "age"
+	o = append(o, 0xa3, 0x61, 0x67, 0x65)
+	o = msgp.AppendUint64(o, z.age)
+	// string "swr"
+	o = append(o, 0xa3, 0x73, 0x77, 0x72)
+	o = msgp.AppendUint64(o, z.swr)
+	// string "sie"
+	o = append(o, 0xa3, 0x73, 0x69, 0x65)
+	o = msgp.AppendUint64(o, z.sie)
 	// string "heapidx"
 	o = append(o, 0xa7, 0x68, 0x65, 0x61, 0x70, 0x69, 0x64, 0x78)
 	o = msgp.AppendInt(o, z.heapidx)
@@ -287,6 +443,37 @@ func (z *item) UnmarshalMsg(bts []byte) (o []byte, err error) {
 				err = msgp.WrapError(err, "cencoding")
 				return
 			}
+		case "etag":
+			z.etag, bts, err = msgp.ReadBytesBytes(bts, z.etag)
+			if err != nil {
+				err = msgp.WrapError(err, "etag")
+				return
+			}
+		case "lmodified":
+			z.lmodified, bts, err = msgp.ReadBytesBytes(bts, z.lmodified)
+			if err != nil {
+				err = msgp.WrapError(err, "lmodified")
+				return
+			}
+		case "vary":
+			var zb0003 uint32
+			zb0003, bts, err = msgp.ReadArrayHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "vary")
+				return
+			}
+			if cap(z.vary) >= int(zb0003) {
+				z.vary = (z.vary)[:zb0003]
+			} else {
+				z.vary = make([]string, zb0003)
+			}
+			for za0003 := range z.vary {
+				z.vary[za0003], bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "vary", za0003)
+					return
+				}
+			}
 		case "status":
 			z.status, bts, err = msgp.ReadIntBytes(bts)
 			if err != nil {
@@ -299,6 +486,30 @@ func (z *item) UnmarshalMsg(bts []byte) (o []byte, err error) {
 				err = msgp.WrapError(err, "exp")
 				return
 			}
+		case "date":
+			z.date, bts, err = msgp.ReadUint64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "date")
+				return
+			}
+		case "age":
+			z.age, bts, err = msgp.ReadUint64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "age")
+				return
+			}
+		case "swr":
+			z.swr, bts, err = msgp.ReadUint64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "swr")
+				return
+			}
+		case "sie":
+			z.sie, bts, err = msgp.ReadUint64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "sie")
+				return
+			}
 		case "heapidx":
 			z.heapidx, bts, err = msgp.ReadIntBytes(bts)
 			if err != nil {
@@ -326,6 +537,128 @@ func (z *item) Msgsize() (s int) {
 			s += msgp.StringPrefixSize + len(za0001) + msgp.BytesPrefixSize + len(za0002)
 		}
 	}
-	s += 5 + msgp.BytesPrefixSize + len(z.body) + 6 + msgp.BytesPrefixSize + len(z.ctype) + 10 + msgp.BytesPrefixSize + len(z.cencoding) + 7 + msgp.IntSize + 4 + msgp.Uint64Size + 8 + msgp.IntSize
+	s += 5 + msgp.BytesPrefixSize + len(z.body) + 6 + msgp.BytesPrefixSize + len(z.ctype) + 10 + msgp.BytesPrefixSize + len(z.cencoding) + 5 + msgp.BytesPrefixSize + len(z.etag) + 10 + msgp.BytesPrefixSize + len(z.lmodified) + 5 + msgp.ArrayHeaderSize
+	for za0003 := range z.vary {
+		s += msgp.StringPrefixSize + len(z.vary[za0003])
+	}
+	s += 7 + msgp.IntSize + 4 + msgp.Uint64Size + 5 + msgp.Uint64Size + 4 + msgp.Uint64Size + 4 + msgp.Uint64Size + 4 + msgp.Uint64Size + 8 + msgp.IntSize
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *tagEntries) DecodeMsg(dc *msgp.Reader) (err error) {
+	var zb0003 uint32
+	zb0003, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	if (*z) == nil {
+		(*z) = make(tagEntries, zb0003)
+	} else if len((*z)) > 0 {
+		for key := range *z {
+			delete((*z), key)
+		}
+	}
+	var field []byte
+	_ = field
+	for zb0003 > 0 {
+		zb0003--
+		var zb0001 string
+		var zb0002 uint64
+		zb0001, err = dc.ReadString()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		zb0002, err = dc.ReadUint64()
+		if err != nil {
+			err = msgp.WrapError(err, zb0001)
+			return
+		}
+		(*z)[zb0001] = zb0002
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z tagEntries) EncodeMsg(en *msgp.Writer) (err error) {
+	err = en.WriteMapHeader(uint32(len(z)))
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0004, zb0005 := range z {
+		err = en.WriteString(zb0004)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		err = en.WriteUint64(zb0005)
+		if err != nil {
+			err = msgp.WrapError(err, zb0004)
+			return
+		}
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z tagEntries) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	o = msgp.AppendMapHeader(o, uint32(len(z)))
+	for zb0004, zb0005 := range z {
+		o = msgp.AppendString(o, zb0004)
+		o = msgp.AppendUint64(o, zb0005)
+	}
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *tagEntries) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var zb0003 uint32
+	zb0003, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	if (*z) == nil {
+		(*z) = make(tagEntries, zb0003)
+	} else if len((*z)) > 0 {
+		for key := range *z {
+			delete((*z), key)
+		}
+	}
+	var field []byte
+	_ = field
+	for zb0003 > 0 {
+		var zb0001 string
+		var zb0002 uint64
+		zb0003--
+		zb0001, bts, err = msgp.ReadStringBytes(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		zb0002, bts, err = msgp.ReadUint64Bytes(bts)
+		if err != nil {
+			err = msgp.WrapError(err, zb0001)
+			return
+		}
+		(*z)[zb0001] = zb0002
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z tagEntries) Msgsize() (s int) {
+	s = msgp.MapHeaderSize
+	if z != nil {
+		for zb0004, zb0005 := range z {
+			_ = zb0005
+			s += msgp.StringPrefixSize + len(zb0004) + msgp.Uint64Size
+		}
+	}
 	return
 }
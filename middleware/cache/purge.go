@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/shared"
+	"github.com/khulnasoft/velocity/utils"
+)
+
+// The keys of the tag indexes in the storage
+const (
+	indexPrefix = "cache_index:"
+	tagIndex    = indexPrefix + "tag:"
+	routeIndex  = indexPrefix + "route:"
+	prefixIndex = indexPrefix + "prefix:"
+	shardsIndex = indexPrefix + "shards"
+)
+
+// indexParts is the number of parts an index is split into, a key is added to one of them,
+// so that only that part is decoded and encoded
+const indexParts = 64
+
+// Tag attaches tags to the response, e.g. the ids of the records it shows, so that
+// the cached response can be purged by them with Purger.PurgeTag
+func Tag(c velocity.Ctx, tags ...string) {
+	existing, _ := c.Locals(tagsKey).([]string) //nolint:errcheck // nil if not set
+	for _, tag := range tags {
+		existing = append(existing, utils.CopyString(tag))
+	}
+	c.Locals(tagsKey, existing)
+}
+
+// Purger purges the responses that were cached by a cache middleware, see NewWithPurger
+type Purger struct {
+	index *indexes
+	// deletes the entries with the keys
+	purge func(keys []string)
+}
+
+// PurgeTag purges the cached responses that were tagged with any of the tags, see Tag
+func (p *Purger) PurgeTag(tags ...string) error {
+	for _, tag := range tags {
+		if err := p.purgeIndex(tagIndex+tag, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeRoute purges the cached responses of the requests that matched the route with the path,
+// e.g. "/articles/:id"
+func (p *Purger) PurgeRoute(path string) error {
+	return p.purgeIndex(routeIndex+path, nil)
+}
+
+// PurgePrefix purges the cached responses whose key starts with the prefix, the key is the
+// output of the KeyGenerator. The keys are indexed by their first path segment, so a prefix
+// that contains a whole segment, e.g. "/articles/", only reads the index of that segment.
+func (p *Purger) PurgePrefix(prefix string) error {
+	match := func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	}
+	if prefix != "" && (prefix[0] != '/' || strings.ContainsAny(prefix[1:], "/?")) {
+		return p.purgeIndex(prefixIndex+prefixShard(prefix), match)
+	}
+
+	// The prefix matches the keys of several segments
+	if p.index == nil {
+		return nil
+	}
+	var shards []string
+	for n := 0; n < indexParts; n++ {
+		var found []string
+		err := p.index.update(indexPart(shardsIndex, n), func(entries tagEntries) tagEntries {
+			found = found[:0]
+			for shard := range entries {
+				if strings.HasPrefix(shard, prefix) {
+					found = append(found, shard)
+				}
+			}
+			return entries
+		})
+		if err != nil {
+			return err
+		}
+		shards = append(shards, found...)
+	}
+	for _, shard := range shards {
+		if err := p.purgeIndex(prefixIndex+shard, match); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefixShard returns the first path segment of the key, e.g. "/articles" for "/articles/1",
+// the keys that are no path share a single shard
+func prefixShard(key string) string {
+	if key == "" || key[0] != '/' {
+		return ""
+	}
+	if i := strings.IndexAny(key[1:], "/?"); i >= 0 {
+		return key[:i+1]
+	}
+	return key
+}
+
+// purgeIndex purges the entries of the index that match, all if match is nil
+func (p *Purger) purgeIndex(name string, match func(key string) bool) error {
+	if p.index == nil {
+		return nil
+	}
+	for n := 0; n < indexParts; n++ {
+		var keys []string
+		err := p.index.update(indexPart(name, n), func(entries tagEntries) tagEntries {
+			keys = keys[:0]
+			for key := range entries {
+				if match == nil || match(key) {
+					keys = append(keys, key)
+					delete(entries, key)
+				}
+			}
+			return entries
+		})
+		if err != nil {
+			return err
+		}
+		p.purge(keys)
+	}
+	return nil
+}
+
+// indexes are the tag indexes of the cache, which store the keys of the entries of a tag,
+// a route or a path segment. They are stored in the storage of the entries, so that the
+// responses can be purged by all instances that share it. Every index is split into
+// indexParts parts by the hash of the keys, the parts are updated under their own locks.
+type indexes struct {
+	manager  *manager
+	updaters [indexParts]*shared.Updater
+	locks    [indexParts]sync.Mutex
+}
+
+func newIndexes(manager *manager) *indexes {
+	i := &indexes{manager: manager}
+	if manager.storage != nil {
+		for n := range i.updaters {
+			i.updaters[n] = shared.NewUpdater(manager.storage)
+		}
+	}
+	return i
+}
+
+// add adds the key of an entry that expires at the timestamp to the indexes
+func (i *indexes) add(key string, exp uint64, names []string) error {
+	part := int(hash(key) % indexParts)
+	for _, name := range names {
+		err := i.update(indexPart(name, part), func(entries tagEntries) tagEntries {
+			entries[key] = max(entries[key], exp)
+			return entries
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexPart returns the name of the part of the index
+func indexPart(name string, part int) string {
+	return name + "#" + strconv.Itoa(part)
+}
+
+// update calls fn with the entries of the part of an index, the expired ones are removed,
+// and stores the entries that it returns. fn is called again if the part was changed
+// by another instance in the meantime.
+func (i *indexes) update(name string, fn func(entries tagEntries) tagEntries) error {
+	n := hash(name) % indexParts
+	if i.updaters[n] == nil {
+		i.locks[n].Lock()
+		defer i.locks[n].Unlock()
+
+		entries, ok := i.manager.memory.Get(name).(tagEntries)
+		if !ok {
+			entries = make(tagEntries)
+		}
+		entries, expiration := expireEntries(fn(entries))
+		if len(entries) == 0 {
+			i.manager.memory.Delete(name)
+		} else {
+			i.manager.memory.Set(name, entries, expiration)
+		}
+		return nil
+	}
+
+	err := i.updaters[n].Update(name, func(old []byte) ([]byte, time.Duration, error) {
+		entries := make(tagEntries)
+		if old != nil {
+			if _, err := entries.UnmarshalMsg(old); err != nil {
+				return nil, 0, fmt.Errorf("failed to decode: %w", err)
+			}
+		}
+		entries, expiration := expireEntries(fn(entries))
+		if len(entries) == 0 {
+			return nil, 0, nil
+		}
+		raw, err := entries.MarshalMsg(nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to encode: %w", err)
+		}
+		return raw, expiration, nil
+	})
+	if err != nil {
+		return fmt.Errorf("cache: failed to update index: %w", err)
+	}
+	return nil
+}
+
+// expireEntries removes the keys of the expired entries and returns the expiration of the index
+func expireEntries(entries tagEntries) (tagEntries, time.Duration) {
+	now := uint64(time.Now().Unix()) //nolint:gosec // Not a concern
+	var exp uint64
+	for key, keyExp := range entries {
+		if keyExp <= now {
+			delete(entries, key)
+			continue
+		}
+		exp = max(exp, keyExp)
+	}
+	return entries, time.Duration(exp-min(exp, now)) * time.Second //nolint:gosec // Not a concern
+}
+
+// hash returns the FNV-1a hash of the string, it is the same in all instances
+func hash(s string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+	return h
+}
@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
+	"github.com/khulnasoft/velocity/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+// purgeStorages are the storages the purging is tested with
+var purgeStorages = map[string]func() velocity.Storage{
+	"default": func() velocity.Storage { return nil },
+	"atomic":  func() velocity.Storage { return memory.New() },
+	"plain":   func() velocity.Storage { return storagetest.NonAtomic(memory.New()) },
+}
+
+// newPurgeApp returns an app with a cached list of articles and cached articles
+func newPurgeApp(t *testing.T, storage velocity.Storage) (*velocity.App, *Purger, *atomic.Int32) {
+	t.Helper()
+
+	handler, purger := NewWithPurger(Config{Storage: storage})
+
+	app := velocity.New()
+	app.Use(handler)
+
+	var count atomic.Int32
+	app.Get("/articles", func(c velocity.Ctx) error {
+		Tag(c, "article:1", "article:2")
+		return c.SendString(strconv.Itoa(int(count.Add(1))))
+	})
+	app.Get("/articles/:id", func(c velocity.Ctx) error {
+		Tag(c, "article:"+c.Params("id"))
+		return c.SendString(strconv.Itoa(int(count.Add(1))))
+	})
+	app.Get("/users/:id", func(c velocity.Ctx) error {
+		return c.SendString(strconv.Itoa(int(count.Add(1))))
+	})
+	return app, purger, &count
+}
+
+// requireCached asserts the cache status of the paths
+func requireCached(t *testing.T, app *velocity.App, cached bool, paths ...string) {
+	t.Helper()
+	status := cacheMiss
+	if cached {
+		status = cacheHit
+	}
+	for _, path := range paths {
+		resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, path, nil))
+		require.NoError(t, err)
+		require.Equal(t, status, resp.Header.Get("X-Cache"), path)
+	}
+}
+
+// go test -run Test_Cache_PurgeTag
+func Test_Cache_PurgeTag(t *testing.T) {
+	t.Parallel()
+
+	for name, storage := range purgeStorages {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			app, purger, _ := newPurgeApp(t, storage())
+
+			requireCached(t, app, false, "/articles", "/articles/1", "/articles/2", "/users/1")
+			requireCached(t, app, true, "/articles", "/articles/1", "/articles/2", "/users/1")
+
+			require.NoError(t, purger.PurgeTag("article:1"))
+			requireCached(t, app, false, "/articles", "/articles/1")
+			requireCached(t, app, true, "/articles/2", "/users/1")
+
+			// The purged responses are cached again with their tags
+			requireCached(t, app, true, "/articles", "/articles/1")
+			require.NoError(t, purger.PurgeTag("article:2", "article:3"))
+			requireCached(t, app, false, "/articles", "/articles/2")
+			requireCached(t, app, true, "/articles/1", "/users/1")
+		})
+	}
+}
+
+// go test -run Test_Cache_PurgeRoute
+func Test_Cache_PurgeRoute(t *testing.T) {
+	t.Parallel()
+
+	for name, storage := range purgeStorages {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			app, purger, _ := newPurgeApp(t, storage())
+
+			requireCached(t, app, false, "/articles", "/articles/1", "/articles/2", "/users/1")
+
+			require.NoError(t, purger.PurgeRoute("/articles/:id"))
+			requireCached(t, app, false, "/articles/1", "/articles/2")
+			requireCached(t, app, true, "/articles", "/users/1")
+		})
+	}
+}
+
+// go test -run Test_Cache_PurgePrefix
+func Test_Cache_PurgePrefix(t *testing.T) {
+	t.Parallel()
+
+	for name, storage := range purgeStorages {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			app, purger, count := newPurgeApp(t, storage())
+
+			requireCached(t, app, false, "/articles", "/articles/1", "/articles/2", "/users/1")
+
+			require.NoError(t, purger.PurgePrefix("/articles/"))
+			requireCached(t, app, false, "/articles/1", "/articles/2")
+			requireCached(t, app, true, "/articles", "/users/1")
+
+			// The prefix spans the keys of several path segments
+			require.NoError(t, purger.PurgePrefix("/art"))
+			requireCached(t, app, false, "/articles", "/articles/1")
+			requireCached(t, app, true, "/users/1")
+
+			require.NoError(t, purger.PurgePrefix("/"))
+			requireCached(t, app, false, "/articles", "/articles/1", "/articles/2", "/users/1")
+			require.Equal(t, int32(12), count.Load())
+		})
+	}
+}
+
+// go test -run Test_Cache_New_NoIndexes
+func Test_Cache_New_NoIndexes(t *testing.T) {
+	t.Parallel()
+
+	storage := memory.New()
+	app := velocity.New()
+	app.Use(New(Config{Storage: storage}))
+	app.Get("/articles/:id", func(c velocity.Ctx) error {
+		Tag(c, "article:"+c.Params("id"))
+		return c.SendString(c.Params("id"))
+	})
+
+	requireCached(t, app, false, "/articles/1")
+	requireCached(t, app, true, "/articles/1")
+
+	// Only the purgeable handler maintains the indexes
+	keys, err := storage.Keys()
+	require.NoError(t, err)
+	require.NotEmpty(t, keys)
+	for _, key := range keys {
+		require.False(t, strings.HasPrefix(string(key), indexPrefix), string(key))
+	}
+}
+
+// go test -run Test_Cache_Purge_IndexParts
+func Test_Cache_Purge_IndexParts(t *testing.T) {
+	t.Parallel()
+
+	storage := memory.New()
+	app, purger, _ := newPurgeApp(t, storage)
+
+	paths := make([]string, 200)
+	for i := range paths {
+		paths[i] = "/articles/" + strconv.Itoa(i)
+	}
+	requireCached(t, app, false, paths...)
+
+	// The keys of the route are spread over the parts of its index
+	var parts, indexed int
+	for n := 0; n < indexParts; n++ {
+		raw, err := storage.Get(indexPart(routeIndex+"/articles/:id", n))
+		require.NoError(t, err)
+		if raw == nil {
+			continue
+		}
+		entries := make(tagEntries)
+		_, err = entries.UnmarshalMsg(raw)
+		require.NoError(t, err)
+		parts++
+		indexed += len(entries)
+	}
+	require.Greater(t, parts, indexParts/2)
+	require.Equal(t, len(paths), indexed)
+
+	require.NoError(t, purger.PurgeRoute("/articles/:id"))
+	requireCached(t, app, false, paths...)
+}
+
+// go test -run Test_Cache_Purge_IndexError
+func Test_Cache_Purge_IndexError(t *testing.T) {
+	t.Parallel()
+
+	app, _, _ := newPurgeApp(t, failingIndexStorage{memory.New()})
+
+	// The response is not kept if it can't be indexed, it couldn't be purged
+	requireCached(t, app, false, "/articles/1", "/articles/1")
+}
+
+// failingIndexStorage fails to store the indexes, it doesn't implement velocity.AtomicStorage
+type failingIndexStorage struct {
+	velocity.Storage
+}
+
+func (s failingIndexStorage) Set(key string, val []byte, exp time.Duration) error {
+	if strings.HasPrefix(key, indexPrefix) {
+		return errors.New("index unavailable")
+	}
+	return s.Storage.Set(key, val, exp)
+}
+
+// go test -run Test_Cache_Purge_MaxBytes
+func Test_Cache_Purge_MaxBytes(t *testing.T) {
+	t.Parallel()
+
+	handler, purger := NewWithPurger(Config{MaxBytes: 2})
+
+	app := velocity.New()
+	app.Use(handler)
+	app.Get("/:id", func(c velocity.Ctx) error {
+		Tag(c, c.Params("id"))
+		return c.SendString("1")
+	})
+
+	requireCached(t, app, false, "/a", "/b")
+	require.NoError(t, purger.PurgeTag("a"))
+
+	// The purged response doesn't count towards the stored bytes
+	requireCached(t, app, false, "/c")
+	requireCached(t, app, true, "/b", "/c")
+}
+
+// go test -run Test_Cache_Purger_Disabled
+func Test_Cache_Purger_Disabled(t *testing.T) {
+	t.Parallel()
+
+	_, purger := NewWithPurger(Config{Expiration: -time.Second})
+	require.NoError(t, purger.PurgeTag("a"))
+	require.NoError(t, purger.PurgePrefix("/"))
+	require.NoError(t, purger.PurgeRoute("/"))
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/utils"
+	"github.com/valyala/fasthttp"
+)
+
+// cache status of Config.RFC9111
+// stale: a stale response is served, because it is revalidated in the background or the server failed
+// revalidated: the stale response was confirmed by the handler with a 304 response
+const (
+	cacheStale       = "stale"
+	cacheRevalidated = "revalidated"
+)
+
+// cacheControl are the directives of a Cache-Control header, see RFC 9111 section 5.2.
+// The delta-seconds are -1 if the directive is missing.
+type cacheControl struct {
+	maxAge               int64
+	sMaxAge              int64
+	staleWhileRevalidate int64
+	staleIfError         int64
+	noCache              bool
+	noStore              bool
+	private              bool
+	public               bool
+	mustRevalidate       bool
+	onlyIfCached         bool
+}
+
+// parseCacheControl parses the directives of a Cache-Control header, unknown directives are ignored
+func parseCacheControl(header string) cacheControl {
+	cc := cacheControl{maxAge: -1, sMaxAge: -1, staleWhileRevalidate: -1, staleIfError: -1}
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		switch utils.ToLower(strings.TrimSpace(name)) {
+		case "max-age":
+			cc.maxAge = parseDeltaSeconds(value)
+		case "s-maxage":
+			cc.sMaxAge = parseDeltaSeconds(value)
+		case "stale-while-revalidate":
+			cc.staleWhileRevalidate = parseDeltaSeconds(value)
+		case "stale-if-error":
+			cc.staleIfError = parseDeltaSeconds(value)
+		case noCache:
+			cc.noCache = true
+		case noStore:
+			cc.noStore = true
+		case "private":
+			cc.private = true
+		case "public":
+			cc.public = true
+		case "must-revalidate", "proxy-revalidate":
+			cc.mustRevalidate = true
+		case "only-if-cached":
+			cc.onlyIfCached = true
+		}
+	}
+	return cc
+}
+
+// maxDeltaSeconds is the largest delta-seconds value, larger values are treated as it ( RFC 9111 section 1.2.2 )
+const maxDeltaSeconds = 1 << 31
+
+// parseDeltaSeconds returns the seconds of a directive, an invalid value is treated as 0
+func parseDeltaSeconds(value string) int64 {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) && seconds > 0 {
+			return maxDeltaSeconds
+		}
+		return 0
+	}
+	return min(max(seconds, 0), maxDeltaSeconds)
+}
+
+// freshness returns the freshness lifetime of the response in seconds and whether it has an explicit
+// expiration time, with the precedence of RFC 9111 section 4.2.1 for a shared cache
+func freshness(res *fasthttp.Response, cc cacheControl, now uint64) (uint64, bool) {
+	switch {
+	case cc.sMaxAge >= 0:
+		return uint64(cc.sMaxAge), true
+	case cc.maxAge >= 0:
+		return uint64(cc.maxAge), true
+	}
+	expires := res.Header.Peek(velocity.HeaderExpires)
+	if len(expires) == 0 {
+		return 0, false
+	}
+	// An invalid date, e.g. "0", represents a time in the past
+	exp, err := fasthttp.ParseHTTPDate(expires)
+	if err != nil {
+		return 0, true
+	}
+	date := int64(now) //nolint:gosec // Not a concern
+	if d, err := fasthttp.ParseHTTPDate(res.Header.Peek(velocity.HeaderDate)); err == nil {
+		date = d.Unix()
+	}
+	return uint64(min(max(exp.Unix()-date, 0), maxDeltaSeconds)), true
+}
+
+// initialAge returns the age of the response when it is received, the larger
+// of its Age header and the time since its Date header ( RFC 9111 section 4.2.3 )
+func initialAge(res *fasthttp.Response, now uint64) uint64 {
+	var age uint64
+	if v, err := strconv.ParseUint(string(res.Header.Peek(velocity.HeaderAge)), 10, 64); err == nil {
+		age = v
+	}
+	if date, err := fasthttp.ParseHTTPDate(res.Header.Peek(velocity.HeaderDate)); err == nil && date.Unix() > 0 {
+		age = max(age, now-min(uint64(date.Unix()), now))
+	}
+	return age
+}
+
+// parseVary returns the canonical header names of a Vary header, "*" if the response varies on
+// anything else than the request headers and can't be served from the cache
+func parseVary(header string) []string {
+	var names []string
+	for _, name := range strings.Split(header, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name == "*" {
+			return []string{"*"}
+		}
+		names = append(names, utils.ToLower(name))
+	}
+	return names
+}
+
+// varyKey returns the suffix of the key of the response that was selected by the values of the request headers
+func varyKey(c velocity.Ctx, names []string) string {
+	var key strings.Builder
+	for _, name := range names {
+		key.WriteString("_")
+		key.WriteString(name)
+		key.WriteString("=")
+		key.WriteString(strconv.Quote(c.Get(name)))
+	}
+	return key.String()
+}
+
+// storable reports whether a shared cache may store the response to the request ( RFC 9111 section 3 )
+func storable(c velocity.Ctx, cc cacheControl, explicit bool) bool {
+	if cc.noStore || cc.private {
+		return false
+	}
+	// The response of an authorized request is only shared if it allows it ( RFC 9111 section 3.5 )
+	if c.Get(velocity.HeaderAuthorization) != "" && !cc.public && !cc.mustRevalidate && cc.sMaxAge < 0 {
+		return false
+	}
+	status := c.Response().StatusCode()
+	// Only the heuristically cacheable status codes may be cached without an explicit expiration time
+	return cacheableStatusCodes[status] || (explicit && status >= velocity.StatusOK && status != velocity.StatusNotModified)
+}
+
+// retention returns how long the entry is kept after it is stored: while it is fresh or may be
+// served stale, and if it has validators for another freshness lifetime, but at least the
+// expiration, to be revalidated
+func retention(e *item, expiration time.Duration) time.Duration {
+	seconds := e.exp - e.date + max(e.swr, e.sie)
+	if len(e.etag) > 0 || len(e.lmodified) > 0 {
+		seconds += max(uint64(expiration.Seconds()), e.exp-e.date)
+	}
+	return time.Duration(seconds) * time.Second //nolint:gosec // Not a concern
+}
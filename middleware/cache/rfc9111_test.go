@@ -0,0 +1,441 @@
+package cache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// doRequest sends the request and returns the response and its body
+func doRequest(t *testing.T, app *velocity.App, req *http.Request) (*http.Response, string) {
+	t.Helper()
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+// go test -run Test_parseCacheControl
+func Test_parseCacheControl(t *testing.T) {
+	t.Parallel()
+
+	cc := parseCacheControl(`public, Max-Age=60, s-maxage="120", stale-while-revalidate=30, stale-if-error=abc, must-revalidate`)
+	require.Equal(t, cacheControl{
+		maxAge:               60,
+		sMaxAge:              120,
+		staleWhileRevalidate: 30,
+		staleIfError:         0,
+		public:               true,
+		mustRevalidate:       true,
+	}, cc)
+
+	cc = parseCacheControl("no-cache, no-store, private, only-if-cached, max-age=99999999999999999999")
+	require.Equal(t, cacheControl{
+		maxAge:               maxDeltaSeconds,
+		sMaxAge:              -1,
+		staleWhileRevalidate: -1,
+		staleIfError:         -1,
+		noCache:              true,
+		noStore:              true,
+		private:              true,
+		onlyIfCached:         true,
+	}, cc)
+}
+
+// go test -run Test_Cache_RFC9111_Freshness
+func Test_Cache_RFC9111_Freshness(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{RFC9111: true, Expiration: time.Hour}))
+
+	var count atomic.Int32
+	app.Get("/:cc", func(c velocity.Ctx) error {
+		c.Set(velocity.HeaderCacheControl, c.Params("cc"))
+		return c.SendString(strconv.Itoa(int(count.Add(1))))
+	})
+	app.Get("/expires/:offset", func(c velocity.Ctx) error {
+		offset, err := strconv.Atoi(c.Params("offset"))
+		require.NoError(t, err)
+		c.Set(velocity.HeaderExpires, time.Now().Add(time.Duration(offset)*time.Second).UTC().Format(http.TimeFormat))
+		return c.SendString(strconv.Itoa(int(count.Add(1))))
+	})
+
+	for _, tc := range []struct {
+		path   string
+		cached bool
+	}{
+		{"/max-age=60", true},
+		{"/max-age=0", false},
+		{"/s-maxage=0,max-age=60", false},
+		{"/s-maxage=60,max-age=0", true},
+		{"/no-cache", false},
+		{"/expires/60", true},
+		{"/expires/-60", false},
+	} {
+		_, first := doRequest(t, app, httptest.NewRequest(velocity.MethodGet, tc.path, nil))
+
+		resp, second := doRequest(t, app, httptest.NewRequest(velocity.MethodGet, tc.path, nil))
+		if tc.cached {
+			require.Equal(t, cacheHit, resp.Header.Get("X-Cache"), tc.path)
+			require.Equal(t, first, second, tc.path)
+			require.Equal(t, "0", resp.Header.Get(velocity.HeaderAge), tc.path)
+		} else {
+			require.NotEqual(t, cacheHit, resp.Header.Get("X-Cache"), tc.path)
+			require.NotEqual(t, first, second, tc.path)
+		}
+	}
+
+	// The heuristic freshness lifetime is the expiration
+	resp, first := doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/public", nil))
+	require.Equal(t, cacheMiss, resp.Header.Get("X-Cache"))
+	resp, second := doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/public", nil))
+	require.Equal(t, cacheHit, resp.Header.Get("X-Cache"))
+	require.Equal(t, first, second)
+	require.Equal(t, "public", resp.Header.Get(velocity.HeaderCacheControl))
+}
+
+// go test -run Test_Cache_RFC9111_Age
+func Test_Cache_RFC9111_Age(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{RFC9111: true}))
+
+	app.Get("/", func(c velocity.Ctx) error {
+		c.Set(velocity.HeaderCacheControl, "max-age=3")
+		// The response was already cached by an upstream cache for 2 seconds
+		c.Set(velocity.HeaderAge, "2")
+		return c.SendString("Hello, World!")
+	})
+
+	resp, _ := doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.Equal(t, cacheMiss, resp.Header.Get("X-Cache"))
+
+	resp, _ = doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.Equal(t, cacheHit, resp.Header.Get("X-Cache"))
+	require.Equal(t, "2", resp.Header.Get(velocity.HeaderAge))
+
+	// The client requires a response that is at most a second old
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set(velocity.HeaderCacheControl, "max-age=1")
+	resp, _ = doRequest(t, app, req)
+	require.Equal(t, cacheMiss, resp.Header.Get("X-Cache"))
+
+	// The response is stale after a second
+	time.Sleep(2 * time.Second)
+
+	resp, _ = doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.Equal(t, cacheMiss, resp.Header.Get("X-Cache"))
+}
+
+// go test -run Test_Cache_RFC9111_NotStorable
+func Test_Cache_RFC9111_NotStorable(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{RFC9111: true}))
+
+	app.Get("/*", func(c velocity.Ctx) error {
+		c.Set(velocity.HeaderCacheControl, c.Query("cc"))
+		c.Set(velocity.HeaderVary, c.Query("vary"))
+		return c.SendString("Hello, World!")
+	})
+
+	for i, tc := range []struct {
+		query         string
+		authorization string
+		cached        bool
+	}{
+		{query: "?cc=private,max-age=60"},
+		{query: "?cc=no-store,max-age=60"},
+		{query: "?cc=max-age=60&vary=*"},
+		{query: "?cc=max-age=60", authorization: "Bearer token"},
+		{query: "?cc=public,max-age=60", authorization: "Bearer token", cached: true},
+		{query: "?cc=s-maxage=60", authorization: "Bearer token", cached: true},
+	} {
+		req := httptest.NewRequest(velocity.MethodGet, "/"+strconv.Itoa(i)+tc.query, nil)
+		if tc.authorization != "" {
+			req.Header.Set(velocity.HeaderAuthorization, tc.authorization)
+		}
+		resp, _ := doRequest(t, app, req)
+		if tc.cached {
+			require.Equal(t, cacheMiss, resp.Header.Get("X-Cache"), tc.query)
+		} else {
+			require.Equal(t, cacheUnreachable, resp.Header.Get("X-Cache"), tc.query)
+		}
+	}
+
+	// The response is not stored if the request has no-store
+	req := httptest.NewRequest(velocity.MethodGet, "/?cc=max-age=60&request=no-store", nil)
+	req.Header.Set(velocity.HeaderCacheControl, "no-store")
+	resp, _ := doRequest(t, app, req)
+	require.Equal(t, cacheUnreachable, resp.Header.Get("X-Cache"))
+}
+
+// go test -run Test_Cache_RFC9111_Vary
+func Test_Cache_RFC9111_Vary(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{RFC9111: true, Storage: memory.New(), MaxBytes: 100}))
+
+	var count atomic.Int32
+	app.Get("/", func(c velocity.Ctx) error {
+		count.Add(1)
+		c.Set(velocity.HeaderCacheControl, "max-age=60")
+		c.Set(velocity.HeaderVary, "Accept-Language")
+		return c.SendString("Hello, " + c.Get(velocity.HeaderAcceptLanguage))
+	})
+
+	request := func(lang string) (*http.Response, string) {
+		req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+		req.Header.Set(velocity.HeaderAcceptLanguage, lang)
+		return doRequest(t, app, req)
+	}
+
+	resp, body := request("en")
+	require.Equal(t, cacheMiss, resp.Header.Get("X-Cache"))
+	require.Equal(t, "Hello, en", body)
+
+	resp, body = request("de")
+	require.Equal(t, cacheMiss, resp.Header.Get("X-Cache"))
+	require.Equal(t, "Hello, de", body)
+
+	resp, body = request("en")
+	require.Equal(t, cacheHit, resp.Header.Get("X-Cache"))
+	require.Equal(t, "Hello, en", body)
+
+	resp, body = request("de")
+	require.Equal(t, cacheHit, resp.Header.Get("X-Cache"))
+	require.Equal(t, "Hello, de", body)
+
+	require.Equal(t, int32(2), count.Load())
+}
+
+// go test -run Test_Cache_RFC9111_Revalidation
+func Test_Cache_RFC9111_Revalidation(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{RFC9111: true}))
+
+	var full, notModified atomic.Int32
+	app.Get("/", func(c velocity.Ctx) error {
+		c.Set(velocity.HeaderCacheControl, "max-age=0, must-revalidate")
+		c.Set(velocity.HeaderETag, `"v1"`)
+		if c.Get(velocity.HeaderIfNoneMatch) == `"v1"` {
+			notModified.Add(1)
+			return c.SendStatus(velocity.StatusNotModified)
+		}
+		full.Add(1)
+		c.Set("X-Version", strconv.Itoa(int(full.Load())))
+		return c.SendString("Hello, World!")
+	})
+
+	resp, body := doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.Equal(t, cacheMiss, resp.Header.Get("X-Cache"))
+	require.Equal(t, "Hello, World!", body)
+
+	// The validators of the client are replaced by the ones of the stored response
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set(velocity.HeaderIfNoneMatch, `"v0"`)
+	for i := 0; i < 2; i++ {
+		resp, body = doRequest(t, app, req)
+		require.Equal(t, velocity.StatusOK, resp.StatusCode)
+		require.Equal(t, cacheRevalidated, resp.Header.Get("X-Cache"))
+		require.Equal(t, "Hello, World!", body)
+		require.Equal(t, "1", resp.Header.Get("X-Version"))
+	}
+
+	require.Equal(t, int32(1), full.Load())
+	require.Equal(t, int32(2), notModified.Load())
+}
+
+// go test -run Test_Cache_RFC9111_StaleWhileRevalidate
+func Test_Cache_RFC9111_StaleWhileRevalidate(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	// The middleware before the cache handles the revalidation as well
+	var seen atomic.Int32
+	app.Use(func(c velocity.Ctx) error {
+		seen.Add(1)
+		c.Locals("tenant", c.Get("X-Tenant"))
+		return c.Next()
+	})
+	app.Use(New(Config{RFC9111: true}))
+
+	var count atomic.Int32
+	app.Get("/", func(c velocity.Ctx) error {
+		c.Set(velocity.HeaderCacheControl, "max-age=1, stale-while-revalidate=60")
+		return c.SendString(c.Locals("tenant").(string) + strconv.Itoa(int(count.Add(1)))) //nolint:forcetypeassert,errcheck // Set by the middleware
+	})
+
+	var sent int32
+	get := func() (*http.Response, string) {
+		sent++
+		req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+		req.Header.Set("X-Tenant", "acme")
+		return doRequest(t, app, req)
+	}
+
+	resp, body := get()
+	require.Equal(t, cacheMiss, resp.Header.Get("X-Cache"))
+	require.Equal(t, "acme1", body)
+
+	// Sleep until the response is stale
+	time.Sleep(2 * time.Second)
+
+	resp, body = get()
+	require.Equal(t, cacheStale, resp.Header.Get("X-Cache"))
+	require.Equal(t, "acme1", body)
+
+	// The response is revalidated in the background
+	require.Eventually(t, func() bool {
+		return count.Load() == 2
+	}, time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		resp, body = get()
+		return resp.Header.Get("X-Cache") == cacheHit && body == "acme2"
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(2), count.Load())
+	require.Equal(t, sent+1, seen.Load())
+}
+
+// go test -run Test_Cache_RFC9111_StaleWhileRevalidate_Panic
+func Test_Cache_RFC9111_StaleWhileRevalidate_Panic(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{RFC9111: true}))
+
+	var count atomic.Int32
+	app.Get("/", func(c velocity.Ctx) error {
+		if count.Add(1) == 2 {
+			panic("unavailable")
+		}
+		c.Set(velocity.HeaderCacheControl, "max-age=1, stale-while-revalidate=60")
+		return c.SendString(strconv.Itoa(int(count.Load())))
+	})
+
+	_, body := doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.Equal(t, "1", body)
+
+	// Sleep until the response is stale
+	time.Sleep(2 * time.Second)
+
+	// The panic of the revalidation is recovered and the stale response is kept
+	resp, body := doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.Equal(t, cacheStale, resp.Header.Get("X-Cache"))
+	require.Equal(t, "1", body)
+	require.Eventually(t, func() bool {
+		return count.Load() == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// The next stale request starts a new revalidation
+	require.Eventually(t, func() bool {
+		resp, body = doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/", nil))
+		return resp.Header.Get("X-Cache") == cacheHit && body == "3"
+	}, time.Second, 10*time.Millisecond)
+}
+
+// go test -run Test_Cache_RFC9111_StaleIfError
+func Test_Cache_RFC9111_StaleIfError(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{RFC9111: true}))
+
+	var count atomic.Int32
+	app.Get("/:cc", func(c velocity.Ctx) error {
+		if count.Add(1) > 1 {
+			return velocity.ErrBadGateway
+		}
+		c.Set(velocity.HeaderCacheControl, c.Params("cc"))
+		return c.SendString("Hello, World!")
+	})
+
+	_, body := doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/max-age=1,stale-if-error=60", nil))
+	require.Equal(t, "Hello, World!", body)
+
+	// Sleep until the response is stale
+	time.Sleep(2 * time.Second)
+
+	resp, body := doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/max-age=1,stale-if-error=60", nil))
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, cacheStale, resp.Header.Get("X-Cache"))
+	require.Equal(t, "Hello, World!", body)
+
+	// must-revalidate doesn't allow to serve stale responses
+	count.Store(0)
+	_, body = doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/max-age=1,stale-if-error=60,must-revalidate", nil))
+	require.Equal(t, "Hello, World!", body)
+
+	time.Sleep(2 * time.Second)
+
+	resp, _ = doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/max-age=1,stale-if-error=60,must-revalidate", nil))
+	require.Equal(t, velocity.StatusBadGateway, resp.StatusCode)
+}
+
+// go test -run Test_Cache_RFC9111_Invalidation
+func Test_Cache_RFC9111_Invalidation(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{RFC9111: true}))
+
+	var count atomic.Int32
+	app.Get("/", func(c velocity.Ctx) error {
+		c.Set(velocity.HeaderCacheControl, "max-age=60")
+		return c.SendString(strconv.Itoa(int(count.Load())))
+	})
+	app.Post("/", func(c velocity.Ctx) error {
+		count.Add(1)
+		return c.SendStatus(velocity.StatusNoContent)
+	})
+
+	_, body := doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.Equal(t, "0", body)
+
+	resp, _ := doRequest(t, app, httptest.NewRequest(velocity.MethodPost, "/", nil))
+	require.Equal(t, velocity.StatusNoContent, resp.StatusCode)
+
+	resp, body = doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.Equal(t, cacheMiss, resp.Header.Get("X-Cache"))
+	require.Equal(t, "1", body)
+}
+
+// go test -run Test_Cache_RFC9111_OnlyIfCached
+func Test_Cache_RFC9111_OnlyIfCached(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{RFC9111: true}))
+
+	app.Get("/", func(c velocity.Ctx) error {
+		c.Set(velocity.HeaderCacheControl, "max-age=60")
+		return c.SendString("Hello, World!")
+	})
+
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set(velocity.HeaderCacheControl, "only-if-cached")
+	resp, _ := doRequest(t, app, req)
+	require.Equal(t, velocity.StatusGatewayTimeout, resp.StatusCode)
+
+	_, _ = doRequest(t, app, httptest.NewRequest(velocity.MethodGet, "/", nil))
+
+	resp, body := doRequest(t, app, req)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, cacheHit, resp.Header.Get("X-Cache"))
+	require.Equal(t, "Hello, World!", body)
+}
@@ -15,7 +15,8 @@ import (
 type Config struct {
 	// Store is used to store the state of the middleware
 	//
-	// Optional. Default: memory.New()
+	// Optional. Default: an in memory store for this process only, which holds up
+	// to 100,000 tokens and evicts the least recently used ones
 	// Ignored if Session is set.
 	Storage velocity.Storage
 
@@ -117,6 +118,9 @@ type Config struct {
 
 const HeaderName = "X-Csrf-Token"
 
+// defaultMaxEntries is the number of tokens of the default storage
+const defaultMaxEntries = 100_000
+
 // ConfigDefault is the default config
 var ConfigDefault = Config{
 	KeyLookup:      "header:" + HeaderName,
@@ -155,8 +155,12 @@ func New(config ...Config) velocity.Handler {
 				return cfg.ErrorHandler(c, ErrTokenNotFound)
 			}
 			if cfg.SingleUseToken {
-				// If token is single use, delete it from storage
-				deleteTokenFromStorage(c, extractedToken, cfg, sessionManager, storageManager)
+				// If token is single use, delete it from storage.
+				// Concurrent requests with the same token can't both consume it.
+				if !consumeTokenFromStorage(c, extractedToken, raw, cfg, sessionManager, storageManager) {
+					expireCSRFCookie(c, cfg)
+					return cfg.ErrorHandler(c, ErrTokenNotFound)
+				}
 			} else {
 				token = extractedToken // Token is valid, safe to set it
 			}
@@ -231,6 +235,16 @@ func deleteTokenFromStorage(c velocity.Ctx, token string, cfg Config, sessionMan
 	}
 }
 
+// consumeTokenFromStorage deletes the single use token from the storage
+// returns false if the token was consumed by another request in the meantime
+func consumeTokenFromStorage(c velocity.Ctx, token string, raw []byte, cfg Config, sessionManager *sessionManager, storageManager *storageManager) bool {
+	if cfg.Session != nil {
+		sessionManager.delRaw(c)
+		return true
+	}
+	return storageManager.consumeRaw(token, raw)
+}
+
 // Update CSRF cookie
 // if expireCookie is true, the cookie will expire immediately
 func updateCSRFCookie(c velocity.Ctx, cfg Config, token string) {
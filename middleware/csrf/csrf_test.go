@@ -3,10 +3,13 @@ package csrf
 import (
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
 	"github.com/khulnasoft/velocity/middleware/session"
 	"github.com/khulnasoft/velocity/utils"
 	"github.com/stretchr/testify/require"
@@ -423,6 +426,56 @@ func Test_CSRF_SingleUseToken(t *testing.T) {
 	require.Equal(t, 403, ctx.Response.StatusCode())
 }
 
+// go test -run Test_CSRF_SingleUseToken_AtomicStorage
+func Test_CSRF_SingleUseToken_AtomicStorage(t *testing.T) {
+	t.Parallel()
+
+	// several instances that share the storage
+	storage := memory.New()
+	handlers := make([]fasthttp.RequestHandler, 4)
+	for i := range handlers {
+		app := velocity.New()
+		app.Use(New(Config{
+			SingleUseToken: true,
+			Storage:        storage,
+		}))
+		app.Post("/", func(c velocity.Ctx) error {
+			return c.SendStatus(velocity.StatusOK)
+		})
+		handlers[i] = app.Handler()
+	}
+
+	// Generate CSRF token
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(velocity.MethodGet)
+	handlers[0](ctx)
+	token := string(ctx.Response.Header.Peek(velocity.HeaderSetCookie))
+	token = strings.Split(strings.Split(token, ";")[0], "=")[1]
+
+	// Use the CSRF token concurrently, only one request can consume it
+	var (
+		wg       sync.WaitGroup
+		accepted atomic.Int32
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(h fasthttp.RequestHandler) {
+			defer wg.Done()
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Request.Header.SetMethod(velocity.MethodPost)
+			ctx.Request.Header.Set(HeaderName, token)
+			ctx.Request.Header.SetCookie(ConfigDefault.CookieName, token)
+			h(ctx)
+			if ctx.Response.StatusCode() == velocity.StatusOK {
+				accepted.Add(1)
+			}
+		}(handlers[i%len(handlers)])
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(1), accepted.Load())
+}
+
 // go test -run Test_CSRF_Next
 func Test_CSRF_Next(t *testing.T) {
 	t.Parallel()
@@ -5,8 +5,8 @@ import (
 	"time"
 
 	"github.com/khulnasoft/velocity"
-	"github.com/khulnasoft/velocity/internal/memory"
-	"github.com/khulnasoft/velocity/utils"
+	"github.com/khulnasoft/velocity/internal/storage/shared"
+	"github.com/khulnasoft/velocity/storage/memory"
 )
 
 // msgp -file="storage_manager.go" -o="storage_manager_msgp.go" -tests=true -unexported
@@ -16,9 +16,9 @@ type item struct{}
 
 //msgp:ignore manager
 type storageManager struct {
-	pool    sync.Pool        `msg:"-"` //nolint:revive // Ignore unexported type
-	memory  *memory.Storage  `msg:"-"` //nolint:revive // Ignore unexported type
-	storage velocity.Storage `msg:"-"` //nolint:revive // Ignore unexported type
+	pool    sync.Pool              `msg:"-"` //nolint:revive // Ignore unexported type
+	storage velocity.Storage       `msg:"-"` //nolint:revive // Ignore unexported type
+	atomic  velocity.AtomicStorage `msg:"-"` //nolint:revive // Ignore unexported type
 }
 
 func newStorageManager(storage velocity.Storage) *storageManager {
@@ -30,42 +30,38 @@ func newStorageManager(storage velocity.Storage) *storageManager {
 			},
 		},
 	}
-	if storage != nil {
-		// Use provided storage if provided
-		storageManager.storage = storage
-	} else {
-		// Fallback too memory storage
-		storageManager.memory = memory.New()
+	if storage == nil {
+		// Fallback to memory storage, it is bounded because every new visitor adds a token
+		storage = memory.New(memory.Config{MaxEntries: defaultMaxEntries})
 	}
+	storageManager.storage = storage
+	// Consume single use tokens atomically if the storage supports it
+	storageManager.atomic = shared.Atomic(storage)
 	return storageManager
 }
 
-// get raw data from storage or memory
+// get raw data from storage
 func (m *storageManager) getRaw(key string) []byte {
-	var raw []byte
-	if m.storage != nil {
-		raw, _ = m.storage.Get(key) //nolint:errcheck // TODO: Do not ignore error
-	} else {
-		raw, _ = m.memory.Get(key).([]byte) //nolint:errcheck // TODO: Do not ignore error
-	}
+	raw, _ := m.storage.Get(key) //nolint:errcheck // TODO: Do not ignore error
 	return raw
 }
 
-// set data to storage or memory
+// set data to storage
 func (m *storageManager) setRaw(key string, raw []byte, exp time.Duration) {
-	if m.storage != nil {
-		_ = m.storage.Set(key, raw, exp) //nolint:errcheck // TODO: Do not ignore error
-	} else {
-		// the key is crucial in crsf and sometimes a reference to another value which can be reused later(pool/unsafe values concept), so a copy is made here
-		m.memory.Set(utils.CopyString(key), raw, exp)
-	}
+	_ = m.storage.Set(key, raw, exp) //nolint:errcheck // TODO: Do not ignore error
 }
 
-// delete data from storage or memory
+// delete data from storage
 func (m *storageManager) delRaw(key string) {
-	if m.storage != nil {
-		_ = m.storage.Delete(key) //nolint:errcheck // TODO: Do not ignore error
-	} else {
-		m.memory.Delete(key)
+	_ = m.storage.Delete(key) //nolint:errcheck // TODO: Do not ignore error
+}
+
+// consumeRaw deletes the data if it is still raw, and reports whether it was deleted by this call
+func (m *storageManager) consumeRaw(key string, raw []byte) bool {
+	if m.atomic != nil {
+		swapped, err := m.atomic.CompareAndSwap(key, raw, nil, 0)
+		return err == nil && swapped
 	}
+	m.delRaw(key)
+	return true
 }
@@ -6,21 +6,32 @@ import (
 	"time"
 
 	"github.com/khulnasoft/velocity"
-	"github.com/khulnasoft/velocity/internal/storage/memory"
+	"github.com/khulnasoft/velocity/storage/memory"
 )
 
 var ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
 
+const (
+	// defaultStorageLockTTL is the time after which the lock of a stopped instance is released
+	defaultStorageLockTTL = time.Minute
+
+	// defaultMaxEntries is the number of responses of the default storage
+	defaultMaxEntries = 100_000
+)
+
 // Config defines the config for middleware.
 type Config struct {
 	// Lock locks an idempotency key.
 	//
-	// Optional. Default: an in-memory locker for this process only.
+	// Optional. Default: a StorageLock if the Storage implements velocity.AtomicStorage,
+	// so that the key is locked across all instances that share it,
+	// otherwise an in-memory locker for this process only.
 	Lock Locker
 
 	// Storage stores response data by idempotency key.
 	//
-	// Optional. Default: an in-memory storage for this process only.
+	// Optional. Default: an in-memory storage for this process only, which holds
+	// up to 100,000 responses and evicts the least recently used ones.
 	Storage velocity.Storage
 	// Next defines a function to skip this middleware when returned true.
 	//
@@ -82,6 +93,7 @@ func configDefault(config ...Config) Config {
 		cfg.Lock = NewMemoryLock()
 		cfg.Storage = memory.New(memory.Config{
 			GCInterval: cfg.Lifetime / 2, // Half the lifetime interval
+			MaxEntries: defaultMaxEntries,
 		})
 
 		return cfg
@@ -112,12 +124,17 @@ func configDefault(config ...Config) Config {
 	}
 
 	if cfg.Lock == nil {
-		cfg.Lock = NewMemoryLock()
+		if storage, ok := cfg.Storage.(velocity.AtomicStorage); ok {
+			cfg.Lock = NewStorageLock(storage, defaultStorageLockTTL)
+		} else {
+			cfg.Lock = NewMemoryLock()
+		}
 	}
 
 	if cfg.Storage == nil {
 		cfg.Storage = memory.New(memory.Config{
 			GCInterval: cfg.Lifetime / 2,
+			MaxEntries: defaultMaxEntries,
 		})
 	}
 
@@ -1,7 +1,12 @@
 package idempotency
 
 import (
+	"fmt"
 	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/utils"
 )
 
 // Locker implements a spinlock for a string key.
@@ -66,3 +71,69 @@ func NewMemoryLock() *MemoryLock {
 }
 
 var _ Locker = (*MemoryLock)(nil)
+
+// StorageLock implements a lock for a string key with the atomic operations of a storage,
+// so that the lock is held across all instances that share the storage.
+type StorageLock struct {
+	storage velocity.AtomicStorage
+	tokens  map[string]string
+	ttl     time.Duration
+	mu      sync.Mutex
+}
+
+const storageLockRetryInterval = 10 * time.Millisecond
+
+// Lock waits until the key is not locked by any instance.
+func (l *StorageLock) Lock(key string) error {
+	token := utils.UUIDv4()
+	for {
+		ok, err := l.storage.SetIfAbsent(storageLockKey(key), []byte(token), l.ttl)
+		if err != nil {
+			return fmt.Errorf("failed to acquire lock: %w", err)
+		}
+		if ok {
+			break
+		}
+		time.Sleep(storageLockRetryInterval)
+	}
+
+	l.mu.Lock()
+	l.tokens[key] = token
+	l.mu.Unlock()
+
+	return nil
+}
+
+// Unlock releases the key, unless the lock expired and was acquired by another instance.
+func (l *StorageLock) Unlock(key string) error {
+	l.mu.Lock()
+	token, ok := l.tokens[key]
+	delete(l.tokens, key)
+	l.mu.Unlock()
+	if !ok {
+		// This happens if we try to unlock an unknown key
+		return nil
+	}
+
+	if _, err := l.storage.CompareAndSwap(storageLockKey(key), []byte(token), nil, 0); err != nil {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+
+	return nil
+}
+
+// NewStorageLock creates a lock that is stored in the storage. The lock expires after
+// the ttl, so that a key is not locked forever if an instance stops while holding it.
+func NewStorageLock(storage velocity.AtomicStorage, ttl time.Duration) *StorageLock {
+	return &StorageLock{
+		storage: storage,
+		tokens:  make(map[string]string),
+		ttl:     ttl,
+	}
+}
+
+func storageLockKey(key string) string {
+	return key + ":lock"
+}
+
+var _ Locker = (*StorageLock)(nil)
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/khulnasoft/velocity/internal/storage/memory"
 	"github.com/khulnasoft/velocity/middleware/idempotency"
 
 	"github.com/stretchr/testify/assert"
@@ -62,6 +63,49 @@ func Test_MemoryLock(t *testing.T) {
 	}
 }
 
+// go test -run Test_StorageLock
+func Test_StorageLock(t *testing.T) {
+	t.Parallel()
+
+	// two instances that share the storage
+	storage := memory.New()
+	l1 := idempotency.NewStorageLock(storage, time.Minute)
+	l2 := idempotency.NewStorageLock(storage, time.Minute)
+
+	require.NoError(t, l1.Lock("a"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		assert.NoError(t, l2.Lock("a"))
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("lock acquired by another instance")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, l1.Unlock("a"))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lock not acquired after unlock")
+	}
+
+	// unlocking a key that is not held by the instance does nothing
+	require.NoError(t, l1.Unlock("a"))
+	val, err := storage.Get("a:lock")
+	require.NoError(t, err)
+	require.NotNil(t, val)
+
+	require.NoError(t, l2.Unlock("a"))
+	val, err = storage.Get("a:lock")
+	require.NoError(t, err)
+	require.Nil(t, val)
+}
+
 func Benchmark_MemoryLock(b *testing.B) {
 	keys := make([]string, b.N)
 	for i := range keys {
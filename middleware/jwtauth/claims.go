@@ -0,0 +1,119 @@
+package jwtauth
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Claims are the claims of a validated token, decoded from JSON
+type Claims map[string]any
+
+// String returns the claim if it is a string, otherwise an empty string
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string) //nolint:errcheck // empty if not a string
+	return s
+}
+
+// Strings returns the claim if it is a string or an array of strings
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		s := make([]string, 0, len(v))
+		for _, e := range v {
+			if str, ok := e.(string); ok {
+				s = append(s, str)
+			}
+		}
+		return s
+	default:
+		return nil
+	}
+}
+
+// Time returns the claim as a NumericDate, zero if it is missing or not a number
+func (c Claims) Time(name string) time.Time {
+	var seconds float64
+	switch v := c[name].(type) {
+	case float64:
+		seconds = v
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}
+		}
+		seconds = f
+	default:
+		return time.Time{}
+	}
+	integer, fraction := math.Modf(seconds)
+	return time.Unix(int64(integer), int64(fraction*1e9))
+}
+
+// Subject returns the "sub" claim
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+// Issuer returns the "iss" claim
+func (c Claims) Issuer() string {
+	return c.String("iss")
+}
+
+// Audience returns the "aud" claim
+func (c Claims) Audience() []string {
+	return c.Strings("aud")
+}
+
+// ExpiresAt returns the "exp" claim, zero if it is missing
+func (c Claims) ExpiresAt() time.Time {
+	return c.Time("exp")
+}
+
+// NotBefore returns the "nbf" claim, zero if it is missing
+func (c Claims) NotBefore() time.Time {
+	return c.Time("nbf")
+}
+
+// IssuedAt returns the "iat" claim, zero if it is missing
+func (c Claims) IssuedAt() time.Time {
+	return c.Time("iat")
+}
+
+// Scopes returns the scopes of the "scope" claim, which is space-delimited as in RFC 8693,
+// or of the "scp" claim, which may also be an array
+func (c Claims) Scopes() []string {
+	if scope := c.String("scope"); scope != "" {
+		return strings.Fields(scope)
+	}
+	if scp := c.String("scp"); scp != "" {
+		return strings.Fields(scp)
+	}
+	return c.Strings("scp")
+}
+
+// validate checks the registered claims
+func (c Claims) validate(cfg *Config, now time.Time) error {
+	if exp := c.ExpiresAt(); !exp.IsZero() && !now.Before(exp.Add(cfg.ClockSkew)) {
+		return ErrTokenExpired
+	}
+	if nbf := c.NotBefore(); !nbf.IsZero() && now.Add(cfg.ClockSkew).Before(nbf) {
+		return ErrTokenNotValidYet
+	}
+	if iat := c.IssuedAt(); !iat.IsZero() && now.Add(cfg.ClockSkew).Before(iat) {
+		return ErrTokenUsedBeforeIssued
+	}
+	if cfg.Issuer != "" && c.Issuer() != cfg.Issuer {
+		return ErrTokenInvalidIssuer
+	}
+	if len(cfg.Audience) > 0 && !slices.ContainsFunc(c.Audience(), func(aud string) bool {
+		return slices.Contains(cfg.Audience, aud)
+	}) {
+		return ErrTokenInvalidAudience
+	}
+	return nil
+}
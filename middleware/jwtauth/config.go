@@ -0,0 +1,165 @@
+package jwtauth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/middleware/keyauth"
+	"github.com/valyala/fasthttp"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip middleware.
+	// Optional. Default: nil
+	Next func(velocity.Ctx) bool
+
+	// SuccessHandler defines a function which is executed for a valid token.
+	// Optional. Default: c.Next()
+	SuccessHandler velocity.Handler
+
+	// ErrorHandler defines a function which is executed for a missing or invalid token.
+	// It may be used to define a custom error.
+	// Optional. Default: 401 with a WWW-Authenticate header
+	ErrorHandler velocity.ErrorHandler
+
+	// CustomKeyLookup extracts the token from the request, e.g. one that is created by
+	// keyauth.MultipleKeySourceLookup. It overrides KeyLookup and AuthScheme.
+	// Optional. Default: nil
+	CustomKeyLookup keyauth.KeyLookupFunc
+
+	// JWKSClient is the client that fetches the JWKS.
+	// Optional. Default: &fasthttp.Client{}
+	JWKSClient *fasthttp.Client
+
+	// KeyLookup is a string in the form of "<source>:<name>" that is used
+	// to extract the token from the request, like in keyauth.
+	// Optional. Default value "header:Authorization".
+	// Possible values:
+	// - "header:<name>"
+	// - "query:<name>"
+	// - "form:<name>"
+	// - "param:<name>"
+	// - "cookie:<name>"
+	KeyLookup string
+
+	// AuthScheme to be used in the Authorization header.
+	// Optional. Default value "Bearer".
+	AuthScheme string
+
+	// JWKSURL is the URL of a JSON Web Key Set with the keys that verify the tokens. The keys
+	// are cached and fetched again after JWKSRefreshInterval, or when a token is signed by an
+	// unknown key ID, but at most once per JWKSRefreshRateLimit.
+	// Required if Keys is empty.
+	JWKSURL string
+
+	// Issuer is the required "iss" claim.
+	// Optional. Default: "" (not checked)
+	Issuer string
+
+	// Keys verify the signatures of the tokens. They are checked before the keys of the JWKS.
+	// Required if JWKSURL is empty.
+	Keys []Key
+
+	// Algorithms are the signature algorithms that are accepted.
+	// Optional. Default: all supported algorithms, "none" is never accepted
+	Algorithms []string
+
+	// Audience are the accepted audiences, the "aud" claim must contain one of them.
+	// Optional. Default: nil (not checked)
+	Audience []string
+
+	// ClockSkew is the tolerance for the "exp", "nbf" and "iat" claims.
+	// Optional. Default: 0
+	ClockSkew time.Duration
+
+	// JWKSRefreshInterval is the time after which the JWKS is fetched again.
+	// Optional. Default: 1 * time.Hour
+	JWKSRefreshInterval time.Duration
+
+	// JWKSRefreshRateLimit is the minimum time between two fetches of the JWKS, e.g. when tokens
+	// with unknown key IDs are sent.
+	// Optional. Default: 1 * time.Minute
+	JWKSRefreshRateLimit time.Duration
+
+	// JWKSTimeout is the timeout of a fetch of the JWKS.
+	// Optional. Default: 10 * time.Second
+	JWKSTimeout time.Duration
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	SuccessHandler: func(c velocity.Ctx) error {
+		return c.Next()
+	},
+	ErrorHandler:         DefaultErrorHandler,
+	KeyLookup:            "header:" + velocity.HeaderAuthorization,
+	AuthScheme:           "Bearer",
+	JWKSRefreshInterval:  time.Hour,
+	JWKSRefreshRateLimit: time.Minute,
+	JWKSTimeout:          10 * time.Second,
+}
+
+// DefaultErrorHandler responds with 401 and a WWW-Authenticate header as described in RFC 6750,
+// an unavailable JWKS is returned as error
+func DefaultErrorHandler(c velocity.Ctx, err error) error {
+	if errors.Is(err, ErrJWKSUnavailable) {
+		return err
+	}
+	if errors.Is(err, keyauth.ErrMissingOrMalformedAPIKey) {
+		c.Set(velocity.HeaderWWWAuthenticate, "Bearer")
+		return c.Status(velocity.StatusUnauthorized).SendString("Missing or malformed JWT")
+	}
+	c.Set(velocity.HeaderWWWAuthenticate, fmt.Sprintf("Bearer error=%q, error_description=%q", "invalid_token", err.Error()))
+	return c.Status(velocity.StatusUnauthorized).SendString("Invalid or expired JWT")
+}
+
+// Helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		panic("velocity: jwtauth middleware requires Keys or a JWKSURL")
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.SuccessHandler == nil {
+		cfg.SuccessHandler = ConfigDefault.SuccessHandler
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = ConfigDefault.ErrorHandler
+	}
+	if cfg.KeyLookup == "" {
+		cfg.KeyLookup = ConfigDefault.KeyLookup
+		// set AuthScheme as "Bearer" only if KeyLookup is set to default.
+		if cfg.AuthScheme == "" {
+			cfg.AuthScheme = ConfigDefault.AuthScheme
+		}
+	}
+	if cfg.JWKSRefreshInterval <= 0 {
+		cfg.JWKSRefreshInterval = ConfigDefault.JWKSRefreshInterval
+	}
+	if cfg.JWKSRefreshRateLimit <= 0 {
+		cfg.JWKSRefreshRateLimit = ConfigDefault.JWKSRefreshRateLimit
+	}
+	if cfg.JWKSTimeout <= 0 {
+		cfg.JWKSTimeout = ConfigDefault.JWKSTimeout
+	}
+	if cfg.JWKSClient == nil && cfg.JWKSURL != "" {
+		cfg.JWKSClient = &fasthttp.Client{}
+	}
+	if len(cfg.Keys) == 0 && cfg.JWKSURL == "" {
+		panic("velocity: jwtauth middleware requires Keys or a JWKSURL")
+	}
+	for _, alg := range cfg.Algorithms {
+		if _, ok := algorithms[alg]; !ok {
+			panic(fmt.Sprintf("velocity: jwtauth middleware does not support the algorithm %q", alg))
+		}
+	}
+
+	return cfg
+}
@@ -0,0 +1,259 @@
+package jwtauth
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Key is a key that verifies the signatures of tokens
+type Key struct {
+	// Key is a []byte for HS algorithms, a *rsa.PublicKey for RS and PS algorithms, a
+	// *ecdsa.PublicKey for ES algorithms and an ed25519.PublicKey for EdDSA.
+	Key any
+
+	// ID is the "kid" of the key. A token with a "kid" header is only verified by the keys
+	// with the same ID or without an ID.
+	// Optional. Default: ""
+	ID string
+
+	// Algorithm restricts the key to an algorithm, e.g. "RS256".
+	// Optional. Default: "" (any algorithm that matches the type of the key)
+	Algorithm string
+}
+
+var (
+	// ErrInvalidJWKS is returned by ParseJWKS if the key set can't be parsed
+	ErrInvalidJWKS = errors.New("jwtauth: invalid JWKS")
+	// ErrJWKSUnavailable is passed to the ErrorHandler if the JWKS can't be fetched and no keys are cached
+	ErrJWKSUnavailable = errors.New("jwtauth: JWKS is unavailable")
+)
+
+// jwk is a JSON Web Key as described in RFC 7517
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses a JSON Web Key Set. The keys of unsupported types and the keys that are not
+// used for signatures are skipped.
+func ParseJWKS(data []byte) ([]Key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidJWKS, err)
+	}
+	keys := make([]Key, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q: %w", ErrInvalidJWKS, k.Kid, err)
+		}
+		if key != nil {
+			keys = append(keys, Key{Key: key, ID: k.Kid, Algorithm: k.Alg})
+		}
+	}
+	return keys, nil
+}
+
+// publicKey returns the key, nil if its type is not supported
+func (k *jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 || e.Int64() < 3 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var curveECDH ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, curveECDH = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, curveECDH = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, curveECDH = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		// Reject points that are not on the curve
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("invalid point")
+		}
+		if _, err := curveECDH.NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, errors.New("invalid point")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid key size")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, err
+		}
+		return secret, nil
+	default:
+		return nil, nil
+	}
+}
+
+// decodeInt decodes a base64url encoded big-endian integer
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// jwks caches the keys of a JSON Web Key Set
+type jwks struct {
+	fetched   time.Time // last successful fetch
+	attempted time.Time // last fetch
+	err       error     // error of the last fetch
+	client    *fasthttp.Client
+	url       string
+	keys      []Key
+	cfg       *Config
+	mu        sync.RWMutex
+	fetchMu   sync.Mutex
+}
+
+func newJWKS(cfg *Config) *jwks {
+	return &jwks{client: cfg.JWKSClient, url: cfg.JWKSURL, cfg: cfg}
+}
+
+// get returns the keys, they are fetched if they are stale or don't contain the key ID
+func (j *jwks) get(kid string) ([]Key, error) {
+	j.mu.RLock()
+	keys, ok := j.current(kid)
+	j.mu.RUnlock()
+	if ok {
+		return keys, nil
+	}
+
+	// Only one request fetches the keys, the others wait for it
+	j.fetchMu.Lock()
+	defer j.fetchMu.Unlock()
+
+	j.mu.RLock()
+	keys, ok = j.current(kid)
+	attempted, err := j.attempted, j.err
+	j.mu.RUnlock()
+	if ok {
+		return keys, nil
+	}
+	if time.Since(attempted) < j.cfg.JWKSRefreshRateLimit {
+		if keys == nil && err != nil {
+			return nil, err
+		}
+		return keys, nil
+	}
+
+	fetched, err := j.fetch()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.attempted = time.Now()
+	j.err = err
+	if err != nil {
+		// Keep using the cached keys while the JWKS is unavailable
+		if j.keys != nil {
+			return j.keys, nil
+		}
+		return nil, err
+	}
+	j.keys = fetched
+	j.fetched = j.attempted
+	return fetched, nil
+}
+
+// current returns the cached keys and whether they are fresh and contain the key ID, j.mu has to be held
+func (j *jwks) current(kid string) ([]Key, bool) {
+	if j.fetched.IsZero() || time.Since(j.fetched) >= j.cfg.JWKSRefreshInterval {
+		return j.keys, false
+	}
+	if kid == "" {
+		return j.keys, true
+	}
+	for _, key := range j.keys {
+		if key.ID == kid {
+			return j.keys, true
+		}
+	}
+	return j.keys, false
+}
+
+// fetch fetches and parses the JWKS
+func (j *jwks) fetch() ([]Key, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(j.url)
+	if err := j.client.DoTimeout(req, resp, j.cfg.JWKSTimeout); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrJWKSUnavailable, err)
+	}
+	if resp.StatusCode() != fasthttp.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status %d", ErrJWKSUnavailable, resp.StatusCode())
+	}
+	keys, err := ParseJWKS(resp.Body())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrJWKSUnavailable, err)
+	}
+	return keys, nil
+}
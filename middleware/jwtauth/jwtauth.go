@@ -0,0 +1,112 @@
+// Package jwtauth provides a middleware that authenticates requests with JSON Web Tokens.
+// The signatures are verified with static keys or the keys of a JWKS endpoint, and the
+// registered claims are validated.
+package jwtauth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/middleware/keyauth"
+)
+
+// The contextKey type is unexported to prevent collisions with context keys defined in
+// other packages.
+type contextKey int
+
+// The keys for the values in context
+const (
+	tokenKey contextKey = iota
+	claimsKey
+)
+
+// New creates a new middleware handler
+func New(config ...Config) velocity.Handler {
+	// Init config
+	cfg := configDefault(config...)
+
+	// Initialize
+	if cfg.CustomKeyLookup == nil {
+		var err error
+		cfg.CustomKeyLookup, err = keyauth.DefaultKeyLookup(cfg.KeyLookup, cfg.AuthScheme)
+		if err != nil {
+			panic(fmt.Errorf("unable to create lookup function: %w", err))
+		}
+	}
+	var keySet *jwks
+	if cfg.JWKSURL != "" {
+		keySet = newJWKS(&cfg)
+	}
+
+	// Return middleware handler
+	return func(c velocity.Ctx) error {
+		// Filter request to skip middleware
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Extract and verify token
+		raw, err := cfg.CustomKeyLookup(c)
+		if err != nil {
+			return cfg.ErrorHandler(c, err)
+		}
+
+		claims, err := validate(&cfg, keySet, raw)
+		if err != nil {
+			return cfg.ErrorHandler(c, err)
+		}
+
+		c.Locals(tokenKey, raw)
+		c.Locals(claimsKey, claims)
+		return cfg.SuccessHandler(c)
+	}
+}
+
+// validate verifies the signature of the token and validates its claims
+func validate(cfg *Config, keySet *jwks, raw string) (Claims, error) {
+	t, err := parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed(t.header.Algorithm, cfg.Algorithms) {
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	valid := t.verify(cfg.Keys)
+	if !valid && keySet != nil {
+		keys, err := keySet.get(t.header.KeyID)
+		if err != nil {
+			return nil, err
+		}
+		valid = t.verify(keys)
+	}
+	if !valid {
+		return nil, ErrTokenSignatureInvalid
+	}
+
+	if err := t.claims.validate(cfg, time.Now()); err != nil {
+		return nil, err
+	}
+	return t.claims, nil
+}
+
+// TokenFromContext returns the validated token from the request context.
+// returns an empty string if the token does not exist
+func TokenFromContext(c velocity.Ctx) string {
+	token, ok := c.Locals(tokenKey).(string)
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+// ClaimsFromContext returns the claims of the validated token from the request context.
+// returns nil if the token does not exist
+func ClaimsFromContext(c velocity.Ctx) Claims {
+	claims, ok := c.Locals(claimsKey).(Claims)
+	if !ok {
+		return nil
+	}
+	return claims
+}
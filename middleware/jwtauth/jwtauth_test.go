@@ -0,0 +1,487 @@
+package jwtauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/middleware/keyauth"
+	"github.com/stretchr/testify/require"
+)
+
+// sign returns a token with the claims that is signed by the private key
+func sign(t testing.TB, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+
+	header := map[string]any{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	headerJSON, err := json.Marshal(header)
+	require.NoError(t, err)
+	claimsJSON, err := json.Marshal(claims)
+	require.NoError(t, err)
+	input := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(algorithms[alg].hash.New, k)
+		_, _ = mac.Write([]byte(input)) //nolint:errcheck // hash.Write never fails
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		hash := algorithms[alg].hash
+		if strings.HasPrefix(alg, "PS") {
+			signature, err = rsa.SignPSS(rand.Reader, k, hash, digest(hash, []byte(input)), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest(hash, []byte(input)))
+		}
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest(algorithms[alg].hash, []byte(input)))
+		require.NoError(t, err)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(input))
+	default:
+		t.Fatalf("unsupported key %T", key)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// newApp returns an app that is protected by the middleware and responds with the subject
+func newApp(config Config) *velocity.App {
+	app := velocity.New()
+	app.Use(New(config))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString(ClaimsFromContext(c).Subject())
+	})
+	return app
+}
+
+// request sends a request with the token and returns the status, the body and the WWW-Authenticate header
+func request(t *testing.T, app *velocity.App, token string) (int, string, string) {
+	t.Helper()
+
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	if token != "" {
+		req.Header.Set(velocity.HeaderAuthorization, "Bearer "+token)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body), resp.Header.Get(velocity.HeaderWWWAuthenticate)
+}
+
+// validClaims returns claims that are valid for an hour
+func validClaims() map[string]any {
+	return map[string]any{"sub": "john", "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+// go test -run Test_JWTAuth_Algorithms
+func Test_JWTAuth_Algorithms(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	secret := []byte("secret")
+
+	tests := []struct {
+		private any
+		public  any
+		alg     string
+	}{
+		{alg: "HS256", private: secret, public: secret},
+		{alg: "HS384", private: secret, public: secret},
+		{alg: "HS512", private: secret, public: secret},
+		{alg: "RS256", private: rsaKey, public: &rsaKey.PublicKey},
+		{alg: "RS384", private: rsaKey, public: &rsaKey.PublicKey},
+		{alg: "RS512", private: rsaKey, public: &rsaKey.PublicKey},
+		{alg: "PS256", private: rsaKey, public: &rsaKey.PublicKey},
+		{alg: "PS384", private: rsaKey, public: &rsaKey.PublicKey},
+		{alg: "PS512", private: rsaKey, public: &rsaKey.PublicKey},
+		{alg: "ES256", private: p256, public: &p256.PublicKey},
+		{alg: "ES384", private: p384, public: &p384.PublicKey},
+		{alg: "ES512", private: p521, public: &p521.PublicKey},
+		{alg: "EdDSA", private: edKey, public: edPub},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			t.Parallel()
+			app := newApp(Config{Keys: []Key{{Key: tt.public}}})
+
+			status, body, _ := request(t, app, sign(t, tt.alg, "", tt.private, validClaims()))
+			require.Equal(t, velocity.StatusOK, status)
+			require.Equal(t, "john", body)
+
+			// A changed token is rejected
+			token := sign(t, tt.alg, "", tt.private, validClaims())
+			status, _, _ = request(t, app, token[:len(token)-4]+"AAAA")
+			require.Equal(t, velocity.StatusUnauthorized, status)
+		})
+	}
+
+	// ES256 is not verified by a P-384 key
+	app := newApp(Config{Keys: []Key{{Key: &p384.PublicKey}}})
+	status, _, _ := request(t, app, sign(t, "ES256", "", p256, validClaims()))
+	require.Equal(t, velocity.StatusUnauthorized, status)
+}
+
+// go test -run Test_JWTAuth_Invalid
+func Test_JWTAuth_Invalid(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	app := newApp(Config{Keys: []Key{{Key: &rsaKey.PublicKey, ID: "1"}}})
+
+	// Missing token
+	status, body, header := request(t, app, "")
+	require.Equal(t, velocity.StatusUnauthorized, status)
+	require.Equal(t, "Missing or malformed JWT", body)
+	require.Equal(t, "Bearer", header)
+
+	// Malformed token
+	status, _, header = request(t, app, "not.a.jwt")
+	require.Equal(t, velocity.StatusUnauthorized, status)
+	require.Equal(t, `Bearer error="invalid_token", error_description="token is malformed"`, header)
+
+	// "none" is never accepted
+	none := sign(t, "HS256", "", []byte("secret"), validClaims())
+	parts := strings.Split(none, ".")
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	status, _, header = request(t, app, parts[0]+"."+parts[1]+".")
+	require.Equal(t, velocity.StatusUnauthorized, status)
+	require.Contains(t, header, ErrUnsupportedAlgorithm.Error())
+
+	// The public key can't be used as HMAC secret
+	publicKey, err := json.Marshal(rsaKey.PublicKey)
+	require.NoError(t, err)
+	status, _, _ = request(t, app, sign(t, "HS256", "1", publicKey, validClaims()))
+	require.Equal(t, velocity.StatusUnauthorized, status)
+
+	// Another key ID
+	status, _, header = request(t, app, sign(t, "RS256", "2", rsaKey, validClaims()))
+	require.Equal(t, velocity.StatusUnauthorized, status)
+	require.Contains(t, header, ErrTokenSignatureInvalid.Error())
+	status, _, _ = request(t, app, sign(t, "RS256", "1", rsaKey, validClaims()))
+	require.Equal(t, velocity.StatusOK, status)
+
+	// Algorithms restricts the accepted algorithms
+	app = newApp(Config{Keys: []Key{{Key: &rsaKey.PublicKey}}, Algorithms: []string{"PS256"}})
+	status, _, _ = request(t, app, sign(t, "RS256", "", rsaKey, validClaims()))
+	require.Equal(t, velocity.StatusUnauthorized, status)
+	status, _, _ = request(t, app, sign(t, "PS256", "", rsaKey, validClaims()))
+	require.Equal(t, velocity.StatusOK, status)
+}
+
+// go test -run Test_JWTAuth_Claims
+func Test_JWTAuth_Claims(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	app := newApp(Config{
+		Keys:      []Key{{Key: secret}},
+		Issuer:    "https://issuer.example.com",
+		Audience:  []string{"api", "web"},
+		ClockSkew: time.Minute,
+	})
+	now := time.Now()
+
+	tests := []struct {
+		claims map[string]any
+		err    error
+		name   string
+	}{
+		{name: "valid", claims: map[string]any{"iss": "https://issuer.example.com", "aud": "api", "exp": now.Add(time.Hour).Unix()}},
+		{name: "audience array", claims: map[string]any{"iss": "https://issuer.example.com", "aud": []string{"other", "web"}}},
+		{name: "expired in skew", claims: map[string]any{"iss": "https://issuer.example.com", "aud": "api", "exp": now.Add(-30 * time.Second).Unix()}},
+		{name: "expired", claims: map[string]any{"iss": "https://issuer.example.com", "aud": "api", "exp": now.Add(-2 * time.Minute).Unix()}, err: ErrTokenExpired},
+		{name: "not valid yet in skew", claims: map[string]any{"iss": "https://issuer.example.com", "aud": "api", "nbf": now.Add(30 * time.Second).Unix()}},
+		{name: "not valid yet", claims: map[string]any{"iss": "https://issuer.example.com", "aud": "api", "nbf": now.Add(2 * time.Minute).Unix()}, err: ErrTokenNotValidYet},
+		{name: "issued in the future", claims: map[string]any{"iss": "https://issuer.example.com", "aud": "api", "iat": now.Add(2 * time.Minute).Unix()}, err: ErrTokenUsedBeforeIssued},
+		{name: "issuer", claims: map[string]any{"iss": "https://other.example.com", "aud": "api"}, err: ErrTokenInvalidIssuer},
+		{name: "audience", claims: map[string]any{"iss": "https://issuer.example.com", "aud": "other"}, err: ErrTokenInvalidAudience},
+		{name: "missing audience", claims: map[string]any{"iss": "https://issuer.example.com"}, err: ErrTokenInvalidAudience},
+	}
+	for _, tt := range tests {
+		status, _, header := request(t, app, sign(t, "HS256", "", secret, tt.claims))
+		if tt.err == nil {
+			require.Equal(t, velocity.StatusOK, status, tt.name)
+		} else {
+			require.Equal(t, velocity.StatusUnauthorized, status, tt.name)
+			require.Contains(t, header, tt.err.Error(), tt.name)
+		}
+	}
+}
+
+// go test -run Test_JWTAuth_Context
+func Test_JWTAuth_Context(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	token := sign(t, "HS256", "", secret, map[string]any{"sub": "john", "scope": "read write", "iat": 1700000000.5})
+
+	app := velocity.New()
+	app.Use(New(Config{Keys: []Key{{Key: secret}}, KeyLookup: "cookie:access_token"}))
+	app.Get("/", func(c velocity.Ctx) error {
+		claims := ClaimsFromContext(c)
+		require.Equal(t, token, TokenFromContext(c))
+		require.Equal(t, []string{"read", "write"}, claims.Scopes())
+		require.Equal(t, time.Unix(1700000000, 500000000), claims.IssuedAt())
+		require.True(t, claims.ExpiresAt().IsZero())
+		return c.SendString(claims.Subject())
+	})
+
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set(velocity.HeaderCookie, "access_token="+token)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+
+	// Without a token
+	app = velocity.New()
+	app.Get("/", func(c velocity.Ctx) error {
+		require.Nil(t, ClaimsFromContext(c))
+		require.Empty(t, TokenFromContext(c))
+		return nil
+	})
+	_, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+}
+
+// go test -run Test_JWTAuth_CustomKeyLookup
+func Test_JWTAuth_CustomKeyLookup(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	lookup, err := keyauth.MultipleKeySourceLookup([]string{"header:Authorization", "query:token"}, "Bearer")
+	require.NoError(t, err)
+	app := newApp(Config{Keys: []Key{{Key: secret}}, CustomKeyLookup: lookup})
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/?token="+sign(t, "HS256", "", secret, validClaims()), nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+}
+
+// toJWK returns the JWK of the public key
+func toJWK(kid string, key any) map[string]any {
+	encode := func(b []byte) string {
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return map[string]any{"kty": "RSA", "kid": kid, "n": encode(k.N.Bytes()), "e": encode(big.NewInt(int64(k.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return map[string]any{"kty": "EC", "kid": kid, "crv": k.Curve.Params().Name, "x": encode(k.X.FillBytes(make([]byte, size))), "y": encode(k.Y.FillBytes(make([]byte, size)))}
+	case ed25519.PublicKey:
+		return map[string]any{"kty": "OKP", "kid": kid, "crv": "Ed25519", "x": encode(k)}
+	case []byte:
+		return map[string]any{"kty": "oct", "kid": kid, "k": encode(k)}
+	default:
+		return nil
+	}
+}
+
+// startJWKS serves the keys as JWKS from a local app, and returns its URL and the number of requests
+func startJWKS(t *testing.T, keys *atomic.Value) (string, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	app := velocity.New()
+	app.Get("/jwks", func(c velocity.Ctx) error {
+		requests.Add(1)
+		return c.JSON(map[string]any{"keys": keys.Load()})
+	})
+	ln, err := net.Listen(velocity.NetworkTCP4, "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = app.Listener(ln, velocity.ListenConfig{DisableStartupMessage: true}) //nolint:errcheck // stopped by the cleanup
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, app.Shutdown())
+	})
+	return "http://" + ln.Addr().String() + "/jwks", &requests
+}
+
+// go test -run Test_JWTAuth_JWKS
+func Test_JWTAuth_JWKS(t *testing.T) {
+	t.Parallel()
+
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var keys atomic.Value
+	keys.Store([]any{toJWK("old", &oldKey.PublicKey)})
+	url, requests := startJWKS(t, &keys)
+
+	app := newApp(Config{JWKSURL: url, JWKSRefreshRateLimit: time.Millisecond})
+
+	// The keys are fetched once and cached
+	for i := 0; i < 3; i++ {
+		status, _, _ := request(t, app, sign(t, "ES256", "old", oldKey, validClaims()))
+		require.Equal(t, velocity.StatusOK, status)
+	}
+	require.Equal(t, int32(1), requests.Load())
+
+	// A token with an unknown key ID fetches the rotated keys
+	keys.Store([]any{toJWK("old", &oldKey.PublicKey), toJWK("new", &newKey.PublicKey)})
+	time.Sleep(5 * time.Millisecond)
+	status, _, _ := request(t, app, sign(t, "ES256", "new", newKey, validClaims()))
+	require.Equal(t, velocity.StatusOK, status)
+	require.Equal(t, int32(2), requests.Load())
+
+	// A removed key is rejected after the refresh
+	keys.Store([]any{toJWK("new", &newKey.PublicKey)})
+	time.Sleep(5 * time.Millisecond)
+	status, _, _ = request(t, app, sign(t, "ES256", "unknown", oldKey, validClaims()))
+	require.Equal(t, velocity.StatusUnauthorized, status)
+	require.Equal(t, int32(3), requests.Load())
+	status, _, _ = request(t, app, sign(t, "ES256", "old", oldKey, validClaims()))
+	require.Equal(t, velocity.StatusUnauthorized, status)
+}
+
+// go test -run Test_JWTAuth_JWKS_RateLimit
+func Test_JWTAuth_JWKS_RateLimit(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	var keys atomic.Value
+	keys.Store([]any{toJWK("1", &key.PublicKey)})
+	url, requests := startJWKS(t, &keys)
+
+	app := newApp(Config{JWKSURL: url, Keys: []Key{{Key: []byte("secret")}}})
+
+	// The static keys are checked first
+	status, _, _ := request(t, app, sign(t, "HS256", "", []byte("secret"), validClaims()))
+	require.Equal(t, velocity.StatusOK, status)
+	require.Equal(t, int32(0), requests.Load())
+
+	// Unknown key IDs don't fetch the JWKS on every request
+	for i := 0; i < 5; i++ {
+		status, _, _ = request(t, app, sign(t, "ES256", "unknown", key, validClaims()))
+		require.Equal(t, velocity.StatusUnauthorized, status)
+	}
+	require.Equal(t, int32(1), requests.Load())
+	status, _, _ = request(t, app, sign(t, "ES256", "1", key, validClaims()))
+	require.Equal(t, velocity.StatusOK, status)
+}
+
+// go test -run Test_JWTAuth_JWKS_Unavailable
+func Test_JWTAuth_JWKS_Unavailable(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen(velocity.NetworkTCP4, "127.0.0.1:0")
+	require.NoError(t, err)
+	url := "http://" + ln.Addr().String() + "/jwks"
+	require.NoError(t, ln.Close())
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	app := newApp(Config{JWKSURL: url, JWKSTimeout: time.Second})
+	status, _, _ := request(t, app, sign(t, "ES256", "1", key, validClaims()))
+	require.Equal(t, velocity.StatusInternalServerError, status)
+}
+
+// go test -run Test_ParseJWKS
+func Test_ParseJWKS(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	encryption := toJWK("enc", &rsaKey.PublicKey)
+	encryption["use"] = "enc"
+	signing := toJWK("rsa", &rsaKey.PublicKey)
+	signing["alg"] = "RS256"
+	data, err := json.Marshal(map[string]any{"keys": []any{
+		signing,
+		toJWK("ec", &ecKey.PublicKey),
+		toJWK("ed", edPub),
+		toJWK("oct", []byte("secret")),
+		encryption,
+		map[string]any{"kty": "unknown"},
+	}})
+	require.NoError(t, err)
+
+	keys, err := ParseJWKS(data)
+	require.NoError(t, err)
+	require.Len(t, keys, 4)
+	require.Equal(t, Key{Key: &rsaKey.PublicKey, ID: "rsa", Algorithm: "RS256"}, keys[0])
+	require.True(t, ecKey.PublicKey.Equal(keys[1].Key))
+	require.Equal(t, edPub, keys[2].Key)
+	require.Equal(t, []byte("secret"), keys[3].Key)
+
+	// A point that is not on the curve is rejected
+	invalid := toJWK("ec", &ecKey.PublicKey)
+	invalid["y"] = invalid["x"]
+	data, err = json.Marshal(map[string]any{"keys": []any{invalid}})
+	require.NoError(t, err)
+	_, err = ParseJWKS(data)
+	require.ErrorIs(t, err, ErrInvalidJWKS)
+
+	_, err = ParseJWKS([]byte("{"))
+	require.ErrorIs(t, err, ErrInvalidJWKS)
+}
+
+// go test -run Test_JWTAuth_Config
+func Test_JWTAuth_Config(t *testing.T) {
+	t.Parallel()
+
+	require.PanicsWithValue(t, "velocity: jwtauth middleware requires Keys or a JWKSURL", func() {
+		New()
+	})
+	require.PanicsWithValue(t, `velocity: jwtauth middleware does not support the algorithm "none"`, func() {
+		New(Config{Keys: []Key{{Key: []byte("secret")}}, Algorithms: []string{"none"}})
+	})
+}
+
+// go test -v -run=^$ -bench=Benchmark_JWTAuth -benchmem -count=4
+func Benchmark_JWTAuth(b *testing.B) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(b, err)
+	cfg := configDefault(Config{Keys: []Key{{Key: &key.PublicKey}}})
+	token := sign(b, "ES256", "", key, validClaims())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := validate(&cfg, nil, token); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // register the hash functions of the algorithms
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"slices"
+	"strings"
+)
+
+// Errors of invalid tokens
+var (
+	ErrTokenMalformed        = errors.New("token is malformed")
+	ErrTokenSignatureInvalid = errors.New("token signature is invalid")
+	ErrTokenExpired          = errors.New("token is expired")
+	ErrTokenNotValidYet      = errors.New("token is not valid yet")
+	ErrTokenUsedBeforeIssued = errors.New("token is used before it was issued")
+	ErrTokenInvalidIssuer    = errors.New("token has an invalid issuer")
+	ErrTokenInvalidAudience  = errors.New("token has an invalid audience")
+	ErrUnsupportedAlgorithm  = errors.New("token has an unsupported algorithm")
+)
+
+// algorithm verifies the signatures of an algorithm
+type algorithm struct {
+	// verify reports whether the signature of the input is valid, false if the type of the key doesn't match
+	verify func(key any, hash crypto.Hash, input, signature []byte) bool
+	hash   crypto.Hash
+}
+
+// algorithms are the supported signature algorithms
+var algorithms = map[string]algorithm{
+	"HS256": {verify: verifyHMAC, hash: crypto.SHA256},
+	"HS384": {verify: verifyHMAC, hash: crypto.SHA384},
+	"HS512": {verify: verifyHMAC, hash: crypto.SHA512},
+	"RS256": {verify: verifyRSA, hash: crypto.SHA256},
+	"RS384": {verify: verifyRSA, hash: crypto.SHA384},
+	"RS512": {verify: verifyRSA, hash: crypto.SHA512},
+	"PS256": {verify: verifyRSAPSS, hash: crypto.SHA256},
+	"PS384": {verify: verifyRSAPSS, hash: crypto.SHA384},
+	"PS512": {verify: verifyRSAPSS, hash: crypto.SHA512},
+	"ES256": {verify: verifyECDSA, hash: crypto.SHA256},
+	"ES384": {verify: verifyECDSA, hash: crypto.SHA384},
+	"ES512": {verify: verifyECDSA, hash: crypto.SHA512},
+	"EdDSA": {verify: verifyEdDSA},
+}
+
+// header is the JOSE header of a token
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// token is a parsed token whose signature is not verified yet
+type token struct {
+	claims    Claims
+	header    header
+	input     []byte // the signed part of the token
+	signature []byte
+}
+
+// parse parses a token in the JWS compact serialization
+func parse(raw string) (*token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	t := &token{input: []byte(raw[:len(parts[0])+1+len(parts[1])])}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if err := json.Unmarshal(headerJSON, &t.header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if err := json.Unmarshal(claimsJSON, &t.claims); err != nil || t.claims == nil {
+		return nil, ErrTokenMalformed
+	}
+	if t.signature, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	return t, nil
+}
+
+// verify reports whether the token is signed by one of the keys
+func (t *token) verify(keys []Key) bool {
+	alg := algorithms[t.header.Algorithm]
+	for _, key := range keys {
+		if key.ID != "" && t.header.KeyID != "" && key.ID != t.header.KeyID {
+			continue
+		}
+		if key.Algorithm != "" && key.Algorithm != t.header.Algorithm {
+			continue
+		}
+		if alg.verify(key.Key, alg.hash, t.input, t.signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// digest returns the hash of the input
+func digest(hash crypto.Hash, input []byte) []byte {
+	h := hash.New()
+	_, _ = h.Write(input) //nolint:errcheck // hash.Write never fails
+	return h.Sum(nil)
+}
+
+func verifyHMAC(key any, hash crypto.Hash, input, signature []byte) bool {
+	secret, ok := key.([]byte)
+	if !ok || len(secret) == 0 {
+		return false
+	}
+	mac := hmac.New(hash.New, secret)
+	_, _ = mac.Write(input) //nolint:errcheck // hash.Write never fails
+	return hmac.Equal(mac.Sum(nil), signature)
+}
+
+func verifyRSA(key any, hash crypto.Hash, input, signature []byte) bool {
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return false
+	}
+	return rsa.VerifyPKCS1v15(pub, hash, digest(hash, input), signature) == nil
+}
+
+func verifyRSAPSS(key any, hash crypto.Hash, input, signature []byte) bool {
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return false
+	}
+	opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+	return rsa.VerifyPSS(pub, hash, digest(hash, input), signature, opts) == nil
+}
+
+func verifyECDSA(key any, hash crypto.Hash, input, signature []byte) bool {
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return false
+	}
+	// The curve has to match the algorithm, e.g. P-256 for ES256
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	if curveHash(pub.Curve.Params().Name) != hash || len(signature) != 2*size {
+		return false
+	}
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size:])
+	return ecdsa.Verify(pub, digest(hash, input), r, s)
+}
+
+// curveHash returns the hash of the ES algorithm of the curve
+func curveHash(name string) crypto.Hash {
+	switch name {
+	case "P-256":
+		return crypto.SHA256
+	case "P-384":
+		return crypto.SHA384
+	case "P-521":
+		return crypto.SHA512
+	default:
+		return 0
+	}
+}
+
+func verifyEdDSA(key any, _ crypto.Hash, input, signature []byte) bool {
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok || len(pub) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(pub, input, signature)
+}
+
+// allowed reports whether the algorithm is supported and accepted
+func allowed(alg string, accepted []string) bool {
+	if _, ok := algorithms[alg]; !ok {
+		return false
+	}
+	return len(accepted) == 0 || slices.Contains(accepted, alg)
+}
@@ -1,16 +1,23 @@
 package limiter
 
 import (
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/storage/memory"
 )
 
+// defaultMaxEntries is the number of keys of the default storage
+const defaultMaxEntries = 100_000
+
 // Config defines the config for middleware.
 type Config struct {
 	// Store is used to store the state of the middleware
 	//
-	// Default: an in memory store for this process only
+	// Default: an in memory store for this process only, which holds up to
+	// 100,000 keys and evicts the least frequently used ones
 	Storage velocity.Storage
 
 	// LimiterMiddleware is the struct that implements a limiter middleware.
@@ -29,6 +36,15 @@ type Config struct {
 	// }
 	MaxFunc func(c velocity.Ctx) int
 
+	// CostFunc returns the number of hits or tokens that a request consumes,
+	// e.g. to make expensive endpoints count more towards the limit. A cost
+	// below 1 counts as 1, a request can't give hits or tokens back.
+	//
+	// Default: func(c velocity.Ctx) int {
+	//   return 1
+	// }
+	CostFunc func(c velocity.Ctx) int
+
 	// KeyGenerator allows you to generate custom keys, by default c.IP() is used
 	//
 	// Default: func(c velocity.Ctx) string {
@@ -53,6 +69,15 @@ type Config struct {
 	// Default: 1 * time.Minute
 	Expiration time.Duration
 
+	// Limits are several limits that are applied to every key at the same time,
+	// e.g. 10 requests per second, 300 per minute and 10,000 per day. A request
+	// is rejected if any of them is exceeded, see ExceededLimit. If set, the
+	// hits are counted in fixed windows and Max, MaxFunc, Expiration and
+	// LimiterMiddleware are ignored.
+	//
+	// Optional. Default: nil
+	Limits []Limit
+
 	// When set to true, requests with StatusCode >= 400 won't be counted.
 	//
 	// Default: false
@@ -64,6 +89,20 @@ type Config struct {
 	SkipSuccessfulRequests bool
 }
 
+// Limit is one of several limits of a key, see Config.Limits.
+type Limit struct {
+	// Name of the limit, it is used as the policy name of the RateLimit headers
+	//
+	// Optional. Default: "tier1", "tier2", ... by position
+	Name string
+
+	// Max number of hits during Expiration before sending a 429 response
+	Max int
+
+	// Expiration is the length of the window of the limit
+	Expiration time.Duration
+}
+
 // ConfigDefault is the default config
 var ConfigDefault = Config{
 	Max:        5,
@@ -74,6 +113,9 @@ var ConfigDefault = Config{
 	LimitReached: func(c velocity.Ctx) error {
 		return c.SendStatus(velocity.StatusTooManyRequests)
 	},
+	CostFunc: func(_ velocity.Ctx) int {
+		return 1
+	},
 	SkipFailedRequests:     false,
 	SkipSuccessfulRequests: false,
 	LimiterMiddleware:      FixedWindow{},
@@ -108,6 +150,34 @@ func configDefault(config ...Config) Config {
 	if cfg.LimiterMiddleware == nil {
 		cfg.LimiterMiddleware = ConfigDefault.LimiterMiddleware
 	}
+	if cfg.CostFunc == nil {
+		cfg.CostFunc = ConfigDefault.CostFunc
+	} else {
+		costFunc := cfg.CostFunc
+		cfg.CostFunc = func(c velocity.Ctx) int {
+			return max(costFunc(c), 1)
+		}
+	}
+	switch limiter := cfg.LimiterMiddleware.(type) {
+	case TokenBucket:
+		validateRate(limiter.Rate)
+	case GCRA:
+		validateRate(limiter.Rate)
+	}
+	if len(cfg.Limits) > 0 {
+		// Copy the limits, so that the names can be set without changing the config of the caller
+		limits := make([]Limit, len(cfg.Limits))
+		for i, limit := range cfg.Limits {
+			if limit.Max <= 0 || limit.Expiration < time.Second {
+				panic("[Limiter] Limits require a Max greater than 0 and an Expiration of at least one second")
+			}
+			if limit.Name == "" {
+				limit.Name = "tier" + strconv.Itoa(i+1)
+			}
+			limits[i] = limit
+		}
+		cfg.Limits = limits
+	}
 	if cfg.MaxFunc == nil {
 		cfg.MaxFunc = func(_ velocity.Ctx) int {
 			return cfg.Max
@@ -115,3 +185,19 @@ func configDefault(config ...Config) Config {
 	}
 	return cfg
 }
+
+// maxRate is the highest rate of the TokenBucket and GCRA limiters, one request per nanosecond
+const maxRate = float64(time.Second)
+
+// validateRate panics if the rate of a TokenBucket or GCRA limiter can't be used, 0 uses the default
+func validateRate(rate float64) {
+	if math.IsNaN(rate) || math.IsInf(rate, 0) || rate > maxRate {
+		panic("[Limiter] Rate must be a finite number of at most one request per nanosecond")
+	}
+}
+
+// newMemory returns the default storage. It is bounded, because every client adds a key. The least
+// frequently used keys are evicted first, those of a flood of new clients before the busy ones.
+func newMemory() velocity.Storage {
+	return memory.New(memory.Config{MaxEntries: defaultMaxEntries, Policy: memory.LFU})
+}
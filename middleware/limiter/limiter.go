@@ -1,6 +1,8 @@
 package limiter
 
 import (
+	"strconv"
+
 	"github.com/khulnasoft/velocity"
 )
 
@@ -9,8 +11,30 @@ const (
 	xRateLimitLimit     = "X-RateLimit-Limit"
 	xRateLimitRemaining = "X-RateLimit-Remaining"
 	xRateLimitReset     = "X-RateLimit-Reset"
+
+	// IETF draft headers, https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+	rateLimitPolicy = "RateLimit-Policy"
+	rateLimit       = "RateLimit"
+
+	// policyName is the name of the quota policy in the IETF draft headers
+	policyName = `"default"`
+)
+
+// The contextKey type is unexported to prevent collisions with context keys defined in
+// other packages.
+type contextKey int
+
+// The keys for the values in context
+const (
+	limitedKey contextKey = iota
+	exceededLimitKey
 )
 
+// ErrCostExceedsBurst is returned by the TokenBucket and GCRA limiters if the cost of a request is
+// greater than the burst, and by Config.Limits if it is greater than the Max of a limit. The request
+// could never be allowed, so it is rejected without Retry-After.
+var ErrCostExceedsBurst = velocity.NewError(velocity.StatusRequestEntityTooLarge, "limiter: the cost of the request exceeds the burst")
+
 type Handler interface {
 	New(config Config) velocity.Handler
 }
@@ -20,6 +44,49 @@ func New(config ...Config) velocity.Handler {
 	// Set default config
 	cfg := configDefault(config...)
 
+	// Apply several limits at once
+	if len(cfg.Limits) > 0 {
+		return newTiered(cfg)
+	}
+
 	// Return the specified middleware handler.
 	return cfg.LimiterMiddleware.New(cfg)
 }
+
+// IsLimited returns true if the request was rejected by the limiter,
+// e.g. for metrics or logging in a middleware that is registered before the limiter.
+func IsLimited(c velocity.Ctx) bool {
+	limited, ok := c.Locals(limitedKey).(bool)
+	return ok && limited
+}
+
+// ExceededLimit returns the limit of Config.Limits that the request exceeded,
+// e.g. to tell the client in the LimitReached handler which limit it reached.
+// If several limits were exceeded, it returns the one that takes the longest to reset.
+func ExceededLimit(c velocity.Ctx) (Limit, bool) {
+	limit, ok := c.Locals(exceededLimitKey).(Limit)
+	return limit, ok
+}
+
+// setHeaders sets the X-RateLimit-* headers and the IETF draft RateLimit-Policy and RateLimit headers
+// with the quota of the window in seconds, the remaining quota and the seconds until the quota is restored
+func setHeaders(c velocity.Ctx, limit, remaining int, reset, window uint64) {
+	c.Set(xRateLimitLimit, strconv.Itoa(limit))
+	c.Set(xRateLimitRemaining, strconv.Itoa(remaining))
+	c.Set(xRateLimitReset, strconv.FormatUint(reset, 10))
+
+	c.Set(rateLimitPolicy, policyName+";q="+strconv.Itoa(limit)+";w="+strconv.FormatUint(window, 10))
+	c.Set(rateLimit, policyName+";r="+strconv.Itoa(max(remaining, 0))+";t="+strconv.FormatUint(reset, 10))
+}
+
+// costExceedsBurst marks the request as rejected and returns ErrCostExceedsBurst
+func costExceedsBurst(c velocity.Ctx) error {
+	c.Locals(limitedKey, true)
+	return ErrCostExceedsBurst
+}
+
+// limitReached marks the request as rejected and calls the LimitReached handler
+func limitReached(c velocity.Ctx, cfg Config) error {
+	c.Locals(limitedKey, true)
+	return cfg.LimitReached(c)
+}
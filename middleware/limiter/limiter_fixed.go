@@ -16,6 +16,8 @@ func (FixedWindow) New(cfg Config) velocity.Handler {
 		// Limiter variables
 		mux        = &sync.RWMutex{}
 		expiration = uint64(cfg.Expiration.Seconds())
+		// Length of the windows of the shared hits
+		windowLength = max(expiration, 1)
 	)
 
 	// Create manager to simplify storage operations ( see manager.go )
@@ -37,38 +39,59 @@ func (FixedWindow) New(cfg Config) velocity.Handler {
 		// Get key from request
 		key := cfg.KeyGenerator(c)
 
-		// Lock entry
-		mux.Lock()
+		// Get the number of hits of the request
+		cost := cfg.CostFunc(c)
+
+		var (
+			remaining  int
+			resetInSec uint64
+			window     uint64
+		)
+
+		if manager.atomic != nil {
+			// The windows of the shared hits start at multiples of the expiration
+			ts := uint64(utils.Timestamp())
+			window = ts - ts%windowLength
+			hits, err := manager.incr(key, window, cost, cfg.Expiration)
+			if err != nil {
+				return err
+			}
+			resetInSec = window + windowLength - ts
+			remaining = maxRequests - hits
+		} else {
+			// Lock entry
+			mux.Lock()
 
-		// Get entry from pool and release when finished
-		e := manager.get(key)
+			// Get entry from pool and release when finished
+			e := manager.get(key)
 
-		// Get timestamp
-		ts := uint64(utils.Timestamp())
+			// Get timestamp
+			ts := uint64(utils.Timestamp())
 
-		// Set expiration if entry does not exist
-		if e.exp == 0 {
-			e.exp = ts + expiration
-		} else if ts >= e.exp {
-			// Check if entry is expired
-			e.currHits = 0
-			e.exp = ts + expiration
-		}
+			// Set expiration if entry does not exist
+			if e.exp == 0 {
+				e.exp = ts + expiration
+			} else if ts >= e.exp {
+				// Check if entry is expired
+				e.currHits = 0
+				e.exp = ts + expiration
+			}
 
-		// Increment hits
-		e.currHits++
+			// Increment hits
+			e.currHits += cost
 
-		// Calculate when it resets in seconds
-		resetInSec := e.exp - ts
+			// Calculate when it resets in seconds
+			resetInSec = e.exp - ts
 
-		// Set how many hits we have left
-		remaining := maxRequests - e.currHits
+			// Set how many hits we have left
+			remaining = maxRequests - e.currHits
 
-		// Update storage
-		manager.set(key, e, cfg.Expiration)
+			// Update storage
+			manager.set(key, e, cfg.Expiration)
 
-		// Unlock entry
-		mux.Unlock()
+			// Unlock entry
+			mux.Unlock()
+		}
 
 		// Check if hits exceed the max
 		if remaining < 0 {
@@ -77,7 +100,7 @@ func (FixedWindow) New(cfg Config) velocity.Handler {
 			c.Set(velocity.HeaderRetryAfter, strconv.FormatUint(resetInSec, 10))
 
 			// Call LimitReached handler
-			return cfg.LimitReached(c)
+			return limitReached(c, cfg)
 		}
 
 		// Continue stack for reaching c.Response().StatusCode()
@@ -87,20 +110,24 @@ func (FixedWindow) New(cfg Config) velocity.Handler {
 		// Check for SkipFailedRequests and SkipSuccessfulRequests
 		if (cfg.SkipSuccessfulRequests && c.Response().StatusCode() < velocity.StatusBadRequest) ||
 			(cfg.SkipFailedRequests && c.Response().StatusCode() >= velocity.StatusBadRequest) {
-			// Lock entry
-			mux.Lock()
-			e = manager.get(key)
-			e.currHits--
-			remaining++
-			manager.set(key, e, cfg.Expiration)
-			// Unlock entry
-			mux.Unlock()
+			if manager.atomic != nil {
+				if _, incrErr := manager.incr(key, window, -cost, cfg.Expiration); incrErr != nil {
+					return incrErr
+				}
+			} else {
+				// Lock entry
+				mux.Lock()
+				e := manager.get(key)
+				e.currHits -= cost
+				manager.set(key, e, cfg.Expiration)
+				// Unlock entry
+				mux.Unlock()
+			}
+			remaining += cost
 		}
 
 		// We can continue, update RateLimit headers
-		c.Set(xRateLimitLimit, strconv.Itoa(maxRequests))
-		c.Set(xRateLimitRemaining, strconv.Itoa(remaining))
-		c.Set(xRateLimitReset, strconv.FormatUint(resetInSec, 10))
+		setHeaders(c, maxRequests, remaining, resetInSec, windowLength)
 
 		return err
 	}
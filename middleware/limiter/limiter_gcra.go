@@ -0,0 +1,135 @@
+package limiter
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// GCRA limits the requests with the generic cell rate algorithm. It allows the same
+// bursts and rate as a TokenBucket, but only stores the theoretical arrival time of
+// the next request per key, which is the time when the quota is fully restored.
+type GCRA struct {
+	// Burst is the number of requests that can be made at once.
+	//
+	// Default: the max of the config, see MaxFunc
+	Burst int
+
+	// Rate is the number of requests per second that are allowed after a burst.
+	//
+	// Default: the max of the config per Expiration
+	Rate float64
+}
+
+// New creates a new GCRA middleware handler
+func (g GCRA) New(cfg Config) velocity.Handler {
+	// Create store to simplify the atomic updates of the arrival times ( see state.go )
+	store := newStateStore(cfg.Storage)
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// The burst defaults to the max, if no generator was provided the default value returned is 5
+		burst := g.Burst
+		if burst <= 0 {
+			burst = cfg.MaxFunc(c)
+		}
+
+		// Don't execute middleware if Next returns true or if the burst is 0
+		if (cfg.Next != nil && cfg.Next(c)) || burst == 0 {
+			return c.Next()
+		}
+
+		rate := g.Rate
+		if rate <= 0 {
+			rate = min(float64(burst)/cfg.Expiration.Seconds(), maxRate)
+		}
+
+		// The emission interval is the time in which one request is restored,
+		// the tolerance is the time in which the whole burst is restored
+		interval := int64(float64(time.Second) / rate)
+		tolerance := int64(burst) * interval
+
+		// Get key and cost from request
+		key := cfg.KeyGenerator(c)
+		cost := int64(cfg.CostFunc(c))
+		if cost > int64(burst) {
+			return costExceedsBurst(c)
+		}
+
+		var (
+			allowed bool
+			now     int64
+			tat     int64
+		)
+		err := store.update(key, func(state []byte) ([]byte, time.Duration) {
+			now = time.Now().UnixNano()
+			tat = max(decodeArrival(state), now)
+
+			newTat := tat + cost*interval
+			allowed = newTat-tolerance <= now
+			if allowed {
+				tat = newTat
+			}
+			return encodeArrival(tat), time.Duration(tat-now) + time.Second
+		})
+		if err != nil {
+			return err
+		}
+
+		if !allowed {
+			// Return response with Retry-After header when the cost can be made
+			// https://tools.ietf.org/html/rfc6584
+			c.Set(velocity.HeaderRetryAfter, strconv.FormatUint(ceilSeconds(tat+cost*interval-tolerance-now), 10))
+
+			// Call LimitReached handler
+			return limitReached(c, cfg)
+		}
+
+		// Continue stack for reaching c.Response().StatusCode()
+		// Store err for returning
+		err = c.Next()
+
+		// Check for SkipFailedRequests and SkipSuccessfulRequests, the cost is given back
+		if (cfg.SkipSuccessfulRequests && c.Response().StatusCode() < velocity.StatusBadRequest) ||
+			(cfg.SkipFailedRequests && c.Response().StatusCode() >= velocity.StatusBadRequest) {
+			if updateErr := store.update(key, func(state []byte) ([]byte, time.Duration) {
+				now = time.Now().UnixNano()
+				tat = max(decodeArrival(state)-cost*interval, now)
+				return encodeArrival(tat), time.Duration(tat-now) + time.Second
+			}); updateErr != nil {
+				return updateErr
+			}
+		}
+
+		// We can continue, update RateLimit headers, the quota is restored at the arrival time
+		remaining := int((tolerance - (tat - now)) / interval)
+		setHeaders(c, burst, remaining, ceilSeconds(tat-now), ceilSeconds(tolerance))
+
+		return err
+	}
+}
+
+// decodeArrival returns the theoretical arrival time of the state, 0 for a new key
+func decodeArrival(state []byte) int64 {
+	if len(state) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(state)) //nolint:gosec // Not a concern
+}
+
+func encodeArrival(tat int64) []byte {
+	state := make([]byte, 8)
+	binary.BigEndian.PutUint64(state, uint64(tat)) //nolint:gosec // Not a concern
+	return state
+}
+
+// ceilSeconds returns the nanoseconds in seconds, rounded up
+func ceilSeconds(ns int64) uint64 {
+	if ns <= 0 {
+		return 0
+	}
+	return uint64(math.Ceil(float64(ns) / float64(time.Second)))
+}
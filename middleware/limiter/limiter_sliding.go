@@ -17,6 +17,8 @@ func (SlidingWindow) New(cfg Config) velocity.Handler {
 		// Limiter variables
 		mux        = &sync.RWMutex{}
 		expiration = uint64(cfg.Expiration.Seconds())
+		// Length of the windows of the shared hits
+		windowLength = max(expiration, 1)
 	)
 
 	// Create manager to simplify storage operations ( see manager.go )
@@ -38,69 +40,100 @@ func (SlidingWindow) New(cfg Config) velocity.Handler {
 		// Get key from request
 		key := cfg.KeyGenerator(c)
 
-		// Lock entry
-		mux.Lock()
+		// Get the number of hits of the request
+		cost := cfg.CostFunc(c)
+
+		var (
+			rate       int
+			resetInSec uint64
+			window     uint64
+		)
+
+		if manager.atomic != nil {
+			// The windows of the shared hits start at multiples of the expiration,
+			// the hits are kept for the next window
+			ts := uint64(utils.Timestamp())
+			window = ts - ts%windowLength
+			currHits, err := manager.incr(key, window, cost, 2*time.Duration(windowLength)*time.Second) //nolint:gosec // Not a concern
+			if err != nil {
+				return err
+			}
+			prevHits, err := manager.hits(key, window-windowLength)
+			if err != nil {
+				return err
+			}
+			resetInSec = window + windowLength - ts
 
-		// Get entry from pool and release when finished
-		e := manager.get(key)
+			// weight = time until current window reset / total window length
+			weight := float64(resetInSec) / float64(windowLength)
 
-		// Get timestamp
-		ts := uint64(utils.Timestamp())
+			// rate = request count in previous window - weight + request count in current window
+			rate = int(float64(prevHits)*weight) + currHits
+		} else {
+			// Lock entry
+			mux.Lock()
 
-		// Set expiration if entry does not exist
-		if e.exp == 0 {
-			e.exp = ts + expiration
-		} else if ts >= e.exp {
-			// The entry has expired, handle the expiration.
-			// Set the prevHits to the current hits and reset the hits to 0.
-			e.prevHits = e.currHits
+			// Get entry from pool and release when finished
+			e := manager.get(key)
 
-			// Reset the current hits to 0.
-			e.currHits = 0
+			// Get timestamp
+			ts := uint64(utils.Timestamp())
 
-			// Check how much into the current window it currently is and sets the
-			// expiry based on that, otherwise this would only reset on
-			// the next request and not show the correct expiry.
-			elapsed := ts - e.exp
-			if elapsed >= expiration {
+			// Set expiration if entry does not exist
+			if e.exp == 0 {
 				e.exp = ts + expiration
-			} else {
-				e.exp = ts + expiration - elapsed
+			} else if ts >= e.exp {
+				// The entry has expired, handle the expiration.
+				// Set the prevHits to the current hits and reset the hits to 0.
+				e.prevHits = e.currHits
+
+				// Reset the current hits to 0.
+				e.currHits = 0
+
+				// Check how much into the current window it currently is and sets the
+				// expiry based on that, otherwise this would only reset on
+				// the next request and not show the correct expiry.
+				elapsed := ts - e.exp
+				if elapsed >= expiration {
+					e.exp = ts + expiration
+				} else {
+					e.exp = ts + expiration - elapsed
+				}
 			}
-		}
 
-		// Increment hits
-		e.currHits++
+			// Increment hits
+			e.currHits += cost
+
+			// Calculate when it resets in seconds
+			resetInSec = e.exp - ts
 
-		// Calculate when it resets in seconds
-		resetInSec := e.exp - ts
+			// weight = time until current window reset / total window length
+			weight := float64(resetInSec) / float64(expiration)
 
-		// weight = time until current window reset / total window length
-		weight := float64(resetInSec) / float64(expiration)
+			// rate = request count in previous window - weight + request count in current window
+			rate = int(float64(e.prevHits)*weight) + e.currHits
 
-		// rate = request count in previous window - weight + request count in current window
-		rate := int(float64(e.prevHits)*weight) + e.currHits
+			// Update storage. Garbage collect when the next window ends.
+			// |--------------------------|--------------------------|
+			//               ^            ^               ^          ^
+			//              ts         e.exp   End sample window   End next window
+			//               <------------>
+			// 				   Reset In Sec
+			// resetInSec = e.exp - ts - time until end of current window.
+			// duration + expiration = end of next window.
+			// Because we don't want to garbage collect in the middle of a window
+			// we add the expiration to the duration.
+			// Otherwise after the end of "sample window", attackers could launch
+			// a new request with the full window length.
+			manager.set(key, e, time.Duration(resetInSec+expiration)*time.Second) //nolint:gosec // Not a concern
+
+			// Unlock entry
+			mux.Unlock()
+		}
 
 		// Calculate how many hits can be made based on the current rate
 		remaining := cfg.Max - rate
 
-		// Update storage. Garbage collect when the next window ends.
-		// |--------------------------|--------------------------|
-		//               ^            ^               ^          ^
-		//              ts         e.exp   End sample window   End next window
-		//               <------------>
-		// 				   Reset In Sec
-		// resetInSec = e.exp - ts - time until end of current window.
-		// duration + expiration = end of next window.
-		// Because we don't want to garbage collect in the middle of a window
-		// we add the expiration to the duration.
-		// Otherwise after the end of "sample window", attackers could launch
-		// a new request with the full window length.
-		manager.set(key, e, time.Duration(resetInSec+expiration)*time.Second) //nolint:gosec // Not a concern
-
-		// Unlock entry
-		mux.Unlock()
-
 		// Check if hits exceed the cfg.Max
 		if remaining < 0 {
 			// Return response with Retry-After header
@@ -108,7 +141,7 @@ func (SlidingWindow) New(cfg Config) velocity.Handler {
 			c.Set(velocity.HeaderRetryAfter, strconv.FormatUint(resetInSec, 10))
 
 			// Call LimitReached handler
-			return cfg.LimitReached(c)
+			return limitReached(c, cfg)
 		}
 
 		// Continue stack for reaching c.Response().StatusCode()
@@ -118,20 +151,24 @@ func (SlidingWindow) New(cfg Config) velocity.Handler {
 		// Check for SkipFailedRequests and SkipSuccessfulRequests
 		if (cfg.SkipSuccessfulRequests && c.Response().StatusCode() < velocity.StatusBadRequest) ||
 			(cfg.SkipFailedRequests && c.Response().StatusCode() >= velocity.StatusBadRequest) {
-			// Lock entry
-			mux.Lock()
-			e = manager.get(key)
-			e.currHits--
-			remaining++
-			manager.set(key, e, cfg.Expiration)
-			// Unlock entry
-			mux.Unlock()
+			if manager.atomic != nil {
+				if _, incrErr := manager.incr(key, window, -cost, 2*time.Duration(windowLength)*time.Second); incrErr != nil { //nolint:gosec // Not a concern
+					return incrErr
+				}
+			} else {
+				// Lock entry
+				mux.Lock()
+				e := manager.get(key)
+				e.currHits -= cost
+				manager.set(key, e, cfg.Expiration)
+				// Unlock entry
+				mux.Unlock()
+			}
+			remaining += cost
 		}
 
 		// We can continue, update RateLimit headers
-		c.Set(xRateLimitLimit, strconv.Itoa(maxRequests))
-		c.Set(xRateLimitRemaining, strconv.Itoa(remaining))
-		c.Set(xRateLimitReset, strconv.FormatUint(resetInSec, 10))
+		setHeaders(c, maxRequests, remaining, resetInSec, windowLength)
 
 		return err
 	}
@@ -2,7 +2,9 @@ package limiter
 
 import (
 	"io"
+	"math"
 	"net/http/httptest"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -227,6 +229,54 @@ func Test_Limiter_Concurrency(t *testing.T) {
 	require.Equal(t, 200, resp.StatusCode)
 }
 
+// go test -run Test_Limiter_Atomic_Storage -race -v
+func Test_Limiter_Atomic_Storage(t *testing.T) {
+	t.Parallel()
+
+	for _, middleware := range []Handler{FixedWindow{}, SlidingWindow{}, TokenBucket{}, GCRA{}} {
+		// several instances that share the storage
+		storage := memory.New()
+		handlers := make([]fasthttp.RequestHandler, 4)
+		for i := range handlers {
+			app := velocity.New()
+			app.Use(New(Config{
+				Max:               10,
+				Expiration:        time.Minute,
+				Storage:           storage,
+				LimiterMiddleware: middleware,
+			}))
+			app.Get("/", func(c velocity.Ctx) error {
+				return c.SendString("Hello tester!")
+			})
+			handlers[i] = app.Handler()
+		}
+
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			allowed int
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(h fasthttp.RequestHandler) {
+				defer wg.Done()
+				ctx := &fasthttp.RequestCtx{}
+				ctx.Request.Header.SetMethod(velocity.MethodGet)
+				ctx.Request.SetRequestURI("/")
+				h(ctx)
+				if ctx.Response.StatusCode() == velocity.StatusOK {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}(handlers[i%len(handlers)])
+		}
+		wg.Wait()
+
+		require.Equal(t, 10, allowed)
+	}
+}
+
 // go test -run Test_Limiter_Fixed_Window_No_Skip_Choices -v
 func Test_Limiter_Fixed_Window_No_Skip_Choices(t *testing.T) {
 	t.Parallel()
@@ -735,6 +785,31 @@ func Test_Limiter_Next(t *testing.T) {
 	require.Equal(t, velocity.StatusNotFound, resp.StatusCode)
 }
 
+// go test -run Test_Limiter_IsLimited
+func Test_Limiter_IsLimited(t *testing.T) {
+	t.Parallel()
+
+	for _, middleware := range []Handler{FixedWindow{}, SlidingWindow{}} {
+		var limited []bool
+		app := velocity.New()
+		app.Use(func(c velocity.Ctx) error {
+			err := c.Next()
+			limited = append(limited, IsLimited(c))
+			return err
+		})
+		app.Use(New(Config{Max: 1, LimiterMiddleware: middleware}))
+		app.Get("/", func(c velocity.Ctx) error {
+			return c.SendString("Hello tester!")
+		})
+
+		for i := 0; i < 2; i++ {
+			_, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+			require.NoError(t, err)
+		}
+		require.Equal(t, []bool{false, true}, limited)
+	}
+}
+
 func Test_Limiter_Headers(t *testing.T) {
 	t.Parallel()
 	app := velocity.New()
@@ -761,6 +836,423 @@ func Test_Limiter_Headers(t *testing.T) {
 	if v := string(fctx.Response.Header.Peek("X-RateLimit-Reset")); !(v == "1" || v == "2") {
 		t.Errorf("The X-RateLimit-Reset header is not set correctly - value is out of bounds.")
 	}
+	require.Equal(t, `"default";q=50;w=2`, string(fctx.Response.Header.Peek("RateLimit-Policy")))
+	require.Regexp(t, `^"default";r=49;t=[12]$`, string(fctx.Response.Header.Peek("RateLimit")))
+}
+
+// go test -run Test_Limiter_Token_Bucket -race -v
+func Test_Limiter_Token_Bucket(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	app.Use(New(Config{
+		LimiterMiddleware: TokenBucket{Burst: 3, Rate: 2},
+	}))
+
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString("Hello tester!")
+	})
+
+	// the burst is allowed at once
+	for i := 2; i >= 0; i-- {
+		resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+		require.NoError(t, err)
+		require.Equal(t, velocity.StatusOK, resp.StatusCode)
+		require.Equal(t, "3", resp.Header.Get("X-RateLimit-Limit"))
+		require.Equal(t, strconv.Itoa(i), resp.Header.Get("X-RateLimit-Remaining"))
+		require.Equal(t, `"default";q=3;w=2`, resp.Header.Get("RateLimit-Policy"))
+		require.Equal(t, `"default";r=`+strconv.Itoa(i)+`;t=`+strconv.Itoa((4-i)/2), resp.Header.Get("RateLimit"))
+	}
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "1", resp.Header.Get(velocity.HeaderRetryAfter))
+
+	// a token is added every half second
+	time.Sleep(600 * time.Millisecond)
+
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusTooManyRequests, resp.StatusCode)
+}
+
+// go test -run Test_Limiter_GCRA -race -v
+func Test_Limiter_GCRA(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	app.Use(New(Config{
+		LimiterMiddleware: GCRA{Burst: 3, Rate: 2},
+	}))
+
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString("Hello tester!")
+	})
+
+	for i := 2; i >= 0; i-- {
+		resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+		require.NoError(t, err)
+		require.Equal(t, velocity.StatusOK, resp.StatusCode)
+		require.Equal(t, strconv.Itoa(i), resp.Header.Get("X-RateLimit-Remaining"))
+		require.Equal(t, `"default";q=3;w=2`, resp.Header.Get("RateLimit-Policy"))
+		require.Equal(t, `"default";r=`+strconv.Itoa(i)+`;t=`+strconv.Itoa((4-i)/2), resp.Header.Get("RateLimit"))
+	}
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "1", resp.Header.Get(velocity.HeaderRetryAfter))
+
+	// a request is restored every half second
+	time.Sleep(600 * time.Millisecond)
+
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusTooManyRequests, resp.StatusCode)
+}
+
+// go test -run Test_Limiter_Cost -race -v
+func Test_Limiter_Cost(t *testing.T) {
+	t.Parallel()
+
+	for _, middleware := range []Handler{FixedWindow{}, SlidingWindow{}, TokenBucket{}, GCRA{}} {
+		app := velocity.New()
+
+		app.Use(New(Config{
+			Max:        5,
+			Expiration: time.Minute,
+			CostFunc: func(c velocity.Ctx) int {
+				if c.Path() == "/expensive" {
+					return 2
+				}
+				return 1
+			},
+			LimiterMiddleware: middleware,
+		}))
+
+		app.Get("/*", func(c velocity.Ctx) error {
+			return c.SendString("Hello tester!")
+		})
+
+		for _, tc := range []struct {
+			path   string
+			status int
+		}{
+			{"/expensive", velocity.StatusOK},
+			{"/expensive", velocity.StatusOK},
+			{"/cheap", velocity.StatusOK},
+			{"/expensive", velocity.StatusTooManyRequests},
+		} {
+			resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, tc.path, nil))
+			require.NoError(t, err)
+			require.Equal(t, tc.status, resp.StatusCode, "%T %s", middleware, tc.path)
+		}
+	}
+}
+
+// go test -run Test_Limiter_Cost_Below_One -race -v
+func Test_Limiter_Cost_Below_One(t *testing.T) {
+	t.Parallel()
+
+	for _, middleware := range []Handler{FixedWindow{}, SlidingWindow{}, TokenBucket{}, GCRA{}} {
+		app := velocity.New()
+
+		app.Use(New(Config{
+			Max:        2,
+			Expiration: time.Minute,
+			CostFunc: func(c velocity.Ctx) int {
+				if c.Path() == "/negative" {
+					return -5
+				}
+				return 0
+			},
+			LimiterMiddleware: middleware,
+		}))
+
+		app.Get("/*", func(c velocity.Ctx) error {
+			return c.SendString("Hello tester!")
+		})
+
+		// Every request counts as 1, the negative cost doesn't give hits or tokens back
+		for _, tc := range []struct {
+			path   string
+			status int
+		}{
+			{"/zero", velocity.StatusOK},
+			{"/negative", velocity.StatusOK},
+			{"/negative", velocity.StatusTooManyRequests},
+			{"/zero", velocity.StatusTooManyRequests},
+		} {
+			resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, tc.path, nil))
+			require.NoError(t, err)
+			require.Equal(t, tc.status, resp.StatusCode, "%T %s", middleware, tc.path)
+		}
+	}
+}
+
+// go test -run Test_Limiter_Rate_Invalid
+func Test_Limiter_Rate_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, rate := range []float64{math.NaN(), math.Inf(1), 2 * float64(time.Second)} {
+		require.Panics(t, func() {
+			New(Config{LimiterMiddleware: TokenBucket{Rate: rate}})
+		})
+		require.Panics(t, func() {
+			New(Config{LimiterMiddleware: GCRA{Rate: rate}})
+		})
+	}
+
+	// The highest rate restores one request per nanosecond
+	for _, middleware := range []Handler{TokenBucket{Burst: 1, Rate: float64(time.Second)}, GCRA{Burst: 1, Rate: float64(time.Second)}} {
+		app := velocity.New()
+		app.Use(New(Config{LimiterMiddleware: middleware}))
+		app.Get("/", func(c velocity.Ctx) error {
+			return c.SendString("Hello tester!")
+		})
+
+		resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+		require.NoError(t, err)
+		require.Equal(t, velocity.StatusOK, resp.StatusCode, "%T", middleware)
+	}
+}
+
+// go test -run Test_Limiter_Cost_Exceeds_Burst -race -v
+func Test_Limiter_Cost_Exceeds_Burst(t *testing.T) {
+	t.Parallel()
+
+	for _, middleware := range []Handler{TokenBucket{Burst: 5, Rate: 1}, GCRA{Burst: 5, Rate: 1}} {
+		app := velocity.New()
+
+		app.Use(func(c velocity.Ctx) error {
+			err := c.Next()
+			c.Set("X-Limited", strconv.FormatBool(IsLimited(c)))
+			return err
+		})
+		app.Use(New(Config{
+			CostFunc: func(c velocity.Ctx) int {
+				if c.Path() == "/export" {
+					return 6
+				}
+				return 1
+			},
+			LimiterMiddleware: middleware,
+		}))
+
+		app.Get("/*", func(c velocity.Ctx) error {
+			return c.SendString("Hello tester!")
+		})
+
+		// The request can never be allowed, so there is no time to retry after
+		resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/export", nil))
+		require.NoError(t, err)
+		require.Equal(t, velocity.StatusRequestEntityTooLarge, resp.StatusCode, "%T", middleware)
+		require.Empty(t, resp.Header.Get(velocity.HeaderRetryAfter), "%T", middleware)
+		require.Equal(t, "true", resp.Header.Get("X-Limited"), "%T", middleware)
+
+		// The rejected request doesn't consume the burst
+		for i := 0; i < 5; i++ {
+			resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/cheap", nil))
+			require.NoError(t, err)
+			require.Equal(t, velocity.StatusOK, resp.StatusCode, "%T", middleware)
+		}
+	}
+}
+
+// go test -run Test_Limiter_Bucket_Skip_Failed_Requests -race -v
+func Test_Limiter_Bucket_Skip_Failed_Requests(t *testing.T) {
+	t.Parallel()
+
+	for _, middleware := range []Handler{TokenBucket{Burst: 1, Rate: 0.1}, GCRA{Burst: 1, Rate: 0.1}} {
+		app := velocity.New()
+
+		app.Use(New(Config{
+			SkipFailedRequests: true,
+			LimiterMiddleware:  middleware,
+		}))
+
+		app.Get("/:status", func(c velocity.Ctx) error {
+			if c.Params("status") == "fail" {
+				return c.SendStatus(400)
+			}
+			return c.SendStatus(200)
+		})
+
+		// the failed requests don't consume the burst
+		for i := 0; i < 3; i++ {
+			resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/fail", nil))
+			require.NoError(t, err)
+			require.Equal(t, 400, resp.StatusCode, "%T", middleware)
+		}
+
+		resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/success", nil))
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode, "%T", middleware)
+
+		resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/success", nil))
+		require.NoError(t, err)
+		require.Equal(t, 429, resp.StatusCode, "%T", middleware)
+	}
+}
+
+// go test -run Test_Limiter_Limits -race -v
+func Test_Limiter_Limits(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	app.Use(New(Config{
+		Limits: []Limit{
+			{Name: "second", Max: 2, Expiration: time.Second},
+			{Name: "minute", Max: 3, Expiration: time.Minute},
+		},
+		LimitReached: func(c velocity.Ctx) error {
+			limit, ok := ExceededLimit(c)
+			require.True(t, ok)
+			return c.Status(velocity.StatusTooManyRequests).SendString(limit.Name)
+		},
+	}))
+
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString("Hello tester!")
+	})
+
+	for _, tc := range []struct {
+		rateLimit string
+		remaining string
+	}{
+		{`"second";r=1;t=1`, "1"},
+		{`"second";r=0;t=1`, "0"},
+	} {
+		resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+		require.NoError(t, err)
+		require.Equal(t, velocity.StatusOK, resp.StatusCode)
+		require.Equal(t, "2", resp.Header.Get("X-RateLimit-Limit"))
+		require.Equal(t, tc.remaining, resp.Header.Get("X-RateLimit-Remaining"))
+		require.Equal(t, `"second";q=2;w=1, "minute";q=3;w=60`, resp.Header.Get("RateLimit-Policy"))
+		require.Equal(t, tc.rateLimit, resp.Header.Get("RateLimit"))
+	}
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "1", resp.Header.Get(velocity.HeaderRetryAfter))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "second", string(body))
+
+	time.Sleep(1100 * time.Millisecond)
+
+	// the rejected request was not counted by the minute limit
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, "3", resp.Header.Get("X-RateLimit-Limit"))
+	require.Equal(t, "0", resp.Header.Get("X-RateLimit-Remaining"))
+	require.Regexp(t, `^"minute";r=0;t=(59|60)$`, resp.Header.Get("RateLimit"))
+
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusTooManyRequests, resp.StatusCode)
+	require.Regexp(t, `^(58|59)$`, resp.Header.Get(velocity.HeaderRetryAfter))
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "minute", string(body))
+}
+
+// go test -run Test_Limiter_Limits_Skip_Successful_Requests -race -v
+func Test_Limiter_Limits_Skip_Successful_Requests(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	app.Use(New(Config{
+		Limits: []Limit{
+			{Max: 1, Expiration: time.Second},
+			{Max: 2, Expiration: time.Minute},
+		},
+		SkipSuccessfulRequests: true,
+		Storage:                memory.New(),
+	}))
+
+	app.Get("/:status", func(c velocity.Ctx) error {
+		if c.Params("status") == "fail" {
+			return c.SendStatus(400)
+		}
+		return c.SendStatus(200)
+	})
+
+	// the successful requests don't count towards any limit
+	for i := 0; i < 3; i++ {
+		resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/success", nil))
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode)
+		require.Equal(t, `"tier1";q=1;w=1, "tier2";q=2;w=60`, resp.Header.Get("RateLimit-Policy"))
+	}
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/fail", nil))
+	require.NoError(t, err)
+	require.Equal(t, 400, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/fail", nil))
+	require.NoError(t, err)
+	require.Equal(t, 429, resp.StatusCode)
+}
+
+// go test -run Test_Limiter_Limits_Cost_Exceeds_Max -race -v
+func Test_Limiter_Limits_Cost_Exceeds_Max(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	app.Use(New(Config{
+		Limits: []Limit{
+			{Max: 10, Expiration: time.Second},
+			{Max: 5, Expiration: time.Minute},
+		},
+		CostFunc: func(c velocity.Ctx) int {
+			if c.Path() == "/export" {
+				return 6
+			}
+			return 1
+		},
+		Storage: memory.New(),
+	}))
+
+	app.Get("/*", func(c velocity.Ctx) error {
+		return c.SendString("Hello tester!")
+	})
+
+	// The request exceeds the max of the second limit, so there is no time to retry after
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/export", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusRequestEntityTooLarge, resp.StatusCode)
+	require.Empty(t, resp.Header.Get(velocity.HeaderRetryAfter))
+
+	// The rejected request doesn't count towards any limit
+	for i := 0; i < 5; i++ {
+		resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/cheap", nil))
+		require.NoError(t, err)
+		require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	}
+}
+
+// go test -run Test_Limiter_Limits_Invalid
+func Test_Limiter_Limits_Invalid(t *testing.T) {
+	t.Parallel()
+
+	require.Panics(t, func() {
+		New(Config{Limits: []Limit{{Max: 10, Expiration: time.Second}, {Max: 0, Expiration: time.Minute}}})
+	})
+	require.Panics(t, func() {
+		New(Config{Limits: []Limit{{Max: 10, Expiration: time.Millisecond}}})
+	})
 }
 
 // go test -v -run=^$ -bench=Benchmark_Limiter -benchmem -count=4
@@ -0,0 +1,174 @@
+package limiter
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// tierWindow is the fixed window of a limit, it starts at the first hit after the previous window
+type tierWindow struct {
+	start int64
+	hits  int64
+}
+
+// newTiered creates a handler that applies all Config.Limits to a key. The windows of all
+// limits are stored in one state, so that they are checked and updated with one storage
+// operation and a rejected request is not counted by any of them.
+func newTiered(cfg Config) velocity.Handler {
+	// Create store to simplify the atomic updates of the windows ( see state.go )
+	store := newStateStore(cfg.Storage)
+
+	// The RateLimit-Policy header lists all limits and is the same for every request
+	policies := make([]string, len(cfg.Limits))
+	for i, limit := range cfg.Limits {
+		policies[i] = strconv.Quote(limit.Name) + ";q=" + strconv.Itoa(limit.Max) + ";w=" + strconv.FormatUint(ceilSeconds(int64(limit.Expiration)), 10)
+	}
+	policy := strings.Join(policies, ", ")
+
+	// A request that costs more than the smallest max could never be allowed
+	maxLimit := int64(cfg.Limits[0].Max)
+	for _, limit := range cfg.Limits[1:] {
+		maxLimit = min(maxLimit, int64(limit.Max))
+	}
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Get key and cost from request
+		key := cfg.KeyGenerator(c)
+		cost := int64(cfg.CostFunc(c))
+		if cost > maxLimit {
+			return costExceedsBurst(c)
+		}
+
+		var (
+			windows  []tierWindow
+			exceeded int
+			now      int64
+		)
+		err := store.update(key, func(state []byte) ([]byte, time.Duration) {
+			now = time.Now().UnixNano()
+			windows = decodeTiers(state, cfg.Limits, now)
+
+			// Find the exceeded limit that takes the longest to reset
+			exceeded = -1
+			for i, limit := range cfg.Limits {
+				if windows[i].hits+cost <= int64(limit.Max) {
+					continue
+				}
+				if exceeded < 0 || resetAt(windows[i], limit) > resetAt(windows[exceeded], cfg.Limits[exceeded]) {
+					exceeded = i
+				}
+			}
+			if exceeded < 0 {
+				for i := range windows {
+					windows[i].hits += cost
+				}
+			}
+			return encodeTiers(windows), tiersExpiration(windows, cfg.Limits, now)
+		})
+		if err != nil {
+			return err
+		}
+
+		if exceeded >= 0 {
+			// Return response with Retry-After header when the exceeded limit resets
+			// https://tools.ietf.org/html/rfc6584
+			c.Set(velocity.HeaderRetryAfter, strconv.FormatUint(ceilSeconds(resetAt(windows[exceeded], cfg.Limits[exceeded])-now), 10))
+
+			// Call LimitReached handler
+			c.Locals(exceededLimitKey, cfg.Limits[exceeded])
+			return limitReached(c, cfg)
+		}
+
+		// Continue stack for reaching c.Response().StatusCode()
+		// Store err for returning
+		err = c.Next()
+
+		// Check for SkipFailedRequests and SkipSuccessfulRequests, the hits are removed
+		// from the windows that were not reset in the meantime
+		if (cfg.SkipSuccessfulRequests && c.Response().StatusCode() < velocity.StatusBadRequest) ||
+			(cfg.SkipFailedRequests && c.Response().StatusCode() >= velocity.StatusBadRequest) {
+			counted := windows
+			if updateErr := store.update(key, func(state []byte) ([]byte, time.Duration) {
+				now = time.Now().UnixNano()
+				windows = decodeTiers(state, cfg.Limits, now)
+				for i := range windows {
+					if windows[i].start == counted[i].start {
+						windows[i].hits = max(windows[i].hits-cost, 0)
+					}
+				}
+				return encodeTiers(windows), tiersExpiration(windows, cfg.Limits, now)
+			}); updateErr != nil {
+				return updateErr
+			}
+		}
+
+		// We can continue, update RateLimit headers with the most restrictive limit,
+		// the one with the fewest remaining hits or the longest reset of those
+		restrictive := 0
+		for i, limit := range cfg.Limits {
+			remaining := int64(limit.Max) - windows[i].hits
+			least := int64(cfg.Limits[restrictive].Max) - windows[restrictive].hits
+			if remaining < least || (remaining == least && resetAt(windows[i], limit) > resetAt(windows[restrictive], cfg.Limits[restrictive])) {
+				restrictive = i
+			}
+		}
+		limit := cfg.Limits[restrictive]
+		remaining := limit.Max - int(windows[restrictive].hits)
+		reset := ceilSeconds(resetAt(windows[restrictive], limit) - now)
+		setHeaders(c, limit.Max, remaining, reset, ceilSeconds(int64(limit.Expiration)))
+		c.Set(rateLimitPolicy, policy)
+		c.Set(rateLimit, strconv.Quote(limit.Name)+";r="+strconv.Itoa(max(remaining, 0))+";t="+strconv.FormatUint(reset, 10))
+
+		return err
+	}
+}
+
+// resetAt returns the time in nanoseconds at which the window of the limit resets
+func resetAt(window tierWindow, limit Limit) int64 {
+	return window.start + int64(limit.Expiration)
+}
+
+// decodeTiers returns the windows of the limits at the time, new windows are started for
+// the expired ones and for all if the limits were changed
+func decodeTiers(state []byte, limits []Limit, now int64) []tierWindow {
+	windows := make([]tierWindow, len(limits))
+	valid := len(state) == 16*len(limits)
+	for i, limit := range limits {
+		if valid {
+			windows[i].start = int64(binary.BigEndian.Uint64(state[16*i:]))  //nolint:gosec // Not a concern
+			windows[i].hits = int64(binary.BigEndian.Uint64(state[16*i+8:])) //nolint:gosec // Not a concern
+		}
+		if !valid || now >= resetAt(windows[i], limit) {
+			windows[i] = tierWindow{start: now}
+		}
+	}
+	return windows
+}
+
+func encodeTiers(windows []tierWindow) []byte {
+	state := make([]byte, 16*len(windows))
+	for i, window := range windows {
+		binary.BigEndian.PutUint64(state[16*i:], uint64(window.start))  //nolint:gosec // Not a concern
+		binary.BigEndian.PutUint64(state[16*i+8:], uint64(window.hits)) //nolint:gosec // Not a concern
+	}
+	return state
+}
+
+// tiersExpiration returns when the last window resets, the state is not needed after that
+func tiersExpiration(windows []tierWindow, limits []Limit, now int64) time.Duration {
+	var last int64
+	for i, limit := range limits {
+		last = max(last, resetAt(windows[i], limit))
+	}
+	return time.Duration(last-now) + time.Second
+}
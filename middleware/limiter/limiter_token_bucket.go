@@ -0,0 +1,136 @@
+package limiter
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// TokenBucket limits the requests with a bucket per key that holds up to Burst tokens
+// and is refilled with Rate tokens per second. A request consumes the tokens of its cost,
+// so that bursts up to the capacity are allowed while the sustained rate is limited.
+type TokenBucket struct {
+	// Burst is the capacity of the bucket, the number of requests that can be made at once.
+	//
+	// Default: the max of the config, see MaxFunc
+	Burst int
+
+	// Rate is the number of tokens that are added to the bucket per second.
+	//
+	// Default: the max of the config per Expiration
+	Rate float64
+}
+
+// New creates a new token bucket middleware handler
+func (t TokenBucket) New(cfg Config) velocity.Handler {
+	// Create store to simplify the atomic updates of the buckets ( see state.go )
+	store := newStateStore(cfg.Storage)
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// The capacity defaults to the max, if no generator was provided the default value returned is 5
+		burst := t.Burst
+		if burst <= 0 {
+			burst = cfg.MaxFunc(c)
+		}
+
+		// Don't execute middleware if Next returns true or if the capacity is 0
+		if (cfg.Next != nil && cfg.Next(c)) || burst == 0 {
+			return c.Next()
+		}
+
+		rate := t.Rate
+		if rate <= 0 {
+			rate = min(float64(burst)/cfg.Expiration.Seconds(), maxRate)
+		}
+
+		// Get key and cost from request
+		key := cfg.KeyGenerator(c)
+		cost := float64(cfg.CostFunc(c))
+		if cost > float64(burst) {
+			return costExceedsBurst(c)
+		}
+
+		var (
+			allowed bool
+			tokens  float64
+		)
+		err := store.update(key, func(state []byte) ([]byte, time.Duration) {
+			now := time.Now().UnixNano()
+			tokens = refill(state, now, burst, rate)
+
+			allowed = tokens >= cost
+			if allowed {
+				tokens -= cost
+			}
+			return encodeBucket(tokens, now), bucketExpiration(tokens, burst, rate)
+		})
+		if err != nil {
+			return err
+		}
+
+		if !allowed {
+			// Return response with Retry-After header when the tokens of the cost are available
+			// https://tools.ietf.org/html/rfc6584
+			c.Set(velocity.HeaderRetryAfter, strconv.FormatUint(seconds(cost-tokens, rate), 10))
+
+			// Call LimitReached handler
+			return limitReached(c, cfg)
+		}
+
+		// Continue stack for reaching c.Response().StatusCode()
+		// Store err for returning
+		err = c.Next()
+
+		// Check for SkipFailedRequests and SkipSuccessfulRequests, the tokens are given back
+		if (cfg.SkipSuccessfulRequests && c.Response().StatusCode() < velocity.StatusBadRequest) ||
+			(cfg.SkipFailedRequests && c.Response().StatusCode() >= velocity.StatusBadRequest) {
+			if updateErr := store.update(key, func(state []byte) ([]byte, time.Duration) {
+				now := time.Now().UnixNano()
+				tokens = min(refill(state, now, burst, rate)+cost, float64(burst))
+				return encodeBucket(tokens, now), bucketExpiration(tokens, burst, rate)
+			}); updateErr != nil {
+				return updateErr
+			}
+		}
+
+		// We can continue, update RateLimit headers, the quota is restored when the bucket is full
+		setHeaders(c, burst, int(tokens), seconds(float64(burst)-tokens, rate), seconds(float64(burst), rate))
+
+		return err
+	}
+}
+
+// refill returns the tokens of the bucket state at the time, a new bucket is full
+func refill(state []byte, now int64, burst int, rate float64) float64 {
+	if len(state) != 16 {
+		return float64(burst)
+	}
+	tokens := math.Float64frombits(binary.BigEndian.Uint64(state))
+	last := int64(binary.BigEndian.Uint64(state[8:])) //nolint:gosec // Not a concern
+	elapsed := float64(max(now-last, 0)) / float64(time.Second)
+	return min(tokens+elapsed*rate, float64(burst))
+}
+
+func encodeBucket(tokens float64, now int64) []byte {
+	state := make([]byte, 16)
+	binary.BigEndian.PutUint64(state, math.Float64bits(tokens))
+	binary.BigEndian.PutUint64(state[8:], uint64(now)) //nolint:gosec // Not a concern
+	return state
+}
+
+// bucketExpiration returns when the bucket is full again, a full bucket doesn't have to be stored
+func bucketExpiration(tokens float64, burst int, rate float64) time.Duration {
+	return time.Duration(seconds(float64(burst)-tokens, rate)+1) * time.Second //nolint:gosec // Not a concern
+}
+
+// seconds returns the seconds it takes to add the tokens with the rate, rounded up
+func seconds(tokens, rate float64) uint64 {
+	if tokens <= 0 {
+		return 0
+	}
+	return uint64(math.Ceil(tokens / rate))
+}
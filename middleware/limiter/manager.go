@@ -1,11 +1,13 @@
 package limiter
 
 import (
+	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/khulnasoft/velocity"
-	"github.com/khulnasoft/velocity/internal/memory"
+	"github.com/khulnasoft/velocity/internal/storage/shared"
 )
 
 // msgp -file="manager.go" -o="manager_msgp.go" -tests=false -unexported
@@ -20,8 +22,8 @@ type item struct {
 //msgp:ignore manager
 type manager struct {
 	pool    sync.Pool
-	memory  *memory.Storage
 	storage velocity.Storage
+	atomic  velocity.AtomicStorage
 }
 
 func newManager(storage velocity.Storage) *manager {
@@ -33,13 +35,12 @@ func newManager(storage velocity.Storage) *manager {
 			},
 		},
 	}
-	if storage != nil {
-		// Use provided storage if provided
-		manager.storage = storage
-	} else {
-		// Fallback too memory storage
-		manager.memory = memory.New()
+	if storage == nil {
+		// Fallback to memory storage, which supports the atomic operations
+		storage = newMemory()
 	}
+	manager.storage = storage
+	manager.atomic = shared.Atomic(storage)
 	return manager
 }
 
@@ -56,38 +57,56 @@ func (m *manager) release(e *item) {
 	m.pool.Put(e)
 }
 
-// get data from storage or memory
+// get data from storage
 func (m *manager) get(key string) *item {
-	var it *item
-	if m.storage != nil {
-		it = m.acquire()
-		raw, err := m.storage.Get(key)
-		if err != nil {
-			return it
-		}
-		if raw != nil {
-			if _, err := it.UnmarshalMsg(raw); err != nil {
-				return it
-			}
-		}
+	it := m.acquire()
+	raw, err := m.storage.Get(key)
+	if err != nil {
 		return it
 	}
-	if it, _ = m.memory.Get(key).(*item); it == nil { //nolint:errcheck // We store nothing else in the pool
-		it = m.acquire()
-		return it
+	if raw != nil {
+		if _, err := it.UnmarshalMsg(raw); err != nil {
+			return it
+		}
 	}
 	return it
 }
 
-// set data to storage or memory
+// set data to storage
 func (m *manager) set(key string, it *item, exp time.Duration) {
-	if m.storage != nil {
-		if raw, err := it.MarshalMsg(nil); err == nil {
-			_ = m.storage.Set(key, raw, exp) //nolint:errcheck // TODO: Handle error here
-		}
-		// we can release data because it's serialized to database
-		m.release(it)
-	} else {
-		m.memory.Set(key, it, exp)
+	if raw, err := it.MarshalMsg(nil); err == nil {
+		_ = m.storage.Set(key, raw, exp) //nolint:errcheck // TODO: Handle error here
 	}
+	// we can release data because it's serialized to database
+	m.release(it)
+}
+
+// incr increments the hits of the window that starts at the timestamp and returns them
+func (m *manager) incr(key string, window uint64, delta int, exp time.Duration) (int, error) {
+	hits, err := m.atomic.Incr(windowKey(key, window), int64(delta), exp)
+	if err != nil {
+		return 0, fmt.Errorf("limiter: failed to count hits: %w", err)
+	}
+	return int(hits), nil
+}
+
+// hits returns the hits of the window that starts at the timestamp
+func (m *manager) hits(key string, window uint64) (int, error) {
+	raw, err := m.atomic.Get(windowKey(key, window))
+	if err != nil {
+		return 0, fmt.Errorf("limiter: failed to get hits: %w", err)
+	}
+	if raw == nil {
+		return 0, nil
+	}
+	hits, err := strconv.Atoi(string(raw))
+	if err != nil {
+		return 0, fmt.Errorf("limiter: failed to parse hits: %w", err)
+	}
+	return hits, nil
+}
+
+// windowKey returns the key of the hits of a window, the windows start at multiples of the expiration
+func windowKey(key string, window uint64) string {
+	return key + ":" + strconv.FormatUint(window, 10)
 }
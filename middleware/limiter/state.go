@@ -0,0 +1,36 @@
+package limiter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/shared"
+)
+
+// stateStore updates the encoded state of a key with a read-modify-write
+type stateStore struct {
+	updater *shared.Updater
+}
+
+func newStateStore(storage velocity.Storage) *stateStore {
+	// Fallback to memory storage, which supports the atomic operations
+	if storage == nil {
+		storage = newMemory()
+	}
+	return &stateStore{updater: shared.NewUpdater(storage)}
+}
+
+// update calls fn with the current state of the key, which is nil if the key does not exist,
+// and stores the returned state with the expiration. fn is called again if the state
+// was changed by another instance in the meantime.
+func (s *stateStore) update(key string, fn func(state []byte) ([]byte, time.Duration)) error {
+	err := s.updater.Update(key, func(old []byte) ([]byte, time.Duration, error) {
+		state, exp := fn(old)
+		return state, exp, nil
+	})
+	if err != nil {
+		return fmt.Errorf("limiter: failed to update state: %w", err)
+	}
+	return nil
+}
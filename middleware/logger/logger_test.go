@@ -20,6 +20,7 @@ import (
 	"github.com/khulnasoft/velocity"
 	velocitylog "github.com/khulnasoft/velocity/log"
 	"github.com/khulnasoft/velocity/middleware/requestid"
+	"github.com/khulnasoft/velocity/middleware/tracing"
 	"github.com/stretchr/testify/require"
 	"github.com/valyala/bytebufferpool"
 	"github.com/valyala/fasthttp"
@@ -624,6 +625,33 @@ func Test_Response_Header(t *testing.T) {
 	require.Equal(t, "Hello velocity!", buf.String())
 }
 
+// go test -run Test_Logger_Trace
+func Test_Logger_Trace(t *testing.T) {
+	t.Parallel()
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	app := velocity.New()
+
+	app.Use(New(Config{
+		Format: "${traceID} ${spanID}",
+		Output: buf,
+	}))
+	app.Use(tracing.New())
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString("Hello velocity!")
+	})
+
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := app.Test(req)
+
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Regexp(t, "^4bf92f3577b34da6a3ce929d0e0e4736 [0-9a-f]{16}$", buf.String())
+	require.NotContains(t, buf.String(), "00f067aa0ba902b7")
+}
+
 // go test -run Test_Req_Header
 func Test_Req_Header(t *testing.T) {
 	t.Parallel()
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/tracecontext"
 )
 
 // Logger variables
@@ -32,6 +33,8 @@ const (
 	TagBytesReceived     = "bytesReceived"
 	TagRoute             = "route"
 	TagError             = "error"
+	TagTraceID           = "traceID"
+	TagSpanID            = "spanID"
 	TagReqHeader         = "reqHeader:"
 	TagRespHeader        = "respHeader:"
 	TagLocals            = "locals:"
@@ -95,6 +98,18 @@ func createTagMap(cfg *Config) map[string]LogFunc {
 		TagRoute: func(output Buffer, c velocity.Ctx, _ *Data, _ string) (int, error) {
 			return output.WriteString(c.Route().Path)
 		},
+		TagTraceID: func(output Buffer, c velocity.Ctx, _ *Data, _ string) (int, error) {
+			if sc, ok := tracecontext.FromContext(c.Context()); ok {
+				return output.WriteString(sc.TraceID.String())
+			}
+			return 0, nil
+		},
+		TagSpanID: func(output Buffer, c velocity.Ctx, _ *Data, _ string) (int, error) {
+			if sc, ok := tracecontext.FromContext(c.Context()); ok {
+				return output.WriteString(sc.SpanID.String())
+			}
+			return 0, nil
+		},
 		TagResBody: func(output Buffer, c velocity.Ctx, _ *Data, _ string) (int, error) {
 			return output.Write(c.Response().Body())
 		},
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"slices"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c velocity.Ctx) bool
+
+	// Path is the route that serves the metrics for GET and HEAD requests.
+	// The requests to it are not recorded.
+	//
+	// Optional. Default: "/metrics"
+	Path string
+
+	// Namespace is prepended to the metric names, e.g. "myapp" results in "myapp_http_requests_total".
+	//
+	// Optional. Default: ""
+	Namespace string
+
+	// DurationBuckets are the upper bounds of the request duration histogram in seconds.
+	//
+	// Optional. Default: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	DurationBuckets []float64
+
+	// SizeBuckets are the upper bounds of the request and response size histograms in bytes.
+	//
+	// Optional. Default: []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+	SizeBuckets []float64
+
+	// CacheHeader is the header that is set by the cache middleware, see EnableCacheMetrics.
+	//
+	// Optional. Default: "X-Cache"
+	CacheHeader string
+
+	// EnableCacheMetrics counts the results of the cache middleware (hit, miss, unreachable),
+	// read from the CacheHeader of the responses. The cache middleware has to be registered after this one.
+	//
+	// Optional. Default: false
+	EnableCacheMetrics bool
+
+	// EnableLimiterMetrics counts the requests that are rejected by the limiter middleware.
+	// The limiter middleware has to be registered after this one.
+	//
+	// Optional. Default: false
+	EnableLimiterMetrics bool
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:            nil,
+	Path:            "/metrics",
+	DurationBuckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	SizeBuckets:     []float64{100, 1000, 10000, 100000, 1000000, 10000000},
+	CacheHeader:     "X-Cache",
+}
+
+// Helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.Path == "" {
+		cfg.Path = ConfigDefault.Path
+	}
+	if len(cfg.DurationBuckets) == 0 {
+		cfg.DurationBuckets = ConfigDefault.DurationBuckets
+	}
+	if len(cfg.SizeBuckets) == 0 {
+		cfg.SizeBuckets = ConfigDefault.SizeBuckets
+	}
+	if cfg.CacheHeader == "" {
+		cfg.CacheHeader = ConfigDefault.CacheHeader
+	}
+
+	// The buckets have to be in increasing order
+	cfg.DurationBuckets = slices.Compact(slices.Sorted(slices.Values(cfg.DurationBuckets)))
+	cfg.SizeBuckets = slices.Compact(slices.Sorted(slices.Values(cfg.SizeBuckets)))
+
+	return cfg
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/middleware/limiter"
+)
+
+// Content types of the exposition formats
+const (
+	contentTypePrometheus  = "text/plain; version=0.0.4; charset=utf-8"
+	contentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	acceptOpenMetrics      = "application/openmetrics-text"
+)
+
+// unmatchedRoute is the route label of the requests that match no route
+const unmatchedRoute = "<unmatched>"
+
+// New creates a new middleware handler
+func New(config ...Config) velocity.Handler {
+	// Set default config
+	cfg := configDefault(config...)
+
+	// Every prefork child serves its own metrics, the pid label keeps the series apart
+	var pid string
+	if velocity.IsChild() {
+		pid = strconv.Itoa(os.Getpid())
+	}
+	reg := newRegistry(cfg, pid)
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		if c.Path() == cfg.Path && (c.Method() == velocity.MethodGet || c.Method() == velocity.MethodHead) {
+			return serve(c, reg)
+		}
+
+		reg.inFlight.Add(1)
+		defer reg.inFlight.Add(-1)
+
+		start := time.Now()
+		err := c.Next()
+		elapsed := time.Since(start)
+
+		// The error handler sets the status after the middleware returns
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = velocity.StatusInternalServerError
+			var e *velocity.Error
+			if errors.As(err, &e) {
+				status = e.Code
+			}
+		}
+
+		// A request that didn't reach a route handler ends at the last middleware
+		route := c.Route().Path
+		if c.Route().IsMiddleware() && (status == velocity.StatusNotFound || status == velocity.StatusMethodNotAllowed) {
+			route = unmatchedRoute
+		}
+		l := labels{
+			method: c.Method(),
+			status: strconv.Itoa(status/100) + "xx",
+			route:  route,
+		}
+		reg.observe(l, elapsed.Seconds(), len(c.Request().Body()), responseSize(c))
+
+		if cfg.EnableCacheMetrics {
+			if result := c.GetRespHeader(cfg.CacheHeader); result != "" {
+				reg.observeCache(result)
+			}
+		}
+		if cfg.EnableLimiterMetrics && limiter.IsLimited(c) {
+			reg.observeLimiter(l)
+		}
+
+		return err
+	}
+}
+
+// serve writes the metrics in the format that is accepted by the client
+func serve(c velocity.Ctx, reg *registry) error {
+	openMetrics := strings.Contains(c.Get(velocity.HeaderAccept), acceptOpenMetrics)
+
+	var b bytes.Buffer
+	reg.write(&b, openMetrics)
+
+	if openMetrics {
+		c.Set(velocity.HeaderContentType, contentTypeOpenMetrics)
+	} else {
+		c.Set(velocity.HeaderContentType, contentTypePrometheus)
+	}
+	c.Set(velocity.HeaderCacheControl, "no-store")
+
+	return c.Send(b.Bytes())
+}
+
+// responseSize returns the size of the body without reading a body stream
+func responseSize(c velocity.Ctx) int {
+	resp := c.Response()
+	if resp.IsBodyStream() {
+		return max(resp.Header.ContentLength(), 0)
+	}
+	return len(resp.Body())
+}
@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/middleware/cache"
+	"github.com/khulnasoft/velocity/middleware/limiter"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func scrape(t *testing.T, app *velocity.App, accept string) (string, string) {
+	t.Helper()
+
+	req := httptest.NewRequest(velocity.MethodGet, "/metrics", nil)
+	if accept != "" {
+		req.Header.Set(velocity.HeaderAccept, accept)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return string(body), resp.Header.Get(velocity.HeaderContentType)
+}
+
+func request(t *testing.T, app *velocity.App, method, target, body string) {
+	t.Helper()
+
+	_, err := app.Test(httptest.NewRequest(method, target, strings.NewReader(body)))
+	require.NoError(t, err)
+}
+
+// go test -run Test_Metrics
+func Test_Metrics(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New())
+	app.Get("/users/:id", func(c velocity.Ctx) error {
+		return c.SendString("user " + c.Params("id"))
+	})
+	app.Post("/users", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusCreated)
+	})
+	app.Get("/missing", func(_ velocity.Ctx) error {
+		return velocity.ErrNotFound
+	})
+	app.Get("/panic", func(_ velocity.Ctx) error {
+		return io.ErrUnexpectedEOF
+	})
+
+	request(t, app, velocity.MethodGet, "/users/1", "")
+	request(t, app, velocity.MethodGet, "/users/22", "")
+	request(t, app, velocity.MethodPost, "/users", "hello")
+	request(t, app, velocity.MethodGet, "/missing", "")
+	request(t, app, velocity.MethodGet, "/panic", "")
+	request(t, app, velocity.MethodGet, "/nowhere", "")
+	request(t, app, velocity.MethodDelete, "/users", "")
+
+	body, contentType := scrape(t, app, "")
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", contentType)
+
+	// the series are labeled with the route pattern
+	require.Contains(t, body, "# TYPE http_requests_total counter\n")
+	require.Contains(t, body, `http_requests_total{method="GET",status="2xx",route="/users/:id"} 2`+"\n")
+	require.Contains(t, body, `http_requests_total{method="POST",status="2xx",route="/users"} 1`+"\n")
+	require.Contains(t, body, `http_requests_total{method="GET",status="4xx",route="/missing"} 1`+"\n")
+	require.Contains(t, body, `http_requests_total{method="GET",status="5xx",route="/panic"} 1`+"\n")
+	require.Contains(t, body, `http_requests_total{method="GET",status="4xx",route="<unmatched>"} 1`+"\n")
+	require.Contains(t, body, `http_requests_total{method="DELETE",status="4xx",route="<unmatched>"} 1`+"\n")
+	require.NotContains(t, body, `route="/"`)
+	require.NotContains(t, body, "/users/22")
+	require.NotContains(t, body, `route="/metrics"`)
+
+	require.Contains(t, body, "# TYPE http_request_duration_seconds histogram\n")
+	require.Contains(t, body, `http_request_duration_seconds_bucket{method="GET",status="2xx",route="/users/:id",le="+Inf"} 2`+"\n")
+	require.Contains(t, body, `http_request_duration_seconds_count{method="GET",status="2xx",route="/users/:id"} 2`+"\n")
+
+	// "user 1" and "user 22"
+	require.Contains(t, body, `http_response_size_bytes_bucket{method="GET",status="2xx",route="/users/:id",le="100"} 2`+"\n")
+	require.Contains(t, body, `http_response_size_bytes_sum{method="GET",status="2xx",route="/users/:id"} 13`+"\n")
+	require.Contains(t, body, `http_request_size_bytes_sum{method="POST",status="2xx",route="/users"} 5`+"\n")
+
+	require.Contains(t, body, "# TYPE http_requests_in_flight gauge\nhttp_requests_in_flight 0\n")
+	require.NotContains(t, body, "cache_results")
+	require.NotContains(t, body, "limiter_rejections")
+}
+
+// go test -run Test_Metrics_OpenMetrics
+func Test_Metrics_OpenMetrics(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{
+		Path:            "/internal/metrics",
+		Namespace:       "shop",
+		DurationBuckets: []float64{1, 0.5},
+	}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	request(t, app, velocity.MethodGet, "/", "")
+
+	// the default path is not served anymore
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/metrics", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusNotFound, resp.StatusCode)
+
+	req := httptest.NewRequest(velocity.MethodGet, "/internal/metrics", nil)
+	req.Header.Set(velocity.HeaderAccept, "application/openmetrics-text;version=1.0.0,text/plain;q=0.5")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, "application/openmetrics-text; version=1.0.0; charset=utf-8", resp.Header.Get(velocity.HeaderContentType))
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	body := string(b)
+
+	require.Contains(t, body, "# TYPE shop_http_requests counter\n")
+	require.Contains(t, body, `shop_http_requests_total{method="GET",status="2xx",route="/"} 1.0`+"\n")
+	require.Contains(t, body, `shop_http_request_duration_seconds_bucket{method="GET",status="2xx",route="/",le="0.5"} 1.0`+"\n")
+	require.Contains(t, body, `shop_http_request_duration_seconds_bucket{method="GET",status="2xx",route="/",le="1.0"} 1.0`+"\n")
+	require.True(t, strings.HasSuffix(body, "# EOF\n"))
+}
+
+// go test -run Test_Metrics_Cache_Limiter
+func Test_Metrics_Cache_Limiter(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{EnableCacheMetrics: true, EnableLimiterMetrics: true}))
+	app.Get("/cached", func(c velocity.Ctx) error {
+		return c.SendString("cached")
+	}, cache.New())
+	app.Get("/limited", func(c velocity.Ctx) error {
+		return c.SendString("limited")
+	}, limiter.New(limiter.Config{Max: 1}))
+
+	request(t, app, velocity.MethodGet, "/cached", "")
+	request(t, app, velocity.MethodGet, "/cached", "")
+	request(t, app, velocity.MethodGet, "/cached", "")
+	request(t, app, velocity.MethodGet, "/limited", "")
+	request(t, app, velocity.MethodGet, "/limited", "")
+
+	body, _ := scrape(t, app, "")
+	require.Contains(t, body, `http_cache_results_total{result="hit"} 2`+"\n")
+	require.Contains(t, body, `http_cache_results_total{result="miss"} 1`+"\n")
+	require.Contains(t, body, `http_limiter_rejections_total{method="GET",route="/limited"} 1`+"\n")
+	require.Contains(t, body, `http_requests_total{method="GET",status="4xx",route="/limited"} 1`+"\n")
+}
+
+// go test -run Test_Metrics_Next
+func Test_Metrics_Next(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{
+		Next: func(c velocity.Ctx) bool {
+			return c.Path() == "/health"
+		},
+	}))
+	app.Get("/health", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusNoContent)
+	})
+
+	request(t, app, velocity.MethodGet, "/health", "")
+
+	body, _ := scrape(t, app, "")
+	require.NotContains(t, body, "/health")
+}
+
+// go test -run Test_Metrics_Prefork
+func Test_Metrics_Prefork(t *testing.T) {
+	t.Setenv("VELOCITY_PREFORK_CHILD", "1")
+
+	app := velocity.New()
+	app.Use(New())
+
+	body, _ := scrape(t, app, "")
+	require.Contains(t, body, `http_requests_in_flight{pid="`+strconv.Itoa(os.Getpid())+`"} 0`)
+}
+
+// go test -run Test_escapeLabelValue
+func Test_escapeLabelValue(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	escapeLabelValue(&b, "a\\b\"c\nd")
+	require.Equal(t, `a\\b\"c\nd`, b.String())
+}
+
+// go test -v -run=^$ -bench=Benchmark_Metrics -benchmem -count=4
+func Benchmark_Metrics(b *testing.B) {
+	app := velocity.New()
+	app.Use(New())
+	app.Get("/users/:id", func(c velocity.Ctx) error {
+		return c.SendString("user")
+	})
+	h := app.Handler()
+
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.Header.SetMethod(velocity.MethodGet)
+	fctx.Request.SetRequestURI("/users/1")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		h(fctx)
+	}
+}
@@ -0,0 +1,295 @@
+package metrics
+
+import (
+	"bytes"
+	"cmp"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/khulnasoft/velocity/utils"
+)
+
+// labels identify the series of the request metrics, the route is the pattern and not the raw path
+type labels struct {
+	method string
+	status string
+	route  string
+}
+
+// clone copies the strings, which can reference the buffers of the request
+func (l labels) clone() labels {
+	return labels{method: utils.CopyString(l.method), status: l.status, route: utils.CopyString(l.route)}
+}
+
+// histogram counts the observations per bucket, the last bucket is +Inf
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) histogram {
+	return histogram{counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(bounds []float64, v float64) {
+	// The first bucket with an upper bound that is greater or equal to the value
+	h.counts[sort.SearchFloat64s(bounds, v)]++
+	h.sum += v
+	h.count++
+}
+
+type series struct {
+	duration     histogram
+	requestSize  histogram
+	responseSize histogram
+	requests     uint64
+}
+
+// registry collects the metrics of a middleware instance
+type registry struct {
+	series            map[labels]*series
+	cacheResults      map[string]*uint64
+	limiterRejections map[labels]*uint64
+	namespace         string
+	pid               string
+	durationBuckets   []float64
+	sizeBuckets       []float64
+	inFlight          atomic.Int64
+	mu                sync.Mutex
+}
+
+func newRegistry(cfg Config, pid string) *registry {
+	namespace := "http"
+	if cfg.Namespace != "" {
+		namespace = cfg.Namespace + "_http"
+	}
+
+	return &registry{
+		series:            make(map[labels]*series),
+		cacheResults:      make(map[string]*uint64),
+		limiterRejections: make(map[labels]*uint64),
+		namespace:         namespace,
+		pid:               pid,
+		durationBuckets:   cfg.DurationBuckets,
+		sizeBuckets:       cfg.SizeBuckets,
+	}
+}
+
+// observe records a completed request
+func (r *registry) observe(l labels, seconds float64, requestSize, responseSize int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.series[l]
+	if !ok {
+		s = &series{
+			duration:     newHistogram(r.durationBuckets),
+			requestSize:  newHistogram(r.sizeBuckets),
+			responseSize: newHistogram(r.sizeBuckets),
+		}
+		r.series[l.clone()] = s
+	}
+
+	s.requests++
+	s.duration.observe(r.durationBuckets, seconds)
+	s.requestSize.observe(r.sizeBuckets, float64(requestSize))
+	s.responseSize.observe(r.sizeBuckets, float64(responseSize))
+}
+
+func (r *registry) observeCache(result string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Assigning to an existing key replaces the key, so the counters are only assigned once
+	count, ok := r.cacheResults[result]
+	if !ok {
+		count = new(uint64)
+		r.cacheResults[utils.CopyString(result)] = count
+	}
+	*count++
+}
+
+func (r *registry) observeLimiter(l labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := labels{method: l.method, route: l.route}
+	count, ok := r.limiterRejections[key]
+	if !ok {
+		count = new(uint64)
+		r.limiterRejections[key.clone()] = count
+	}
+	*count++
+}
+
+// write writes the metrics in the Prometheus text format, or in the OpenMetrics text format
+func (r *registry) write(b *bytes.Buffer, openMetrics bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]labels, 0, len(r.series))
+	for l := range r.series {
+		keys = append(keys, l)
+	}
+	slices.SortFunc(keys, compareLabels)
+
+	e := &encoder{b: b, pid: r.pid, openMetrics: openMetrics}
+
+	e.header(r.namespace+"_requests_total", "counter", "Total number of HTTP requests by method, status class and route.")
+	for _, l := range keys {
+		e.sample(r.namespace+"_requests_total", float64(r.series[l].requests), requestLabels(l)...)
+	}
+
+	histograms := []struct {
+		get    func(s *series) *histogram
+		name   string
+		help   string
+		bounds []float64
+	}{
+		{func(s *series) *histogram { return &s.duration }, r.namespace + "_request_duration_seconds", "Duration of HTTP requests in seconds.", r.durationBuckets},
+		{func(s *series) *histogram { return &s.requestSize }, r.namespace + "_request_size_bytes", "Size of HTTP request bodies in bytes.", r.sizeBuckets},
+		{func(s *series) *histogram { return &s.responseSize }, r.namespace + "_response_size_bytes", "Size of HTTP response bodies in bytes.", r.sizeBuckets},
+	}
+	for _, h := range histograms {
+		e.header(h.name, "histogram", h.help)
+		for _, l := range keys {
+			e.histogram(h.name, h.bounds, h.get(r.series[l]), requestLabels(l))
+		}
+	}
+
+	e.header(r.namespace+"_requests_in_flight", "gauge", "Number of HTTP requests that are currently handled.")
+	e.sample(r.namespace+"_requests_in_flight", float64(r.inFlight.Load()))
+
+	if len(r.cacheResults) > 0 {
+		e.header(r.namespace+"_cache_results_total", "counter", "Total number of responses of the cache middleware by result.")
+		for _, result := range slices.Sorted(maps.Keys(r.cacheResults)) {
+			e.sample(r.namespace+"_cache_results_total", float64(*r.cacheResults[result]), "result", result)
+		}
+	}
+
+	if len(r.limiterRejections) > 0 {
+		e.header(r.namespace+"_limiter_rejections_total", "counter", "Total number of requests rejected by the limiter middleware by method and route.")
+		rejected := make([]labels, 0, len(r.limiterRejections))
+		for l := range r.limiterRejections {
+			rejected = append(rejected, l)
+		}
+		slices.SortFunc(rejected, compareLabels)
+		for _, l := range rejected {
+			e.sample(r.namespace+"_limiter_rejections_total", float64(*r.limiterRejections[l]), "method", l.method, "route", l.route)
+		}
+	}
+
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+}
+
+func requestLabels(l labels) []string {
+	return []string{"method", l.method, "status", l.status, "route", l.route}
+}
+
+func compareLabels(a, b labels) int {
+	return cmp.Or(strings.Compare(a.route, b.route), strings.Compare(a.method, b.method), strings.Compare(a.status, b.status))
+}
+
+// encoder writes the samples of the text formats
+type encoder struct {
+	b           *bytes.Buffer
+	pid         string
+	openMetrics bool
+}
+
+func (e *encoder) header(name, typ, help string) {
+	// OpenMetrics names counter families without the _total suffix
+	if e.openMetrics && typ == "counter" {
+		name = strings.TrimSuffix(name, "_total")
+	}
+	e.b.WriteString("# HELP ")
+	e.b.WriteString(name)
+	e.b.WriteByte(' ')
+	e.b.WriteString(help)
+	e.b.WriteString("\n# TYPE ")
+	e.b.WriteString(name)
+	e.b.WriteByte(' ')
+	e.b.WriteString(typ)
+	e.b.WriteByte('\n')
+}
+
+func (e *encoder) histogram(name string, bounds []float64, h *histogram, pairs []string) {
+	var cumulative uint64
+	for i, count := range h.counts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(bounds) {
+			le = e.float(bounds[i])
+		}
+		e.sample(name+"_bucket", float64(cumulative), append(pairs, "le", le)...)
+	}
+	e.sample(name+"_sum", h.sum, pairs...)
+	e.sample(name+"_count", float64(h.count), pairs...)
+}
+
+// sample writes a sample with the label name and value pairs, the pid label is added under prefork
+func (e *encoder) sample(name string, value float64, pairs ...string) {
+	if e.pid != "" {
+		pairs = append([]string{"pid", e.pid}, pairs...)
+	}
+
+	e.b.WriteString(name)
+	if len(pairs) > 0 {
+		e.b.WriteByte('{')
+		for i := 0; i < len(pairs); i += 2 {
+			if i > 0 {
+				e.b.WriteByte(',')
+			}
+			e.b.WriteString(pairs[i])
+			e.b.WriteString(`="`)
+			escapeLabelValue(e.b, pairs[i+1])
+			e.b.WriteByte('"')
+		}
+		e.b.WriteByte('}')
+	}
+	e.b.WriteByte(' ')
+	e.b.WriteString(e.float(value))
+	e.b.WriteByte('\n')
+}
+
+// float formats the value, integral values get a fractional part in OpenMetrics like the reference implementation writes them
+func (e *encoder) float(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if e.openMetrics && v == math.Trunc(v) && !strings.ContainsAny(s, "e.") {
+		s += ".0"
+	}
+	return s
+}
+
+func escapeLabelValue(b *bytes.Buffer, v string) {
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package openapi
+
+import (
+	"github.com/khulnasoft/velocity"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c velocity.Ctx) bool
+
+	// Title of the API in the info object of the document.
+	//
+	// Optional. Default: the AppName of the app or "Velocity API"
+	Title string
+
+	// Version of the API in the info object of the document.
+	//
+	// Optional. Default: "1.0.0"
+	Version string
+
+	// Description of the API in the info object of the document.
+	//
+	// Optional. Default: ""
+	Description string
+
+	// Servers that provide the API, e.g. "https://api.example.com".
+	//
+	// Optional. Default: nil
+	Servers []Server
+
+	// Path is the path where the document is served in the JSON format.
+	//
+	// Optional. Default: "/openapi.json"
+	Path string
+
+	// YAMLPath is the path where the document is served in the YAML format.
+	//
+	// Optional. Default: "/openapi.yaml"
+	YAMLPath string
+
+	// IgnoreUndocumented excludes routes without an Operation in their metadata from the document.
+	//
+	// Optional. Default: false
+	IgnoreUndocumented bool
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:     nil,
+	Version:  "1.0.0",
+	Path:     "/openapi.json",
+	YAMLPath: "/openapi.yaml",
+}
+
+// Helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.Version == "" {
+		cfg.Version = ConfigDefault.Version
+	}
+	if cfg.Path == "" {
+		cfg.Path = ConfigDefault.Path
+	}
+	if cfg.YAMLPath == "" {
+		cfg.YAMLPath = ConfigDefault.YAMLPath
+	}
+
+	return cfg
+}
@@ -0,0 +1,106 @@
+package openapi
+
+import (
+	"encoding/json"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the version of the OpenAPI specification the generated documents conform to
+const Version = "3.1.0"
+
+// Document is the root object of an OpenAPI document.
+// The fields are ordered like in the specification to keep the encoded document readable.
+type Document struct { //nolint:govet // Aligning the struct fields is not necessary. betteralign:ignore
+	OpenAPI    string              `json:"openapi" yaml:"openapi"`
+	Info       Info                `json:"info" yaml:"info"`
+	Servers    []Server            `json:"servers,omitempty" yaml:"servers,omitempty"`
+	Paths      map[string]PathItem `json:"paths" yaml:"paths"`
+	Components *Components         `json:"components,omitempty" yaml:"components,omitempty"`
+}
+
+// Info provides metadata about the API.
+type Info struct {
+	Title       string `json:"title" yaml:"title"`
+	Version     string `json:"version" yaml:"version"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Server is a server that provides the API.
+type Server struct {
+	URL         string `json:"url" yaml:"url"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Components holds the reusable schemas of the document.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+}
+
+// PathItem holds the operations of a path by lowercase HTTP method.
+type PathItem map[string]*OperationObject
+
+// OperationObject describes a single API operation on a path.
+type OperationObject struct { //nolint:govet // Aligning the struct fields is not necessary. betteralign:ignore
+	Tags        []string                  `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Summary     string                    `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Description string                    `json:"description,omitempty" yaml:"description,omitempty"`
+	OperationID string                    `json:"operationId,omitempty" yaml:"operationId,omitempty"`
+	Parameters  []ParameterObject         `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *RequestBodyObject        `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]ResponseObject `json:"responses" yaml:"responses"`
+	Deprecated  bool                      `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+}
+
+// ParameterObject describes a path, query, header or cookie parameter.
+type ParameterObject struct { //nolint:govet // Aligning the struct fields is not necessary. betteralign:ignore
+	Name        string  `json:"name" yaml:"name"`
+	In          string  `json:"in" yaml:"in"`
+	Description string  `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool    `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+}
+
+// RequestBodyObject describes the request body by content type.
+type RequestBodyObject struct {
+	Content  map[string]MediaTypeObject `json:"content" yaml:"content"`
+	Required bool                       `json:"required,omitempty" yaml:"required,omitempty"`
+}
+
+// ResponseObject describes a response of an operation.
+type ResponseObject struct {
+	Content     map[string]MediaTypeObject `json:"content,omitempty" yaml:"content,omitempty"`
+	Description string                     `json:"description" yaml:"description"`
+}
+
+// MediaTypeObject holds the schema of a content type.
+type MediaTypeObject struct {
+	Schema *Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+}
+
+// Schema is a JSON Schema as used by OpenAPI 3.1.
+type Schema struct { //nolint:govet // Aligning the struct fields is not necessary. betteralign:ignore
+	Ref                  string             `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty" yaml:"type,omitempty"`
+	Format               string             `json:"format,omitempty" yaml:"format,omitempty"`
+	Description          string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Pattern              string             `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty" yaml:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	Items                *Schema            `json:"items,omitempty" yaml:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty" yaml:"required,omitempty"`
+}
+
+// JSON returns the document encoded as JSON.
+func (d *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// YAML returns the document encoded as YAML.
+func (d *Document) YAML() ([]byte, error) {
+	return yaml.Marshal(d)
+}
@@ -0,0 +1,370 @@
+package openapi
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/utils"
+)
+
+// MetaKey is the key of the Operation in the metadata of a route
+const MetaKey = "openapi"
+
+// Operation describes a route for the OpenAPI document. It is attached to a route with Meta:
+//
+//	app.Get("/users/:id<int>", handler).Meta(openapi.MetaKey, openapi.Operation{
+//		Summary:   "Get a user",
+//		Tags:      []string{"users"},
+//		Responses: []openapi.Response{{Status: 200, Body: User{}}},
+//	})
+type Operation struct {
+	// Body is a value of the struct that is bound with Bind().JSON, Bind().Form or Bind().Body.
+	// The schema of the request body is derived from its json and form tags.
+	Body any
+
+	// Query is a value of the struct that is bound with Bind().Query, the parameters are derived from its query tags.
+	Query any
+
+	// URI is a value of the struct that is bound with Bind().URI, it describes the path parameters of the route
+	// that have no constraints, e.g. ":id" instead of ":id<int>".
+	URI any
+
+	// Header is a value of the struct that is bound with Bind().Header, the parameters are derived from its header tags.
+	Header any
+
+	// OperationID is a unique identifier of the operation, the name of the route is used if it is empty.
+	OperationID string
+
+	// Summary is a short summary of what the operation does.
+	Summary string
+
+	// Description is a verbose explanation of the operation.
+	Description string
+
+	// Tags are used for the logical grouping of the operations.
+	Tags []string
+
+	// Responses are the possible responses of the operation, a "200 OK" response is documented if it is empty.
+	Responses []Response
+
+	// Deprecated marks the operation as deprecated.
+	Deprecated bool
+}
+
+// Response describes a possible response of an Operation.
+type Response struct {
+	// Body is a value of the type that is sent as response body, e.g. with c.JSON.
+	Body any
+
+	// Description of the response, the status message is used if it is empty.
+	Description string
+
+	// ContentType of the response body.
+	//
+	// Default: "application/json"
+	ContentType string
+
+	// Status code of the response.
+	//
+	// Default: 200
+	Status int
+}
+
+// methods that can be described by a path item, CONNECT and custom methods are not supported
+var methods = map[string]bool{
+	velocity.MethodGet:     true,
+	velocity.MethodHead:    true,
+	velocity.MethodPost:    true,
+	velocity.MethodPut:     true,
+	velocity.MethodPatch:   true,
+	velocity.MethodDelete:  true,
+	velocity.MethodOptions: true,
+	velocity.MethodTrace:   true,
+}
+
+// New creates a new middleware handler that serves the OpenAPI document of the app.
+// The document is generated on the first request, when all routes have been registered.
+func New(config ...Config) velocity.Handler {
+	// Set default config
+	cfg := configDefault(config...)
+
+	var (
+		once     sync.Once
+		jsonBody []byte
+		yamlBody []byte
+		err      error
+	)
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Only GET and HEAD requests to the document paths are served
+		path := c.Path()
+		if (path != cfg.Path && path != cfg.YAMLPath) || (c.Method() != velocity.MethodGet && c.Method() != velocity.MethodHead) {
+			return c.Next()
+		}
+
+		once.Do(func() {
+			doc := Generate(c.App(), cfg)
+			if jsonBody, err = doc.JSON(); err != nil {
+				return
+			}
+			yamlBody, err = doc.YAML()
+		})
+		if err != nil {
+			return err
+		}
+
+		if path == cfg.Path {
+			c.Set(velocity.HeaderContentType, velocity.MIMEApplicationJSONCharsetUTF8)
+			return c.Send(jsonBody)
+		}
+		c.Set(velocity.HeaderContentType, "application/yaml; charset=utf-8")
+		return c.Send(yamlBody)
+	}
+}
+
+// Generate creates the OpenAPI document from the routes of the app and the Operation in their metadata.
+// Routes of mounted apps are only included after the app has been started, e.g. with Listen or Test.
+func Generate(app *velocity.App, config ...Config) *Document {
+	// Set default config
+	cfg := configDefault(config...)
+
+	title := cfg.Title
+	if title == "" {
+		title = app.Config().AppName
+	}
+	if title == "" {
+		title = "Velocity API"
+	}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       title,
+			Version:     cfg.Version,
+			Description: cfg.Description,
+		},
+		Servers: cfg.Servers,
+		Paths:   make(map[string]PathItem),
+	}
+
+	g := newGenerator()
+	for _, route := range app.GetRoutes(true) {
+		// Skip unsupported methods and mounted apps that are not processed yet
+		if !methods[route.Method] || len(route.Handlers) == 0 {
+			continue
+		}
+
+		op, ok := operationOf(route)
+		if !ok && cfg.IgnoreUndocumented {
+			continue
+		}
+
+		path, params := g.pathParameters(route, op.URI)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[path] = item
+		}
+		item[utils.ToLower(route.Method)] = g.operation(route, op, params)
+	}
+
+	if len(g.schemas) > 0 {
+		doc.Components = &Components{Schemas: g.schemas}
+	}
+
+	return doc
+}
+
+// operationOf returns the Operation from the metadata of the route
+func operationOf(route velocity.Route) (Operation, bool) {
+	switch op := route.Meta[MetaKey].(type) {
+	case Operation:
+		return op, true
+	case *Operation:
+		if op != nil {
+			return *op, true
+		}
+	}
+
+	return Operation{}, false
+}
+
+// pathParameters converts the route path into an OpenAPI path template and describes its parameters
+func (g *generator) pathParameters(route velocity.Route, uri any) (string, []ParameterObject) {
+	fields := g.parameterSchemas(uri, "uri")
+
+	var (
+		b      strings.Builder
+		params []ParameterObject
+	)
+	for _, seg := range route.Segments() {
+		if !seg.IsParam {
+			b.WriteString(seg.Const)
+			continue
+		}
+
+		name := parameterName(seg.Name)
+		b.WriteString("{" + name + "}")
+
+		param := ParameterObject{
+			Name: name,
+			In:   "path",
+			// Path parameters are always required in OpenAPI
+			Required: true,
+			Schema:   constraintSchema(seg.Constraints),
+		}
+		if field, ok := fields[seg.Name]; ok {
+			param.Description = field.Description
+			if len(seg.Constraints) == 0 {
+				param.Schema = field.Schema
+			}
+		}
+		if seg.IsOptional && param.Description == "" {
+			param.Description = "Optional, the path is also matched without this parameter."
+		}
+		params = append(params, param)
+	}
+
+	return b.String(), params
+}
+
+// operation creates the operation object of the route
+func (g *generator) operation(route velocity.Route, op Operation, params []ParameterObject) *OperationObject {
+	obj := &OperationObject{
+		Tags:        op.Tags,
+		Summary:     op.Summary,
+		Description: op.Description,
+		OperationID: op.OperationID,
+		Parameters:  params,
+		Responses:   make(map[string]ResponseObject),
+		Deprecated:  op.Deprecated,
+	}
+	if obj.OperationID == "" {
+		obj.OperationID = route.Name
+	}
+
+	obj.Parameters = append(obj.Parameters, g.parameters(op.Query, "query")...)
+	obj.Parameters = append(obj.Parameters, g.parameters(op.Header, "header")...)
+
+	if op.Body != nil {
+		obj.RequestBody = g.requestBody(op.Body)
+	}
+
+	if len(op.Responses) == 0 {
+		obj.Responses[strconv.Itoa(velocity.StatusOK)] = ResponseObject{
+			Description: utils.StatusMessage(velocity.StatusOK),
+		}
+	}
+	for _, resp := range op.Responses {
+		status := resp.Status
+		if status == 0 {
+			status = velocity.StatusOK
+		}
+
+		response := ResponseObject{Description: resp.Description}
+		if response.Description == "" {
+			response.Description = utils.StatusMessage(status)
+		}
+		if resp.Body != nil {
+			contentType := resp.ContentType
+			if contentType == "" {
+				contentType = velocity.MIMEApplicationJSON
+			}
+			response.Content = map[string]MediaTypeObject{
+				contentType: {Schema: g.schema(resp.Body, "json")},
+			}
+		}
+		obj.Responses[strconv.Itoa(status)] = response
+	}
+
+	return obj
+}
+
+// parameterName converts the name of wildcard and plus parameters, e.g. "*1", into a valid template name
+func parameterName(name string) string {
+	switch {
+	case strings.HasPrefix(name, "*"):
+		return "wildcard" + name[1:]
+	case strings.HasPrefix(name, "+"):
+		return "plus" + name[1:]
+	default:
+		return name
+	}
+}
+
+// constraintSchema derives the schema of a path parameter from its constraints
+func constraintSchema(constraints []*velocity.Constraint) *Schema {
+	schema := &Schema{Type: "string"}
+	for _, c := range constraints {
+		switch c.Name {
+		case velocity.ConstraintInt:
+			schema.Type = "integer"
+		case velocity.ConstraintBool:
+			schema.Type = "boolean"
+		case velocity.ConstraintFloat:
+			schema.Type = "number"
+		case velocity.ConstraintAlpha:
+			schema.Pattern = `^\p{L}+$`
+		case velocity.ConstraintGUID:
+			schema.Format = "uuid"
+		case velocity.ConstraintMinLen, velocity.ConstraintMinLenLower:
+			schema.MinLength = intData(c, 0)
+		case velocity.ConstraintMaxLen, velocity.ConstraintMaxLenLower:
+			schema.MaxLength = intData(c, 0)
+		case velocity.ConstraintLen:
+			schema.MinLength, schema.MaxLength = intData(c, 0), intData(c, 0)
+		case velocity.ConstraintBetweenLen, velocity.ConstraintBetweenLenLower:
+			schema.MinLength, schema.MaxLength = intData(c, 0), intData(c, 1)
+		case velocity.ConstraintMin:
+			schema.Type, schema.Minimum = "integer", floatData(c, 0)
+		case velocity.ConstraintMax:
+			schema.Type, schema.Maximum = "integer", floatData(c, 0)
+		case velocity.ConstraintRange:
+			schema.Type, schema.Minimum, schema.Maximum = "integer", floatData(c, 0), floatData(c, 1)
+		case velocity.ConstraintDatetime:
+			if len(c.Data) > 0 {
+				schema.Description = "Date and time in the Go layout " + strconv.Quote(c.Data[0])
+			}
+		case velocity.ConstraintRegex:
+			if len(c.Data) > 0 {
+				schema.Pattern = c.Data[0]
+			}
+		}
+	}
+
+	return schema
+}
+
+// intData returns the constraint data at the index as int
+func intData(c *velocity.Constraint, i int) *int {
+	if i >= len(c.Data) {
+		return nil
+	}
+	n, err := strconv.Atoi(c.Data[i])
+	if err != nil {
+		return nil
+	}
+
+	return &n
+}
+
+// floatData returns the constraint data at the index as float64
+func floatData(c *velocity.Constraint, i int) *float64 {
+	if i >= len(c.Data) {
+		return nil
+	}
+	n, err := strconv.ParseFloat(c.Data[i], 64)
+	if err != nil {
+		return nil
+	}
+
+	return &n
+}
@@ -0,0 +1,261 @@
+package openapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+type createUser struct {
+	Name     string   `json:"name" form:"name" validate:"required" description:"Full name of the user"`
+	Email    string   `json:"email" form:"email" validate:"required,email"`
+	Password string   `json:"-" form:"password"`
+	Tags     []string `json:"tags,omitempty" form:"tags"`
+	Age      uint8    `json:"age" form:"age"`
+}
+
+type address struct {
+	Street string `json:"street"`
+}
+
+type user struct {
+	CreatedAt time.Time `json:"created_at"`
+	Address   *address  `json:"address,omitempty"`
+	Friends   []user    `json:"friends"`
+	Name      string    `json:"name"`
+	ID        int       `json:"id"`
+}
+
+type listQuery struct {
+	Sort  string `query:"sort" description:"Sort order"`
+	Page  int    `query:"page" validate:"required"`
+	Limit int    `query:"limit"`
+}
+
+type authHeader struct {
+	Token string `header:"X-Token" validate:"required"`
+}
+
+type fileURI struct {
+	Name string `uri:"name" description:"Name of the file"`
+}
+
+func newTestApp() *velocity.App {
+	app := velocity.New()
+	handler := func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	}
+
+	app.Get("/users", handler).Meta(MetaKey, Operation{
+		Summary:   "List users",
+		Tags:      []string{"users"},
+		Query:     listQuery{},
+		Header:    authHeader{},
+		Responses: []Response{{Status: velocity.StatusOK, Body: []user{}}},
+	})
+	app.Post("/users", handler).Name("createUser").Meta(MetaKey, Operation{
+		Summary: "Create a user",
+		Tags:    []string{"users"},
+		Body:    createUser{},
+		Responses: []Response{
+			{Status: velocity.StatusCreated, Body: user{}},
+			{Status: velocity.StatusBadRequest, Description: "Invalid user"},
+		},
+	})
+	app.Get("/users/:id<int;min(1)>", handler).Meta(MetaKey, &Operation{
+		Summary:    "Get a user",
+		Deprecated: true,
+		Responses:  []Response{{Body: user{}}},
+	})
+	app.Get("/files/:name/*", handler).Meta(MetaKey, Operation{
+		URI: fileURI{},
+	})
+	app.Delete("/sessions/:id<guid>", handler)
+
+	return app
+}
+
+func Test_OpenAPI_Generate(t *testing.T) {
+	t.Parallel()
+
+	doc := Generate(newTestApp(), Config{
+		Title:   "Users API",
+		Servers: []Server{{URL: "https://api.example.com"}},
+	})
+
+	require.Equal(t, Version, doc.OpenAPI)
+	require.Equal(t, Info{Title: "Users API", Version: "1.0.0"}, doc.Info)
+	require.Equal(t, []Server{{URL: "https://api.example.com"}}, doc.Servers)
+	require.Len(t, doc.Paths, 4)
+
+	// query and header parameters
+	list := doc.Paths["/users"]["get"]
+	require.Equal(t, "List users", list.Summary)
+	require.Equal(t, []string{"users"}, list.Tags)
+	require.Equal(t, []ParameterObject{
+		{Name: "sort", In: "query", Description: "Sort order", Schema: &Schema{Type: "string"}},
+		{Name: "page", In: "query", Required: true, Schema: &Schema{Type: "integer", Format: "int64"}},
+		{Name: "limit", In: "query", Schema: &Schema{Type: "integer", Format: "int64"}},
+		{Name: "X-Token", In: "header", Required: true, Schema: &Schema{Type: "string"}},
+	}, list.Parameters)
+	require.Equal(t, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/user"}}, list.Responses["200"].Content["application/json"].Schema)
+
+	// request body from the json and form tags
+	create := doc.Paths["/users"]["post"]
+	require.Equal(t, "createUser", create.OperationID)
+	require.True(t, create.RequestBody.Required)
+	require.Len(t, create.RequestBody.Content, 3)
+	require.Equal(t, &Schema{Ref: "#/components/schemas/createUser"}, create.RequestBody.Content[velocity.MIMEApplicationJSON].Schema)
+	form := create.RequestBody.Content[velocity.MIMEApplicationForm].Schema
+	require.Same(t, form, create.RequestBody.Content[velocity.MIMEMultipartForm].Schema)
+	require.Equal(t, "object", form.Type)
+	require.Contains(t, form.Properties, "password")
+	require.Equal(t, []string{"name", "email"}, form.Required)
+	require.Equal(t, "Created", create.Responses["201"].Description)
+	require.Equal(t, "Invalid user", create.Responses["400"].Description)
+	require.Nil(t, create.Responses["400"].Content)
+
+	// path parameters with constraints
+	get := doc.Paths["/users/{id}"]["get"]
+	require.True(t, get.Deprecated)
+	minimum := float64(1)
+	require.Equal(t, []ParameterObject{
+		{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer", Minimum: &minimum}},
+	}, get.Parameters)
+
+	// path parameters described by the uri struct and wildcards
+	files := doc.Paths["/files/{name}/{wildcard1}"]["get"]
+	require.Len(t, files.Parameters, 2)
+	require.Equal(t, ParameterObject{Name: "name", In: "path", Required: true, Description: "Name of the file", Schema: &Schema{Type: "string"}}, files.Parameters[0])
+	require.Equal(t, "wildcard1", files.Parameters[1].Name)
+	require.NotEmpty(t, files.Parameters[1].Description)
+
+	// undocumented routes are included
+	del := doc.Paths["/sessions/{id}"]["delete"]
+	require.Equal(t, &Schema{Type: "string", Format: "uuid"}, del.Parameters[0].Schema)
+	require.Equal(t, map[string]ResponseObject{"200": {Description: "OK"}}, del.Responses)
+
+	// components
+	require.Len(t, doc.Components.Schemas, 3)
+	userSchema := doc.Components.Schemas["user"]
+	require.Equal(t, &Schema{Type: "string", Format: "date-time"}, userSchema.Properties["created_at"])
+	require.Equal(t, &Schema{Ref: "#/components/schemas/address"}, userSchema.Properties["address"])
+	require.Equal(t, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/user"}}, userSchema.Properties["friends"])
+	createSchema := doc.Components.Schemas["createUser"]
+	require.NotContains(t, createSchema.Properties, "password")
+	require.Equal(t, "Full name of the user", createSchema.Properties["name"].Description)
+	require.Equal(t, []string{"name", "email"}, createSchema.Required)
+}
+
+func Test_OpenAPI_Generate_IgnoreUndocumented(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp()
+	doc := Generate(app, Config{IgnoreUndocumented: true})
+
+	require.Equal(t, "Velocity API", doc.Info.Title)
+	require.Len(t, doc.Paths, 3)
+	require.NotContains(t, doc.Paths, "/sessions/{id}")
+}
+
+func Test_OpenAPI_Constraints(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	handler := func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	}
+	app.Get("/a/:p1<bool>/:p2<float>/:p3<alpha;minLen(2);maxLen(8)>", handler)
+	app.Get("/b/:p1<len(4)>/:p2<betweenLen(2,5)>/:p3<range(1,10)>/:p4<max(5)>", handler)
+	app.Get(`/c/:p1<regex(\d+)>/:p2<datetime(2006\-01\-02)>/:p3?`, handler)
+
+	doc := Generate(app)
+	schemas := func(path string) []*Schema {
+		var result []*Schema
+		for _, p := range doc.Paths[path]["get"].Parameters {
+			result = append(result, p.Schema)
+		}
+		return result
+	}
+	two, four, five, eight := 2, 4, 5, 8
+	one, ten, maximum := float64(1), float64(10), float64(5)
+
+	require.Equal(t, []*Schema{
+		{Type: "boolean"},
+		{Type: "number"},
+		{Type: "string", Pattern: `^\p{L}+$`, MinLength: &two, MaxLength: &eight},
+	}, schemas("/a/{p1}/{p2}/{p3}"))
+	require.Equal(t, []*Schema{
+		{Type: "string", MinLength: &four, MaxLength: &four},
+		{Type: "string", MinLength: &two, MaxLength: &five},
+		{Type: "integer", Minimum: &one, Maximum: &ten},
+		{Type: "integer", Maximum: &maximum},
+	}, schemas("/b/{p1}/{p2}/{p3}/{p4}"))
+	require.Equal(t, []*Schema{
+		{Type: "string", Pattern: `\d+`},
+		{Type: "string", Description: `Date and time in the Go layout "2006-01-02"`},
+		{Type: "string"},
+	}, schemas("/c/{p1}/{p2}/{p3}"))
+}
+
+func Test_OpenAPI_Handler(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp()
+	app.Use(New(Config{Version: "2.0.0"}))
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/openapi.json", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, velocity.MIMEApplicationJSONCharsetUTF8, resp.Header.Get(velocity.HeaderContentType))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(body, &doc))
+	require.Equal(t, "3.1.0", doc["openapi"])
+	require.Equal(t, "2.0.0", doc["info"].(map[string]any)["version"]) //nolint:forcetypeassert // test
+	require.Len(t, doc["paths"], 4)
+
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/openapi.yaml", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/yaml; charset=utf-8", resp.Header.Get(velocity.HeaderContentType))
+
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	doc = nil
+	require.NoError(t, yaml.Unmarshal(body, &doc))
+	require.Equal(t, "3.1.0", doc["openapi"])
+	require.Contains(t, doc["paths"], "/users/{id}")
+
+	// other paths and methods are passed to the next handler
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodPost, "/openapi.json", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusNotFound, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/users", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+}
+
+func Test_OpenAPI_Handler_Next(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{
+		Next: func(_ velocity.Ctx) bool {
+			return true
+		},
+	}))
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/openapi.json", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusNotFound, resp.StatusCode)
+}
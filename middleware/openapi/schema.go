@@ -0,0 +1,268 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	// characters that are not allowed in the names of component schemas
+	invalidComponentChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+)
+
+// generator derives schemas from go types and collects the reusable ones as components
+type generator struct {
+	schemas  map[string]*Schema      // component schemas by name
+	names    map[reflect.Type]string // component names by type
+	visiting map[reflect.Type]bool   // inline struct types that are currently generated, to stop recursion
+}
+
+func newGenerator() *generator {
+	return &generator{
+		schemas:  make(map[string]*Schema),
+		names:    make(map[reflect.Type]string),
+		visiting: make(map[reflect.Type]bool),
+	}
+}
+
+// field is a struct field as it is seen by a binder
+type field struct {
+	Schema      *Schema
+	Name        string
+	Description string
+	Required    bool
+}
+
+// schema returns the schema for the type of the value, named structs are added
+// as components when the json tag is used
+func (g *generator) schema(value any, tag string) *Schema {
+	return g.typeSchema(reflect.TypeOf(value), tag)
+}
+
+func (g *generator) typeSchema(t reflect.Type, tag string) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		minimum := float64(0)
+		return &Schema{Type: "integer", Minimum: &minimum}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.typeSchema(t.Elem(), tag)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.typeSchema(t.Elem(), tag)}
+	case reflect.Struct:
+		if tag == "json" && t.Name() != "" {
+			return g.component(t)
+		}
+		if g.visiting[t] {
+			return &Schema{Type: "object"}
+		}
+		g.visiting[t] = true
+		defer delete(g.visiting, t)
+		return g.object(t, tag)
+	default:
+		// interfaces and other types can hold any value
+		return &Schema{}
+	}
+}
+
+// component adds the schema of the named struct type to the components and returns a reference to it
+func (g *generator) component(t reflect.Type) *Schema {
+	name, ok := g.names[t]
+	if !ok {
+		name = invalidComponentChars.ReplaceAllString(t.Name(), "_")
+		// Types with the same name from different packages get a suffix
+		for i := 2; g.schemas[name] != nil; i++ {
+			name = invalidComponentChars.ReplaceAllString(t.Name(), "_") + strconv.Itoa(i)
+		}
+		g.names[t] = name
+
+		// Register the name before the schema is generated, so that recursive types can reference it
+		schema := &Schema{}
+		g.schemas[name] = schema
+		*schema = *g.object(t, "json")
+	}
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// object creates the schema of a struct type with the properties named by the tag
+func (g *generator) object(t reflect.Type, tag string) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, f := range g.fields(t, tag) {
+		f.Schema.Description = f.Description
+		schema.Properties[f.Name] = f.Schema
+		if f.Required {
+			schema.Required = append(schema.Required, f.Name)
+		}
+	}
+
+	return schema
+}
+
+// fields returns the fields of the struct type like they are seen by a binder that uses the tag,
+// fields of embedded structs without a tag are promoted
+func (g *generator) fields(t reflect.Type, tag string) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			fields = append(fields, g.fields(ft, tag)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fields = append(fields, field{
+			Name:        name,
+			Description: f.Tag.Get("description"),
+			Required:    isRequired(f),
+			Schema:      g.typeSchema(f.Type, tag),
+		})
+	}
+
+	return fields
+}
+
+// parameterSchemas returns the fields of a struct that is bound by the tag, e.g. with Bind().URI
+func (g *generator) parameterSchemas(value any, tag string) map[string]field {
+	t := structType(value)
+	if t == nil {
+		return nil
+	}
+
+	fields := make(map[string]field)
+	for _, f := range g.fields(t, tag) {
+		fields[f.Name] = f
+	}
+
+	return fields
+}
+
+// parameters describes the fields of a struct that is bound by the tag as parameters, e.g. with Bind().Query
+func (g *generator) parameters(value any, tag string) []ParameterObject {
+	t := structType(value)
+	if t == nil {
+		return nil
+	}
+
+	fields := g.fields(t, tag)
+	params := make([]ParameterObject, 0, len(fields))
+	for _, f := range fields {
+		params = append(params, ParameterObject{
+			Name:        f.Name,
+			In:          tag,
+			Description: f.Description,
+			Required:    f.Required,
+			Schema:      f.Schema,
+		})
+	}
+
+	return params
+}
+
+// requestBody describes the request body with the content types the struct can be bound from
+func (g *generator) requestBody(value any) *RequestBodyObject {
+	body := &RequestBodyObject{
+		Required: true,
+		Content: map[string]MediaTypeObject{
+			velocity.MIMEApplicationJSON: {Schema: g.schema(value, "json")},
+		},
+	}
+
+	if t := structType(value); t != nil && hasTag(t, "form") {
+		form := g.schema(value, "form")
+		body.Content[velocity.MIMEApplicationForm] = MediaTypeObject{Schema: form}
+		body.Content[velocity.MIMEMultipartForm] = MediaTypeObject{Schema: form}
+	}
+
+	return body
+}
+
+// structType returns the struct type of the value or nil if it is no struct
+func structType(value any) reflect.Type {
+	t := reflect.TypeOf(value)
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return t
+}
+
+// hasTag checks whether any field of the struct type or its embedded structs uses the tag
+func hasTag(t reflect.Type, tag string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if _, ok := f.Tag.Lookup(tag); ok {
+			return true
+		}
+		if ft := f.Type; f.Anonymous && ft.Kind() == reflect.Struct && hasTag(ft, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isRequired checks whether the field is marked as required for the struct validator
+func isRequired(f reflect.StructField) bool {
+	for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,256 @@
+package session
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/khulnasoft/velocity/utils"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// ErrNonStringKey is returned by the codecs that only support string keys if the session
+// data has a key of another type.
+var ErrNonStringKey = errors.New("session: codec only supports string keys")
+
+// Codec encodes the session data to the bytes that are stored, and decodes them again.
+type Codec interface {
+	// Encode returns the encoding of the session data.
+	Encode(data map[any]any) ([]byte, error)
+	// Decode decodes the encoded session data into data.
+	Decode(raw []byte, data map[any]any) error
+}
+
+// Names of the internal values of the session in the encoded data, so that codecs that only
+// support string keys can encode them and other services can read them. The times are stored
+// as Unix time in milliseconds.
+const (
+	absExpirationName = "_velocity_abs_expiration"
+	subjectName       = "_velocity_subject"
+	subjectSinceName  = "_velocity_subject_since"
+)
+
+// GobCodec encodes the session data with encoding/gob. It supports keys and values of any
+// type, but the types have to be registered with Store.RegisterType and the data can only
+// be read by Go programs that know these types. It is the default codec.
+type GobCodec struct{}
+
+// Encode implements Codec
+func (GobCodec) Encode(data map[any]any) ([]byte, error) {
+	byteBuffer := byteBufferPool.Get().(*bytes.Buffer) //nolint:forcetypeassert,errcheck // We store nothing else in the pool
+	defer byteBufferPool.Put(byteBuffer)
+	defer byteBuffer.Reset()
+	if err := gob.NewEncoder(byteBuffer).Encode(&data); err != nil {
+		return nil, err
+	}
+	// Copy the data in buffer
+	return utils.CopyBytes(byteBuffer.Bytes()), nil
+}
+
+// Decode implements Codec
+func (GobCodec) Decode(raw []byte, data map[any]any) error {
+	return gob.NewDecoder(bytes.NewReader(raw)).Decode(&data)
+}
+
+// JSONCodec encodes the session data as a JSON object. The keys have to be strings, numbers
+// are decoded as float64 and structs as map[string]any.
+type JSONCodec struct {
+	// Marshal encodes the data.
+	//
+	// Optional. Default: json.Marshal
+	Marshal utils.JSONMarshal
+
+	// Unmarshal decodes the data.
+	//
+	// Optional. Default: json.Unmarshal
+	Unmarshal utils.JSONUnmarshal
+}
+
+// Encode implements Codec
+func (c JSONCodec) Encode(data map[any]any) ([]byte, error) {
+	m, err := stringKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	if c.Marshal == nil {
+		return json.Marshal(m)
+	}
+	return c.Marshal(m)
+}
+
+// Decode implements Codec
+func (c JSONCodec) Decode(raw []byte, data map[any]any) error {
+	unmarshal := c.Unmarshal
+	if unmarshal == nil {
+		unmarshal = json.Unmarshal
+	}
+	var m map[string]any
+	if err := unmarshal(raw, &m); err != nil {
+		return err
+	}
+	for k, v := range m {
+		data[k] = v
+	}
+	return nil
+}
+
+// MsgPackCodec encodes the session data as a MessagePack map. The keys have to be strings and
+// the values of the types that msgp.AppendIntf supports, e.g. the basic types, slices,
+// map[string]any and the types that implement msgp.Marshaler.
+type MsgPackCodec struct{}
+
+// Encode implements Codec
+func (MsgPackCodec) Encode(data map[any]any) ([]byte, error) {
+	m, err := stringKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	return msgp.AppendMapStrIntf(nil, m)
+}
+
+// Decode implements Codec
+func (MsgPackCodec) Decode(raw []byte, data map[any]any) error {
+	m, _, err := msgp.ReadMapStrIntfBytes(raw, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range m {
+		data[k] = v
+	}
+	return nil
+}
+
+// CBORCodec encodes the session data as a CBOR map. The keys have to be strings, structs are
+// decoded as map[any]any.
+type CBORCodec struct {
+	// Marshal encodes the data.
+	//
+	// Optional. Default: cbor.Marshal
+	Marshal utils.CBORMarshal
+
+	// Unmarshal decodes the data.
+	//
+	// Optional. Default: cbor.Unmarshal
+	Unmarshal utils.CBORUnmarshal
+}
+
+// Encode implements Codec
+func (c CBORCodec) Encode(data map[any]any) ([]byte, error) {
+	m, err := stringKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	if c.Marshal == nil {
+		return cbor.Marshal(m)
+	}
+	return c.Marshal(m)
+}
+
+// Decode implements Codec
+func (c CBORCodec) Decode(raw []byte, data map[any]any) error {
+	unmarshal := c.Unmarshal
+	if unmarshal == nil {
+		unmarshal = cbor.Unmarshal
+	}
+	var m map[string]any
+	if err := unmarshal(raw, &m); err != nil {
+		return err
+	}
+	for k, v := range m {
+		data[k] = v
+	}
+	return nil
+}
+
+// stringKeys returns the data as a map with string keys
+func stringKeys(data map[any]any) (map[string]any, error) {
+	m := make(map[string]any, len(data))
+	for k, v := range data {
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T", ErrNonStringKey, k)
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// exportData returns a copy of the session data in which the internal values are stored
+// under their names, so that every codec can encode them.
+func exportData(data map[any]any) map[any]any {
+	m := make(map[any]any, len(data))
+	for k, v := range data {
+		switch k {
+		case absExpirationKey:
+			m[absExpirationName] = unixMilli(v)
+		case subjectKey:
+			m[subjectName] = v
+		case subjectSinceKey:
+			m[subjectSinceName] = unixMilli(v)
+		default:
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// unixMilli returns the Unix time in milliseconds if v is a time
+func unixMilli(v any) any {
+	if t, ok := v.(time.Time); ok {
+		return t.UnixMilli()
+	}
+	return v
+}
+
+// importData moves the internal values of decoded session data back to their keys.
+func importData(data map[any]any) {
+	if v, ok := data[absExpirationName]; ok {
+		delete(data, absExpirationName)
+		if ms, ok := toInt64(v); ok {
+			data[absExpirationKey] = time.UnixMilli(ms)
+		}
+	}
+	if v, ok := data[subjectName]; ok {
+		delete(data, subjectName)
+		if subject, ok := v.(string); ok {
+			data[subjectKey] = subject
+		}
+	}
+	if v, ok := data[subjectSinceName]; ok {
+		delete(data, subjectSinceName)
+		if ms, ok := toInt64(v); ok {
+			data[subjectSinceKey] = time.UnixMilli(ms)
+		}
+	}
+}
+
+// toInt64 converts the integer types that the codecs decode numbers to
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case uint64:
+		if n > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,174 @@
+package session
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+	"github.com/tinylib/msgp/msgp"
+	"github.com/valyala/fasthttp"
+)
+
+// go test -run Test_Session_Codec
+func Test_Session_Codec(t *testing.T) {
+	t.Parallel()
+
+	codecs := map[string]Codec{
+		"Gob":     GobCodec{},
+		"JSON":    JSONCodec{},
+		"MsgPack": MsgPackCodec{},
+		"CBOR":    CBORCodec{},
+	}
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			store := NewStore(Config{
+				Codec:           codec,
+				IdleTimeout:     time.Minute,
+				AbsoluteTimeout: time.Hour,
+			})
+			app := velocity.New()
+
+			ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+			sess, err := store.Get(ctx)
+			require.NoError(t, err)
+			sess.Set("name", "john")
+			sess.SetSubject("user-1")
+			absExpiration := sess.absExpiration()
+			id := sess.ID()
+			require.NoError(t, sess.Save())
+			sess.Release()
+			app.ReleaseCtx(ctx)
+
+			sess, err = store.GetByID(id)
+			require.NoError(t, err)
+			defer sess.Release()
+			require.Equal(t, "john", sess.Get("name"))
+			require.Equal(t, "user-1", sess.Subject())
+			require.Equal(t, absExpiration.UnixMilli(), sess.absExpiration().UnixMilli())
+			require.False(t, sess.isAbsExpired())
+			require.ElementsMatch(t, []any{"name", absExpirationKey, subjectKey, subjectSinceKey}, sess.Keys())
+		})
+	}
+}
+
+// go test -run Test_Session_Codec_Readable
+func Test_Session_Codec_Readable(t *testing.T) {
+	t.Parallel()
+
+	storage := memory.New()
+	store := NewStore(Config{Storage: storage, Codec: JSONCodec{}})
+	app := velocity.New()
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, err := store.Get(ctx)
+	require.NoError(t, err)
+	defer sess.Release()
+	sess.Set("name", "john")
+	sess.SetSubject("user-1")
+	require.NoError(t, sess.Save())
+
+	// Another service reads the stored session data
+	raw, err := storage.Get(sess.ID())
+	require.NoError(t, err)
+	var data map[string]any
+	require.NoError(t, json.Unmarshal(raw, &data))
+	require.Equal(t, "john", data["name"])
+	require.Equal(t, "user-1", data[subjectName])
+	require.IsType(t, float64(0), data[subjectSinceName])
+}
+
+// go test -run Test_Session_Codec_NonStringKey
+func Test_Session_Codec_NonStringKey(t *testing.T) {
+	t.Parallel()
+
+	for _, codec := range []Codec{JSONCodec{}, MsgPackCodec{}, CBORCodec{}} {
+		_, err := codec.Encode(map[any]any{1: "one"})
+		require.ErrorIs(t, err, ErrNonStringKey)
+	}
+}
+
+// go test -run Test_Session_Codec_Custom
+func Test_Session_Codec_Custom(t *testing.T) {
+	t.Parallel()
+
+	var marshaled, unmarshaled bool
+	codec := CBORCodec{
+		Marshal: func(v any) ([]byte, error) {
+			marshaled = true
+			return cbor.Marshal(v)
+		},
+		Unmarshal: func(data []byte, v any) error {
+			unmarshaled = true
+			return cbor.Unmarshal(data, v)
+		},
+	}
+	raw, err := codec.Encode(map[any]any{"name": "john"})
+	require.NoError(t, err)
+	data := make(map[any]any)
+	require.NoError(t, codec.Decode(raw, data))
+	require.Equal(t, map[any]any{"name": "john"}, data)
+	require.True(t, marshaled)
+	require.True(t, unmarshaled)
+}
+
+// go test -run Test_Session_Codec_MsgPack_Types
+func Test_Session_Codec_MsgPack_Types(t *testing.T) {
+	t.Parallel()
+
+	raw, err := MsgPackCodec{}.Encode(map[any]any{"int": 1, "bytes": []byte("doe"), "bool": true})
+	require.NoError(t, err)
+
+	// The data is a plain MessagePack map
+	m, _, err := msgp.ReadMapStrIntfBytes(raw, nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"int": int64(1), "bytes": []byte("doe"), "bool": true}, m)
+}
+
+// go test -run Test_Session_Codec_Legacy
+func Test_Session_Codec_Legacy(t *testing.T) {
+	t.Parallel()
+
+	// Session data that was stored with typed internal keys is still decoded
+	storage := memory.New()
+	store := NewStore(Config{Storage: storage, AbsoluteTimeout: time.Hour})
+	absExpiration := time.Now().Add(time.Hour)
+	raw, err := GobCodec{}.Encode(map[any]any{"name": "john", absExpirationKey: absExpiration})
+	require.NoError(t, err)
+	require.NoError(t, storage.Set("legacy", raw, time.Minute))
+
+	sess, err := store.GetByID("legacy")
+	require.NoError(t, err)
+	defer sess.Release()
+	require.Equal(t, "john", sess.Get("name"))
+	require.True(t, absExpiration.Equal(sess.absExpiration()))
+}
+
+// go test -v -run=^$ -bench=Benchmark_Session_Codec -benchmem -count=4
+func Benchmark_Session_Codec(b *testing.B) {
+	data := map[any]any{"name": "john", "id": 42, "roles": []any{"admin", "user"}}
+	for name, codec := range map[string]Codec{
+		"Gob":     GobCodec{},
+		"JSON":    JSONCodec{},
+		"MsgPack": MsgPackCodec{},
+		"CBOR":    CBORCodec{},
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				raw, err := codec.Encode(data)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := codec.Decode(raw, make(map[any]any)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
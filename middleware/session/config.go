@@ -13,7 +13,8 @@ import (
 type Config struct {
 	// Storage interface for storing session data.
 	//
-	// Optional. Default: memory.New()
+	// Optional. Default: an in memory store for this process only, which holds up to
+	// 100,000 keys and evicts the least recently used sessions
 	Storage velocity.Storage
 
 	// Next defines a function to skip this middleware when it returns true.
@@ -35,6 +36,19 @@ type Config struct {
 	// Optional. Default: utils.UUIDv4
 	KeyGenerator func() string
 
+	// Codec encodes the session data before it is stored. Use JSONCodec, MsgPackCodec or
+	// CBORCodec to store the data in a format that services in other languages can read.
+	//
+	// Optional. Default: GobCodec{}
+	Codec Codec
+
+	// Encryptor encrypts the encoded session data, e.g. with NewAESGCM, so that it can't be
+	// read or changed by others who have access to the storage. Session data that can't be
+	// decrypted, e.g. after its key was removed, is treated as missing.
+	//
+	// Optional. Default: nil
+	Encryptor Encryptor
+
 	// KeyLookup is a string in the format "<source>:<name>" used to extract the session ID from the request.
 	//
 	// Possible values: "header:<name>", "query:<name>", "cookie:<name>"
@@ -87,6 +101,17 @@ type Config struct {
 	// Optional. Default: false
 	CookieHTTPOnly bool
 
+	// Stateless keeps the whole session data in the cookie instead of the Storage, so that no
+	// storage is needed. The data is sealed by the Encryptor, which is required, and is split
+	// into several cookies if it exceeds the size of a cookie. The IdleTimeout and the
+	// AbsoluteTimeout are enforced from the data in the cookie. KeyLookup must use a cookie.
+	//
+	// Sessions that are kept in cookies can't be retrieved by ID, deleted by the Store or listed
+	// by their subject.
+	//
+	// Optional. Default: false
+	Stateless bool
+
 	// CookieSessionOnly determines if the cookie should expire when the browser session ends.
 	//
 	// If true, the cookie will be deleted when the browser is closed.
@@ -105,11 +130,15 @@ const (
 	SourceURLQuery Source = "query"
 )
 
+// defaultMaxEntries is the number of keys of the default storage, the sessions and the subject indexes
+const defaultMaxEntries = 100_000
+
 // ConfigDefault provides the default configuration.
 var ConfigDefault = Config{
 	IdleTimeout:  30 * time.Minute,
 	KeyLookup:    "cookie:session_id",
 	KeyGenerator: utils.UUIDv4,
+	Codec:        GobCodec{},
 	source:       SourceCookie,
 	sessionName:  "session_id",
 }
@@ -165,6 +194,9 @@ func configDefault(config ...Config) Config {
 	if cfg.KeyGenerator == nil {
 		cfg.KeyGenerator = ConfigDefault.KeyGenerator
 	}
+	if cfg.Codec == nil {
+		cfg.Codec = ConfigDefault.Codec
+	}
 
 	// Parse KeyLookup into source and session name.
 	selectors := strings.Split(cfg.KeyLookup, ":")
@@ -184,5 +216,14 @@ func configDefault(config ...Config) Config {
 	}
 	cfg.sessionName = selectors[1]
 
+	if cfg.Stateless {
+		if cfg.source != SourceCookie {
+			panic("[session] Stateless requires a cookie in KeyLookup")
+		}
+		if cfg.Encryptor == nil {
+			panic("[session] Stateless requires an Encryptor")
+		}
+	}
+
 	return cfg
 }
@@ -0,0 +1,122 @@
+package session
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidKeyLength is returned by NewAESGCM if a key is not 16, 24 or 32 bytes long.
+	ErrInvalidKeyLength = errors.New("session: encryption key must be 16, 24, or 32 bytes")
+	// ErrNoKeys is returned by NewAESGCM if no key is given.
+	ErrNoKeys = errors.New("session: at least one encryption key is required")
+	// ErrDecryption is returned if encrypted session data can't be decrypted, e.g. because it
+	// was changed or its key was removed.
+	ErrDecryption = errors.New("session: failed to decrypt session data")
+)
+
+// Encryptor encrypts the encoded session data before it is stored, so that the storage can
+// neither read nor change it.
+type Encryptor interface {
+	// Encrypt encrypts and authenticates the plaintext together with the additional data,
+	// which is the ID of the session.
+	Encrypt(plaintext, additionalData []byte) ([]byte, error)
+	// Decrypt returns the plaintext of a ciphertext of Encrypt with the same additional data.
+	Decrypt(ciphertext, additionalData []byte) ([]byte, error)
+}
+
+const (
+	// aesGCMVersion is the first byte of the ciphertexts of AESGCM
+	aesGCMVersion = 1
+	// aesGCMKeyIDLen is the length of the IDs of the keys in the ciphertexts of AESGCM
+	aesGCMKeyIDLen = 4
+)
+
+// AESGCM encrypts the session data with AES-GCM. The first key encrypts and all keys decrypt,
+// so that a key can be rotated: the new key is put in front, and the old one is removed when
+// the sessions that it encrypted have expired.
+//
+// The ciphertext consists of a version byte, the first 4 bytes of the SHA-256 hash of the key,
+// the nonce and the sealed data. The ID of the session is authenticated with the data, so
+// that the data of a session can't be moved to another one.
+type AESGCM struct {
+	aeads []cipher.AEAD
+	ids   [][]byte
+}
+
+var _ Encryptor = (*AESGCM)(nil)
+
+// NewAESGCM creates an AESGCM with the keys, which must be 16, 24 or 32 bytes long to
+// select AES-128, AES-192 or AES-256.
+//
+// Parameters:
+//   - keys: The keys, the first one encrypts and all of them decrypt.
+//
+// Returns:
+//   - *AESGCM: The encryptor.
+//   - error: An error if no key is given or a key is invalid.
+//
+// Usage:
+//
+//	encryptor, err := session.NewAESGCM(newKey, oldKey)
+func NewAESGCM(keys ...[]byte) (*AESGCM, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	e := &AESGCM{
+		aeads: make([]cipher.AEAD, len(keys)),
+		ids:   make([][]byte, len(keys)),
+	}
+	for i, key := range keys {
+		if l := len(key); l != 16 && l != 24 && l != 32 {
+			return nil, ErrInvalidKeyLength
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("session: failed to create AES cipher: %w", err)
+		}
+		if e.aeads[i], err = cipher.NewGCM(block); err != nil {
+			return nil, fmt.Errorf("session: failed to create GCM mode: %w", err)
+		}
+		hash := sha256.Sum256(key)
+		e.ids[i] = hash[:aesGCMKeyIDLen]
+	}
+	return e, nil
+}
+
+// Encrypt implements Encryptor
+func (e *AESGCM) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	aead := e.aeads[0]
+	out := make([]byte, 0, 1+aesGCMKeyIDLen+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out = append(out, aesGCMVersion)
+	out = append(out, e.ids[0]...)
+	nonce := out[len(out) : len(out)+aead.NonceSize()]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("session: failed to read nonce: %w", err)
+	}
+	out = out[:len(out)+len(nonce)]
+	return aead.Seal(out, nonce, plaintext, additionalData), nil
+}
+
+// Decrypt implements Encryptor
+func (e *AESGCM) Decrypt(ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < 1+aesGCMKeyIDLen || ciphertext[0] != aesGCMVersion {
+		return nil, ErrDecryption
+	}
+	id, rest := ciphertext[1:1+aesGCMKeyIDLen], ciphertext[1+aesGCMKeyIDLen:]
+	for i, aead := range e.aeads {
+		if !bytes.Equal(e.ids[i], id) || len(rest) < aead.NonceSize() {
+			continue
+		}
+		nonce, sealed := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+		if plaintext, err := aead.Open(nil, nonce, sealed, additionalData); err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, ErrDecryption
+}
@@ -0,0 +1,121 @@
+package session
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// go test -run Test_AESGCM
+func Test_AESGCM(t *testing.T) {
+	t.Parallel()
+
+	oldKey := bytes.Repeat([]byte{1}, 16)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	oldEncryptor, err := NewAESGCM(oldKey)
+	require.NoError(t, err)
+	encryptor, err := NewAESGCM(newKey, oldKey)
+	require.NoError(t, err)
+
+	ciphertext, err := oldEncryptor.Encrypt([]byte("john"), []byte("id"))
+	require.NoError(t, err)
+	require.NotContains(t, string(ciphertext), "john")
+
+	// The old key still decrypts after the rotation
+	plaintext, err := encryptor.Decrypt(ciphertext, []byte("id"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("john"), plaintext)
+
+	// The new key encrypts, the old encryptor can't decrypt it
+	ciphertext, err = encryptor.Encrypt([]byte("john"), []byte("id"))
+	require.NoError(t, err)
+	_, err = oldEncryptor.Decrypt(ciphertext, []byte("id"))
+	require.ErrorIs(t, err, ErrDecryption)
+
+	// The additional data is authenticated
+	_, err = encryptor.Decrypt(ciphertext, []byte("other"))
+	require.ErrorIs(t, err, ErrDecryption)
+
+	// Changed data is rejected
+	ciphertext[len(ciphertext)-1] ^= 1
+	_, err = encryptor.Decrypt(ciphertext, []byte("id"))
+	require.ErrorIs(t, err, ErrDecryption)
+
+	_, err = encryptor.Decrypt([]byte{1, 2}, []byte("id"))
+	require.ErrorIs(t, err, ErrDecryption)
+}
+
+// go test -run Test_AESGCM_Invalid
+func Test_AESGCM_Invalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewAESGCM()
+	require.ErrorIs(t, err, ErrNoKeys)
+
+	_, err = NewAESGCM(make([]byte, 16), make([]byte, 10))
+	require.ErrorIs(t, err, ErrInvalidKeyLength)
+}
+
+// go test -run Test_Session_Encryptor
+func Test_Session_Encryptor(t *testing.T) {
+	t.Parallel()
+
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+	oldEncryptor, err := NewAESGCM(oldKey)
+	require.NoError(t, err)
+
+	storage := memory.New()
+	store := NewStore(Config{Storage: storage, Codec: JSONCodec{}, Encryptor: oldEncryptor})
+	app := velocity.New()
+
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	sess, err := store.Get(ctx)
+	require.NoError(t, err)
+	sess.Set("name", "john")
+	id := sess.ID()
+	require.NoError(t, sess.Save())
+	sess.Release()
+	app.ReleaseCtx(ctx)
+
+	// The storage only holds the ciphertext
+	raw, err := storage.Get(id)
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "john")
+
+	// The data of a session can't be moved to another one
+	require.NoError(t, storage.Set("other", raw, time.Minute))
+	_, err = store.GetByID("other")
+	require.ErrorIs(t, err, ErrSessionIDNotFoundInStore)
+
+	// The data is decrypted after the key was rotated
+	encryptor, err := NewAESGCM(newKey, oldKey)
+	require.NoError(t, err)
+	store.Encryptor = encryptor
+	sess, err = store.GetByID(id)
+	require.NoError(t, err)
+	require.Equal(t, "john", sess.Get("name"))
+	sess.Release()
+
+	// Data that can't be decrypted is treated as missing
+	store.Encryptor, err = NewAESGCM(newKey)
+	require.NoError(t, err)
+	_, err = store.GetByID(id)
+	require.ErrorIs(t, err, ErrSessionIDNotFoundInStore)
+
+	ctx = app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+	ctx.Request().Header.SetCookie(store.sessionName, id)
+	sess, err = store.Get(ctx)
+	require.NoError(t, err)
+	defer sess.Release()
+	require.True(t, sess.Fresh())
+	require.NotEqual(t, id, sess.ID())
+	require.Nil(t, sess.Get("name"))
+}
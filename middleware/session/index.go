@@ -0,0 +1,160 @@
+package session
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/shared"
+)
+
+// subjectIndexPrefix is the prefix of the keys of the subject indexes in the storage
+const subjectIndexPrefix = "session_subject:"
+
+// msgp -file="index.go" -o="index_msgp.go" -tests=true -unexported
+//
+//go:generate msgp -o=index_msgp.go -tests=true -unexported
+type subjectEntry struct {
+	// Sessions are the IDs of the sessions of the subject with the Unix time when they expire
+	Sessions map[string]int64 `msg:"s"`
+	// Revoked is the Unix time in nanoseconds when the sessions of the subject were deleted,
+	// the sessions that were marked with the subject before are not stored again
+	Revoked int64 `msg:"r"`
+	// Exp is the Unix time until which the index is kept at least, so that a revoked
+	// session that is still in use is not stored again
+	Exp int64 `msg:"e"`
+}
+
+// subjectIndex stores the IDs of the sessions of a subject in the storage of the sessions,
+// so that all instances that share the storage can list and revoke them
+//
+//msgp:ignore subjectIndex
+type subjectIndex struct {
+	storage     velocity.Storage
+	updater     *shared.Updater
+	idleTimeout time.Duration
+}
+
+func newSubjectIndex(storage velocity.Storage, idleTimeout time.Duration) *subjectIndex {
+	return &subjectIndex{storage: storage, updater: shared.NewUpdater(storage), idleTimeout: idleTimeout}
+}
+
+// add adds the session that was marked with the subject at since and expires at exp. It reports
+// false if the sessions of the subject were revoked after since, the session must then be deleted.
+func (i *subjectIndex) add(subject, id string, since, exp time.Time) (bool, error) {
+	var added bool
+	err := i.update(subject, func(entry *subjectEntry) {
+		added = since.UnixNano() > entry.Revoked
+		if added {
+			entry.Sessions[id] = exp.Unix()
+		}
+	})
+	return added, err
+}
+
+// remove removes the sessions from the index of the subject
+func (i *subjectIndex) remove(subject string, ids ...string) error {
+	return i.update(subject, func(entry *subjectEntry) {
+		for _, id := range ids {
+			delete(entry.Sessions, id)
+		}
+	})
+}
+
+// revoke removes all sessions from the index of the subject and returns their IDs, the
+// sessions that were marked with the subject before can't be added again
+func (i *subjectIndex) revoke(subject string) ([]string, error) {
+	var ids []string
+	err := i.update(subject, func(entry *subjectEntry) {
+		ids = ids[:0]
+		now := time.Now()
+		entry.Exp = max(entry.Exp, now.Add(i.idleTimeout).Unix())
+		for id, exp := range entry.Sessions {
+			ids = append(ids, id)
+			entry.Exp = max(entry.Exp, exp)
+		}
+		entry.Sessions = make(map[string]int64)
+		entry.Revoked = now.UnixNano()
+	})
+	return ids, err
+}
+
+// ids returns the IDs of the sessions of the subject
+func (i *subjectIndex) ids(subject string) ([]string, error) {
+	entry, err := i.get(subject)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	ids := make([]string, 0, len(entry.Sessions))
+	for id, exp := range entry.Sessions {
+		if exp > now {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// get returns the index of the subject
+func (i *subjectIndex) get(subject string) (subjectEntry, error) {
+	raw, err := i.storage.Get(subjectIndexPrefix + subject)
+	if err != nil {
+		return subjectEntry{}, fmt.Errorf("session: failed to get subject index: %w", err)
+	}
+	entry, err := decodeSubjectEntry(raw)
+	if err != nil {
+		return entry, fmt.Errorf("session: failed to get subject index: %w", err)
+	}
+	return entry, nil
+}
+
+// decodeSubjectEntry decodes the index of a subject, an empty one if raw is nil
+func decodeSubjectEntry(raw []byte) (subjectEntry, error) {
+	entry := subjectEntry{Sessions: make(map[string]int64)}
+	if raw == nil {
+		return entry, nil
+	}
+	if _, err := entry.UnmarshalMsg(raw); err != nil {
+		return entry, fmt.Errorf("failed to decode: %w", err)
+	}
+	if entry.Sessions == nil {
+		entry.Sessions = make(map[string]int64)
+	}
+	return entry, nil
+}
+
+// update calls fn with the index of the subject, the expired sessions are removed, and stores
+// the index. fn is called again if the index was changed by another instance in the meantime.
+func (i *subjectIndex) update(subject string, fn func(entry *subjectEntry)) error {
+	err := i.updater.Update(subjectIndexPrefix+subject, func(old []byte) ([]byte, time.Duration, error) {
+		entry, err := decodeSubjectEntry(old)
+		if err != nil {
+			return nil, 0, err
+		}
+		fn(&entry)
+
+		// Remove the expired sessions
+		now := time.Now().Unix()
+		exp := entry.Exp
+		for id, sessionExp := range entry.Sessions {
+			if sessionExp <= now {
+				delete(entry.Sessions, id)
+				continue
+			}
+			exp = max(exp, sessionExp)
+		}
+		if exp <= now {
+			return nil, 0, nil
+		}
+
+		raw, err := entry.MarshalMsg(nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to encode: %w", err)
+		}
+		return raw, time.Duration(exp-now) * time.Second, nil
+	})
+	if err != nil {
+		return fmt.Errorf("session: failed to update subject index: %w", err)
+	}
+	return nil
+}
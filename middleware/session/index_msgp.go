@@ -0,0 +1,231 @@
+package session
+
+// Code generated by github.com/tinylib/msgp DO NOT EDIT.
+
+import (
+	"github.com/tinylib/msgp/msgp"
+)
+
+// DecodeMsg implements msgp.Decodable
+func (z *subjectEntry) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "s":
+			var zb0002 uint32
+			zb0002, err = dc.ReadMapHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "Sessions")
+				return
+			}
+			if z.Sessions == nil {
+				z.Sessions = make(map[string]int64, zb0002)
+			} else if len(z.Sessions) > 0 {
+				for key := range z.Sessions {
+					delete(z.Sessions, key)
+				}
+			}
+			for zb0002 > 0 {
+				zb0002--
+				var za0001 string
+				var za0002 int64
+				za0001, err = dc.ReadString()
+				if err != nil {
+					err = msgp.WrapError(err, "Sessions")
+					return
+				}
+				za0002, err = dc.ReadInt64()
+				if err != nil {
+					err = msgp.WrapError(err, "Sessions", za0001)
+					return
+				}
+				z.Sessions[za0001] = za0002
+			}
+		case "r":
+			z.Revoked, err = dc.ReadInt64()
+			if err != nil {
+				err = msgp.WrapError(err, "Revoked")
+				return
+			}
+		case "e":
+			z.Exp, err = dc.ReadInt64()
+			if err != nil {
+				err = msgp.WrapError(err, "Exp")
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z *subjectEntry) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 3
+	// write "s"
+	err = en.Append(0x83, 0xa1, 0x73)
+	if err != nil {
+		return
+	}
+	err = en.WriteMapHeader(uint32(len(z.Sessions)))
+	if err != nil {
+		err = msgp.WrapError(err, "Sessions")
+		return
+	}
+	for za0001, za0002 := range z.Sessions {
+		err = en.WriteString(za0001)
+		if err != nil {
+			err = msgp.WrapError(err, "Sessions")
+			return
+		}
+		err = en.WriteInt64(za0002)
+		if err != nil {
+			err = msgp.WrapError(err, "Sessions", za0001)
+			return
+		}
+	}
+	// write "r"
+	err = en.Append(0xa1, 0x72)
+	if err != nil {
+		return
+	}
+	err = en.WriteInt64(z.Revoked)
+	if err != nil {
+		err = msgp.WrapError(err, "Revoked")
+		return
+	}
+	// write "e"
+	err = en.Append(0xa1, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteInt64(z.Exp)
+	if err != nil {
+		err = msgp.WrapError(err, "Exp")
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *subjectEntry) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 3
+	// string "s"
+	o = append(o, 0x83, 0xa1, 0x73)
+	o = msgp.AppendMapHeader(o, uint32(len(z.Sessions)))
+	for za0001, za0002 := range z.Sessions {
+		o = msgp.AppendString(o, za0001)
+		o = msgp.AppendInt64(o, za0002)
+	}
+	// string "r"
+	o = append(o, 0xa1, 0x72)
+	o = msgp.AppendInt64(o, z.Revoked)
+	// string "e"
+	o = append(o, 0xa1, 0x65)
+	o = msgp.AppendInt64(o, z.Exp)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *subjectEntry) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "s":
+			var zb0002 uint32
+			zb0002, bts, err = msgp.ReadMapHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Sessions")
+				return
+			}
+			if z.Sessions == nil {
+				z.Sessions = make(map[string]int64, zb0002)
+			} else if len(z.Sessions) > 0 {
+				for key := range z.Sessions {
+					delete(z.Sessions, key)
+				}
+			}
+			for zb0002 > 0 {
+				var za0001 string
+				var za0002 int64
+				zb0002--
+				za0001, bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Sessions")
+					return
+				}
+				za0002, bts, err = msgp.ReadInt64Bytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Sessions", za0001)
+					return
+				}
+				z.Sessions[za0001] = za0002
+			}
+		case "r":
+			z.Revoked, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Revoked")
+				return
+			}
+		case "e":
+			z.Exp, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Exp")
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *subjectEntry) Msgsize() (s int) {
+	s = 1 + 2 + msgp.MapHeaderSize
+	if z.Sessions != nil {
+		for za0001, za0002 := range z.Sessions {
+			_ = za0002
+			s += msgp.StringPrefixSize + len(za0001) + msgp.Int64Size
+		}
+	}
+	s += 2 + msgp.Int64Size + 2 + msgp.Int64Size
+	return
+}
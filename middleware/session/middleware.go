@@ -232,6 +232,36 @@ func (m *Middleware) Delete(key any) {
 	m.Session.Delete(key)
 }
 
+// SetSubject marks the session with a subject, e.g. the ID of the logged in user.
+//
+// Parameters:
+//   - subject: The subject of the session.
+//
+// Usage:
+//
+//	m.SetSubject(userID)
+func (m *Middleware) SetSubject(subject string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Session.SetSubject(subject)
+}
+
+// Subject returns the subject of the session.
+//
+// Returns:
+//   - string: The subject of the session, empty if it has none.
+//
+// Usage:
+//
+//	userID := m.Subject()
+func (m *Middleware) Subject() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.Session.Subject()
+}
+
 // Destroy destroys the session.
 //
 // Returns:
@@ -451,3 +451,56 @@ func Test_Session_Middleware_Store(t *testing.T) {
 	h(ctx)
 	require.Equal(t, velocity.StatusOK, ctx.Response.StatusCode())
 }
+
+// go test -run Test_Session_Middleware_Subject
+func Test_Session_Middleware_Subject(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	handler, store := NewWithStore()
+	app.Use(handler)
+
+	app.Post("/login", func(c velocity.Ctx) error {
+		FromContext(c).SetSubject(c.Query("user"))
+		return c.SendStatus(velocity.StatusOK)
+	})
+	app.Get("/whoami", func(c velocity.Ctx) error {
+		return c.SendString(FromContext(c).Subject())
+	})
+	app.Post("/logout-everywhere", func(c velocity.Ctx) error {
+		return store.DeleteBySubject(FromContext(c).Subject())
+	})
+
+	// login returns the session cookie of a new session of the user
+	login := func(user string) string {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(velocity.MethodPost)
+		ctx.Request.SetRequestURI("/login?user=" + user)
+		app.Handler()(ctx)
+		require.Equal(t, velocity.StatusOK, ctx.Response.StatusCode())
+		return string(ctx.Response.Header.PeekCookie("session_id"))
+	}
+	// request sends a request with the session cookie and returns the body
+	request := func(method, path, cookie string) string {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(method)
+		ctx.Request.SetRequestURI(path)
+		ctx.Request.Header.Set(velocity.HeaderCookie, strings.Split(cookie, ";")[0])
+		app.Handler()(ctx)
+		return string(ctx.Response.Body())
+	}
+
+	laptop := login("john")
+	phone := login("john")
+	other := login("jane")
+	require.Equal(t, "john", request(velocity.MethodGet, "/whoami", laptop))
+
+	ids, err := store.ListBySubject("john")
+	require.NoError(t, err)
+	require.Len(t, ids, 2)
+
+	request(velocity.MethodPost, "/logout-everywhere", laptop)
+	require.Empty(t, request(velocity.MethodGet, "/whoami", laptop))
+	require.Empty(t, request(velocity.MethodGet, "/whoami", phone))
+	require.Equal(t, "jane", request(velocity.MethodGet, "/whoami", other))
+}
@@ -2,7 +2,6 @@ package session
 
 import (
 	"bytes"
-	"encoding/gob"
 	"fmt"
 	"sync"
 	"time"
@@ -19,6 +18,7 @@ type Session struct {
 	data        *data         // key value data
 	id          string        // session id
 	idleTimeout time.Duration // idleTimeout of this session
+	oldSubject  string        // subject the session was indexed with before it was changed
 	mu          sync.RWMutex  // Mutex to protect non-data fields
 	fresh       bool          // if new session
 }
@@ -30,6 +30,15 @@ const (
 	absExpirationKey absExpirationKeyType = iota
 )
 
+type subjectKeyType int
+
+const (
+	// subjectKey is the key of the subject of the session in the session data.
+	subjectKey subjectKeyType = iota
+	// subjectSinceKey is the key of the time when the subject was set in the session data.
+	subjectSinceKey
+)
+
 // Session pool for reusing byte buffers.
 var byteBufferPool = sync.Pool{
 	New: func() any {
@@ -88,6 +97,7 @@ func releaseSession(s *Session) {
 	s.mu.Lock()
 	s.id = ""
 	s.idleTimeout = 0
+	s.oldSubject = ""
 	s.ctx = nil
 	s.config = nil
 	if s.data != nil {
@@ -187,14 +197,16 @@ func (s *Session) Destroy() error {
 		return nil
 	}
 
+	subject := s.Subject()
+
 	// Reset local data
 	s.data.Reset()
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	// Use external Storage if exist
-	if err := s.config.Storage.Delete(s.id); err != nil {
+	if err := s.deleteStored(subject); err != nil {
 		return err
 	}
 
@@ -216,7 +228,7 @@ func (s *Session) Regenerate() error {
 	defer s.mu.Unlock()
 
 	// Delete old id from storage
-	if err := s.config.Storage.Delete(s.id); err != nil {
+	if err := s.deleteStored(s.Subject()); err != nil {
 		return err
 	}
 
@@ -235,6 +247,8 @@ func (s *Session) Regenerate() error {
 //
 //	err := s.Reset()
 func (s *Session) Reset() error {
+	subject := s.Subject()
+
 	// Reset local data
 	if s.data != nil {
 		s.data.Reset()
@@ -247,7 +261,7 @@ func (s *Session) Reset() error {
 	s.idleTimeout = 0
 
 	// Delete old id from storage
-	if err := s.config.Storage.Delete(s.id); err != nil {
+	if err := s.deleteStored(subject); err != nil {
 		return err
 	}
 
@@ -260,6 +274,24 @@ func (s *Session) Reset() error {
 	return nil
 }
 
+// deleteStored deletes the session from the storage and from the index of its subject, s.mu has to be held.
+//
+// Parameters:
+//   - subject: The subject of the session, empty if it has none.
+//
+// Returns:
+//   - error: An error if the session can't be deleted.
+func (s *Session) deleteStored(subject string) error {
+	// Stateless sessions are only kept in the cookie
+	if s.config.Stateless {
+		return nil
+	}
+	if err := s.config.Storage.Delete(s.id); err != nil {
+		return err
+	}
+	return s.unindex(subject)
+}
+
 // refresh generates a new session, and sets session.fresh to be true.
 func (s *Session) refresh() {
 	s.id = s.config.KeyGenerator()
@@ -308,7 +340,9 @@ func (s *Session) saveSession() error {
 	}
 
 	// Update client cookie
-	s.setSession()
+	if !s.config.Stateless {
+		s.setSession()
+	}
 
 	// Encode session data
 	s.data.RLock()
@@ -318,8 +352,117 @@ func (s *Session) saveSession() error {
 		return fmt.Errorf("failed to encode data: %w", err)
 	}
 
+	// Stateless sessions are kept in the cookie instead of the storage
+	if s.config.Stateless {
+		return s.setDataCookies(encodedBytes)
+	}
+
 	// Pass copied bytes with session id to provider
-	return s.config.Storage.Set(s.id, encodedBytes, s.idleTimeout)
+	if err := s.config.Storage.Set(s.id, encodedBytes, s.idleTimeout); err != nil {
+		return err
+	}
+
+	return s.index()
+}
+
+// index adds the session to the index of its subject and removes it from the index
+// of its old subject, s.mu has to be held.
+//
+// Returns:
+//   - error: An error if the index can't be updated.
+func (s *Session) index() error {
+	subject := s.Subject()
+	if s.oldSubject != subject {
+		if err := s.unindex(""); err != nil {
+			return err
+		}
+	}
+	s.oldSubject = ""
+	if subject == "" {
+		return nil
+	}
+
+	since, _ := s.Get(subjectSinceKey).(time.Time) //nolint:errcheck // zero if not set
+	added, err := s.config.index.add(subject, s.id, since, time.Now().Add(s.idleTimeout))
+	if err != nil {
+		return err
+	}
+	if !added {
+		// The sessions of the subject were revoked while the session was in use
+		if err := s.config.Storage.Delete(s.id); err != nil {
+			return err
+		}
+		s.delSession()
+	}
+	return nil
+}
+
+// unindex removes the session from the index of the subject and of its old subject, s.mu has to be held.
+//
+// Parameters:
+//   - subject: The subject of the session, empty if it has none.
+//
+// Returns:
+//   - error: An error if the index can't be updated.
+func (s *Session) unindex(subject string) error {
+	subjects := []string{subject}
+	if s.oldSubject != subject {
+		subjects = append(subjects, s.oldSubject)
+	}
+	for _, sub := range subjects {
+		if sub == "" {
+			continue
+		}
+		if err := s.config.index.remove(sub, s.id); err != nil {
+			return err
+		}
+	}
+	s.oldSubject = ""
+	return nil
+}
+
+// SetSubject marks the session with a subject, e.g. the ID of the logged in user, so that the
+// sessions of the subject can be listed with Store.ListBySubject and deleted with Store.DeleteBySubject.
+// An empty subject removes the mark. The session is indexed when it is saved.
+//
+// Parameters:
+//   - subject: The subject of the session.
+//
+// Usage:
+//
+//	s.SetSubject(userID)
+func (s *Session) SetSubject(subject string) {
+	old := s.Subject()
+	if old == subject {
+		return
+	}
+
+	s.mu.Lock()
+	if s.oldSubject == "" {
+		s.oldSubject = old
+	}
+	s.mu.Unlock()
+
+	if subject == "" {
+		s.Delete(subjectKey)
+		s.Delete(subjectSinceKey)
+		return
+	}
+	s.Set(subjectKey, subject)
+	s.Set(subjectSinceKey, time.Now())
+}
+
+// Subject returns the subject of the session that was set by SetSubject.
+//
+// Returns:
+//   - string: The subject of the session, empty if it has none.
+//
+// Usage:
+//
+//	userID := s.Subject()
+func (s *Session) Subject() string {
+	subject, _ := s.Get(subjectKey).(string) //nolint:errcheck // empty if not set
+	return subject
 }
 
 // Keys retrieves all keys in the current session.
@@ -360,31 +503,68 @@ func (s *Session) setSession() {
 		s.ctx.Request().Header.SetBytesV(s.config.sessionName, []byte(s.id))
 		s.ctx.Response().Header.SetBytesV(s.config.sessionName, []byte(s.id))
 	} else {
-		fcookie := fasthttp.AcquireCookie()
-		fcookie.SetKey(s.config.sessionName)
-		fcookie.SetValue(s.id)
-		fcookie.SetPath(s.config.CookiePath)
-		fcookie.SetDomain(s.config.CookieDomain)
-		// Cookies are also session cookies if they do not specify the Expires or Max-Age attribute.
-		// refer: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Set-Cookie
-		if !s.config.CookieSessionOnly {
-			fcookie.SetMaxAge(int(s.idleTimeout.Seconds()))
-			fcookie.SetExpire(time.Now().Add(s.idleTimeout))
-		}
-		fcookie.SetSecure(s.config.CookieSecure)
-		fcookie.SetHTTPOnly(s.config.CookieHTTPOnly)
-
-		switch utils.ToLower(s.config.CookieSameSite) {
-		case "strict":
-			fcookie.SetSameSite(fasthttp.CookieSameSiteStrictMode)
-		case "none":
-			fcookie.SetSameSite(fasthttp.CookieSameSiteNoneMode)
-		default:
-			fcookie.SetSameSite(fasthttp.CookieSameSiteLaxMode)
-		}
-		s.ctx.Response().Header.SetCookie(fcookie)
-		fasthttp.ReleaseCookie(fcookie)
+		s.setCookie(s.config.sessionName, s.id)
+	}
+}
+
+// setCookie sets a cookie of the session that expires after the idle timeout.
+//
+// Parameters:
+//   - name: The name of the cookie.
+//   - value: The value of the cookie.
+func (s *Session) setCookie(name, value string) {
+	fcookie := s.acquireCookie(name)
+	fcookie.SetValue(value)
+	// Cookies are also session cookies if they do not specify the Expires or Max-Age attribute.
+	// refer: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Set-Cookie
+	if !s.config.CookieSessionOnly {
+		fcookie.SetMaxAge(int(s.idleTimeout.Seconds()))
+		fcookie.SetExpire(time.Now().Add(s.idleTimeout))
+	}
+	s.ctx.Response().Header.SetCookie(fcookie)
+	fasthttp.ReleaseCookie(fcookie)
+}
+
+// expireCookie deletes a cookie of the session from the request and the client.
+//
+// Parameters:
+//   - name: The name of the cookie.
+func (s *Session) expireCookie(name string) {
+	s.ctx.Request().Header.DelCookie(name)
+	s.ctx.Response().Header.DelCookie(name)
+
+	fcookie := s.acquireCookie(name)
+	fcookie.SetMaxAge(-1)
+	fcookie.SetExpire(time.Now().Add(-1 * time.Minute))
+	s.ctx.Response().Header.SetCookie(fcookie)
+	fasthttp.ReleaseCookie(fcookie)
+}
+
+// acquireCookie returns a cookie with the name and the attributes of the config, it has to be
+// released with fasthttp.ReleaseCookie.
+//
+// Parameters:
+//   - name: The name of the cookie.
+//
+// Returns:
+//   - *fasthttp.Cookie: The cookie.
+func (s *Session) acquireCookie(name string) *fasthttp.Cookie {
+	fcookie := fasthttp.AcquireCookie()
+	fcookie.SetKey(name)
+	fcookie.SetPath(s.config.CookiePath)
+	fcookie.SetDomain(s.config.CookieDomain)
+	fcookie.SetSecure(s.config.CookieSecure)
+	fcookie.SetHTTPOnly(s.config.CookieHTTPOnly)
+
+	switch utils.ToLower(s.config.CookieSameSite) {
+	case "strict":
+		fcookie.SetSameSite(fasthttp.CookieSameSiteStrictMode)
+	case "none":
+		fcookie.SetSameSite(fasthttp.CookieSameSiteNoneMode)
+	default:
+		fcookie.SetSameSite(fasthttp.CookieSameSiteLaxMode)
 	}
+	return fcookie
 }
 
 func (s *Session) delSession() {
@@ -395,83 +575,80 @@ func (s *Session) delSession() {
 	if s.config.source == SourceHeader {
 		s.ctx.Request().Header.Del(s.config.sessionName)
 		s.ctx.Response().Header.Del(s.config.sessionName)
+	} else if s.config.Stateless {
+		s.expireDataCookies(0)
 	} else {
-		s.ctx.Request().Header.DelCookie(s.config.sessionName)
-		s.ctx.Response().Header.DelCookie(s.config.sessionName)
-
-		fcookie := fasthttp.AcquireCookie()
-		fcookie.SetKey(s.config.sessionName)
-		fcookie.SetPath(s.config.CookiePath)
-		fcookie.SetDomain(s.config.CookieDomain)
-		fcookie.SetMaxAge(-1)
-		fcookie.SetExpire(time.Now().Add(-1 * time.Minute))
-		fcookie.SetSecure(s.config.CookieSecure)
-		fcookie.SetHTTPOnly(s.config.CookieHTTPOnly)
-
-		switch utils.ToLower(s.config.CookieSameSite) {
-		case "strict":
-			fcookie.SetSameSite(fasthttp.CookieSameSiteStrictMode)
-		case "none":
-			fcookie.SetSameSite(fasthttp.CookieSameSiteNoneMode)
-		default:
-			fcookie.SetSameSite(fasthttp.CookieSameSiteLaxMode)
-		}
-
-		s.ctx.Response().Header.SetCookie(fcookie)
-		fasthttp.ReleaseCookie(fcookie)
+		s.expireCookie(s.config.sessionName)
 	}
 }
 
-// decodeSessionData decodes session data from raw bytes
+// decodeSessionData decrypts and decodes session data from raw bytes
 //
 // Parameters:
 //   - rawData: The raw byte data to decode.
 //
 // Returns:
-//   - error: An error if the decoding fails.
+//   - error: An error if the decoding fails, ErrDecryption if the data can't be decrypted.
 //
 // Usage:
 //
 //	err := s.decodeSessionData(rawData)
 func (s *Session) decodeSessionData(rawData []byte) error {
-	byteBuffer := byteBufferPool.Get().(*bytes.Buffer) //nolint:forcetypeassert,errcheck // We store nothing else in the pool
-	defer byteBufferPool.Put(byteBuffer)
-	defer byteBuffer.Reset()
-	_, _ = byteBuffer.Write(rawData)
-	decCache := gob.NewDecoder(byteBuffer)
-	if err := decCache.Decode(&s.data.Data); err != nil {
+	if s.config.Encryptor != nil {
+		plaintext, err := s.config.Encryptor.Decrypt(rawData, s.additionalData())
+		if err != nil {
+			return ErrDecryption
+		}
+		rawData = plaintext
+	}
+	if err := s.config.Codec.Decode(rawData, s.data.Data); err != nil {
 		return fmt.Errorf("failed to decode session data: %w", err)
 	}
+	importData(s.data.Data)
 	return nil
 }
 
-// encodeSessionData encodes session data to raw bytes
-//
-// Parameters:
-//   - rawData: The raw byte data to encode.
+// encodeSessionData encodes and encrypts session data to raw bytes
 //
 // Returns:
+//   - []byte: The encoded data.
 //   - error: An error if the encoding fails.
 //
 // Usage:
 //
-//	err := s.encodeSessionData(rawData)
+//	rawData, err := s.encodeSessionData()
 func (s *Session) encodeSessionData() ([]byte, error) {
-	byteBuffer := byteBufferPool.Get().(*bytes.Buffer) //nolint:forcetypeassert,errcheck // We store nothing else in the pool
-	defer byteBufferPool.Put(byteBuffer)
-	defer byteBuffer.Reset()
-	encCache := gob.NewEncoder(byteBuffer)
-	if err := encCache.Encode(&s.data.Data); err != nil {
+	data := exportData(s.data.Data)
+	if s.config.Stateless {
+		// The cookie carries the ID and the idle expiration of the session
+		data[statelessIDName] = s.id
+		data[statelessExpirationName] = time.Now().Add(s.idleTimeout).UnixMilli()
+	}
+	encodedBytes, err := s.config.Codec.Encode(data)
+	if err != nil {
 		return nil, fmt.Errorf("failed to encode session data: %w", err)
 	}
-	// Copy the bytes
-	// Copy the data in buffer
-	encodedBytes := make([]byte, byteBuffer.Len())
-	copy(encodedBytes, byteBuffer.Bytes())
-
+	if s.config.Encryptor != nil {
+		if encodedBytes, err = s.config.Encryptor.Encrypt(encodedBytes, s.additionalData()); err != nil {
+			return nil, fmt.Errorf("failed to encrypt session data: %w", err)
+		}
+	}
 	return encodedBytes, nil
 }
 
+// additionalData returns the data that is authenticated together with the encrypted session
+// data, the ID of the session or the name of the cookie of a stateless session, whose ID is
+// part of the encrypted data.
+//
+// Returns:
+//   - []byte: The additional data.
+func (s *Session) additionalData() []byte {
+	if s.config.Stateless {
+		return []byte(s.config.sessionName)
+	}
+	return []byte(s.id)
+}
+
 // absExpiration returns the session absolute expiration time or a zero time if not set.
 //
 // Returns:
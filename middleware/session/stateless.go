@@ -0,0 +1,175 @@
+package session
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// ErrCookieTooLarge is returned when a stateless session is saved whose data doesn't fit into
+// the cookies.
+var ErrCookieTooLarge = errors.New("session: session data is too large for the cookies")
+
+// Names of the values that a stateless session keeps in its cookie in addition to the session data
+const (
+	statelessIDName         = "_velocity_id"
+	statelessExpirationName = "_velocity_expiration"
+)
+
+const (
+	// maxCookieSize is the size of the name and value of a cookie that all browsers accept
+	maxCookieSize = 4000
+	// maxCookieChunks is the number of cookies that the data of a stateless session is split into at most
+	maxCookieChunks = 8
+)
+
+// getStatelessSession returns the session whose data is kept in the cookies of the request.
+// A missing, invalid or expired cookie starts a new session.
+//
+// Parameters:
+//   - c: The Velocity context.
+//
+// Returns:
+//   - *Session: The session object.
+//   - error: An error if the session can't be reset after its absolute expiration.
+func (s *Store) getStatelessSession(c velocity.Ctx) (*Session, error) {
+	sess := acquireSession()
+
+	sess.mu.Lock()
+	sess.ctx = c
+	sess.config = s
+
+	var id string
+	if raw := s.readDataCookies(c); raw != nil {
+		sess.data.Lock()
+		id = sess.decodeStatelessData(raw)
+		sess.data.Unlock()
+		if id == "" {
+			sess.data.Reset()
+		}
+	}
+
+	fresh := id == ""
+	if fresh {
+		var ok bool
+		if id, ok = c.Locals(sessionIDContextKey).(string); !ok {
+			id = s.KeyGenerator()
+			c.Locals(sessionIDContextKey, id)
+		}
+	}
+	sess.id = id
+	sess.fresh = fresh
+
+	sess.mu.Unlock()
+
+	if err := s.checkAbsExpiration(sess, fresh); err != nil {
+		return nil, err
+	}
+
+	return sess, nil
+}
+
+// readDataCookies returns the data of a stateless session from the cookies of the request.
+//
+// Parameters:
+//   - c: The Velocity context.
+//
+// Returns:
+//   - []byte: The encrypted session data, nil if there is none.
+func (s *Store) readDataCookies(c velocity.Ctx) []byte {
+	var value []byte
+	for i := 0; i < maxCookieChunks; i++ {
+		chunk := c.Request().Header.Cookie(chunkName(s.sessionName, i))
+		if len(chunk) == 0 {
+			break
+		}
+		value = append(value, chunk...)
+	}
+	if len(value) == 0 {
+		return nil
+	}
+	raw := make([]byte, base64.RawURLEncoding.DecodedLen(len(value)))
+	n, err := base64.RawURLEncoding.Decode(raw, value)
+	if err != nil {
+		return nil
+	}
+	return raw[:n]
+}
+
+// decodeStatelessData decodes the data of a stateless session, s.data has to be locked.
+//
+// Parameters:
+//   - raw: The encrypted session data.
+//
+// Returns:
+//   - string: The ID of the session, empty if the data is invalid or expired.
+func (s *Session) decodeStatelessData(raw []byte) string {
+	if err := s.decodeSessionData(raw); err != nil {
+		return ""
+	}
+	id, _ := s.data.Data[statelessIDName].(string) //nolint:errcheck // empty if invalid
+	expiration, ok := toInt64(s.data.Data[statelessExpirationName])
+	delete(s.data.Data, statelessIDName)
+	delete(s.data.Data, statelessExpirationName)
+	if !ok || expiration <= time.Now().UnixMilli() {
+		return ""
+	}
+	return id
+}
+
+// setDataCookies sets the cookies with the data of a stateless session, the data is split into
+// several cookies if it doesn't fit into one. s.mu has to be held.
+//
+// Parameters:
+//   - raw: The encrypted session data.
+//
+// Returns:
+//   - error: ErrCookieTooLarge if the data doesn't fit into the cookies.
+func (s *Session) setDataCookies(raw []byte) error {
+	value := base64.RawURLEncoding.EncodeToString(raw)
+	// Leave room for the suffix of the chunks
+	size := maxCookieSize - len(s.config.sessionName) - len("_0")
+	n := (len(value) + size - 1) / size
+	if n > maxCookieChunks {
+		return ErrCookieTooLarge
+	}
+	for i := 0; i < n; i++ {
+		s.setCookie(chunkName(s.config.sessionName, i), value[i*size:min((i+1)*size, len(value))])
+	}
+	// Remove the chunks of a larger previous value
+	s.expireDataCookies(n)
+	return nil
+}
+
+// expireDataCookies expires the cookies with the data of a stateless session from the chunk
+// with the index from on. s.mu has to be held.
+//
+// Parameters:
+//   - from: The index of the first chunk that is expired.
+func (s *Session) expireDataCookies(from int) {
+	for i := from; i < maxCookieChunks; i++ {
+		name := chunkName(s.config.sessionName, i)
+		if i > 0 && len(s.ctx.Request().Header.Cookie(name)) == 0 {
+			break
+		}
+		s.expireCookie(name)
+	}
+}
+
+// chunkName returns the name of the cookie with the i-th chunk of the data of a stateless session.
+//
+// Parameters:
+//   - name: The name of the session cookie.
+//   - i: The index of the chunk.
+//
+// Returns:
+//   - string: The name of the cookie.
+func chunkName(name string, i int) string {
+	if i == 0 {
+		return name
+	}
+	return name + "_" + strconv.Itoa(i)
+}
@@ -0,0 +1,218 @@
+package session
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// statelessApp returns an app with stateless sessions and a function that sends a request with
+// the cookies of the previous responses, like a browser, and returns the response body
+func statelessApp(t *testing.T, config Config) (*velocity.App, func(method, path string) string, map[string]string) {
+	t.Helper()
+
+	app := velocity.New()
+	app.Use(New(config))
+	app.Get("/get", func(c velocity.Ctx) error {
+		value, _ := FromContext(c).Get("key").(string) //nolint:errcheck // empty if not set
+		return c.SendString(value)
+	})
+	app.Post("/set", func(c velocity.Ctx) error {
+		FromContext(c).Set("key", c.Query("value"))
+		return c.SendStatus(velocity.StatusOK)
+	})
+	app.Get("/id", func(c velocity.Ctx) error {
+		return c.SendString(FromContext(c).ID())
+	})
+	app.Post("/destroy", func(c velocity.Ctx) error {
+		return FromContext(c).Destroy()
+	})
+
+	jar := make(map[string]string)
+	request := func(method, path string) string {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(method)
+		ctx.Request.SetRequestURI(path)
+		for name, value := range jar {
+			ctx.Request.Header.SetCookie(name, value)
+		}
+		app.Handler()(ctx)
+		require.Equal(t, velocity.StatusOK, ctx.Response.StatusCode())
+		ctx.Response.Header.VisitAllCookie(func(_, value []byte) {
+			cookie := fasthttp.AcquireCookie()
+			defer fasthttp.ReleaseCookie(cookie)
+			require.NoError(t, cookie.ParseBytes(value))
+			if len(cookie.Value()) == 0 {
+				delete(jar, string(cookie.Key()))
+			} else {
+				jar[string(cookie.Key())] = string(cookie.Value())
+			}
+		})
+		return string(ctx.Response.Body())
+	}
+	return app, request, jar
+}
+
+// statelessConfig returns the config of a stateless session with the keys
+func statelessConfig(t *testing.T, keys ...[]byte) Config {
+	t.Helper()
+	encryptor, err := NewAESGCM(keys...)
+	require.NoError(t, err)
+	return Config{Stateless: true, Encryptor: encryptor}
+}
+
+// go test -run Test_Session_Stateless
+func Test_Session_Stateless(t *testing.T) {
+	t.Parallel()
+
+	_, request, jar := statelessApp(t, statelessConfig(t, bytes.Repeat([]byte{1}, 32)))
+
+	require.Empty(t, request(velocity.MethodGet, "/get"))
+	request(velocity.MethodPost, "/set?value=john")
+	require.Equal(t, "john", request(velocity.MethodGet, "/get"))
+	require.Len(t, jar, 1)
+	require.NotContains(t, jar["session_id"], "john")
+
+	// The ID is kept in the cookie
+	id := request(velocity.MethodGet, "/id")
+	require.NotEmpty(t, id)
+	require.Equal(t, id, request(velocity.MethodGet, "/id"))
+
+	// Large data is split into several cookies, which are removed when the data shrinks
+	large := strings.Repeat("a", 10000)
+	request(velocity.MethodPost, "/set?value="+large)
+	require.Len(t, jar, 4)
+	require.Equal(t, large, request(velocity.MethodGet, "/get"))
+	request(velocity.MethodPost, "/set?value=john")
+	require.Len(t, jar, 1)
+	require.Equal(t, "john", request(velocity.MethodGet, "/get"))
+
+	// A changed cookie starts a new session
+	jar["session_id"] = jar["session_id"][:len(jar["session_id"])-2] + "AA"
+	require.Empty(t, request(velocity.MethodGet, "/get"))
+	require.NotEqual(t, id, request(velocity.MethodGet, "/id"))
+
+	request(velocity.MethodPost, "/set?value=john")
+	request(velocity.MethodPost, "/destroy")
+	require.Empty(t, jar)
+}
+
+// go test -run Test_Session_Stateless_KeyRotation
+func Test_Session_Stateless_KeyRotation(t *testing.T) {
+	t.Parallel()
+
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	_, request, jar := statelessApp(t, statelessConfig(t, oldKey))
+	request(velocity.MethodPost, "/set?value=john")
+
+	_, rotated, rotatedJar := statelessApp(t, statelessConfig(t, newKey, oldKey))
+	for name, value := range jar {
+		rotatedJar[name] = value
+	}
+	require.Equal(t, "john", rotated(velocity.MethodGet, "/get"))
+
+	// The session is encrypted with the new key when it is saved
+	_, removed, removedJar := statelessApp(t, statelessConfig(t, newKey))
+	for name, value := range rotatedJar {
+		removedJar[name] = value
+	}
+	require.Equal(t, "john", removed(velocity.MethodGet, "/get"))
+}
+
+// go test -run Test_Session_Stateless_IdleTimeout
+func Test_Session_Stateless_IdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	config := statelessConfig(t, bytes.Repeat([]byte{1}, 32))
+	config.IdleTimeout = 500 * time.Millisecond
+	_, request, _ := statelessApp(t, config)
+
+	request(velocity.MethodPost, "/set?value=john")
+	require.Equal(t, "john", request(velocity.MethodGet, "/get"))
+
+	// The expiration in the cookie is enforced, even if the client keeps the cookie
+	time.Sleep(config.IdleTimeout + 100*time.Millisecond)
+	require.Empty(t, request(velocity.MethodGet, "/get"))
+}
+
+// go test -run Test_Session_Stateless_AbsoluteTimeout
+func Test_Session_Stateless_AbsoluteTimeout(t *testing.T) {
+	t.Parallel()
+
+	config := statelessConfig(t, bytes.Repeat([]byte{1}, 32))
+	config.AbsoluteTimeout = time.Hour
+	app := velocity.New()
+	app.Use(New(config))
+	app.Post("/expire", func(c velocity.Ctx) error {
+		sess := FromContext(c)
+		sess.Set("key", "john")
+		sess.Session.setAbsExpiration(time.Now().Add(-time.Second))
+		return c.SendStatus(velocity.StatusOK)
+	})
+	app.Get("/get", func(c velocity.Ctx) error {
+		value, _ := FromContext(c).Get("key").(string) //nolint:errcheck // empty if not set
+		return c.SendString(value)
+	})
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(velocity.MethodPost)
+	ctx.Request.SetRequestURI("/expire")
+	app.Handler()(ctx)
+	cookie := string(ctx.Response.Header.PeekCookie("session_id"))
+	require.NotEmpty(t, cookie)
+
+	// The absolute expiration in the cookie is enforced
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/get")
+	ctx.Request.Header.Set(velocity.HeaderCookie, strings.Split(cookie, ";")[0])
+	app.Handler()(ctx)
+	require.Empty(t, string(ctx.Response.Body()))
+}
+
+// go test -run Test_Session_Stateless_TooLarge
+func Test_Session_Stateless_TooLarge(t *testing.T) {
+	t.Parallel()
+
+	store := NewStore(statelessConfig(t, bytes.Repeat([]byte{1}, 32)))
+	app := velocity.New()
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, err := store.Get(ctx)
+	require.NoError(t, err)
+	defer sess.Release()
+	sess.Set("key", strings.Repeat("a", maxCookieChunks*maxCookieSize))
+	require.ErrorIs(t, sess.Save(), ErrCookieTooLarge)
+}
+
+// go test -run Test_Session_Stateless_Store
+func Test_Session_Stateless_Store(t *testing.T) {
+	t.Parallel()
+
+	store := NewStore(statelessConfig(t, bytes.Repeat([]byte{1}, 32)))
+	require.Nil(t, store.Storage)
+
+	_, err := store.GetByID("id")
+	require.ErrorIs(t, err, ErrStatelessStore)
+	require.ErrorIs(t, store.Delete("id"), ErrStatelessStore)
+	require.ErrorIs(t, store.Reset(), ErrStatelessStore)
+	_, err = store.ListBySubject("john")
+	require.ErrorIs(t, err, ErrStatelessStore)
+	require.ErrorIs(t, store.DeleteBySubject("john"), ErrStatelessStore)
+
+	require.PanicsWithValue(t, "[session] Stateless requires an Encryptor", func() {
+		NewStore(Config{Stateless: true})
+	})
+	require.PanicsWithValue(t, "[session] Stateless requires a cookie in KeyLookup", func() {
+		config := statelessConfig(t, bytes.Repeat([]byte{1}, 32))
+		config.KeyLookup = "header:session_id"
+		NewStore(config)
+	})
+}
@@ -7,8 +7,8 @@ import (
 	"time"
 
 	"github.com/khulnasoft/velocity"
-	"github.com/khulnasoft/velocity/internal/storage/memory"
 	"github.com/khulnasoft/velocity/log"
+	"github.com/khulnasoft/velocity/storage/memory"
 	"github.com/khulnasoft/velocity/utils"
 )
 
@@ -17,6 +17,8 @@ var (
 	ErrEmptySessionID                   = errors.New("session ID cannot be empty")
 	ErrSessionAlreadyLoadedByMiddleware = errors.New("session already loaded by middleware")
 	ErrSessionIDNotFoundInStore         = errors.New("session ID not found in session store")
+	ErrEmptySubject                     = errors.New("session subject cannot be empty")
+	ErrStatelessStore                   = errors.New("session store keeps the sessions in cookies")
 )
 
 // sessionIDKey is the local key type used to store and retrieve the session ID in context.
@@ -28,6 +30,7 @@ const (
 )
 
 type Store struct {
+	index *subjectIndex
 	Config
 }
 
@@ -46,23 +49,30 @@ func NewStore(config ...Config) *Store {
 	// Set default config
 	cfg := configDefault(config...)
 
-	if cfg.Storage == nil {
-		cfg.Storage = memory.New()
-	}
-
 	store := &Store{
 		Config: cfg,
 	}
 
+	// Stateless sessions are kept in cookies and need no storage
+	if !cfg.Stateless {
+		if store.Storage == nil {
+			store.Storage = memory.New(memory.Config{MaxEntries: defaultMaxEntries})
+		}
+		store.index = newSubjectIndex(store.Storage, cfg.IdleTimeout)
+	}
+
+	// The internal keys are encoded by name, they are registered to decode older session data
 	if cfg.AbsoluteTimeout > 0 {
 		store.RegisterType(absExpirationKey)
-		store.RegisterType(time.Time{})
 	}
+	store.RegisterType(subjectKey)
+	store.RegisterType(time.Time{})
 
 	return store
 }
 
 // RegisterType registers a custom type for encoding/decoding into any storage provider.
+// It is only needed for GobCodec.
 //
 // Parameters:
 //   - i: The custom type to register.
@@ -119,6 +129,10 @@ func (s *Store) Get(c velocity.Ctx) (*Session, error) {
 //	    // handle error
 //	}
 func (s *Store) getSession(c velocity.Ctx) (*Session, error) {
+	if s.Stateless {
+		return s.getStatelessSession(c)
+	}
+
 	var rawData []byte
 	var err error
 
@@ -163,6 +177,15 @@ func (s *Store) getSession(c velocity.Ctx) (*Session, error) {
 		sess.data.Lock()
 		err := sess.decodeSessionData(rawData)
 		sess.data.Unlock()
+		if errors.Is(err, ErrDecryption) {
+			// Data that can't be decrypted is treated as missing, prepare a new session
+			err = nil
+			fresh = true
+			id = s.KeyGenerator()
+			c.Locals(sessionIDContextKey, id)
+			sess.id = id
+			sess.fresh = fresh
+		}
 		if err != nil {
 			sess.mu.Unlock()
 			sess.Release()
@@ -172,16 +195,32 @@ func (s *Store) getSession(c velocity.Ctx) (*Session, error) {
 
 	sess.mu.Unlock()
 
+	if err := s.checkAbsExpiration(sess, fresh); err != nil {
+		return nil, err
+	}
+
+	return sess, nil
+}
+
+// checkAbsExpiration sets the absolute expiration of a fresh session, and resets the session
+// if it is expired.
+//
+// Parameters:
+//   - sess: The session.
+//   - fresh: Whether the session is new.
+//
+// Returns:
+//   - error: An error if the session can't be reset.
+func (s *Store) checkAbsExpiration(sess *Session, fresh bool) error {
 	if fresh && s.AbsoluteTimeout > 0 {
 		sess.setAbsExpiration(time.Now().Add(s.AbsoluteTimeout))
 	} else if sess.isAbsExpired() {
 		if err := sess.Reset(); err != nil {
-			return nil, fmt.Errorf("failed to reset session: %w", err)
+			return fmt.Errorf("failed to reset session: %w", err)
 		}
 		sess.setAbsExpiration(time.Now().Add(s.AbsoluteTimeout))
 	}
-
-	return sess, nil
+	return nil
 }
 
 // getSessionID returns the session ID from cookies, headers, or query string.
@@ -230,6 +269,9 @@ func (s *Store) getSessionID(c velocity.Ctx) string {
 //	    // handle error
 //	}
 func (s *Store) Reset() error {
+	if s.Stateless {
+		return ErrStatelessStore
+	}
 	return s.Storage.Reset()
 }
 
@@ -251,6 +293,9 @@ func (s *Store) Delete(id string) error {
 	if id == "" {
 		return ErrEmptySessionID
 	}
+	if s.Stateless {
+		return ErrStatelessStore
+	}
 	return s.Storage.Delete(id)
 }
 
@@ -291,6 +336,9 @@ func (s *Store) GetByID(id string) (*Session, error) {
 	if id == "" {
 		return nil, ErrEmptySessionID
 	}
+	if s.Stateless {
+		return nil, ErrStatelessStore
+	}
 
 	rawData, err := s.Storage.Get(id)
 	if err != nil {
@@ -314,6 +362,9 @@ func (s *Store) GetByID(id string) (*Session, error) {
 	sess.mu.Unlock()
 	if decodeErr != nil {
 		sess.Release()
+		if errors.Is(decodeErr, ErrDecryption) {
+			return nil, ErrSessionIDNotFoundInStore
+		}
 		return nil, fmt.Errorf("failed to decode session data: %w", decodeErr)
 	}
 
@@ -329,3 +380,90 @@ func (s *Store) GetByID(id string) (*Session, error) {
 
 	return sess, nil
 }
+
+// ListBySubject returns the IDs of the sessions that were marked with the subject by
+// Session.SetSubject, e.g. to show a user the devices they are logged in on.
+//
+// Parameters:
+//   - subject: The subject of the sessions, e.g. a user ID.
+//
+// Returns:
+//   - []string: The IDs of the sessions.
+//   - error: An error if the index can't be read or if the subject is empty.
+//
+// Usage:
+//
+//	ids, err := store.ListBySubject(userID)
+//	if err != nil {
+//	    // handle error
+//	}
+func (s *Store) ListBySubject(subject string) ([]string, error) {
+	if subject == "" {
+		return nil, ErrEmptySubject
+	}
+	if s.Stateless {
+		return nil, ErrStatelessStore
+	}
+
+	ids, err := s.index.ids(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove the sessions that were deleted by Store.Delete without the index
+	existing := ids[:0]
+	var deleted []string
+	for _, id := range ids {
+		rawData, err := s.Storage.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if rawData == nil {
+			deleted = append(deleted, id)
+		} else {
+			existing = append(existing, id)
+		}
+	}
+	if len(deleted) > 0 {
+		if err := s.index.remove(subject, deleted...); err != nil {
+			return nil, err
+		}
+	}
+	return existing, nil
+}
+
+// DeleteBySubject deletes all sessions that were marked with the subject by Session.SetSubject,
+// e.g. to log a user out everywhere or after a password change. A session of the subject that
+// is in use by a request while it is deleted is not stored again.
+//
+// Parameters:
+//   - subject: The subject of the sessions, e.g. a user ID.
+//
+// Returns:
+//   - error: An error if the deletion fails or if the subject is empty.
+//
+// Usage:
+//
+//	err := store.DeleteBySubject(userID)
+//	if err != nil {
+//	    // handle error
+//	}
+func (s *Store) DeleteBySubject(subject string) error {
+	if subject == "" {
+		return ErrEmptySubject
+	}
+	if s.Stateless {
+		return ErrStatelessStore
+	}
+
+	ids, err := s.index.revoke(subject)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.Storage.Delete(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
+	"github.com/khulnasoft/velocity/storage/storagetest"
 	"github.com/stretchr/testify/require"
 	"github.com/valyala/fasthttp"
 )
@@ -223,3 +225,123 @@ func Test_Store_GetByID(t *testing.T) {
 		})
 	})
 }
+
+// subjectStorages are the storages the subject index is tested with
+var subjectStorages = map[string]func() velocity.Storage{
+	"atomic": func() velocity.Storage { return memory.New() },
+	"plain":  func() velocity.Storage { return storagetest.NonAtomic(memory.New()) },
+}
+
+// newSubjectSession returns a saved session of the store that is marked with the subject
+func newSubjectSession(t *testing.T, store *Store, subject string) *Session {
+	t.Helper()
+	sess, err := store.getSession(velocity.New().AcquireCtx(&fasthttp.RequestCtx{}))
+	require.NoError(t, err)
+	sess.SetSubject(subject)
+	require.NoError(t, sess.Save())
+	return sess
+}
+
+// go test -run Test_Store_ListBySubject
+func Test_Store_ListBySubject(t *testing.T) {
+	t.Parallel()
+
+	for name, storage := range subjectStorages {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			store := NewStore(Config{Storage: storage()})
+
+			first := newSubjectSession(t, store, "john")
+			second := newSubjectSession(t, store, "john")
+			other := newSubjectSession(t, store, "jane")
+
+			ids, err := store.ListBySubject("john")
+			require.NoError(t, err)
+			require.ElementsMatch(t, []string{first.ID(), second.ID()}, ids)
+
+			// A destroyed session is removed from the index
+			require.NoError(t, second.Destroy())
+			ids, err = store.ListBySubject("john")
+			require.NoError(t, err)
+			require.Equal(t, []string{first.ID()}, ids)
+
+			// A session that is deleted by its ID isn't listed
+			require.NoError(t, store.Delete(first.ID()))
+			ids, err = store.ListBySubject("john")
+			require.NoError(t, err)
+			require.Empty(t, ids)
+
+			// A session is moved to the index of its new subject when it is saved
+			other.SetSubject("john")
+			require.Equal(t, "john", other.Subject())
+			require.NoError(t, other.Save())
+			ids, err = store.ListBySubject("jane")
+			require.NoError(t, err)
+			require.Empty(t, ids)
+			ids, err = store.ListBySubject("john")
+			require.NoError(t, err)
+			require.Equal(t, []string{other.ID()}, ids)
+
+			// A regenerated session is listed with its new ID
+			oldID := other.ID()
+			require.NoError(t, other.Regenerate())
+			require.NoError(t, other.Save())
+			ids, err = store.ListBySubject("john")
+			require.NoError(t, err)
+			require.Equal(t, []string{other.ID()}, ids)
+			require.NotEqual(t, oldID, other.ID())
+
+			// Removing the subject removes the session from the index
+			other.SetSubject("")
+			require.NoError(t, other.Save())
+			ids, err = store.ListBySubject("john")
+			require.NoError(t, err)
+			require.Empty(t, ids)
+
+			_, err = store.ListBySubject("")
+			require.ErrorIs(t, err, ErrEmptySubject)
+		})
+	}
+}
+
+// go test -run Test_Store_DeleteBySubject
+func Test_Store_DeleteBySubject(t *testing.T) {
+	t.Parallel()
+
+	for name, storage := range subjectStorages {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			store := NewStore(Config{Storage: storage()})
+
+			first := newSubjectSession(t, store, "john")
+			second := newSubjectSession(t, store, "john")
+			other := newSubjectSession(t, store, "jane")
+
+			require.NoError(t, store.DeleteBySubject("john"))
+			for _, sess := range []*Session{first, second} {
+				_, err := store.GetByID(sess.ID())
+				require.ErrorIs(t, err, ErrSessionIDNotFoundInStore)
+			}
+			ids, err := store.ListBySubject("john")
+			require.NoError(t, err)
+			require.Empty(t, ids)
+
+			// The sessions of other subjects are kept
+			_, err = store.GetByID(other.ID())
+			require.NoError(t, err)
+
+			// A revoked session that was still in use is not stored again
+			require.NoError(t, first.Save())
+			_, err = store.GetByID(first.ID())
+			require.ErrorIs(t, err, ErrSessionIDNotFoundInStore)
+
+			// A session that is marked with the subject after the revocation is stored
+			third := newSubjectSession(t, store, "john")
+			ids, err = store.ListBySubject("john")
+			require.NoError(t, err)
+			require.Equal(t, []string{third.ID()}, ids)
+
+			require.ErrorIs(t, store.DeleteBySubject(""), ErrEmptySubject)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"github.com/khulnasoft/velocity"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c velocity.Ctx) bool
+
+	// Exporter receives the sampled spans when the requests are completed.
+	// The spans are only propagated if it is nil.
+	//
+	// Optional. Default: nil
+	Exporter Exporter
+
+	// Sampler decides whether a trace that is started by this service is sampled.
+	// Requests with a traceparent header keep the sampling decision of the caller.
+	//
+	// Optional. Default: sample every request
+	Sampler func(c velocity.Ctx) bool
+
+	// SpanName returns the name of the span, it is called after the request is handled.
+	//
+	// Optional. Default: the method and the matched route pattern, e.g. "GET /users/:id"
+	SpanName func(c velocity.Ctx) string
+
+	// DisableIncoming ignores the traceparent and tracestate headers of the requests,
+	// so every request starts a new trace. Use it if the callers are not trusted.
+	//
+	// Optional. Default: false
+	DisableIncoming bool
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:     nil,
+	Exporter: nil,
+	Sampler: func(_ velocity.Ctx) bool {
+		return true
+	},
+	SpanName: func(c velocity.Ctx) string {
+		return c.Method() + " " + c.Route().Path
+	},
+}
+
+// Helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.Sampler == nil {
+		cfg.Sampler = ConfigDefault.Sampler
+	}
+	if cfg.SpanName == nil {
+		cfg.SpanName = ConfigDefault.SpanName
+	}
+	return cfg
+}
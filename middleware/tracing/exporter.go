@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"sync"
+)
+
+// Exporter sends the completed spans to a tracing backend.
+// ExportSpan is called by the request goroutine, implementations should not block
+// and must not modify the span.
+type Exporter interface {
+	ExportSpan(span *Span) error
+}
+
+// InMemoryExporter keeps the exported spans in memory, it is meant for tests
+type InMemoryExporter struct {
+	spans []*Span
+	mu    sync.Mutex
+}
+
+// NewInMemoryExporter creates a new in-memory exporter
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// ExportSpan stores the span
+func (e *InMemoryExporter) ExportSpan(span *Span) error {
+	e.mu.Lock()
+	e.spans = append(e.spans, span)
+	e.mu.Unlock()
+	return nil
+}
+
+// Spans returns the exported spans in the order they were completed
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+// Reset removes the exported spans
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"time"
+
+	"github.com/khulnasoft/velocity/internal/tracecontext"
+)
+
+type (
+	// TraceID identifies a trace
+	TraceID = tracecontext.TraceID
+	// SpanID identifies a span within a trace
+	SpanID = tracecontext.SpanID
+	// SpanContext is the part of a span that is propagated with the traceparent and tracestate headers
+	SpanContext = tracecontext.SpanContext
+)
+
+// FlagSampled is the trace flag that marks a trace as sampled
+const FlagSampled = tracecontext.FlagSampled
+
+// Attribute names of the server spans, see the OpenTelemetry semantic conventions for HTTP
+const (
+	AttributeMethod     = "http.request.method"
+	AttributeRoute      = "http.route"
+	AttributeStatusCode = "http.response.status_code"
+	AttributePath       = "url.path"
+	AttributeScheme     = "url.scheme"
+	AttributeHost       = "server.address"
+	AttributeUserAgent  = "user_agent.original"
+)
+
+// Span is the server span of a request
+type Span struct {
+	StartTime  time.Time
+	EndTime    time.Time
+	Err        error
+	Attributes map[string]any
+	Name       string
+	// Parent is the span context of the caller, it is invalid if the request started the trace
+	Parent      SpanContext
+	spanContext SpanContext
+}
+
+// SpanContext returns the span context that is propagated to downstream calls
+func (s *Span) SpanContext() SpanContext {
+	return s.spanContext
+}
+
+// SetAttribute sets an attribute of the span, e.g. in a handler
+func (s *Span) SetAttribute(key string, value any) {
+	s.Attributes[key] = value
+}
+
+// Duration returns the time it took to handle the request
+func (s *Span) Duration() time.Duration {
+	return s.EndTime.Sub(s.StartTime)
+}
+
+// ParseTraceparent parses the values of the traceparent and tracestate headers.
+// The tracestate is dropped if it is malformed.
+func ParseTraceparent(traceparent, tracestate string) (SpanContext, bool) {
+	return tracecontext.Parse(traceparent, tracestate)
+}
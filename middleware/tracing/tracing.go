@@ -0,0 +1,136 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/tracecontext"
+	"github.com/khulnasoft/velocity/log"
+	"github.com/khulnasoft/velocity/utils"
+)
+
+// The contextKey type is unexported to prevent collisions with context keys defined in
+// other packages.
+type contextKey int
+
+// The keys for the values in context
+const (
+	spanKey contextKey = iota
+)
+
+// New creates a new middleware handler
+func New(config ...Config) velocity.Handler {
+	// Set default config
+	cfg := configDefault(config...)
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		span := &Span{
+			StartTime: time.Now(),
+			Attributes: map[string]any{
+				AttributeMethod:    utils.CopyString(c.Method()),
+				AttributePath:      utils.CopyString(c.Path()),
+				AttributeScheme:    utils.CopyString(c.Scheme()),
+				AttributeHost:      utils.CopyString(c.Hostname()),
+				AttributeUserAgent: utils.CopyString(c.Get(velocity.HeaderUserAgent)),
+			},
+		}
+
+		// Continue the trace of the caller, or start a new one
+		var parent SpanContext
+		var ok bool
+		if !cfg.DisableIncoming {
+			parent, ok = tracecontext.Parse(c.Get(tracecontext.HeaderTraceparent), c.Get(tracecontext.HeaderTracestate))
+		}
+		if ok {
+			span.Parent = parent
+			span.spanContext = SpanContext{
+				TraceID:    parent.TraceID,
+				TraceFlags: parent.TraceFlags,
+				TraceState: utils.CopyString(parent.TraceState),
+			}
+		} else {
+			span.spanContext = SpanContext{TraceID: tracecontext.NewTraceID()}
+			if cfg.Sampler(c) {
+				span.spanContext.TraceFlags = FlagSampled
+			}
+		}
+		span.spanContext.SpanID = tracecontext.NewSpanID()
+
+		// Add the span to locals and to the context, the client continues the trace of the context
+		c.Locals(spanKey, span)
+		c.SetContext(tracecontext.ContextWith(c.Context(), span))
+
+		err := c.Next()
+
+		span.EndTime = time.Now()
+		span.Err = err
+
+		// The error handler sets the status after the middleware returns
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = velocity.StatusInternalServerError
+			var e *velocity.Error
+			if errors.As(err, &e) {
+				status = e.Code
+			}
+		}
+		span.Name = utils.CopyString(cfg.SpanName(c))
+		span.Attributes[AttributeRoute] = utils.CopyString(c.Route().Path)
+		span.Attributes[AttributeStatusCode] = status
+
+		if cfg.Exporter != nil && span.spanContext.IsSampled() {
+			if exportErr := cfg.Exporter.ExportSpan(span); exportErr != nil {
+				log.Errorf("tracing: failed to export span: %v", exportErr)
+			}
+		}
+
+		return err
+	}
+}
+
+// FromContext returns the span of the request from context.
+// If there is no span, nil is returned.
+// Supported context types:
+// - velocity.Ctx: Retrieves the span from Locals
+// - context.Context: Retrieves the span from context values
+func FromContext(c any) *Span {
+	switch ctx := c.(type) {
+	case velocity.Ctx:
+		if span, ok := ctx.Locals(spanKey).(*Span); ok {
+			return span
+		}
+	case context.Context:
+		if span, ok := tracecontext.CarrierFromContext(ctx).(*Span); ok {
+			return span
+		}
+	default:
+		log.Errorf("Unsupported context type: %T. Expected velocity.Ctx or context.Context", c)
+	}
+	return nil
+}
+
+// spanContextCarrier carries a span context without a span
+type spanContextCarrier SpanContext
+
+func (s spanContextCarrier) SpanContext() SpanContext {
+	return SpanContext(s)
+}
+
+// ContextWithSpanContext returns a copy of the context that carries the span context,
+// e.g. to continue a trace in a background job with the client package.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return tracecontext.ContextWith(ctx, spanContextCarrier(sc))
+}
+
+// SpanContextFromContext returns the span context of the span in the context, or of ContextWithSpanContext
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	return tracecontext.FromContext(ctx)
+}
@@ -0,0 +1,307 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/client"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentID    = "00f067aa0ba902b7"
+)
+
+func request(t *testing.T, app *velocity.App, target string, headers map[string]string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(velocity.MethodGet, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp.StatusCode
+}
+
+// go test -run Test_Tracing
+func Test_Tracing(t *testing.T) {
+	t.Parallel()
+
+	exporter := NewInMemoryExporter()
+	app := velocity.New()
+	app.Use(New(Config{Exporter: exporter}))
+	app.Get("/users/:id", func(c velocity.Ctx) error {
+		FromContext(c).SetAttribute("user.id", c.Params("id"))
+		return c.SendString("user")
+	})
+
+	require.Equal(t, velocity.StatusOK, request(t, app, "/users/1", nil))
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	require.Equal(t, "GET /users/:id", span.Name)
+	require.True(t, span.SpanContext().IsValid())
+	require.True(t, span.SpanContext().IsSampled())
+	require.False(t, span.SpanContext().Remote)
+	require.False(t, span.Parent.IsValid())
+	require.NoError(t, span.Err)
+	require.False(t, span.EndTime.Before(span.StartTime))
+	require.Equal(t, map[string]any{
+		AttributeMethod:     velocity.MethodGet,
+		AttributePath:       "/users/1",
+		AttributeRoute:      "/users/:id",
+		AttributeScheme:     "http",
+		AttributeHost:       "example.com",
+		AttributeUserAgent:  "",
+		AttributeStatusCode: velocity.StatusOK,
+		"user.id":           "1",
+	}, span.Attributes)
+
+	// every request starts a new trace
+	require.Equal(t, velocity.StatusOK, request(t, app, "/users/2", nil))
+	spans = exporter.Spans()
+	require.Len(t, spans, 2)
+	require.NotEqual(t, spans[0].SpanContext().TraceID, spans[1].SpanContext().TraceID)
+
+	exporter.Reset()
+	require.Empty(t, exporter.Spans())
+}
+
+// go test -run Test_Tracing_Traceparent
+func Test_Tracing_Traceparent(t *testing.T) {
+	t.Parallel()
+
+	exporter := NewInMemoryExporter()
+	app := velocity.New()
+	app.Use(New(Config{Exporter: exporter}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusNoContent)
+	})
+
+	require.Equal(t, velocity.StatusNoContent, request(t, app, "/", map[string]string{
+		"traceparent": traceparent,
+		"tracestate":  "rojo=00f067aa0ba902b7, ,congo=t61rcWkgMzE",
+	}))
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 1)
+	sc := spans[0].SpanContext()
+	require.Equal(t, traceID, sc.TraceID.String())
+	require.NotEqual(t, parentID, sc.SpanID.String())
+	require.Equal(t, "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE", sc.TraceState)
+	require.Equal(t, parentID, spans[0].Parent.SpanID.String())
+	require.True(t, spans[0].Parent.Remote)
+}
+
+// go test -run Test_Tracing_Sampling
+func Test_Tracing_Sampling(t *testing.T) {
+	t.Parallel()
+
+	exporter := NewInMemoryExporter()
+	app := velocity.New()
+	app.Use(New(Config{
+		Exporter: exporter,
+		Sampler: func(c velocity.Ctx) bool {
+			return c.Path() != "/health"
+		},
+	}))
+	app.Get("/*", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+
+	// the sampler decides for new traces
+	request(t, app, "/health", nil)
+	require.Empty(t, exporter.Spans())
+
+	// the decision of the caller is kept
+	request(t, app, "/", map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"})
+	require.Empty(t, exporter.Spans())
+	request(t, app, "/health", map[string]string{"traceparent": traceparent})
+	require.Len(t, exporter.Spans(), 1)
+}
+
+// go test -run Test_Tracing_Invalid_Traceparent
+func Test_Tracing_Invalid_Traceparent(t *testing.T) {
+	t.Parallel()
+
+	exporter := NewInMemoryExporter()
+	app := velocity.New()
+	app.Use(New(Config{Exporter: exporter}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+
+	request(t, app, "/", map[string]string{"traceparent": "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", "tracestate": "rojo=1"})
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 1)
+	require.NotEqual(t, traceID, spans[0].SpanContext().TraceID.String())
+	require.False(t, spans[0].Parent.IsValid())
+	require.Empty(t, spans[0].SpanContext().TraceState)
+}
+
+// go test -run Test_Tracing_DisableIncoming
+func Test_Tracing_DisableIncoming(t *testing.T) {
+	t.Parallel()
+
+	exporter := NewInMemoryExporter()
+	app := velocity.New()
+	app.Use(New(Config{Exporter: exporter, DisableIncoming: true}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+
+	request(t, app, "/", map[string]string{"traceparent": traceparent})
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 1)
+	require.NotEqual(t, traceID, spans[0].SpanContext().TraceID.String())
+	require.False(t, spans[0].Parent.IsValid())
+}
+
+// go test -run Test_Tracing_Error
+func Test_Tracing_Error(t *testing.T) {
+	t.Parallel()
+
+	exporter := NewInMemoryExporter()
+	app := velocity.New()
+	app.Use(New(Config{
+		Exporter: exporter,
+		SpanName: func(c velocity.Ctx) string {
+			return c.Route().Path
+		},
+	}))
+	app.Get("/missing", func(_ velocity.Ctx) error {
+		return velocity.ErrNotFound
+	})
+	app.Get("/broken", func(_ velocity.Ctx) error {
+		return errors.New("broken")
+	})
+
+	require.Equal(t, velocity.StatusNotFound, request(t, app, "/missing", nil))
+	require.Equal(t, velocity.StatusInternalServerError, request(t, app, "/broken", nil))
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 2)
+	require.Equal(t, "/missing", spans[0].Name)
+	require.ErrorIs(t, spans[0].Err, velocity.ErrNotFound)
+	require.Equal(t, velocity.StatusNotFound, spans[0].Attributes[AttributeStatusCode])
+	require.Equal(t, "/broken", spans[1].Name)
+	require.EqualError(t, spans[1].Err, "broken")
+	require.Equal(t, velocity.StatusInternalServerError, spans[1].Attributes[AttributeStatusCode])
+}
+
+// go test -run Test_Tracing_Next
+func Test_Tracing_Next(t *testing.T) {
+	t.Parallel()
+
+	exporter := NewInMemoryExporter()
+	app := velocity.New()
+	app.Use(New(Config{
+		Exporter: exporter,
+		Next: func(_ velocity.Ctx) bool {
+			return true
+		},
+	}))
+	app.Get("/", func(c velocity.Ctx) error {
+		require.Nil(t, FromContext(c))
+		require.Nil(t, FromContext(c.Context()))
+		return c.SendStatus(velocity.StatusOK)
+	})
+
+	require.Equal(t, velocity.StatusOK, request(t, app, "/", nil))
+	require.Empty(t, exporter.Spans())
+}
+
+// go test -run Test_Tracing_Client
+func Test_Tracing_Client(t *testing.T) {
+	t.Parallel()
+
+	headers := make(chan http.Header, 1)
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer downstream.Close()
+
+	exporter := NewInMemoryExporter()
+	app := velocity.New()
+	app.Use(New(Config{Exporter: exporter}))
+	app.Get("/", func(c velocity.Ctx) error {
+		require.Same(t, FromContext(c), FromContext(c.Context()))
+
+		resp, err := client.New().R().SetContext(c.Context()).Get(downstream.URL)
+		if err != nil {
+			return err
+		}
+		defer resp.Close()
+		return c.SendStatus(resp.StatusCode())
+	})
+
+	require.Equal(t, velocity.StatusNoContent, request(t, app, "/", map[string]string{
+		"traceparent": traceparent,
+		"tracestate":  "rojo=00f067aa0ba902b7",
+	}))
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 1)
+
+	// the downstream call is a child of the server span
+	h := <-headers
+	require.Equal(t, "00-"+traceID+"-"+spans[0].SpanContext().SpanID.String()+"-01", h.Get("traceparent"))
+	require.Equal(t, "rojo=00f067aa0ba902b7", h.Get("tracestate"))
+}
+
+// go test -run Test_ContextWithSpanContext
+func Test_ContextWithSpanContext(t *testing.T) {
+	t.Parallel()
+
+	sc, ok := ParseTraceparent(traceparent, "")
+	require.True(t, ok)
+
+	ctx := ContextWithSpanContext(context.Background(), sc)
+	got, ok := SpanContextFromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, sc, got)
+	require.Nil(t, FromContext(ctx))
+
+	_, ok = SpanContextFromContext(context.Background())
+	require.False(t, ok)
+}
+
+type discardExporter struct{}
+
+func (discardExporter) ExportSpan(_ *Span) error {
+	return nil
+}
+
+// go test -v -run=^$ -bench=Benchmark_Tracing -benchmem -count=4
+func Benchmark_Tracing(b *testing.B) {
+	app := velocity.New()
+	app.Use(New(Config{Exporter: discardExporter{}}))
+	app.Get("/users/:id", func(c velocity.Ctx) error {
+		return c.SendString("user")
+	})
+	h := app.Handler()
+
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.Header.SetMethod(velocity.MethodGet)
+	fctx.Request.Header.Set("traceparent", traceparent)
+	fctx.Request.SetRequestURI("/users/1")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		h(fctx)
+	}
+}
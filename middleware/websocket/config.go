@@ -0,0 +1,124 @@
+package websocket
+
+import (
+	"compress/flate"
+	"fmt"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c velocity.Ctx) bool
+
+	// AllowOriginsFunc defines a function that allows the upgrade of a request with the
+	// Origin header when returned true. It is checked in addition to AllowOrigins.
+	//
+	// Optional. Default: nil
+	AllowOriginsFunc func(origin string) bool
+
+	// AllowOrigins defines a list of origins that may open a connection, e.g. "https://example.com".
+	// Requests without an Origin header, which are not sent by browsers, and requests whose Origin
+	// host equals the Host header are always allowed, other origins are rejected with 403 Forbidden
+	// to prevent cross-site WebSocket hijacking.
+	//
+	// If the special wildcard `"*"` is present in the list, all origins will be allowed.
+	//
+	// Optional. Default: nil
+	AllowOrigins []string
+
+	// Subprotocols are the supported application protocols in the order of preference.
+	// The first protocol that is also requested by the client is selected.
+	//
+	// Optional. Default: nil
+	Subprotocols []string
+
+	// ReadLimit is the maximum size of a message in bytes, after decompression.
+	// The connection is closed with CloseMessageTooBig if a message exceeds it.
+	//
+	// Optional. Default: 1 MiB
+	ReadLimit int64
+
+	// PingInterval is the interval in which pings are sent to the client.
+	// A negative value disables the pings.
+	//
+	// Optional. Default: 30 * time.Second
+	PingInterval time.Duration
+
+	// PongTimeout is the time to wait for any data from the client after a ping was sent,
+	// the connection is closed if it exceeds.
+	//
+	// Optional. Default: 10 * time.Second
+	PongTimeout time.Duration
+
+	// WriteTimeout is the maximum duration of a write to the client.
+	//
+	// Optional. Default: 10 * time.Second
+	WriteTimeout time.Duration
+
+	// EnableCompression negotiates the permessage-deflate extension (RFC 7692) with clients that support it.
+	//
+	// Optional. Default: false
+	EnableCompression bool
+
+	// CompressionLevel is the flate compression level of the messages that are sent, from
+	// flate.HuffmanOnly to flate.BestCompression. Zero selects the default level.
+	//
+	// Optional. Default: flate.BestSpeed
+	CompressionLevel int
+
+	// DisableWriteCompression sends the messages uncompressed even if the extension is negotiated,
+	// the compressed messages of the clients are still accepted.
+	//
+	// Optional. Default: false
+	DisableWriteCompression bool
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:             nil,
+	AllowOriginsFunc: nil,
+	AllowOrigins:     nil,
+	ReadLimit:        1 << 20,
+	PingInterval:     30 * time.Second,
+	PongTimeout:      10 * time.Second,
+	WriteTimeout:     10 * time.Second,
+	CompressionLevel: flate.BestSpeed,
+}
+
+// Helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.ReadLimit <= 0 {
+		cfg.ReadLimit = ConfigDefault.ReadLimit
+	}
+	if cfg.PingInterval == 0 {
+		cfg.PingInterval = ConfigDefault.PingInterval
+	}
+	if cfg.PongTimeout <= 0 {
+		cfg.PongTimeout = ConfigDefault.PongTimeout
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = ConfigDefault.WriteTimeout
+	}
+	if cfg.CompressionLevel == 0 {
+		cfg.CompressionLevel = ConfigDefault.CompressionLevel
+	}
+	if cfg.CompressionLevel < flate.HuffmanOnly || cfg.CompressionLevel > flate.BestCompression {
+		panic(fmt.Sprintf("velocity: websocket middleware does not support the compression level %d", cfg.CompressionLevel))
+	}
+
+	return cfg
+}
@@ -0,0 +1,585 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/utils"
+)
+
+// The message types of RFC 6455
+const (
+	// TextMessage is a UTF-8 encoded text message.
+	TextMessage = 1
+	// BinaryMessage is a binary data message.
+	BinaryMessage = 2
+	// CloseMessage is a close control message, it is sent with Close or CloseWithStatus.
+	CloseMessage = 8
+	// PingMessage is a ping control message, the client answers it with a pong.
+	PingMessage = 9
+	// PongMessage is a pong control message.
+	PongMessage = 10
+)
+
+// The close codes of RFC 6455
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	continuationFrame = 0
+	maxControlPayload = 125
+	finalBit          = 0x80
+	rsv1Bit           = 0x40
+	rsv2Bit           = 0x20
+	rsv3Bit           = 0x10
+	opcodeMask        = 0x0f
+	maskBit           = 0x80
+	lengthMask        = 0x7f
+)
+
+var (
+	// ErrReadLimit is returned when a message is larger than the ReadLimit of the config.
+	ErrReadLimit = errors.New("websocket: read limit exceeded")
+	// ErrProtocol is returned when the client violates the WebSocket protocol.
+	ErrProtocol = errors.New("websocket: protocol error")
+	// ErrCloseSent is returned when a message is written after the connection was closed.
+	ErrCloseSent = errors.New("websocket: close sent")
+	// ErrInvalidMessageType is returned when a message with an unknown type is written.
+	ErrInvalidMessageType = errors.New("websocket: invalid message type")
+)
+
+// The tail of a compressed message that is removed by the sender, followed by an empty final block
+// that lets the flate reader return io.EOF at the end of the message
+const deflateTail = "\x00\x00\xff\xff\x01\x00\x00\xff\xff"
+
+// CloseError is returned by ReadMessage when the client closed the connection.
+type CloseError struct {
+	// Text is the reason that was sent by the client.
+	Text string
+	// Code is the close code that was sent by the client, CloseNoStatusReceived if there was none.
+	Code int
+}
+
+// Error implements the error interface.
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// Conn is a WebSocket connection. The values of the request are captured before the upgrade,
+// because the Ctx is released when the connection is handed over.
+//
+// ReadMessage must be called by one goroutine at a time, it also answers pings and receives pongs.
+// WriteMessage, Close and CloseWithStatus can be called concurrently.
+type Conn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	bw          *bufio.Writer
+	fw          *flate.Writer
+	readErr     error
+	params      map[string]string
+	queries     map[string]string
+	cookies     map[string]string
+	locals      map[any]any
+	done        chan struct{}
+	subprotocol string
+	cfg         Config
+	writeMu     sync.Mutex
+	localsMu    sync.RWMutex
+	compress    bool
+	closeSent   bool
+}
+
+// acquireConn creates the connection and captures the values of the request
+func acquireConn(c velocity.Ctx, cfg Config) *Conn {
+	conn := &Conn{
+		params:  make(map[string]string),
+		queries: make(map[string]string),
+		cookies: make(map[string]string),
+		locals:  make(map[any]any),
+		cfg:     cfg,
+	}
+
+	for _, name := range c.Route().Params {
+		conn.params[name] = utils.CopyString(c.Params(name))
+	}
+	for key, value := range c.Queries() {
+		conn.queries[utils.CopyString(key)] = utils.CopyString(value)
+	}
+	c.Request().Header.VisitAllCookie(func(key, value []byte) {
+		conn.cookies[string(key)] = string(value)
+	})
+	c.RequestCtx().VisitUserValuesAll(func(key, value any) {
+		conn.locals[key] = value
+	})
+
+	return conn
+}
+
+// Params returns the value of the route parameter that was captured before the upgrade.
+func (c *Conn) Params(key string, defaultValue ...string) string {
+	return valueOrDefault(c.params, key, defaultValue)
+}
+
+// Query returns the value of the query parameter that was captured before the upgrade.
+func (c *Conn) Query(key string, defaultValue ...string) string {
+	return valueOrDefault(c.queries, key, defaultValue)
+}
+
+// Cookies returns the value of the request cookie that was captured before the upgrade.
+func (c *Conn) Cookies(key string, defaultValue ...string) string {
+	return valueOrDefault(c.cookies, key, defaultValue)
+}
+
+// Locals returns the local value that was set before the upgrade, e.g. by a middleware.
+// If a value is passed, it is stored under the key for the lifetime of the connection.
+func (c *Conn) Locals(key any, value ...any) any {
+	if len(value) == 0 {
+		c.localsMu.RLock()
+		defer c.localsMu.RUnlock()
+		return c.locals[key]
+	}
+
+	c.localsMu.Lock()
+	c.locals[key] = value[0]
+	c.localsMu.Unlock()
+	return value[0]
+}
+
+// Subprotocol returns the negotiated subprotocol or an empty string.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// NetConn returns the underlying network connection.
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+// serve runs the handler on the hijacked connection
+func (c *Conn) serve(nc net.Conn, handler func(*Conn)) {
+	c.conn = nc
+	c.br = bufio.NewReader(nc)
+	c.bw = bufio.NewWriter(nc)
+	c.done = make(chan struct{})
+
+	if c.cfg.PingInterval > 0 {
+		go c.keepalive()
+	}
+	defer func() {
+		close(c.done)
+		// Ends the connection gracefully if the handler did not close it
+		_ = c.CloseWithStatus(CloseNormalClosure, "") //nolint:errcheck // The connection is closed anyway
+	}()
+
+	handler(c)
+}
+
+// keepalive sends pings until the connection is done
+func (c *Conn) keepalive() {
+	ticker := time.NewTicker(c.cfg.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.WriteMessage(PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ReadMessage reads the next text or binary message. Pings are answered while reading,
+// a CloseError is returned when the client closes the connection.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+
+	messageType, data, err := c.readMessage()
+	if err != nil {
+		c.readErr = err
+	}
+
+	return messageType, data, err
+}
+
+func (c *Conn) readMessage() (int, []byte, error) {
+	var (
+		messageType int
+		compressed  bool
+		data        []byte
+	)
+
+	for {
+		if c.cfg.PingInterval > 0 {
+			// The client has to answer the next ping in time
+			if err := c.conn.SetReadDeadline(time.Now().Add(c.cfg.PingInterval + c.cfg.PongTimeout)); err != nil {
+				return 0, nil, err
+			}
+		}
+
+		h, err := readFrameHeader(c.br)
+		if err != nil {
+			return 0, nil, err
+		}
+		if !h.masked {
+			return 0, nil, c.fail(CloseProtocolError, "client frames must be masked")
+		}
+		if h.rsv2 || h.rsv3 || (h.rsv1 && (!c.compress || h.opcode != TextMessage && h.opcode != BinaryMessage)) {
+			return 0, nil, c.fail(CloseProtocolError, "unexpected reserved bits")
+		}
+
+		switch h.opcode {
+		case CloseMessage, PingMessage, PongMessage:
+			if !h.fin || h.length > maxControlPayload {
+				return 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+			}
+			payload, err := h.readPayload(c.br)
+			if err != nil {
+				return 0, nil, err
+			}
+			if err := c.control(h.opcode, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			messageType, compressed = int(h.opcode), h.rsv1
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		if int64(len(data))+h.length > c.cfg.ReadLimit {
+			_ = c.CloseWithStatus(CloseMessageTooBig, "") //nolint:errcheck // The read limit error is more important
+			return 0, nil, ErrReadLimit
+		}
+		payload, err := h.readPayload(c.br)
+		if err != nil {
+			return 0, nil, err
+		}
+		data = append(data, payload...)
+
+		if h.fin {
+			break
+		}
+	}
+
+	if compressed {
+		var err error
+		if data, err = c.inflate(data); err != nil {
+			return 0, nil, err
+		}
+	}
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, c.fail(CloseInvalidFramePayloadData, "invalid UTF-8 in text message")
+	}
+
+	return messageType, data, nil
+}
+
+// control handles a control frame from the client
+func (c *Conn) control(opcode byte, payload []byte) error {
+	switch opcode {
+	case PingMessage:
+		if err := c.WriteMessage(PongMessage, payload); err != nil && !errors.Is(err, ErrCloseSent) {
+			return err
+		}
+	case CloseMessage:
+		closeErr := &CloseError{Code: CloseNoStatusReceived}
+		if len(payload) == 1 {
+			return c.fail(CloseProtocolError, "invalid close frame")
+		}
+		if len(payload) >= 2 {
+			closeErr.Code = int(binary.BigEndian.Uint16(payload))
+			closeErr.Text = string(payload[2:])
+			if !validCloseCode(closeErr.Code) || !utf8.Valid(payload[2:]) {
+				return c.fail(CloseProtocolError, "invalid close frame")
+			}
+		}
+
+		// Echo the close code to complete the closing handshake
+		code := closeErr.Code
+		if code == CloseNoStatusReceived {
+			code = CloseNormalClosure
+		}
+		_ = c.CloseWithStatus(code, "") //nolint:errcheck // The close error is returned
+		return closeErr
+	}
+
+	return nil
+}
+
+// fail closes the connection with the code because of a protocol violation
+func (c *Conn) fail(code int, text string) error {
+	_ = c.CloseWithStatus(code, text) //nolint:errcheck // The protocol error is more important
+	return fmt.Errorf("%w: %s", ErrProtocol, text)
+}
+
+// inflate decompresses a permessage-deflate message within the read limit
+func (c *Conn) inflate(data []byte) ([]byte, error) {
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(data), strings.NewReader(deflateTail)))
+	defer r.Close() //nolint:errcheck // Closing a flate reader does not fail
+
+	data, err := io.ReadAll(io.LimitReader(r, c.cfg.ReadLimit+1))
+	if err != nil {
+		return nil, c.fail(CloseInvalidFramePayloadData, "invalid compressed message")
+	}
+	if int64(len(data)) > c.cfg.ReadLimit {
+		_ = c.CloseWithStatus(CloseMessageTooBig, "") //nolint:errcheck // The read limit error is more important
+		return nil, ErrReadLimit
+	}
+
+	return data, nil
+}
+
+// WriteMessage writes a text, binary, ping or pong message to the client.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+	case PingMessage, PongMessage:
+		if len(data) > maxControlPayload {
+			return ErrInvalidMessageType
+		}
+	default:
+		return ErrInvalidMessageType
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrCloseSent
+	}
+
+	compressed := false
+	if c.compress && !c.cfg.DisableWriteCompression && (messageType == TextMessage || messageType == BinaryMessage) {
+		var err error
+		if data, err = c.deflate(data); err != nil {
+			return err
+		}
+		compressed = true
+	}
+
+	return c.writeFrame(byte(messageType), compressed, data)
+}
+
+// Close sends a normal closure to the client. The connection itself is closed when the handler returns.
+func (c *Conn) Close() error {
+	return c.CloseWithStatus(CloseNormalClosure, "")
+}
+
+// CloseWithStatus sends a close message with the code and reason to the client, later messages are not sent.
+func (c *Conn) CloseWithStatus(code int, reason string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	c.closeSent = true
+
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code)) //nolint:gosec // Close codes are 16 bit
+	payload = append(payload, reason...)
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+
+	return c.writeFrame(CloseMessage, false, payload)
+}
+
+// deflate compresses a message without the tail that is removed by permessage-deflate, the caller must hold the write lock
+func (c *Conn) deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if c.fw == nil {
+		fw, err := flate.NewWriter(&buf, c.cfg.CompressionLevel)
+		if err != nil {
+			return nil, fmt.Errorf("websocket: failed to create compressor: %w", err)
+		}
+		c.fw = fw
+	} else {
+		c.fw.Reset(&buf)
+	}
+
+	if _, err := c.fw.Write(data); err != nil {
+		return nil, fmt.Errorf("websocket: failed to compress message: %w", err)
+	}
+	if err := c.fw.Flush(); err != nil {
+		return nil, fmt.Errorf("websocket: failed to compress message: %w", err)
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte(deflateTail[:4])), nil
+}
+
+// writeFrame writes a single unmasked frame, the caller must hold the write lock
+func (c *Conn) writeFrame(opcode byte, compressed bool, payload []byte) error {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteTimeout)); err != nil {
+		return err
+	}
+	if _, err := c.bw.Write(appendFrameHeader(nil, opcode, compressed, len(payload), nil)); err != nil {
+		return err
+	}
+	if _, err := c.bw.Write(payload); err != nil {
+		return err
+	}
+
+	return c.bw.Flush()
+}
+
+// frameHeader is the decoded header of a frame
+type frameHeader struct {
+	length int64
+	mask   [4]byte
+	opcode byte
+	fin    bool
+	rsv1   bool
+	rsv2   bool
+	rsv3   bool
+	masked bool
+}
+
+// readFrameHeader reads the header of the next frame
+func readFrameHeader(r *bufio.Reader) (frameHeader, error) {
+	var h frameHeader
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:2]); err != nil {
+		return h, err
+	}
+
+	h.fin = b[0]&finalBit != 0
+	h.rsv1 = b[0]&rsv1Bit != 0
+	h.rsv2 = b[0]&rsv2Bit != 0
+	h.rsv3 = b[0]&rsv3Bit != 0
+	h.opcode = b[0] & opcodeMask
+	h.masked = b[1]&maskBit != 0
+	h.length = int64(b[1] & lengthMask)
+
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(r, b[:2]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(r, b[:8]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint64(b[:8])) //nolint:gosec // Negative lengths are rejected below
+		if h.length < 0 {
+			return h, fmt.Errorf("%w: invalid frame length", ErrProtocol)
+		}
+	}
+
+	if h.masked {
+		if _, err := io.ReadFull(r, h.mask[:]); err != nil {
+			return h, err
+		}
+	}
+
+	return h, nil
+}
+
+// readPayload reads and unmasks the payload of the frame
+func (h *frameHeader) readPayload(r *bufio.Reader) ([]byte, error) {
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if h.masked {
+		for i := range payload {
+			payload[i] ^= h.mask[i%4]
+		}
+	}
+
+	return payload, nil
+}
+
+// appendFrameHeader appends the header of a final frame, the payload has to be masked by the caller if a mask is passed
+func appendFrameHeader(dst []byte, opcode byte, compressed bool, length int, mask []byte) []byte {
+	b0 := finalBit | opcode
+	if compressed {
+		b0 |= rsv1Bit
+	}
+
+	var b1 byte
+	if mask != nil {
+		b1 = maskBit
+	}
+
+	switch {
+	case length <= maxControlPayload:
+		dst = append(dst, b0, b1|byte(length))
+	case length <= 0xffff:
+		dst = append(dst, b0, b1|126)
+		dst = binary.BigEndian.AppendUint16(dst, uint16(length))
+	default:
+		dst = append(dst, b0, b1|127)
+		dst = binary.BigEndian.AppendUint64(dst, uint64(length))
+	}
+
+	return append(dst, mask...)
+}
+
+// validCloseCode checks whether the close code may be sent in a close frame
+func validCloseCode(code int) bool {
+	switch {
+	case code >= CloseNormalClosure && code <= CloseUnsupportedData,
+		code >= CloseInvalidFramePayloadData && code <= CloseInternalServerErr:
+		return true
+	default:
+		return code >= 3000 && code <= 4999
+	}
+}
+
+// valueOrDefault returns the value of the key or the default value if it is empty
+func valueOrDefault(values map[string]string, key string, defaultValue []string) string {
+	if value := values[key]; value != "" {
+		return value
+	}
+	if len(defaultValue) > 0 {
+		return defaultValue[0]
+	}
+
+	return ""
+}
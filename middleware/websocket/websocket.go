@@ -0,0 +1,166 @@
+package websocket
+
+import (
+	"crypto/sha1" //nolint:gosec // SHA-1 is required by the WebSocket handshake
+	"encoding/base64"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/utils"
+)
+
+// GUID that is appended to the Sec-WebSocket-Key to compute the Sec-WebSocket-Accept header (RFC 6455)
+const keyGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// The permessage-deflate response, the context is reset after every message in both directions
+const deflateExtension = "permessage-deflate; server_no_context_takeover; client_no_context_takeover"
+
+// New creates a new middleware handler that upgrades the request to a WebSocket connection
+// and calls the handler with it. Requests that are no WebSocket upgrade are answered with
+// "426 Upgrade Required". The connection is closed when the handler returns.
+//
+//	app.Get("/ws/:room", websocket.New(func(c *websocket.Conn) {
+//		for {
+//			mt, msg, err := c.ReadMessage()
+//			if err != nil {
+//				return
+//			}
+//			if err := c.WriteMessage(mt, msg); err != nil {
+//				return
+//			}
+//		}
+//	}))
+func New(handler func(*Conn), config ...Config) velocity.Handler {
+	// Set default config
+	cfg := configDefault(config...)
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		if !IsWebSocketUpgrade(c) {
+			return velocity.ErrUpgradeRequired
+		}
+		if c.Get(velocity.HeaderSecWebSocketVersion) != "13" {
+			c.Set(velocity.HeaderSecWebSocketVersion, "13")
+			return velocity.ErrUpgradeRequired
+		}
+
+		key := c.Get(velocity.HeaderSecWebSocketKey)
+		if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+			return velocity.ErrBadRequest
+		}
+
+		if origin := c.Get(velocity.HeaderOrigin); origin != "" && !originAllowed(cfg, origin, c.Get(velocity.HeaderHost)) {
+			return velocity.ErrForbidden
+		}
+
+		conn := acquireConn(c, cfg)
+		conn.subprotocol = selectSubprotocol(c.Get(velocity.HeaderSecWebSocketProtocol), cfg.Subprotocols)
+		conn.compress = cfg.EnableCompression && acceptDeflate(c.Get(velocity.HeaderSecWebSocketExtensions))
+
+		c.Status(velocity.StatusSwitchingProtocols)
+		c.Set(velocity.HeaderUpgrade, "websocket")
+		c.Set(velocity.HeaderConnection, "Upgrade")
+		c.Set(velocity.HeaderSecWebSocketAccept, acceptKey(key))
+		if conn.subprotocol != "" {
+			c.Set(velocity.HeaderSecWebSocketProtocol, conn.subprotocol)
+		}
+		if conn.compress {
+			c.Set(velocity.HeaderSecWebSocketExtensions, deflateExtension)
+		}
+
+		// The connection is handed over after the response has been sent
+		c.RequestCtx().Hijack(func(nc net.Conn) {
+			conn.serve(nc, handler)
+		})
+
+		return nil
+	}
+}
+
+// IsWebSocketUpgrade checks whether the request asks for an upgrade to the WebSocket protocol.
+func IsWebSocketUpgrade(c velocity.Ctx) bool {
+	return c.Method() == velocity.MethodGet &&
+		headerContainsToken(c.Get(velocity.HeaderConnection), "upgrade") &&
+		utils.EqualFold(c.Get(velocity.HeaderUpgrade), "websocket")
+}
+
+// acceptKey computes the value of the Sec-WebSocket-Accept header
+func acceptKey(key string) string {
+	h := sha1.New() //nolint:gosec // SHA-1 is required by the WebSocket handshake
+	h.Write([]byte(key + keyGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// originAllowed checks whether the origin is the same as the host of the request or one of the allowed origins of the config
+func originAllowed(cfg Config, origin, host string) bool {
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && utils.EqualFold(u.Host, host) {
+		return true
+	}
+	for _, allowed := range cfg.AllowOrigins {
+		if allowed == "*" || utils.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return cfg.AllowOriginsFunc != nil && cfg.AllowOriginsFunc(origin)
+}
+
+// selectSubprotocol returns the first supported subprotocol that is requested by the client
+func selectSubprotocol(header string, supported []string) string {
+	if header == "" {
+		return ""
+	}
+	for _, protocol := range supported {
+		if headerContainsToken(header, protocol) {
+			return protocol
+		}
+	}
+
+	return ""
+}
+
+// acceptDeflate checks whether the client offers permessage-deflate with parameters that can be accepted.
+// The window size of compress/flate can't be reduced, so offers that limit the window of the server are declined.
+func acceptDeflate(header string) bool {
+	for _, offer := range strings.Split(header, ",") {
+		params := strings.Split(offer, ";")
+		if !utils.EqualFold(strings.TrimSpace(params[0]), "permessage-deflate") {
+			continue
+		}
+
+		accepted := true
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			switch utils.ToLower(strings.TrimSpace(name)) {
+			case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+			case "server_max_window_bits":
+				accepted = accepted && strings.Trim(strings.TrimSpace(value), `"`) == "15"
+			default:
+				accepted = false
+			}
+		}
+		if accepted {
+			return true
+		}
+	}
+
+	return false
+}
+
+// headerContainsToken checks whether the comma separated header value contains the token, ignoring case
+func headerContainsToken(header, token string) bool {
+	for _, v := range strings.Split(header, ",") {
+		if utils.EqualFold(strings.TrimSpace(v), token) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,445 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// The sample key and accept value of RFC 6455
+const (
+	testKey    = "dGhlIHNhbXBsZSBub25jZQ=="
+	testAccept = "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="
+)
+
+var testMask = []byte{0x12, 0x34, 0x56, 0x78}
+
+// testClient is a minimal WebSocket client for the tests
+type testClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+	resp *http.Response
+}
+
+// serve starts the app on an in-memory listener
+func serve(t *testing.T, app *velocity.App) *fasthttputil.InmemoryListener {
+	t.Helper()
+	ln := fasthttputil.NewInmemoryListener()
+	go func() {
+		_ = app.Listener(ln, velocity.ListenConfig{DisableStartupMessage: true}) //nolint:errcheck // stopped by the cleanup
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, app.Shutdown())
+	})
+
+	return ln
+}
+
+// dial connects to the app and sends the upgrade request with the additional headers
+func dial(t *testing.T, ln *fasthttputil.InmemoryListener, target string, header map[string]string) *testClient {
+	t.Helper()
+	conn, err := ln.Dial()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close() //nolint:errcheck // test
+	})
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	req := httptest.NewRequest(velocity.MethodGet, target, nil)
+	req.Header.Set(velocity.HeaderConnection, "Upgrade")
+	req.Header.Set(velocity.HeaderUpgrade, "websocket")
+	req.Header.Set(velocity.HeaderSecWebSocketVersion, "13")
+	req.Header.Set(velocity.HeaderSecWebSocketKey, testKey)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	require.NoError(t, req.Write(conn))
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	require.NoError(t, err)
+
+	return &testClient{conn: conn, br: br, resp: resp}
+}
+
+// write sends a masked frame
+func (c *testClient) write(t *testing.T, fin bool, opcode byte, compressed bool, payload []byte) {
+	t.Helper()
+	frame := appendFrameHeader(nil, opcode, compressed, len(payload), testMask)
+	if !fin {
+		frame[0] &^= finalBit
+	}
+	for i, b := range payload {
+		frame = append(frame, b^testMask[i%4])
+	}
+	_, err := c.conn.Write(frame)
+	require.NoError(t, err)
+}
+
+// read receives the next frame
+func (c *testClient) read(t *testing.T) (frameHeader, []byte) {
+	t.Helper()
+	h, err := readFrameHeader(c.br)
+	require.NoError(t, err)
+	require.False(t, h.masked)
+	payload, err := h.readPayload(c.br)
+	require.NoError(t, err)
+
+	return h, payload
+}
+
+func closePayload(code int, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...) //nolint:gosec // test
+}
+
+func echo(c *Conn) {
+	for {
+		mt, msg, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err := c.WriteMessage(mt, msg); err != nil {
+			return
+		}
+	}
+}
+
+// go test -run Test_WebSocket_Echo
+func Test_WebSocket_Echo(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(func(c velocity.Ctx) error {
+		c.Locals("user", "john")
+		return c.Next()
+	})
+
+	closed := make(chan error, 1)
+	app.Get("/ws/:room", New(func(c *Conn) {
+		info := c.Params("room") + "|" + c.Locals("user").(string) + "|" + c.Query("token") + "|" + //nolint:forcetypeassert // test
+			c.Cookies("session") + "|" + c.Query("missing", "default")
+		if err := c.WriteMessage(TextMessage, []byte(info)); err != nil {
+			closed <- err
+			return
+		}
+		for {
+			mt, msg, err := c.ReadMessage()
+			if err != nil {
+				closed <- err
+				return
+			}
+			if err := c.WriteMessage(mt, msg); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}))
+	ln := serve(t, app)
+
+	client := dial(t, ln, "/ws/lobby?token=abc", map[string]string{
+		velocity.HeaderCookie: "session=xyz",
+	})
+	require.Equal(t, velocity.StatusSwitchingProtocols, client.resp.StatusCode)
+	require.Equal(t, testAccept, client.resp.Header.Get(velocity.HeaderSecWebSocketAccept))
+	require.Equal(t, "websocket", client.resp.Header.Get(velocity.HeaderUpgrade))
+	require.Empty(t, client.resp.Header.Get(velocity.HeaderSecWebSocketExtensions))
+
+	h, payload := client.read(t)
+	require.Equal(t, byte(TextMessage), h.opcode)
+	require.Equal(t, "lobby|john|abc|xyz|default", string(payload))
+
+	// fragmented message with a ping in between
+	client.write(t, false, TextMessage, false, []byte("hel"))
+	client.write(t, true, PingMessage, false, []byte("ping"))
+	client.write(t, true, continuationFrame, false, []byte("lo"))
+
+	h, payload = client.read(t)
+	require.Equal(t, byte(PongMessage), h.opcode)
+	require.Equal(t, "ping", string(payload))
+	h, payload = client.read(t)
+	require.True(t, h.fin)
+	require.Equal(t, byte(TextMessage), h.opcode)
+	require.Equal(t, "hello", string(payload))
+
+	// closing handshake
+	client.write(t, true, CloseMessage, false, closePayload(CloseGoingAway, "bye"))
+	h, payload = client.read(t)
+	require.Equal(t, byte(CloseMessage), h.opcode)
+	require.Equal(t, closePayload(CloseGoingAway, ""), payload)
+
+	err := <-closed
+	var closeErr *CloseError
+	require.ErrorAs(t, err, &closeErr)
+	require.Equal(t, CloseGoingAway, closeErr.Code)
+	require.Equal(t, "bye", closeErr.Text)
+}
+
+// go test -run Test_WebSocket_Handshake
+func Test_WebSocket_Handshake(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Get("/ws", New(echo, Config{
+		AllowOrigins: []string{"https://example.com"},
+		AllowOriginsFunc: func(origin string) bool {
+			return origin == "https://trusted.example.com"
+		},
+	}))
+
+	upgrade := func(header map[string]string) *http.Response {
+		req := httptest.NewRequest(velocity.MethodGet, "/ws", nil)
+		req.Header.Set(velocity.HeaderConnection, "keep-alive, Upgrade")
+		req.Header.Set(velocity.HeaderUpgrade, "WebSocket")
+		req.Header.Set(velocity.HeaderSecWebSocketVersion, "13")
+		req.Header.Set(velocity.HeaderSecWebSocketKey, testKey)
+		req.Header.Set(velocity.HeaderOrigin, "https://example.com")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// no upgrade request
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/ws", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusUpgradeRequired, resp.StatusCode)
+
+	// unsupported version
+	resp = upgrade(map[string]string{velocity.HeaderSecWebSocketVersion: "8"})
+	require.Equal(t, velocity.StatusUpgradeRequired, resp.StatusCode)
+	require.Equal(t, "13", resp.Header.Get(velocity.HeaderSecWebSocketVersion))
+
+	// invalid key
+	resp = upgrade(map[string]string{velocity.HeaderSecWebSocketKey: "invalid"})
+	require.Equal(t, velocity.StatusBadRequest, resp.StatusCode)
+
+	// origins
+	resp = upgrade(map[string]string{velocity.HeaderOrigin: "https://evil.com"})
+	require.Equal(t, velocity.StatusForbidden, resp.StatusCode)
+
+	// The hijacked connection of App.Test can't be used, so the upgrades are tested with a listener
+	ln := serve(t, app)
+	client := dial(t, ln, "/ws", map[string]string{velocity.HeaderOrigin: "https://trusted.example.com"})
+	require.Equal(t, velocity.StatusSwitchingProtocols, client.resp.StatusCode)
+	client = dial(t, ln, "/ws", map[string]string{velocity.HeaderOrigin: "https://EXAMPLE.com"})
+	require.Equal(t, velocity.StatusSwitchingProtocols, client.resp.StatusCode)
+	require.Equal(t, testAccept, client.resp.Header.Get(velocity.HeaderSecWebSocketAccept))
+}
+
+// go test -run Test_WebSocket_SameOrigin
+func Test_WebSocket_SameOrigin(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Get("/ws", New(echo))
+
+	// A cross-origin upgrade is rejected by default
+	req := httptest.NewRequest(velocity.MethodGet, "http://example.com/ws", nil)
+	req.Header.Set(velocity.HeaderConnection, "Upgrade")
+	req.Header.Set(velocity.HeaderUpgrade, "websocket")
+	req.Header.Set(velocity.HeaderSecWebSocketVersion, "13")
+	req.Header.Set(velocity.HeaderSecWebSocketKey, testKey)
+	req.Header.Set(velocity.HeaderOrigin, "https://evil.com")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusForbidden, resp.StatusCode)
+
+	ln := serve(t, app)
+	client := dial(t, ln, "/ws", map[string]string{velocity.HeaderOrigin: "https://Example.com"})
+	require.Equal(t, velocity.StatusSwitchingProtocols, client.resp.StatusCode)
+	client = dial(t, ln, "/ws", map[string]string{velocity.HeaderOrigin: "https://example.com.evil.com"})
+	require.Equal(t, velocity.StatusForbidden, client.resp.StatusCode)
+	client = dial(t, ln, "/ws", map[string]string{velocity.HeaderOrigin: "null"})
+	require.Equal(t, velocity.StatusForbidden, client.resp.StatusCode)
+
+	// All origins are allowed with the wildcard
+	app = velocity.New()
+	app.Get("/ws", New(echo, Config{AllowOrigins: []string{"*"}}))
+	client = dial(t, serve(t, app), "/ws", map[string]string{velocity.HeaderOrigin: "https://evil.com"})
+	require.Equal(t, velocity.StatusSwitchingProtocols, client.resp.StatusCode)
+}
+
+// go test -run Test_configDefault
+func Test_configDefault(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, flate.BestSpeed, configDefault(Config{}).CompressionLevel)
+	require.Equal(t, flate.HuffmanOnly, configDefault(Config{CompressionLevel: flate.HuffmanOnly}).CompressionLevel)
+	require.Equal(t, flate.BestCompression, configDefault(Config{CompressionLevel: flate.BestCompression}).CompressionLevel)
+	require.Panics(t, func() { configDefault(Config{CompressionLevel: flate.HuffmanOnly - 1}) })
+	require.Panics(t, func() { configDefault(Config{CompressionLevel: flate.BestCompression + 1}) })
+}
+
+// go test -run Test_WebSocket_Subprotocol
+func Test_WebSocket_Subprotocol(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Get("/ws", New(func(c *Conn) {
+		_ = c.WriteMessage(TextMessage, []byte(c.Subprotocol())) //nolint:errcheck // test
+	}, Config{Subprotocols: []string{"graphql-ws", "chat"}}))
+	ln := serve(t, app)
+
+	client := dial(t, ln, "/ws", map[string]string{
+		velocity.HeaderSecWebSocketProtocol: "chat, graphql-ws",
+	})
+	require.Equal(t, "graphql-ws", client.resp.Header.Get(velocity.HeaderSecWebSocketProtocol))
+	_, payload := client.read(t)
+	require.Equal(t, "graphql-ws", string(payload))
+
+	// the connection is closed when the handler returns
+	h, payload := client.read(t)
+	require.Equal(t, byte(CloseMessage), h.opcode)
+	require.Equal(t, closePayload(CloseNormalClosure, ""), payload)
+}
+
+// go test -run Test_WebSocket_ReadLimit
+func Test_WebSocket_ReadLimit(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	errs := make(chan error, 1)
+	app.Get("/ws", New(func(c *Conn) {
+		_, _, err := c.ReadMessage()
+		errs <- err
+	}, Config{ReadLimit: 4}))
+	ln := serve(t, app)
+
+	client := dial(t, ln, "/ws", nil)
+	client.write(t, true, BinaryMessage, false, []byte("too large"))
+
+	h, payload := client.read(t)
+	require.Equal(t, byte(CloseMessage), h.opcode)
+	require.Equal(t, closePayload(CloseMessageTooBig, ""), payload)
+	require.ErrorIs(t, <-errs, ErrReadLimit)
+}
+
+// go test -run Test_WebSocket_ProtocolError
+func Test_WebSocket_ProtocolError(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	errs := make(chan error, 1)
+	app.Get("/ws", New(func(c *Conn) {
+		_, _, err := c.ReadMessage()
+		errs <- err
+	}))
+	ln := serve(t, app)
+
+	client := dial(t, ln, "/ws", nil)
+	// unmasked frame
+	_, err := client.conn.Write(appendFrameHeader([]byte(nil), TextMessage, false, 2, nil))
+	require.NoError(t, err)
+	_, err = client.conn.Write([]byte("hi"))
+	require.NoError(t, err)
+
+	h, payload := client.read(t)
+	require.Equal(t, byte(CloseMessage), h.opcode)
+	require.Equal(t, uint16(CloseProtocolError), binary.BigEndian.Uint16(payload))
+	require.ErrorIs(t, <-errs, ErrProtocol)
+}
+
+// go test -run Test_WebSocket_Keepalive
+func Test_WebSocket_Keepalive(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Get("/ws", New(echo, Config{PingInterval: 10 * time.Millisecond}))
+	ln := serve(t, app)
+
+	client := dial(t, ln, "/ws", nil)
+	h, _ := client.read(t)
+	require.Equal(t, byte(PingMessage), h.opcode)
+}
+
+// go test -run Test_WebSocket_Compression
+func Test_WebSocket_Compression(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Get("/ws", New(echo, Config{EnableCompression: true}))
+	ln := serve(t, app)
+
+	client := dial(t, ln, "/ws", map[string]string{
+		velocity.HeaderSecWebSocketExtensions: "permessage-deflate; server_max_window_bits=10, permessage-deflate; client_max_window_bits",
+	})
+	require.Equal(t, deflateExtension, client.resp.Header.Get(velocity.HeaderSecWebSocketExtensions))
+
+	message := bytes.Repeat([]byte("velocity "), 100)
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.BestCompression)
+	require.NoError(t, err)
+	_, err = fw.Write(message)
+	require.NoError(t, err)
+	require.NoError(t, fw.Flush())
+	client.write(t, true, TextMessage, true, bytes.TrimSuffix(buf.Bytes(), []byte{0, 0, 0xff, 0xff}))
+
+	h, payload := client.read(t)
+	require.True(t, h.rsv1)
+	require.Less(t, len(payload), len(message))
+	inflated, err := io.ReadAll(flate.NewReader(io.MultiReader(bytes.NewReader(payload), bytes.NewReader([]byte(deflateTail)))))
+	require.NoError(t, err)
+	require.Equal(t, message, inflated)
+}
+
+// go test -run Test_WebSocket_DisableWriteCompression
+func Test_WebSocket_DisableWriteCompression(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Get("/ws", New(echo, Config{EnableCompression: true, DisableWriteCompression: true}))
+	ln := serve(t, app)
+
+	client := dial(t, ln, "/ws", map[string]string{velocity.HeaderSecWebSocketExtensions: "permessage-deflate"})
+	require.Equal(t, deflateExtension, client.resp.Header.Get(velocity.HeaderSecWebSocketExtensions))
+
+	// The compressed message of the client is accepted, the echo is sent uncompressed
+	message := bytes.Repeat([]byte("velocity "), 100)
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.BestCompression)
+	require.NoError(t, err)
+	_, err = fw.Write(message)
+	require.NoError(t, err)
+	require.NoError(t, fw.Flush())
+	client.write(t, true, TextMessage, true, bytes.TrimSuffix(buf.Bytes(), []byte{0, 0, 0xff, 0xff}))
+
+	h, payload := client.read(t)
+	require.False(t, h.rsv1)
+	require.Equal(t, message, payload)
+}
+
+// go test -run Test_acceptDeflate
+func Test_acceptDeflate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		header   string
+		accepted bool
+	}{
+		{header: "", accepted: false},
+		{header: "x-webkit-deflate-frame", accepted: false},
+		{header: "permessage-deflate", accepted: true},
+		{header: "permessage-deflate; client_max_window_bits", accepted: true},
+		{header: "permessage-deflate; server_max_window_bits=15", accepted: true},
+		{header: "permessage-deflate; server_max_window_bits=9", accepted: false},
+		{header: "permessage-deflate; unknown", accepted: false},
+		{header: "permessage-deflate; unknown, permessage-deflate; server_no_context_takeover", accepted: true},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.accepted, acceptDeflate(tc.header), tc.header)
+	}
+}
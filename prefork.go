@@ -34,7 +34,8 @@ func IsChild() bool {
 	return os.Getenv(envPreforkChildKey) == envPreforkChildVal
 }
 
-// prefork manages child processes to make use of the OS REUSEPORT or REUSEADDR feature
+// prefork manages child processes to make use of the OS REUSEPORT or REUSEADDR feature.
+// Unix sockets and sockets from socket activation are opened by the master and inherited by the children.
 func (app *App) prefork(addr string, tlsConfig *tls.Config, cfg ListenConfig) error {
 	var ln net.Listener
 	var err error
@@ -43,9 +44,14 @@ func (app *App) prefork(addr string, tlsConfig *tls.Config, cfg ListenConfig) er
 	if IsChild() {
 		// use 1 cpu core per child process
 		runtime.GOMAXPROCS(1)
-		// Linux will use SO_REUSEPORT and Windows falls back to SO_REUSEADDR
-		// Only tcp4 or tcp6 is supported when preforking, both are not supported
-		if ln, err = reuseport.Listen(cfg.ListenerNetwork, addr); err != nil {
+		// Unix sockets and sockets from socket activation are inherited from the master
+		ln, err = activatedListener()
+		if err == nil && ln == nil {
+			// Linux will use SO_REUSEPORT and Windows falls back to SO_REUSEADDR
+			// Only tcp4 or tcp6 is supported when preforking, both are not supported
+			ln, err = reuseport.Listen(cfg.ListenerNetwork, addr)
+		}
+		if err != nil {
 			if !cfg.DisableStartupMessage {
 				time.Sleep(sleepDuration) // avoid colliding with startup message
 			}
@@ -67,7 +73,7 @@ func (app *App) prefork(addr string, tlsConfig *tls.Config, cfg ListenConfig) er
 		}
 
 		// listen for incoming connections
-		return app.server.Serve(ln)
+		return app.serve(ln, cfg)
 	}
 
 	// 👮 master process 👮
@@ -91,6 +97,25 @@ func (app *App) prefork(addr string, tlsConfig *tls.Config, cfg ListenConfig) er
 		}
 	}()
 
+	// Sockets that can't be opened with SO_REUSEPORT are created once and shared with the children
+	var shared *os.File
+	if cfg.EnableSocketActivation || cfg.ListenerNetwork == NetworkUnix {
+		if ln, err = listen(addr, cfg); err != nil {
+			return fmt.Errorf("prefork: %w", err)
+		}
+		defer ln.Close() //nolint:errcheck // The children are gone
+
+		if shared, err = listenerFile(ln); err != nil {
+			return fmt.Errorf("prefork: %w", err)
+		}
+		defer shared.Close() //nolint:errcheck // The children have their own copy
+
+		addr = ln.Addr().String()
+		if ln.Addr().Network() == NetworkUnix {
+			cfg.ListenerNetwork = NetworkUnix
+		}
+	}
+
 	// collect child pids
 	var pids []string
 
@@ -107,10 +132,19 @@ func (app *App) prefork(addr string, tlsConfig *tls.Config, cfg ListenConfig) er
 		cmd.Stderr = os.Stderr
 
 		// add velocity prefork child flag into child proc env
-		cmd.Env = append(os.Environ(),
+		cmd.Env = append(childEnv(),
 			fmt.Sprintf("%s=%s", envPreforkChildKey, envPreforkChildVal),
 		)
 
+		// pass the shared socket like socket activation does, the child checks the pid of its master
+		if shared != nil {
+			cmd.ExtraFiles = []*os.File{shared}
+			cmd.Env = append(cmd.Env,
+				fmt.Sprintf("%s=%d", envListenPID, os.Getpid()),
+				envListenFDs+"=1",
+			)
+		}
+
 		if err = cmd.Start(); err != nil {
 			return fmt.Errorf("failed to start a child prefork process, error: %w", err)
 		}
@@ -153,6 +187,20 @@ func (app *App) prefork(addr string, tlsConfig *tls.Config, cfg ListenConfig) er
 	return (<-channel).err
 }
 
+// childEnv returns the environment of the master without the variables of socket activation,
+// they are only passed to the children together with the shared socket
+func childEnv() []string {
+	env := os.Environ()
+	filtered := env[:0:0]
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		if key != envListenPID && key != envListenFDs && key != envListenFDNames {
+			filtered = append(filtered, kv)
+		}
+	}
+	return filtered
+}
+
 // watchMaster watches child procs
 func watchMaster() {
 	if runtime.GOOS == "windows" {
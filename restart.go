@@ -0,0 +1,169 @@
+package velocity
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"reflect"
+	"sync"
+
+	"github.com/khulnasoft/velocity/log"
+)
+
+const (
+	envRestartChildKey = "VELOCITY_RESTART_CHILD"
+	envRestartChildVal = "1"
+)
+
+// restartCommand creates the command that starts the new process of a graceful restart
+var restartCommand = func() *exec.Cmd {
+	return exec.Command(os.Args[0], os.Args[1:]...) //nolint:gosec // It's fine to launch the same process again
+}
+
+// isRestartChild determines if the process was started by a graceful restart and didn't report its readiness yet
+func isRestartChild() bool {
+	return os.Getenv(envRestartChildKey) == envRestartChildVal
+}
+
+// watchRestartSignal restarts the app gracefully when the signal is received.
+// The returned function stops watching and waits for a running restart to complete.
+func (app *App) watchRestartSignal(ln net.Listener, cfg ListenConfig) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, cfg.RestartSignal)
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-signals:
+				if err := app.restart(ln, cfg); err != nil {
+					log.Errorf("restart: %v", err)
+					continue
+				}
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+		})
+		<-finished
+	}
+}
+
+// restart passes the listener to a new process of the app. When the new process is ready to serve,
+// the OnRestart hooks are executed and the app shuts down after the open connections are drained.
+func (app *App) restart(ln net.Listener, cfg ListenConfig) error {
+	base := unwrapListener(ln)
+	f, err := listenerFile(base)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck // The new process has its own copy
+
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create the readiness pipe: %w", err)
+	}
+	defer ready.Close() //nolint:errcheck // It is only read once
+
+	// pass the listener like socket activation does and the pipe as the next file descriptor
+	cmd := restartCommand()
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(childEnv(),
+		fmt.Sprintf("%s=%s", envRestartChildKey, envRestartChildVal),
+		fmt.Sprintf("%s=%d", envListenPID, os.Getpid()),
+		envListenFDs+"=1",
+	)
+	cmd.ExtraFiles = []*os.File{f, readyWriter}
+
+	err = cmd.Start()
+	_ = readyWriter.Close() //nolint:errcheck // Only the new process writes to the pipe
+	if err != nil {
+		return fmt.Errorf("failed to start the new process: %w", err)
+	}
+	pid := cmd.Process.Pid
+
+	// the pipe is closed without data if the new process exits before it is ready
+	if _, err := ready.Read(make([]byte, 1)); err != nil {
+		_ = cmd.Process.Kill() //nolint:errcheck // It may have exited already
+		if waitErr := cmd.Wait(); waitErr != nil {
+			err = waitErr
+		}
+		return fmt.Errorf("new process %d exited before it was ready: %w", pid, err)
+	}
+	go cmd.Wait() //nolint:errcheck // The new process outlives this one, the exit status is only collected
+
+	if app.hooks != nil {
+		app.hooks.executeOnRestartHooks(pid)
+	}
+
+	// the socket file is served by the new process now
+	if unixLn, ok := base.(*net.UnixListener); ok {
+		unixLn.SetUnlinkOnClose(false)
+	}
+
+	ctx := context.Background()
+	if cfg.ShutdownTimeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.ShutdownTimeout)
+		defer cancel()
+	}
+
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		cfg.OnShutdownError(err)
+		return nil
+	}
+
+	if success := cfg.OnShutdownSuccess; success != nil {
+		success()
+	}
+
+	return nil
+}
+
+// notifyRestartReady tells the previous process that the app serves after a graceful restart
+func notifyRestartReady() error {
+	if !isRestartChild() {
+		return nil
+	}
+	// The pipe is only written once
+	if err := os.Unsetenv(envRestartChildKey); err != nil {
+		return fmt.Errorf("restart: %w", err)
+	}
+
+	f := os.NewFile(listenFDsStart+1, "restart-ready")
+	if f == nil {
+		return nil
+	}
+	defer f.Close() //nolint:errcheck // The previous process only reads one byte
+
+	if _, err := f.Write([]byte{1}); err != nil {
+		return fmt.Errorf("restart: failed to notify the previous process: %w", err)
+	}
+
+	return nil
+}
+
+// unwrapListener returns the network listener of a TLS listener
+func unwrapListener(ln net.Listener) net.Listener {
+	if getTLSConfig(ln) == nil {
+		return ln
+	}
+	if inner, ok := reflect.Indirect(reflect.ValueOf(ln)).FieldByName("Listener").Interface().(net.Listener); ok {
+		return inner
+	}
+	return ln
+}
@@ -0,0 +1,134 @@
+//go:build !windows
+
+package velocity
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// setRestartCommand replaces the command of the restarts until the test ends
+func setRestartCommand(t *testing.T, cmd func() *exec.Cmd) {
+	t.Helper()
+	old := restartCommand
+	restartCommand = cmd
+	t.Cleanup(func() {
+		restartCommand = old
+	})
+}
+
+// go test -run Test_App_Restart
+func Test_App_Restart(t *testing.T) {
+	// the new process of the restart runs this test again
+	if isRestartChild() {
+		app := New()
+		app.Get("/", func(c Ctx) error {
+			return c.SendString("new")
+		})
+		require.NoError(t, app.Listen("ignored", ListenConfig{DisableStartupMessage: true}))
+		return
+	}
+
+	setRestartCommand(t, func() *exec.Cmd {
+		return exec.Command(os.Args[0], "-test.run=^Test_App_Restart$") //nolint:gosec // test
+	})
+
+	app := New()
+	app.Get("/", func(c Ctx) error {
+		return c.SendString("old")
+	})
+	app.Get("/slow", func(c Ctx) error {
+		time.Sleep(500 * time.Millisecond)
+		return c.SendString("drained")
+	})
+
+	var pid atomic.Int64
+	app.Hooks().OnRestart(func(p int) error {
+		pid.Store(int64(p))
+		return nil
+	})
+	t.Cleanup(func() {
+		if p, err := os.FindProcess(int(pid.Load())); err == nil && pid.Load() != 0 {
+			_ = p.Kill() //nolint:errcheck // test
+		}
+	})
+
+	listening := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Listen("127.0.0.1:0", ListenConfig{
+			DisableStartupMessage: true,
+			RestartSignal:         syscall.SIGUSR2,
+			ListenerAddrFunc: func(addr net.Addr) {
+				listening <- addr.String()
+			},
+		})
+	}()
+	url := "http://" + <-listening + "/"
+	require.Equal(t, "old", getBody(t, url))
+
+	// a request that is in flight during the restart is completed by the old process
+	slow := make(chan string, 1)
+	go func() {
+		slow <- getBody(t, url+"slow")
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+	require.NoError(t, <-done)
+	require.NotZero(t, pid.Load())
+	require.Equal(t, "drained", <-slow)
+
+	// the new process serves on the same socket
+	require.Equal(t, "new", getBody(t, url))
+}
+
+// go test -run Test_App_Restart_Failed
+func Test_App_Restart_Failed(t *testing.T) {
+	setRestartCommand(t, dummyCmd)
+
+	path := filepath.Join(t.TempDir(), "app.sock")
+	ln, err := net.Listen(NetworkUnix, path)
+	require.NoError(t, err)
+	defer ln.Close() //nolint:errcheck // test
+
+	// the new process exits without being ready, the app keeps serving
+	require.ErrorContains(t, New().restart(ln, listenConfigDefault()), "exited before it was ready")
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+}
+
+// go test -run Test_unwrapListener
+func Test_unwrapListener(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen(NetworkTCP4, "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close() //nolint:errcheck // test
+
+	require.Equal(t, ln, unwrapListener(ln))
+	require.Equal(t, ln, unwrapListener(tls.NewListener(ln, &tls.Config{MinVersion: tls.VersionTLS12})))
+}
+
+func getBody(t *testing.T, url string) string {
+	t.Helper()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get(url) //nolint:noctx // test
+	require.NoError(t, err)
+	defer resp.Body.Close() //nolint:errcheck // test
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
@@ -9,7 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"html"
-	"sort"
+	"maps"
 	"sync/atomic"
 
 	"github.com/khulnasoft/velocity/utils"
@@ -38,6 +38,7 @@ type Router interface {
 	Route(path string) Register
 
 	Name(name string) Router
+	Meta(key string, value any) Router
 }
 
 // Route is a struct that holds all metadata for each registered handler.
@@ -51,10 +52,11 @@ type Route struct {
 	Method string `json:"method"` // HTTP method
 	Name   string `json:"name"`   // Route's name
 	//nolint:revive // Having both a Path (uppercase) and a path (lowercase) is fine
-	Path        string      `json:"path"`   // Original registered route path
-	Params      []string    `json:"params"` // Case-sensitive param keys
-	Handlers    []Handler   `json:"-"`      // Ctx handlers
-	routeParser routeParser // Parameter parser
+	Path        string         `json:"path"`   // Original registered route path
+	Params      []string       `json:"params"` // Case-sensitive param keys
+	Handlers    []Handler      `json:"-"`      // Ctx handlers
+	Meta        map[string]any `json:"-"`      // Custom metadata, e.g. for documentation or authorization
+	routeParser routeParser    // Parameter parser
 	// Data for routing
 	pos   uint32 // Position in stack -> important for the sort of the matched routes
 	use   bool   // USE matches path prefixes
@@ -63,6 +65,76 @@ type Route struct {
 	root  bool   // Path equals '/'
 }
 
+// Guard is implemented by the values of the route metadata that have to allow a request, e.g. an
+// authorization policy. The guards of a route are checked after its middleware: right before the
+// last handler of a route, and when a Use route passes the request on with Next, so that e.g. an
+// authentication middleware that runs before them can be passed to the route. If a guard doesn't
+// allow the request, the remaining handlers are skipped and the error of the guard is returned.
+// The guards of a route are checked in no particular order.
+type Guard interface {
+	// Allow reports whether the request is allowed, a guard that sends the response itself
+	// returns false and a nil error
+	Allow(c Ctx) (bool, error)
+}
+
+// RouteSegment describes a constant part or a parameter of a route path.
+type RouteSegment struct {
+	Const       string        // Constant part of the path, empty for parameters
+	Name        string        // Case-sensitive param key, empty for constant parts
+	Constraints []*Constraint // Constraints of the parameter, e.g. "int" for ":id<int>"
+	IsParam     bool          // Indicates whether the segment is a parameter or a constant part
+	IsOptional  bool          // Indicates whether the parameter is optional, e.g. ":id?" or "*"
+	IsGreedy    bool          // Indicates whether the parameter may contain slashes, e.g. "*" or "+"
+}
+
+// Segments returns the constant parts and the parameters of the registered route path in order.
+// It can be used to describe a route, e.g. to generate documentation from the registered routes.
+func (r *Route) Segments() []RouteSegment {
+	parser := parseRoute(r.Path)
+	segments := make([]RouteSegment, len(parser.segs))
+	for i, seg := range parser.segs {
+		segments[i] = RouteSegment{
+			Const:       seg.Const,
+			Name:        seg.ParamName,
+			Constraints: seg.Constraints,
+			IsParam:     seg.IsParam,
+			IsOptional:  seg.IsOptional,
+			IsGreedy:    seg.IsGreedy,
+		}
+	}
+
+	return segments
+}
+
+// IsMiddleware reports whether the route is registered with Use or by a Group with handlers,
+// such a route matches the path prefix for all HTTP methods.
+func (r *Route) IsMiddleware() bool {
+	return r.use
+}
+
+// allow checks the guards in the metadata of the route
+func (r *Route) allow(c Ctx) (bool, error) {
+	for _, value := range r.Meta {
+		if guard, ok := value.(Guard); ok {
+			if allowed, err := guard.Allow(c); !allowed || err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// execute runs the handler of the route with the index, the guards of a route that isn't a
+// Use route are checked before its last handler
+func (r *Route) execute(c Ctx, index int) error {
+	if !r.use && index == len(r.Handlers)-1 {
+		if allowed, err := r.allow(c); !allowed {
+			return err
+		}
+	}
+	return r.Handlers[index](c)
+}
+
 func (r *Route) match(detectionPath, path string, params *[maxParams]string) bool {
 	// root detectionPath check
 	if r.root && len(detectionPath) == 1 && detectionPath[0] == '/' {
@@ -110,10 +182,7 @@ func (r *Route) match(detectionPath, path string, params *[maxParams]string) boo
 
 func (app *App) nextCustom(c CustomCtx) (bool, error) { //nolint: unparam // bool param might be useful for testing
 	// Get stack length
-	tree, ok := app.treeStack[c.getMethodINT()][c.getTreePath()]
-	if !ok {
-		tree = app.treeStack[c.getMethodINT()][""]
-	}
+	tree := c.getTreeNode().stack(c.getMethodINT())
 	lenr := len(tree) - 1
 
 	// Loop over the route stack starting from previous index
@@ -141,16 +210,20 @@ func (app *App) nextCustom(c CustomCtx) (bool, error) { //nolint: unparam // boo
 
 		// Execute first handler of route
 		c.setIndexHandler(0)
-		err := route.Handlers[0](c)
+		err := route.execute(c, 0)
 		return match, err // Stop scanning the stack
 	}
 
 	// If c.Next() does not match, return 404
-	err := NewError(StatusNotFound, "Cannot "+c.Method()+" "+c.getPathOriginal())
+	err := NewError(StatusNotFound, "Cannot "+c.Method()+" "+html.EscapeString(c.getPathOriginal()))
 
 	// If no match, scan stack again if other methods match the request
 	// Moved from app.handler because middleware may break the route chain
 	if !c.getMatched() && app.methodExistCustom(c) {
+		// Answer OPTIONS requests with the methods listed in the Allow header
+		if app.config.EnableAutoOptions && c.getMethodINT() == app.methodInt(MethodOptions) {
+			return false, c.SendStatus(StatusNoContent)
+		}
 		err = ErrMethodNotAllowed
 	}
 	return false, err
@@ -158,10 +231,7 @@ func (app *App) nextCustom(c CustomCtx) (bool, error) { //nolint: unparam // boo
 
 func (app *App) next(c *DefaultCtx) (bool, error) {
 	// Get stack length
-	tree, ok := app.treeStack[c.methodINT][c.treePath]
-	if !ok {
-		tree = app.treeStack[c.methodINT][""]
-	}
+	tree := c.treeNode.stack(c.methodINT)
 	lenTree := len(tree) - 1
 
 	// Loop over the route stack starting from previous index
@@ -196,7 +266,7 @@ func (app *App) next(c *DefaultCtx) (bool, error) {
 		// Execute first handler of route
 		c.indexHandler = 0
 		if len(route.Handlers) > 0 {
-			err = route.Handlers[0](c)
+			err = route.execute(c, 0)
 		}
 		return match, err // Stop scanning the stack
 	}
@@ -206,6 +276,10 @@ func (app *App) next(c *DefaultCtx) (bool, error) {
 	if !c.matched && app.methodExist(c) {
 		// If no match, scan stack again if other methods match the request
 		// Moved from app.handler because middleware may break the route chain
+		// Answer OPTIONS requests with the methods listed in the Allow header
+		if app.config.EnableAutoOptions && c.methodINT == app.methodInt(MethodOptions) {
+			return false, c.SendStatus(StatusNoContent)
+		}
 		err = ErrMethodNotAllowed
 	}
 	return false, err
@@ -315,6 +389,7 @@ func (*App) copyRoute(route *Route) *Route {
 		Name:     route.Name,
 		Method:   route.Method,
 		Handlers: route.Handlers,
+		Meta:     maps.Clone(route.Meta),
 	}
 }
 
@@ -372,6 +447,7 @@ func (app *App) register(methods []string, pathRaw string, group *Group, handler
 			Path:     pathRaw,
 			Method:   method,
 			Handlers: handlers,
+			Meta:     group.metadata(),
 		}
 
 		// Increment global handler count
@@ -449,33 +525,21 @@ func (app *App) buildTree() *App {
 		return app
 	}
 
-	// loop all the methods and stacks and create the prefix tree
+	methods := len(app.config.RequestMethods)
+	root := newRouteNode("", methods)
+
+	// loop all the methods and stacks and add the routes to the node of their constant prefix
 	for m := range app.config.RequestMethods {
-		tsMap := make(map[string][]*Route)
 		for _, route := range app.stack[m] {
-			treePath := ""
-			if len(route.routeParser.segs) > 0 && len(route.routeParser.segs[0].Const) >= 3 {
-				treePath = route.routeParser.segs[0].Const[:3]
-			}
-			// create tree stack
-			tsMap[treePath] = append(tsMap[treePath], route)
+			node := root.insert(route.treePrefix(), methods)
+			node.routes[m] = append(node.routes[m], route)
 		}
-		app.treeStack[m] = tsMap
 	}
 
-	// loop the methods and tree stacks and add global stack and sort everything
-	for m := range app.config.RequestMethods {
-		tsMap := app.treeStack[m]
-		for treePart := range tsMap {
-			if treePart != "" {
-				// merge global tree routes in current tree stack
-				tsMap[treePart] = uniqueRouteStack(append(tsMap[treePart], tsMap[""]...))
-			}
-			// sort tree slices with the positions
-			slc := tsMap[treePart]
-			sort.Slice(slc, func(i, j int) bool { return slc[i].pos < slc[j].pos })
-		}
-	}
+	// pass the routes of the parent nodes down the tree and sort everything by position
+	root.inherit(make([][]*Route, methods))
+
+	app.treeStack = root
 	app.routesRefreshed = false
 
 	return app
@@ -58,6 +58,119 @@ func Test_Route_Match_SameLength(t *testing.T) {
 	require.Equal(t, "test", app.getString(body))
 }
 
+func Test_Route_Segments(t *testing.T) {
+	t.Parallel()
+
+	app := New()
+	app.Get("/api/v1/users/:id<int;min(1)>/files/*", func(_ Ctx) error {
+		return nil
+	})
+	app.Get("/shop/:Product?", func(_ Ctx) error {
+		return nil
+	})
+
+	routes := app.GetRoutes(true)
+	require.Len(t, routes, 2)
+
+	segments := routes[0].Segments()
+	require.Len(t, segments, 4)
+	require.Equal(t, RouteSegment{Const: "/api/v1/users/"}, segments[0])
+	require.True(t, segments[1].IsParam)
+	require.Equal(t, "id", segments[1].Name)
+	require.Len(t, segments[1].Constraints, 2)
+	require.Equal(t, ConstraintInt, segments[1].Constraints[0].Name)
+	require.Equal(t, ConstraintMin, segments[1].Constraints[1].Name)
+	require.Equal(t, []string{"1"}, segments[1].Constraints[1].Data)
+	require.Equal(t, "/files/", segments[2].Const)
+	require.Equal(t, RouteSegment{Name: "*1", IsParam: true, IsOptional: true, IsGreedy: true}, segments[3])
+
+	// param names are case-sensitive
+	segments = routes[1].Segments()
+	require.Len(t, segments, 2)
+	require.Equal(t, RouteSegment{Name: "Product", IsParam: true, IsOptional: true}, segments[1])
+}
+
+func Test_Route_IsMiddleware(t *testing.T) {
+	t.Parallel()
+
+	app := New()
+	handler := func(c Ctx) error {
+		return c.Next()
+	}
+	app.Use(handler)
+	app.Group("/api", handler).Get("/users", handler)
+
+	for _, route := range app.GetRoutes() {
+		require.Equal(t, route.Path != "/api/users", route.IsMiddleware(), route.Method+" "+route.Path)
+	}
+}
+
+// guardFunc is a Guard for the tests
+type guardFunc func(c Ctx) (bool, error)
+
+func (g guardFunc) Allow(c Ctx) (bool, error) {
+	return g(c)
+}
+
+// go test -run Test_Route_Guard
+func Test_Route_Guard(t *testing.T) {
+	t.Parallel()
+
+	app := New()
+	errDenied := NewError(StatusTeapot, "denied")
+	// The guard only allows the requests that passed the middleware of the route
+	guard := guardFunc(func(c Ctx) (bool, error) {
+		switch {
+		case c.Locals("user") == nil:
+			return false, c.SendStatus(StatusUnauthorized)
+		case c.Get("X-Deny") != "":
+			return false, errDenied
+		}
+		return true, nil
+	})
+	authenticate := func(c Ctx) error {
+		if c.Get("X-User") != "" {
+			c.Locals("user", c.Get("X-User"))
+		}
+		return c.Next()
+	}
+	handler := func(c Ctx) error {
+		return c.SendString("ok")
+	}
+
+	app.Get("/route", handler, authenticate).Meta("guard", guard)
+	app.Group("/group").Meta("guard", guard).Use(authenticate).Get("/", handler)
+	app.Use("/use", authenticate).Meta("guard", guard)
+	app.Get("/use", handler)
+	// The guard of a route without middleware is checked before its handler
+	app.Get("/public", handler).Meta("guard", guardFunc(func(Ctx) (bool, error) {
+		return true, nil
+	}))
+
+	for _, path := range []string{"/route", "/group", "/use"} {
+		resp, err := app.Test(httptest.NewRequest(MethodGet, path, nil))
+		require.NoError(t, err)
+		require.Equal(t, StatusUnauthorized, resp.StatusCode, path)
+
+		req := httptest.NewRequest(MethodGet, path, nil)
+		req.Header.Set("X-User", "john")
+		resp, err = app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, StatusOK, resp.StatusCode, path)
+
+		req = httptest.NewRequest(MethodGet, path, nil)
+		req.Header.Set("X-User", "john")
+		req.Header.Set("X-Deny", "1")
+		resp, err = app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, StatusTeapot, resp.StatusCode, path)
+	}
+
+	resp, err := app.Test(httptest.NewRequest(MethodGet, "/public", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusOK, resp.StatusCode)
+}
+
 func Test_Route_Match_Star(t *testing.T) {
 	t.Parallel()
 
@@ -567,7 +680,7 @@ func Benchmark_Router_Next(b *testing.B) {
 	}
 	require.NoError(b, err)
 	require.True(b, res)
-	require.Equal(b, 4, c.indexRoute)
+	require.Equal(b, 0, c.indexRoute)
 }
 
 // go test -v ./... -run=^$ -bench=Benchmark_Router_Next_Default -benchmem -count=4
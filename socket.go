@@ -0,0 +1,149 @@
+package velocity
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Environment variables of the systemd socket activation protocol, see sd_listen_fds(3)
+const (
+	envListenPID     = "LISTEN_PID"
+	envListenFDs     = "LISTEN_FDS"
+	envListenFDNames = "LISTEN_FDNAMES"
+)
+
+// staleSocketDialTimeout limits the check whether an existing unix socket is still in use
+const staleSocketDialTimeout = time.Second
+
+// listenFDsStart is the first file descriptor passed by socket activation (SD_LISTEN_FDS_START)
+var listenFDsStart uintptr = 3
+
+// listen creates the listener for the network, or returns the listener
+// passed by socket activation if it is enabled and the process was activated
+func listen(addr string, cfg ListenConfig) (net.Listener, error) {
+	if cfg.EnableSocketActivation || isRestartChild() {
+		ln, err := activatedListener()
+		if err != nil || ln != nil {
+			return ln, err
+		}
+	}
+
+	if cfg.ListenerNetwork == NetworkUnix {
+		return listenUnix(addr, cfg.UnixSocketFileMode)
+	}
+
+	return net.Listen(cfg.ListenerNetwork, addr)
+}
+
+// activatedListener returns the first listener passed by socket activation, or nil if the
+// process was not activated. The listener of a prefork child or of the new process of
+// a graceful restart is passed the same way by its parent.
+func activatedListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv(envListenPID))
+	if err != nil || (pid != os.Getpid() && (!(IsChild() || isRestartChild()) || pid != os.Getppid())) {
+		return nil, nil //nolint:nilnil // The process was not activated
+	}
+
+	fds, err := strconv.Atoi(os.Getenv(envListenFDs))
+	if err != nil || fds < 1 {
+		return nil, nil //nolint:nilnil // No sockets were passed
+	}
+
+	// The sockets are only used once, like sd_listen_fds(3) with unset_environment
+	for _, key := range []string{envListenPID, envListenFDs, envListenFDNames} {
+		if err := os.Unsetenv(key); err != nil {
+			return nil, fmt.Errorf("socket activation: %w", err)
+		}
+	}
+
+	f := os.NewFile(listenFDsStart, "LISTEN_FD_"+strconv.Itoa(int(listenFDsStart)))
+	if f == nil {
+		return nil, errors.New("socket activation: invalid file descriptor")
+	}
+	// The listener uses a duplicate of the file descriptor
+	defer f.Close() //nolint:errcheck // The duplicate stays open
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("socket activation: %w", err)
+	}
+
+	return ln, nil
+}
+
+// listenUnix listens on the unix socket at path and sets its file mode.
+// A socket file that is left by a process that didn't shut down cleanly is removed first.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen(NetworkUnix, path)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // Wrapped by the caller
+	}
+
+	// Abstract sockets have no file
+	if mode != 0 && path != "" && path[0] != '@' {
+		if err := os.Chmod(path, mode); err != nil {
+			_ = ln.Close() //nolint:errcheck // The chmod error is returned
+			return nil, fmt.Errorf("failed to set the mode of the unix socket: %w", err)
+		}
+	}
+
+	return ln, nil
+}
+
+// removeStaleSocket removes the socket file at path if no process accepts connections on it.
+// Files that are not sockets and sockets that are in use are left alone, so that listening fails.
+func removeStaleSocket(path string) error {
+	if path == "" || path[0] == '@' {
+		return nil
+	}
+
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat the unix socket: %w", err)
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return nil
+	}
+
+	conn, err := net.DialTimeout(NetworkUnix, path, staleSocketDialTimeout)
+	if err == nil {
+		return conn.Close() //nolint:wrapcheck // The socket is in use
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return nil
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove the stale unix socket: %w", err)
+	}
+
+	return nil
+}
+
+// listenerFile returns a duplicate of the file descriptor of the listener
+func listenerFile(ln net.Listener) (*os.File, error) {
+	filer, ok := ln.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, fmt.Errorf("listener %T can't be shared", ln)
+	}
+
+	f, err := filer.File()
+	if err != nil {
+		return nil, fmt.Errorf("failed to share the listener: %w", err)
+	}
+
+	return f, nil
+}
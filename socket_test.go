@@ -0,0 +1,244 @@
+//go:build !windows
+
+package velocity
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startSocketTestApp listens with the config in the background and returns the address of the listener
+func startSocketTestApp(t *testing.T, addr string, cfg ListenConfig) string {
+	t.Helper()
+
+	app := New()
+	app.Get("/", func(c Ctx) error {
+		return c.SendString("hello")
+	})
+
+	listening := make(chan string, 1)
+	done := make(chan error, 1)
+	cfg.DisableStartupMessage = true
+	cfg.ListenerAddrFunc = func(addr net.Addr) {
+		listening <- addr.String()
+	}
+	go func() {
+		done <- app.Listen(addr, cfg)
+	}()
+
+	var listenAddr string
+	select {
+	case listenAddr = <-listening:
+	case err := <-done:
+		require.NoError(t, err)
+	}
+
+	t.Cleanup(func() {
+		assert.NoError(t, app.Shutdown())
+		assert.NoError(t, <-done)
+	})
+
+	return listenAddr
+}
+
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, NetworkUnix, path)
+		},
+	}}
+}
+
+func requireHello(t *testing.T, client *http.Client, url string) {
+	t.Helper()
+
+	resp, err := client.Get(url) //nolint:noctx // test
+	require.NoError(t, err)
+	defer resp.Body.Close() //nolint:errcheck // test
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(body))
+}
+
+// go test -run Test_Listen_Unix
+func Test_Listen_Unix(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.sock")
+
+	// a socket left by a crashed process is removed
+	stale, err := net.ListenUnix(NetworkUnix, &net.UnixAddr{Name: path, Net: NetworkUnix})
+	require.NoError(t, err)
+	stale.SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	t.Run("serve", func(t *testing.T) {
+		addr := startSocketTestApp(t, path, ListenConfig{ListenerNetwork: NetworkUnix, UnixSocketFileMode: 0o600})
+		require.Equal(t, path, addr)
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+		requireHello(t, unixClient(path), "http://unix/")
+	})
+
+	// the socket is removed on shutdown
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// go test -run Test_Listen_Unix_InUse
+func Test_Listen_Unix_InUse(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfg := ListenConfig{DisableStartupMessage: true, ListenerNetwork: NetworkUnix}
+
+	path := filepath.Join(dir, "app.sock")
+	ln, err := net.Listen(NetworkUnix, path)
+	require.NoError(t, err)
+	defer ln.Close() //nolint:errcheck // test
+
+	require.Error(t, New().Listen(path, cfg))
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+
+	// files that are not sockets are never removed
+	path = filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0o600))
+
+	require.Error(t, New().Listen(path, cfg))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "data", string(data))
+}
+
+// setupSocketActivation passes the listener to the process like a service manager
+func setupSocketActivation(t *testing.T, ln net.Listener, pid int) {
+	t.Helper()
+
+	f, err := listenerFile(ln)
+	require.NoError(t, err)
+	fd, err := syscall.Dup(int(f.Fd()))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// the descriptor is closed by the app once the listener was created
+	start := listenFDsStart
+	listenFDsStart = uintptr(fd)
+	t.Cleanup(func() {
+		listenFDsStart = start
+	})
+
+	t.Setenv(envListenPID, strconv.Itoa(pid))
+	t.Setenv(envListenFDs, "1")
+}
+
+// go test -run Test_Listen_SocketActivation
+func Test_Listen_SocketActivation(t *testing.T) {
+	ln, err := net.Listen(NetworkTCP4, "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close() //nolint:errcheck // test
+	setupSocketActivation(t, ln, os.Getpid())
+
+	addr := startSocketTestApp(t, "ignored:0", ListenConfig{EnableSocketActivation: true})
+	require.Equal(t, ln.Addr().String(), addr)
+	requireHello(t, http.DefaultClient, "http://"+addr+"/")
+}
+
+// go test -run Test_Listen_SocketActivation_OtherProcess
+func Test_Listen_SocketActivation_OtherProcess(t *testing.T) {
+	ln, err := net.Listen(NetworkTCP4, "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close() //nolint:errcheck // test
+	setupSocketActivation(t, ln, os.Getpid()+1)
+
+	// the sockets were passed to another process, the app listens on the address
+	addr := startSocketTestApp(t, "127.0.0.1:0", ListenConfig{EnableSocketActivation: true})
+	require.NotEqual(t, ln.Addr().String(), addr)
+	requireHello(t, http.DefaultClient, "http://"+addr+"/")
+}
+
+// go test -run Test_App_Prefork_Child_Process_Inherited_Socket
+func Test_App_Prefork_Child_Process_Inherited_Socket(t *testing.T) {
+	setupIsChild(t)
+	defer teardownIsChild(t)
+
+	path := filepath.Join(t.TempDir(), "app.sock")
+	ln, err := net.Listen(NetworkUnix, path)
+	require.NoError(t, err)
+	defer ln.Close() //nolint:errcheck // test
+	setupSocketActivation(t, ln, os.Getppid())
+
+	app := New()
+	app.Get("/", func(c Ctx) error {
+		return c.SendString("hello")
+	})
+
+	listening := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- app.prefork("ignored", nil, ListenConfig{
+			ListenerNetwork: NetworkUnix,
+			ListenerAddrFunc: func(net.Addr) {
+				close(listening)
+			},
+		})
+	}()
+	<-listening
+
+	requireHello(t, unixClient(path), "http://unix/")
+	require.NoError(t, app.Shutdown())
+	require.NoError(t, <-done)
+}
+
+// go test -run Test_App_Prefork_Master_Process_Unix
+func Test_App_Prefork_Master_Process_Unix(t *testing.T) {
+	testPreforkMaster = true
+
+	path := filepath.Join(t.TempDir(), "app.sock")
+	var addr ListenData
+	app := New()
+	app.Hooks().OnListen(func(data ListenData) error {
+		addr = data
+		return nil
+	})
+
+	require.NoError(t, app.prefork(path, nil, ListenConfig{DisableStartupMessage: true, ListenerNetwork: NetworkUnix}))
+	require.Equal(t, ListenData{Host: path}, addr)
+
+	// the master removes the socket when the children are gone
+	_, err := os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// go test -run Test_Listen_Unix_Startup_Message
+func Test_Listen_Unix_Startup_Message(t *testing.T) {
+	startupMessage := captureOutput(func() {
+		New().startupMessage("/run/app.sock", false, "", ListenConfig{ListenerNetwork: NetworkUnix})
+	})
+	require.Contains(t, startupMessage, "http://unix:/run/app.sock")
+}
+
+// go test -run Test_childEnv
+func Test_childEnv(t *testing.T) {
+	t.Setenv(envListenPID, "1")
+	t.Setenv(envListenFDs, "1")
+	t.Setenv(envListenFDNames, "http")
+
+	for _, kv := range childEnv() {
+		require.NotContains(t, kv, "LISTEN_")
+	}
+}
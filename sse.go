@@ -0,0 +1,352 @@
+package velocity
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khulnasoft/velocity/log"
+)
+
+// SSEEvent is a single Server-Sent Event.
+type SSEEvent struct {
+	// Data is the payload of the event. Line breaks are sent as multiple data lines,
+	// the client joins them with "\n" again.
+	Data string
+
+	// Event is the type of the event. The client dispatches events without a type as "message".
+	Event string
+
+	// ID is the ID of the event. The client sends the last ID it received
+	// in the Last-Event-ID header when it reconnects.
+	ID string
+
+	// Retry is the reconnection time of the client, it is only sent if it is greater than zero.
+	Retry time.Duration
+}
+
+// SSEConfig configures a Server-Sent Events stream.
+type SSEConfig struct {
+	// HeartbeatInterval is the interval in which comments are sent to keep the connection alive
+	// and to detect disconnected clients while no events are sent. A negative value disables the heartbeat.
+	//
+	// Optional. Default: 15 * time.Second
+	HeartbeatInterval time.Duration
+}
+
+const defaultSSEHeartbeatInterval = 15 * time.Second
+
+// ErrSSEStreamClosed is returned when an event is sent after the stream was closed.
+var ErrSSEStreamClosed = errors.New("sse: stream closed")
+
+// Line breaks end a field of an event, they are removed from the event type and ID
+var sseFieldReplacer = strings.NewReplacer("\r\n", "", "\r", "", "\n", "", "\x00", "")
+
+// SSEStream writes Server-Sent Events to the client. It is safe for concurrent use.
+type SSEStream struct {
+	ctx         context.Context //nolint:containedctx // The stream is bound to the lifetime of the connection
+	cancel      context.CancelFunc
+	w           *bufio.Writer
+	err         error
+	lastEventID string
+	mu          sync.Mutex
+}
+
+// Context returns the context of the stream. It is derived from the context of the request
+// and canceled when the stream handler returns, a write to the client fails, the client closes
+// the connection or the server shuts down.
+func (s *SSEStream) Context() context.Context {
+	return s.ctx
+}
+
+// LastEventID returns the value of the Last-Event-ID request header, which is the ID of the
+// last event the client received before it reconnected.
+func (s *SSEStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Send writes the event and flushes it to the client.
+func (s *SSEStream) Send(event SSEEvent) error {
+	var b strings.Builder
+	if event.Event != "" {
+		b.WriteString("event: ")
+		b.WriteString(sseFieldReplacer.Replace(event.Event))
+		b.WriteByte('\n')
+	}
+	if event.ID != "" {
+		b.WriteString("id: ")
+		b.WriteString(sseFieldReplacer.Replace(event.ID))
+		b.WriteByte('\n')
+	}
+	if event.Retry > 0 {
+		b.WriteString("retry: ")
+		b.WriteString(strconv.FormatInt(event.Retry.Milliseconds(), 10))
+		b.WriteByte('\n')
+	}
+	// Events without data are not dispatched by the client,
+	// so an empty data line is written unless only the ID or the retry time are set
+	if event.Data != "" || event.Event != "" || b.Len() == 0 {
+		for _, line := range splitSSELines(event.Data) {
+			b.WriteString("data: ")
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	b.WriteByte('\n')
+
+	return s.write(b.String())
+}
+
+// Comment writes a comment line, which is ignored by the client, and flushes it.
+func (s *SSEStream) Comment(text string) error {
+	var b strings.Builder
+	for _, line := range splitSSELines(text) {
+		b.WriteString(": ")
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+
+	return s.write(b.String())
+}
+
+// write writes the raw data and flushes it, the stream is closed on the first error
+func (s *SSEStream) write(data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	if _, err := s.w.WriteString(data); err != nil {
+		s.close(err)
+		return err
+	}
+	if err := s.w.Flush(); err != nil {
+		s.close(err)
+		return err
+	}
+
+	return nil
+}
+
+// close stops all writes to the stream and cancels its context, the caller must hold the lock
+func (s *SSEStream) close(err error) {
+	s.err = err
+	s.cancel()
+}
+
+// heartbeat sends a comment in every interval until the context of the stream is done
+func (s *SSEStream) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Comment("heartbeat"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// SSE sets up the response for Server-Sent Events and calls the handler with the stream once the
+// headers have been sent. The handler runs after the request handler returned, so it must not
+// use the Ctx, values that are needed have to be copied before. The stream ends when the handler
+// returns, its context is canceled when the client closes the connection or the server shuts down,
+// so handlers that wait for events should select on it.
+func (c *DefaultCtx) SSE(handler func(stream *SSEStream) error, config ...SSEConfig) error {
+	var cfg SSEConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.HeartbeatInterval == 0 {
+		cfg.HeartbeatInterval = defaultSSEHeartbeatInterval
+	}
+
+	c.fasthttp.Response.Header.SetContentType(MIMETextEventStream)
+	c.fasthttp.Response.Header.Set(HeaderCacheControl, "no-cache")
+	c.fasthttp.Response.Header.Set(HeaderConnection, "keep-alive")
+	// Disables the response buffering of nginx
+	c.fasthttp.Response.Header.Set(HeaderXAccelBuffering, "no")
+
+	// The Ctx is released before the stream writer is called, so the values are copied
+	parent := c.Context()
+	fctx := c.fasthttp
+	lastEventID := c.app.getString(c.fasthttp.Request.Header.Peek(HeaderLastEventID))
+	if c.app.config.Immutable {
+		lastEventID = strings.Clone(lastEventID)
+	}
+
+	c.fasthttp.Response.SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithCancel(parent)
+		stream := &SSEStream{
+			ctx:         ctx,
+			cancel:      cancel,
+			w:           w,
+			lastEventID: lastEventID,
+		}
+
+		// Send the headers before the first event
+		if err := stream.write(""); err != nil {
+			return
+		}
+
+		var wg sync.WaitGroup
+		if cfg.HeartbeatInterval > 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				stream.heartbeat(cfg.HeartbeatInterval)
+			}()
+		}
+
+		// The done channel of the RequestCtx is closed when the server shuts down
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-fctx.Done():
+				stream.cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		conn := fctx.Conn()
+		watchConn := isClientConn(conn)
+		var received atomic.Bool
+		if watchConn {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				received.Store(watchDisconnect(conn, stream.cancel))
+			}()
+		}
+
+		err := handler(stream)
+
+		stream.mu.Lock()
+		disconnected := stream.err != nil
+		stream.close(ErrSSEStreamClosed)
+		stream.mu.Unlock()
+		if watchConn {
+			// Unblock the read of watchDisconnect
+			_ = conn.SetReadDeadline(time.Now()) //nolint:errcheck // The read ends when the connection is closed as well
+		}
+		wg.Wait()
+		if watchConn {
+			_ = conn.SetReadDeadline(time.Time{}) //nolint:errcheck // The server sets the deadline of the next request
+			if received.Load() {
+				// The bytes of the next request were consumed, the connection can't be reused
+				_ = conn.Close() //nolint:errcheck // The connection is not usable
+			}
+		}
+
+		if err != nil && !disconnected && !errors.Is(err, context.Canceled) {
+			log.Errorf("sse: %v", err)
+		}
+	})
+
+	return nil
+}
+
+// isClientConn reports whether the connection belongs to a single client, a read from it detects
+// that the client closed it. The connections of App.Test and of HTTP/2 streams can't be read.
+func isClientConn(conn net.Conn) bool {
+	switch conn.(type) {
+	case nil, *testConn, *http2Conn, *http2TLSConn:
+		return false
+	default:
+		return true
+	}
+}
+
+// watchDisconnect reads from the connection until the client closes it or sends data, which it
+// doesn't while it receives events, then it calls cancel. It returns true if data was received.
+// The read is ended with a read deadline once the stream is closed.
+func watchDisconnect(conn net.Conn, cancel context.CancelFunc) bool {
+	var b [1]byte
+	n, err := conn.Read(b[:])
+	if n == 0 && errors.Is(err, os.ErrDeadlineExceeded) {
+		return false
+	}
+	cancel()
+	return n > 0
+}
+
+// splitSSELines splits the text at CRLF, CR and LF line breaks
+func splitSSELines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	return strings.Split(text, "\n")
+}
+
+// ReadSSEEvents reads the Server-Sent Events from the body until it ends, e.g. from the response of App.Test:
+//
+//	resp, _ := app.Test(httptest.NewRequest(velocity.MethodGet, "/events", nil))
+//	events, err := velocity.ReadSSEEvents(resp.Body)
+//
+// Comments are skipped. Unlike a browser, events without data are returned as well.
+func ReadSSEEvents(body io.Reader) ([]SSEEvent, error) {
+	var (
+		events  []SSEEvent
+		event   SSEEvent
+		data    []string
+		pending bool
+	)
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			// A blank line dispatches the event
+			if pending {
+				event.Data = strings.Join(data, "\n")
+				events = append(events, event)
+			}
+			event, data, pending = SSEEvent{}, nil, false
+			continue
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			data = append(data, value)
+		case "event":
+			event.Event = value
+		case "id":
+			event.ID = value
+		case "retry":
+			ms, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			event.Retry = time.Duration(ms) * time.Millisecond
+		default:
+			continue
+		}
+		pending = true
+	}
+	if err := scanner.Err(); err != nil {
+		return events, fmt.Errorf("sse: failed to read events: %w", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,212 @@
+package velocity
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -run Test_Ctx_SSE
+func Test_Ctx_SSE(t *testing.T) {
+	t.Parallel()
+	app := New()
+
+	app.Get("/", func(c Ctx) error {
+		return c.SSE(func(stream *SSEStream) error {
+			if err := stream.Send(SSEEvent{Data: "hello"}); err != nil {
+				return err
+			}
+			if err := stream.Send(SSEEvent{Event: "update", ID: "2\n", Retry: 3 * time.Second, Data: "line 1\nline 2\r\n line 3"}); err != nil {
+				return err
+			}
+			if err := stream.Comment("note"); err != nil {
+				return err
+			}
+			return stream.Send(SSEEvent{ID: "3"})
+		})
+	})
+
+	resp, err := app.Test(httptest.NewRequest(MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusOK, resp.StatusCode)
+	require.Equal(t, MIMETextEventStream, resp.Header.Get(HeaderContentType))
+	require.Equal(t, "no-cache", resp.Header.Get(HeaderCacheControl))
+	require.Equal(t, "no", resp.Header.Get(HeaderXAccelBuffering))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "data: hello\n\n"+
+		"event: update\nid: 2\nretry: 3000\ndata: line 1\ndata: line 2\ndata:  line 3\n\n"+
+		": note\n\n"+
+		"id: 3\n\n", string(body))
+
+	events, err := ReadSSEEvents(strings.NewReader(string(body)))
+	require.NoError(t, err)
+	require.Equal(t, []SSEEvent{
+		{Data: "hello"},
+		{Event: "update", ID: "2", Retry: 3 * time.Second, Data: "line 1\nline 2\n line 3"},
+		{ID: "3"},
+	}, events)
+}
+
+// go test -run Test_Ctx_SSE_LastEventID
+func Test_Ctx_SSE_LastEventID(t *testing.T) {
+	t.Parallel()
+	app := New()
+
+	app.Get("/", func(c Ctx) error {
+		return c.SSE(func(stream *SSEStream) error {
+			return stream.Send(SSEEvent{Data: "resumed after " + stream.LastEventID()})
+		})
+	})
+
+	req := httptest.NewRequest(MethodGet, "/", nil)
+	req.Header.Set(HeaderLastEventID, "42")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+
+	events, err := ReadSSEEvents(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, []SSEEvent{{Data: "resumed after 42"}}, events)
+}
+
+// go test -run Test_Ctx_SSE_Heartbeat
+func Test_Ctx_SSE_Heartbeat(t *testing.T) {
+	t.Parallel()
+	app := New()
+
+	app.Get("/", func(c Ctx) error {
+		return c.SSE(func(stream *SSEStream) error {
+			time.Sleep(50 * time.Millisecond)
+			return stream.Send(SSEEvent{Data: "done"})
+		}, SSEConfig{HeartbeatInterval: 10 * time.Millisecond})
+	})
+
+	resp, err := app.Test(httptest.NewRequest(MethodGet, "/", nil))
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), ": heartbeat\n\n")
+	require.True(t, strings.HasSuffix(string(body), "data: done\n\n"))
+}
+
+// startSSETestApp serves the app on a local listener and returns the address
+func startSSETestApp(t *testing.T, app *App) string {
+	t.Helper()
+	ln, err := net.Listen(NetworkTCP4, "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		assert.NoError(t, app.Listener(ln, ListenConfig{DisableStartupMessage: true}))
+	}()
+	return ln.Addr().String()
+}
+
+// go test -run Test_Ctx_SSE_Disconnect
+func Test_Ctx_SSE_Disconnect(t *testing.T) {
+	t.Parallel()
+	app := New()
+
+	done := make(chan error, 1)
+	app.Get("/", func(c Ctx) error {
+		return c.SSE(func(stream *SSEStream) error {
+			if err := stream.Send(SSEEvent{Data: "hello"}); err != nil {
+				return err
+			}
+			<-stream.Context().Done()
+			done <- stream.Context().Err()
+			return nil
+		}, SSEConfig{HeartbeatInterval: time.Minute})
+	})
+	addr := startSSETestApp(t, app)
+	t.Cleanup(func() {
+		require.NoError(t, app.Shutdown())
+	})
+
+	conn, err := net.Dial(NetworkTCP4, addr)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/1.1 200 OK\r\n", line)
+	require.NoError(t, conn.Close())
+
+	// The context is canceled long before the first heartbeat would fail
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the context of the stream was not canceled")
+	}
+}
+
+// go test -run Test_Ctx_SSE_Shutdown
+func Test_Ctx_SSE_Shutdown(t *testing.T) {
+	t.Parallel()
+	app := New()
+
+	app.Get("/", func(c Ctx) error {
+		return c.SSE(func(stream *SSEStream) error {
+			if err := stream.Send(SSEEvent{Event: "ready"}); err != nil {
+				return err
+			}
+			<-stream.Context().Done()
+			return stream.Send(SSEEvent{Event: "shutdown"})
+		}, SSEConfig{HeartbeatInterval: -1})
+	})
+	addr := startSSETestApp(t, app)
+
+	resp, err := http.Get("http://" + addr + "/") //nolint:noctx // test
+	require.NoError(t, err)
+	defer resp.Body.Close() //nolint:errcheck // test
+
+	require.NoError(t, app.ShutdownWithTimeout(5*time.Second))
+	events, err := ReadSSEEvents(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, []SSEEvent{{Event: "ready"}, {Event: "shutdown"}}, events)
+}
+
+// go test -run Test_ReadSSEEvents
+func Test_ReadSSEEvents(t *testing.T) {
+	t.Parallel()
+
+	events, err := ReadSSEEvents(strings.NewReader(": comment\n\n" +
+		"data:no space\nunknown: field\n\n" +
+		"retry: invalid\nevent: ping\n\n" +
+		"data: incomplete"))
+	require.NoError(t, err)
+	require.Equal(t, []SSEEvent{
+		{Data: "no space"},
+		{Event: "ping"},
+	}, events)
+}
+
+// go test -v -run=^$ -bench=Benchmark_SSEStream_Send -benchmem -count=4
+func Benchmark_SSEStream_Send(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &SSEStream{
+		ctx:    ctx,
+		cancel: cancel,
+		w:      bufio.NewWriter(io.Discard),
+	}
+	event := SSEEvent{Event: "update", ID: "1", Data: "line 1\nline 2"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := stream.Send(event); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
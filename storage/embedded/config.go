@@ -0,0 +1,58 @@
+package embedded
+
+import (
+	"time"
+)
+
+// Config defines the config for storage.
+type Config struct {
+	// Path of the database file, it is created if it does not exist
+	//
+	// Default is "./velocity.db"
+	Path string
+
+	// Time before deleting expired keys, the file is compacted afterwards
+	// if most of it is taken by deleted and overwritten values
+	//
+	// Default is 10 * time.Second
+	GCInterval time.Duration
+
+	// Reset deletes the existing keys when the storage is created
+	//
+	// Default is false
+	Reset bool
+
+	// SyncWrites flushes every write to the disk before it returns, so that
+	// no write is lost if the machine crashes, at the cost of the write speed
+	//
+	// Default is false
+	SyncWrites bool
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Path:       "./velocity.db",
+	GCInterval: 10 * time.Second,
+	Reset:      false,
+	SyncWrites: false,
+}
+
+// configDefault is a helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.Path == "" {
+		cfg.Path = ConfigDefault.Path
+	}
+	if int(cfg.GCInterval.Seconds()) <= 0 {
+		cfg.GCInterval = ConfigDefault.GCInterval
+	}
+	return cfg
+}
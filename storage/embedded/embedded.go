@@ -0,0 +1,510 @@
+// Package embedded is a storage that keeps all keys in a single file. The writes are appended
+// to the file as records and an index of the keys is kept in memory, so that a value is read
+// with a single read. The file is compacted when most of it is taken by deleted and overwritten
+// values. The file must only be opened by one process at a time.
+package embedded
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/utils"
+)
+
+// ErrNotInteger is returned by Incr if the value of the key is not an integer
+var ErrNotInteger = errors.New("embedded: value is not an integer")
+
+var _ velocity.AtomicStorage = (*Storage)(nil)
+
+// A record is
+//
+//	crc32 (4) | op (1) | expiration (8) | key length (4) | value length (4) | key | value
+//
+// the checksum covers the rest of the record, the expiration is in Unix nanoseconds, 0 means no expiration
+const recordHeaderSize = 21
+
+// The operations of the records
+const (
+	opSet    byte = 1
+	opDelete byte = 2
+)
+
+// minCompactSize is the amount of garbage in bytes below which the file is never compacted
+const minCompactSize = 1 << 20
+
+// Storage interface that is implemented by storage providers
+type Storage struct {
+	file       *os.File
+	index      map[string]location
+	done       chan struct{}
+	path       string
+	size       int64 // offset of the next record
+	garbage    int64 // bytes of the records that are deleted, overwritten or expired
+	gcInterval time.Duration
+	syncWrites bool
+	mux        sync.RWMutex
+}
+
+// location is the position of the record of a key in the file
+type location struct {
+	offset int64
+	expire int64
+	keyLen uint32
+	valLen uint32
+}
+
+// size returns the size of the record
+func (l location) size() int64 {
+	return recordHeaderSize + int64(l.keyLen) + int64(l.valLen)
+}
+
+// expired reports whether the key has expired at now
+func (l location) expired(now int64) bool {
+	return l.expire != 0 && l.expire <= now
+}
+
+// New opens or creates the database file, it panics if the file can't be opened
+func New(config ...Config) *Storage {
+	// Set default config
+	cfg := configDefault(config...)
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o700); err != nil {
+		panic(fmt.Errorf("embedded: failed to create directory: %w", err))
+	}
+	file, err := os.OpenFile(cfg.Path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		panic(fmt.Errorf("embedded: failed to open file: %w", err))
+	}
+
+	// Create storage
+	store := &Storage{
+		file:       file,
+		index:      make(map[string]location),
+		done:       make(chan struct{}),
+		path:       cfg.Path,
+		gcInterval: cfg.GCInterval,
+		syncWrites: cfg.SyncWrites,
+	}
+
+	if err := store.load(); err != nil {
+		_ = file.Close() //nolint:errcheck // The load error is more relevant
+		panic(err)
+	}
+	if cfg.Reset {
+		if err := store.Reset(); err != nil {
+			_ = file.Close() //nolint:errcheck // The reset error is more relevant
+			panic(err)
+		}
+	}
+
+	// Start garbage collector
+	go store.gc()
+
+	return store
+}
+
+// Get value by key
+func (s *Storage) Get(key string) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, nil
+	}
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	loc, ok := s.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	return s.read(loc)
+}
+
+// Set key with value
+func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
+	// Ain't Nobody Got Time For That
+	if len(key) == 0 || len(val) == 0 {
+		return nil
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.set(key, val, expiry(exp))
+}
+
+// Incr increments the integer value of key by delta, a new key gets the expiration
+func (s *Storage) Incr(key string, delta int64, exp time.Duration) (int64, error) {
+	if len(key) == 0 {
+		return 0, nil
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	loc, ok := s.lookup(key)
+	if !ok {
+		return delta, s.set(key, strconv.AppendInt(nil, delta, 10), expiry(exp))
+	}
+
+	val, err := s.read(loc)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(utils.UnsafeString(val), 10, 64)
+	if err != nil {
+		return 0, ErrNotInteger
+	}
+	n += delta
+	return n, s.set(key, strconv.AppendInt(nil, n, 10), loc.expire)
+}
+
+// CompareAndSwap sets the value of key to newVal if the value equals oldVal, a nil oldVal matches a missing key
+func (s *Storage) CompareAndSwap(key string, oldVal, newVal []byte, exp time.Duration) (bool, error) {
+	if len(key) == 0 {
+		return false, nil
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	loc, ok := s.lookup(key)
+	if oldVal == nil && ok || oldVal != nil && !ok {
+		return false, nil
+	}
+	if ok {
+		val, err := s.read(loc)
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(val, oldVal) {
+			return false, nil
+		}
+	}
+
+	// An empty value deletes the key
+	if len(newVal) == 0 {
+		return true, s.delete(key)
+	}
+	return true, s.set(key, newVal, expiry(exp))
+}
+
+// SetIfAbsent stores the value if key does not exist
+func (s *Storage) SetIfAbsent(key string, val []byte, exp time.Duration) (bool, error) {
+	// Ain't Nobody Got Time For That
+	if len(key) == 0 || len(val) == 0 {
+		return false, nil
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.lookup(key); ok {
+		return false, nil
+	}
+	return true, s.set(key, val, expiry(exp))
+}
+
+// Delete key by key
+func (s *Storage) Delete(key string) error {
+	// Ain't Nobody Got Time For That
+	if len(key) == 0 {
+		return nil
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.delete(key)
+}
+
+// Reset all keys
+func (s *Storage) Reset() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := s.file.Truncate(0); err != nil {
+		return fmt.Errorf("embedded: failed to truncate file: %w", err)
+	}
+	s.index = make(map[string]location)
+	s.size = 0
+	s.garbage = 0
+	return s.sync()
+}
+
+// Close the embedded storage, the writes are flushed to the disk
+func (s *Storage) Close() error {
+	close(s.done)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("embedded: failed to sync file: %w", err)
+	}
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("embedded: failed to close file: %w", err)
+	}
+	return nil
+}
+
+// Compact rewrites the file with only the current values of the keys, the space of the
+// deleted, overwritten and expired values is freed. It is called by the garbage collector
+// if most of the file is garbage.
+func (s *Storage) Compact() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.compact()
+}
+
+// lookup returns the location of key if it exists and is not expired, s.mux has to be held
+func (s *Storage) lookup(key string) (location, bool) {
+	loc, ok := s.index[key]
+	if !ok || loc.expired(time.Now().UnixNano()) {
+		return location{}, false
+	}
+	return loc, true
+}
+
+// read reads the value of the record at the location
+func (s *Storage) read(loc location) ([]byte, error) {
+	val := make([]byte, loc.valLen)
+	if _, err := s.file.ReadAt(val, loc.offset+recordHeaderSize+int64(loc.keyLen)); err != nil {
+		return nil, fmt.Errorf("embedded: failed to read value: %w", err)
+	}
+	return val, nil
+}
+
+// set appends a record that sets the value of key, s.mux has to be locked
+func (s *Storage) set(key string, val []byte, expire int64) error {
+	loc, err := s.append(opSet, key, val, expire)
+	if err != nil {
+		return err
+	}
+	if old, ok := s.index[key]; ok {
+		s.garbage += old.size()
+	}
+	s.index[utils.CopyString(key)] = loc
+	return nil
+}
+
+// delete appends a record that deletes key if it is stored, s.mux has to be locked
+func (s *Storage) delete(key string) error {
+	old, ok := s.index[key]
+	if !ok {
+		return nil
+	}
+	loc, err := s.append(opDelete, key, nil, 0)
+	if err != nil {
+		return err
+	}
+	s.garbage += old.size() + loc.size()
+	delete(s.index, key)
+	return nil
+}
+
+// append writes a record to the end of the file, s.mux has to be locked
+func (s *Storage) append(op byte, key string, val []byte, expire int64) (location, error) {
+	record := encodeRecord(nil, op, key, val, expire)
+	if _, err := s.file.WriteAt(record, s.size); err != nil {
+		// Remove the partially written record, the next load would discard it anyway
+		_ = s.file.Truncate(s.size) //nolint:errcheck // The write error is more relevant
+		return location{}, fmt.Errorf("embedded: failed to write record: %w", err)
+	}
+	if err := s.sync(); err != nil {
+		return location{}, err
+	}
+	loc := location{offset: s.size, expire: expire, keyLen: uint32(len(key)), valLen: uint32(len(val))} //nolint:gosec // Not a concern
+	s.size += int64(len(record))
+	return loc, nil
+}
+
+// sync flushes the file to the disk if SyncWrites is enabled
+func (s *Storage) sync() error {
+	if !s.syncWrites {
+		return nil
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("embedded: failed to sync file: %w", err)
+	}
+	return nil
+}
+
+// load builds the index from the records of the file. The file is truncated at the first
+// incomplete or corrupted record, which is left by a crash during a write.
+func (s *Storage) load() error {
+	info, err := s.file.Stat()
+	if err != nil {
+		return fmt.Errorf("embedded: failed to stat file: %w", err)
+	}
+	fileSize := info.Size()
+
+	reader := bufio.NewReader(io.NewSectionReader(s.file, 0, fileSize))
+	header := make([]byte, recordHeaderSize)
+	var data []byte
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			break
+		}
+		loc := location{
+			offset: s.size,
+			expire: int64(binary.BigEndian.Uint64(header[5:13])), //nolint:gosec // Not a concern
+			keyLen: binary.BigEndian.Uint32(header[13:17]),
+			valLen: binary.BigEndian.Uint32(header[17:21]),
+		}
+		op := header[4]
+		if loc.keyLen == 0 || s.size+loc.size() > fileSize || (op != opSet && op != opDelete) {
+			break
+		}
+		if n := int(loc.keyLen) + int(loc.valLen); cap(data) < n {
+			data = make([]byte, n)
+		} else {
+			data = data[:n]
+		}
+		if _, err := io.ReadFull(reader, data); err != nil {
+			break
+		}
+		checksum := crc32.NewIEEE()
+		_, _ = checksum.Write(header[4:]) //nolint:errcheck // A hash never returns an error
+		_, _ = checksum.Write(data)       //nolint:errcheck // A hash never returns an error
+		if checksum.Sum32() != binary.BigEndian.Uint32(header[:4]) {
+			break
+		}
+
+		key := string(data[:loc.keyLen])
+		if old, ok := s.index[key]; ok {
+			s.garbage += old.size()
+		}
+		if op == opSet {
+			s.index[key] = loc
+		} else {
+			s.garbage += loc.size()
+			delete(s.index, key)
+		}
+		s.size += loc.size()
+	}
+
+	if s.size < fileSize {
+		if err := s.file.Truncate(s.size); err != nil {
+			return fmt.Errorf("embedded: failed to truncate file: %w", err)
+		}
+	}
+
+	now := time.Now().UnixNano()
+	for key, loc := range s.index {
+		if loc.expired(now) {
+			s.garbage += loc.size()
+			delete(s.index, key)
+		}
+	}
+	return nil
+}
+
+// compact writes the current values to a new file that replaces the file, s.mux has to be locked
+func (s *Storage) compact() error {
+	tmpPath := s.path + ".compact"
+	file, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600) //nolint:gosec // The path is configured
+	if err != nil {
+		return fmt.Errorf("embedded: failed to create file: %w", err)
+	}
+
+	index := make(map[string]location, len(s.index))
+	writer := bufio.NewWriter(file)
+	now := time.Now().UnixNano()
+	var size int64
+	var record []byte
+	err = func() error {
+		for key, loc := range s.index {
+			if loc.expired(now) {
+				continue
+			}
+			val, err := s.read(loc)
+			if err != nil {
+				return err
+			}
+			record = encodeRecord(record[:0], opSet, key, val, loc.expire)
+			if _, err := writer.Write(record); err != nil {
+				return fmt.Errorf("embedded: failed to write record: %w", err)
+			}
+			loc.offset = size
+			index[key] = loc
+			size += int64(len(record))
+		}
+		if err := writer.Flush(); err != nil {
+			return fmt.Errorf("embedded: failed to write record: %w", err)
+		}
+		if err := file.Sync(); err != nil {
+			return fmt.Errorf("embedded: failed to sync file: %w", err)
+		}
+		if err := os.Rename(tmpPath, s.path); err != nil {
+			return fmt.Errorf("embedded: failed to replace file: %w", err)
+		}
+		return nil
+	}()
+	if err != nil {
+		_ = file.Close()       //nolint:errcheck // The compaction error is more relevant
+		_ = os.Remove(tmpPath) //nolint:errcheck // The compaction error is more relevant
+		return err
+	}
+
+	_ = s.file.Close() //nolint:errcheck // The file was replaced
+	s.file = file
+	s.index = index
+	s.size = size
+	s.garbage = 0
+	return nil
+}
+
+// encodeRecord appends the record to dst
+func encodeRecord(dst []byte, op byte, key string, val []byte, expire int64) []byte {
+	start := len(dst)
+	dst = append(dst, make([]byte, recordHeaderSize)...)
+	header := dst[start:]
+	header[4] = op
+	binary.BigEndian.PutUint64(header[5:13], uint64(expire))    //nolint:gosec // Not a concern
+	binary.BigEndian.PutUint32(header[13:17], uint32(len(key))) //nolint:gosec // Not a concern
+	binary.BigEndian.PutUint32(header[17:21], uint32(len(val))) //nolint:gosec // Not a concern
+	dst = append(dst, key...)
+	dst = append(dst, val...)
+	binary.BigEndian.PutUint32(dst[start:], crc32.ChecksumIEEE(dst[start+4:]))
+	return dst
+}
+
+// expiry returns the time in Unix nanoseconds when a key with the expiration expires
+func expiry(exp time.Duration) int64 {
+	if exp == 0 {
+		return 0
+	}
+	return time.Now().Add(exp).UnixNano()
+}
+
+func (s *Storage) gc() {
+	ticker := time.NewTicker(s.gcInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			now := time.Now().UnixNano()
+			s.mux.Lock()
+			for key, loc := range s.index {
+				if loc.expired(now) {
+					s.garbage += loc.size()
+					delete(s.index, key)
+				}
+			}
+			// Compact the file if most of it is garbage
+			if s.garbage >= minCompactSize && s.garbage > s.size-s.garbage {
+				_ = s.compact() //nolint:errcheck // It is retried in the next run
+			}
+			s.mux.Unlock()
+		}
+	}
+}
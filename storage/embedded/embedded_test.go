@@ -0,0 +1,143 @@
+package embedded
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+// requireGet asserts the value of the key, nil asserts that the key does not exist
+func requireGet(t *testing.T, store *Storage, key string, expected []byte) {
+	t.Helper()
+	val, err := store.Get(key)
+	require.NoError(t, err)
+	require.Equal(t, expected, val, key)
+}
+
+// go test -run Test_Storage_Embedded
+func Test_Storage_Embedded(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestStorage(t, func() velocity.Storage {
+		return New(Config{Path: filepath.Join(t.TempDir(), "velocity.db")})
+	})
+}
+
+// go test -run Test_Storage_Embedded_Reopen
+func Test_Storage_Embedded_Reopen(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "velocity.db")
+
+	store := New(Config{Path: path, SyncWrites: true})
+	require.NoError(t, store.Set("john", []byte("doe"), 0))
+	require.NoError(t, store.Set("jane", []byte("doe"), time.Hour))
+	require.NoError(t, store.Set("jane", []byte("smith"), time.Hour))
+	require.NoError(t, store.Set("max", []byte("doe"), 0))
+	require.NoError(t, store.Delete("max"))
+	_, err := store.Incr("counter", 2, 0)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	// The keys survive a restart
+	store = New(Config{Path: path})
+	requireGet(t, store, "john", []byte("doe"))
+	requireGet(t, store, "jane", []byte("smith"))
+	requireGet(t, store, "max", nil)
+	requireGet(t, store, "counter", []byte("2"))
+	require.NoError(t, store.Close())
+
+	// Reset deletes them
+	store = New(Config{Path: path, Reset: true})
+	requireGet(t, store, "john", nil)
+	require.NoError(t, store.Close())
+}
+
+// go test -run Test_Storage_Embedded_Recovery
+func Test_Storage_Embedded_Recovery(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "velocity.db")
+
+	store := New(Config{Path: path})
+	require.NoError(t, store.Set("john", []byte("doe"), 0))
+	require.NoError(t, store.Set("jane", []byte("doe"), 0))
+	size := store.size
+	require.NoError(t, store.Close())
+
+	// A crash during a write leaves a partial record at the end of the file
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	record := encodeRecord(nil, opSet, "max", []byte("doe"), 0)
+	require.NoError(t, os.WriteFile(path, append(data, record[:len(record)-1]...), 0o600))
+
+	store = New(Config{Path: path})
+	requireGet(t, store, "john", []byte("doe"))
+	requireGet(t, store, "jane", []byte("doe"))
+	requireGet(t, store, "max", nil)
+	require.Equal(t, size, store.size)
+
+	// The file was truncated, so the next records can be read
+	require.NoError(t, store.Set("max", []byte("doe"), 0))
+	require.NoError(t, store.Close())
+
+	// A corrupted record and the records after it are discarded
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	data[size-1] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	store = New(Config{Path: path})
+	requireGet(t, store, "john", []byte("doe"))
+	requireGet(t, store, "jane", nil)
+	requireGet(t, store, "max", nil)
+	require.NoError(t, store.Close())
+}
+
+// go test -run Test_Storage_Embedded_Compact
+func Test_Storage_Embedded_Compact(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "velocity.db")
+
+	store := New(Config{Path: path})
+	for i := 0; i < 100; i++ {
+		require.NoError(t, store.Set("john", []byte("doe "+strconv.Itoa(i)), 0))
+		require.NoError(t, store.Set("key "+strconv.Itoa(i), []byte("doe"), 0))
+		require.NoError(t, store.Delete("key "+strconv.Itoa(i)))
+	}
+	require.NoError(t, store.Set("jane", []byte("doe"), time.Hour))
+	require.NoError(t, store.Set("max", []byte("doe"), time.Nanosecond))
+
+	before, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Compact())
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Less(t, after.Size(), before.Size()/10)
+	require.Equal(t, after.Size(), store.size)
+	require.Zero(t, store.garbage)
+
+	requireGet(t, store, "john", []byte("doe 99"))
+	requireGet(t, store, "jane", []byte("doe"))
+	requireGet(t, store, "max", nil)
+
+	// The compacted file is used for the next writes
+	require.NoError(t, store.Set("key", []byte("doe"), 0))
+	require.NoError(t, store.Close())
+
+	store = New(Config{Path: path})
+	requireGet(t, store, "john", []byte("doe 99"))
+	requireGet(t, store, "jane", []byte("doe"))
+	requireGet(t, store, "key", []byte("doe"))
+	require.NoError(t, store.Close())
+}
+
+func Benchmark_Storage_Embedded(b *testing.B) {
+	storagetest.BenchmarkStorage(b, func() velocity.Storage {
+		return New(Config{Path: filepath.Join(b.TempDir(), "velocity.db")})
+	})
+}
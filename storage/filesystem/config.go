@@ -0,0 +1,50 @@
+package filesystem
+
+import (
+	"time"
+)
+
+// Config defines the config for storage.
+type Config struct {
+	// Directory the files of the keys are stored in, it is created if it does not exist
+	//
+	// Default is "./velocity_storage"
+	Root string
+
+	// Time before deleting expired keys
+	//
+	// Default is 10 * time.Second
+	GCInterval time.Duration
+
+	// Reset deletes the existing keys when the storage is created
+	//
+	// Default is false
+	Reset bool
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Root:       "./velocity_storage",
+	GCInterval: 10 * time.Second,
+	Reset:      false,
+}
+
+// configDefault is a helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.Root == "" {
+		cfg.Root = ConfigDefault.Root
+	}
+	if int(cfg.GCInterval.Seconds()) <= 0 {
+		cfg.GCInterval = ConfigDefault.GCInterval
+	}
+	return cfg
+}
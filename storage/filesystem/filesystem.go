@@ -0,0 +1,264 @@
+// Package filesystem is a storage that stores each key in its own file in a directory.
+// The keys survive a restart of the application, the expired keys are deleted by a
+// garbage collector.
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// headerSize is the size of the expiration time in front of the value of a file
+const headerSize = 8
+
+// tempPrefix is the prefix of the files that are written before they replace the file of a key
+const tempPrefix = ".tmp-"
+
+var _ velocity.Storage = (*Storage)(nil)
+
+// Storage interface that is implemented by storage providers
+type Storage struct {
+	done       chan struct{}
+	root       string
+	gcInterval time.Duration
+	// Get, Set and Delete hold a read lock, so that the garbage collector
+	// doesn't delete a file that was replaced after it found it expired
+	mux sync.RWMutex
+}
+
+// New creates a new filesystem storage, it panics if the directory can't be created
+func New(config ...Config) *Storage {
+	// Set default config
+	cfg := configDefault(config...)
+
+	if err := os.MkdirAll(cfg.Root, 0o700); err != nil {
+		panic(fmt.Errorf("filesystem: failed to create directory: %w", err))
+	}
+
+	// Create storage
+	store := &Storage{
+		root:       cfg.Root,
+		gcInterval: cfg.GCInterval,
+		done:       make(chan struct{}),
+	}
+
+	if cfg.Reset {
+		if err := store.Reset(); err != nil {
+			panic(err)
+		}
+	}
+
+	// Start garbage collector
+	go store.gc()
+
+	return store
+}
+
+// Get value by key
+func (s *Storage) Get(key string) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, nil
+	}
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("filesystem: failed to read file: %w", err)
+	}
+	if len(data) < headerSize || expired(data, time.Now()) {
+		return nil, nil
+	}
+	return data[headerSize:], nil
+}
+
+// Set key with value
+func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
+	// Ain't Nobody Got Time For That
+	if len(key) == 0 || len(val) == 0 {
+		return nil
+	}
+
+	var expire int64
+	if exp != 0 {
+		expire = time.Now().Add(exp).UnixNano()
+	}
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	// The value is written to a temporary file that replaces the file of the key,
+	// so that a concurrent Get never reads a partially written value
+	file, err := os.CreateTemp(s.root, tempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("filesystem: failed to create file: %w", err)
+	}
+	var header [headerSize]byte
+	binary.BigEndian.PutUint64(header[:], uint64(expire)) //nolint:gosec // Not a concern
+	_, err = file.Write(header[:])
+	if err == nil {
+		_, err = file.Write(val)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), s.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(file.Name()) //nolint:errcheck // The write error is more relevant
+		return fmt.Errorf("filesystem: failed to write file: %w", err)
+	}
+	return nil
+}
+
+// Delete key by key
+func (s *Storage) Delete(key string) error {
+	// Ain't Nobody Got Time For That
+	if len(key) == 0 {
+		return nil
+	}
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("filesystem: failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// Reset all keys, the files in the directory that don't belong to the storage are kept
+func (s *Storage) Reset() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	names, err := s.files()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := os.Remove(filepath.Join(s.root, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("filesystem: failed to delete file: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close the filesystem storage, the files are kept
+func (s *Storage) Close() error {
+	close(s.done)
+	return nil
+}
+
+// Conn returns the directory of the storage
+func (s *Storage) Conn() string {
+	return s.root
+}
+
+// path returns the path of the file of key, the name is derived from the key
+// so that keys with any characters can be stored
+func (s *Storage) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.root, hex.EncodeToString(sum[:]))
+}
+
+// files returns the names of the files of the storage in the directory
+func (s *Storage) files() ([]string, error) {
+	entries, err := os.ReadDir(s.root)
+	if err != nil {
+		return nil, fmt.Errorf("filesystem: failed to read directory: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && (isKeyFile(entry.Name()) || strings.HasPrefix(entry.Name(), tempPrefix)) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// isKeyFile reports whether name is the name of the file of a key, see path
+func isKeyFile(name string) bool {
+	if len(name) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// expired reports whether the file content has expired at now
+func expired(data []byte, now time.Time) bool {
+	expire := int64(binary.BigEndian.Uint64(data)) //nolint:gosec // Not a concern
+	return expire != 0 && expire <= now.UnixNano()
+}
+
+// readHeader returns the header of the file, false if it can't be read
+func readHeader(path string) ([]byte, bool) {
+	file, err := os.Open(path) //nolint:gosec // The path is built by the storage
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close() //nolint:errcheck // The file is only read
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, false
+	}
+	return header, true
+}
+
+func (s *Storage) gc() {
+	ticker := time.NewTicker(s.gcInterval)
+	defer ticker.Stop()
+	var expiredNames []string
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			expiredNames = expiredNames[:0]
+			names, err := s.files()
+			if err != nil {
+				continue
+			}
+			for _, name := range names {
+				if !isKeyFile(name) {
+					continue
+				}
+				if header, ok := readHeader(filepath.Join(s.root, name)); ok && expired(header, now) {
+					expiredNames = append(expiredNames, name)
+				}
+			}
+			if len(expiredNames) == 0 {
+				continue
+			}
+			s.mux.Lock()
+			// Double-checked locking.
+			// We might have replaced the file in the meantime.
+			for _, name := range expiredNames {
+				path := filepath.Join(s.root, name)
+				if header, ok := readHeader(path); ok && expired(header, now) {
+					_ = os.Remove(path) //nolint:errcheck // It is deleted in the next run
+				}
+			}
+			s.mux.Unlock()
+		}
+	}
+}
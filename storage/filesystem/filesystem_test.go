@@ -0,0 +1,79 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -run Test_Storage_Filesystem
+func Test_Storage_Filesystem(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestStorage(t, func() velocity.Storage {
+		return New(Config{Root: t.TempDir()})
+	})
+}
+
+// go test -run Test_Storage_Filesystem_Reopen
+func Test_Storage_Filesystem_Reopen(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+
+	store := New(Config{Root: root})
+	require.NoError(t, store.Set("john", []byte("doe"), 0))
+	require.NoError(t, store.Set("jane", []byte("doe"), time.Hour))
+	require.NoError(t, store.Close())
+
+	// The keys survive a restart
+	store = New(Config{Root: root})
+	val, err := store.Get("john")
+	require.NoError(t, err)
+	require.Equal(t, []byte("doe"), val)
+	val, err = store.Get("jane")
+	require.NoError(t, err)
+	require.Equal(t, []byte("doe"), val)
+	require.NoError(t, store.Close())
+
+	// Reset deletes them
+	store = New(Config{Root: root, Reset: true})
+	val, err = store.Get("john")
+	require.NoError(t, err)
+	require.Nil(t, val)
+	require.NoError(t, store.Close())
+}
+
+// go test -run Test_Storage_Filesystem_GC
+func Test_Storage_Filesystem_GC(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+
+	// The files that don't belong to the storage are kept
+	require.NoError(t, os.WriteFile(filepath.Join(root, "other"), []byte("other"), 0o600))
+
+	store := New(Config{Root: root, GCInterval: time.Second})
+	defer store.Close() //nolint:errcheck // It is a test
+	require.NoError(t, store.Set("john", []byte("doe"), time.Second))
+	require.NoError(t, store.Set("jane", []byte("doe"), 0))
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(store.path("john"))
+		return os.IsNotExist(err)
+	}, 5*time.Second, 100*time.Millisecond)
+	require.FileExists(t, store.path("jane"))
+
+	require.NoError(t, store.Reset())
+	require.NoFileExists(t, store.path("jane"))
+	require.FileExists(t, filepath.Join(root, "other"))
+}
+
+func Benchmark_Storage_Filesystem(b *testing.B) {
+	storagetest.BenchmarkStorage(b, func() velocity.Storage {
+		return New(Config{Root: b.TempDir()})
+	})
+}
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"time"
+)
+
+// Policy decides which key is evicted when the storage is full
+type Policy int
+
+const (
+	// LRU evicts the least recently used key
+	LRU Policy = iota
+	// LFU evicts the least frequently used key, the least recently used one of them if several
+	// keys were used equally often
+	LFU
+)
+
+// Config defines the config for storage.
+type Config struct {
+	// Time before deleting expired keys
+	//
+	// Default is 10 * time.Second
+	GCInterval time.Duration
+
+	// MaxEntries is the maximum number of keys, 0 means no limit. A key is evicted
+	// according to the Policy when a new key would exceed it.
+	//
+	// Default is 0
+	MaxEntries int
+
+	// MaxBytes is the maximum size of the keys and values in bytes, 0 means no limit.
+	// Keys are evicted according to the Policy until a new value fits.
+	//
+	// Default is 0
+	MaxBytes int
+
+	// Policy decides which key is evicted when a limit is reached
+	//
+	// Default is LRU
+	Policy Policy
+
+	// Shards is the number of shards the keys are split into, each with its own lock,
+	// to reduce the contention of concurrent requests. Every shard gets an equal share
+	// of the limits, so keys may be evicted before a limit is reached if they are not
+	// spread evenly. There are never more shards than keys or bytes in the limits.
+	//
+	// Default is 16
+	Shards int
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	GCInterval: 10 * time.Second,
+	MaxEntries: 0,
+	MaxBytes:   0,
+	Policy:     LRU,
+	Shards:     16,
+}
+
+// configDefault is a helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if int(cfg.GCInterval.Seconds()) <= 0 {
+		cfg.GCInterval = ConfigDefault.GCInterval
+	}
+	if cfg.MaxEntries < 0 {
+		cfg.MaxEntries = ConfigDefault.MaxEntries
+	}
+	if cfg.MaxBytes < 0 {
+		cfg.MaxBytes = ConfigDefault.MaxBytes
+	}
+	if cfg.Policy != LRU && cfg.Policy != LFU {
+		cfg.Policy = ConfigDefault.Policy
+	}
+	if cfg.Shards <= 0 {
+		cfg.Shards = ConfigDefault.Shards
+	}
+	// Every shard has to hold at least one key and one byte, a limit of 0 is unlimited
+	if cfg.MaxEntries > 0 {
+		cfg.Shards = min(cfg.Shards, cfg.MaxEntries)
+	}
+	if cfg.MaxBytes > 0 {
+		cfg.Shards = min(cfg.Shards, cfg.MaxBytes)
+	}
+	return cfg
+}
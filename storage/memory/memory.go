@@ -0,0 +1,346 @@
+// Package memory is a storage that keeps the keys in the memory of the process. Its size can be
+// limited by the number of keys and the bytes of the keys and values, the keys are then evicted
+// by a LRU or LFU policy. The keys are split into shards with their own locks.
+package memory
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/utils"
+)
+
+// ErrNotInteger is returned by Incr if the value of the key is not an integer
+var ErrNotInteger = errors.New("memory: value is not an integer")
+
+// ErrTooLarge is returned if a key and value are larger than the MaxBytes of a shard
+var ErrTooLarge = errors.New("memory: value is too large")
+
+var _ velocity.AtomicStorage = (*Storage)(nil)
+
+// Storage interface that is implemented by storage providers
+type Storage struct {
+	done       chan struct{}
+	shards     []*shard
+	gcInterval time.Duration
+}
+
+// Stats are the counters of a storage
+type Stats struct {
+	// Hits is the number of Get calls that found the key
+	Hits uint64
+	// Misses is the number of Get calls that did not find the key
+	Misses uint64
+	// Evictions is the number of keys that were evicted to respect the limits
+	Evictions uint64
+	// Entries is the number of stored keys, including the expired ones that were not deleted yet
+	Entries int
+	// Bytes is the size of the stored keys and values
+	Bytes int
+}
+
+// shard is a part of the keys with its own lock
+type shard struct {
+	entries    map[string]*entry
+	policy     policy
+	maxEntries int
+	maxBytes   int
+	bytes      int
+	hits       uint64
+	misses     uint64
+	evictions  uint64
+	mux        sync.Mutex
+}
+
+type entry struct {
+	prev, next *entry // lru
+	key        string
+	val        []byte
+	index      int    // lfu
+	uses       uint64 // lfu
+	used       uint64 // lfu
+	// max value is 4294967295 -> Sun Feb 07 2106 06:28:15 GMT+0000
+	expiry uint32
+}
+
+// size returns the bytes that the entry counts towards MaxBytes
+func (e *entry) size() int {
+	return len(e.key) + len(e.val)
+}
+
+// expired reports whether the entry has expired at the timestamp
+func (e *entry) expired(ts uint32) bool {
+	return e.expiry != 0 && e.expiry <= ts
+}
+
+// New creates a new memory storage
+func New(config ...Config) *Storage {
+	// Set default config
+	cfg := configDefault(config...)
+
+	// Create storage
+	store := &Storage{
+		shards:     make([]*shard, cfg.Shards),
+		gcInterval: cfg.GCInterval,
+		done:       make(chan struct{}),
+	}
+	for i := range store.shards {
+		store.shards[i] = &shard{
+			entries:    make(map[string]*entry),
+			policy:     newPolicy(cfg.Policy),
+			maxEntries: share(cfg.MaxEntries, cfg.Shards, i),
+			maxBytes:   share(cfg.MaxBytes, cfg.Shards, i),
+		}
+	}
+
+	// Start garbage collector
+	utils.StartTimeStampUpdater()
+	go store.gc()
+
+	return store
+}
+
+// share returns the part of the limit of the i-th of n shards
+func share(limit, n, i int) int {
+	part := limit / n
+	if i < limit%n {
+		part++
+	}
+	return part
+}
+
+// Get value by key
+func (s *Storage) Get(key string) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, nil
+	}
+	sh := s.shard(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+
+	e, ok := sh.lookup(key)
+	if !ok {
+		sh.misses++
+		return nil, nil
+	}
+	sh.hits++
+	sh.policy.touch(e)
+	return e.val, nil
+}
+
+// Set key with value, the value is copied
+func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
+	// Ain't Nobody Got Time For That
+	if len(key) == 0 || len(val) == 0 {
+		return nil
+	}
+	sh := s.shard(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+
+	return sh.set(key, utils.CopyBytes(val), expiry(exp))
+}
+
+// Incr increments the integer value of key by delta, a new key gets the expiration
+func (s *Storage) Incr(key string, delta int64, exp time.Duration) (int64, error) {
+	if len(key) == 0 {
+		return 0, nil
+	}
+	sh := s.shard(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+
+	e, ok := sh.lookup(key)
+	if !ok {
+		return delta, sh.set(key, strconv.AppendInt(nil, delta, 10), expiry(exp))
+	}
+
+	n, err := strconv.ParseInt(utils.UnsafeString(e.val), 10, 64)
+	if err != nil {
+		return 0, ErrNotInteger
+	}
+	n += delta
+	return n, sh.set(key, strconv.AppendInt(nil, n, 10), e.expiry)
+}
+
+// CompareAndSwap sets the value of key to newVal if the value equals oldVal, a nil oldVal matches a missing key
+func (s *Storage) CompareAndSwap(key string, oldVal, newVal []byte, exp time.Duration) (bool, error) {
+	if len(key) == 0 {
+		return false, nil
+	}
+	sh := s.shard(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+
+	e, ok := sh.lookup(key)
+	if oldVal == nil && ok || oldVal != nil && (!ok || !bytes.Equal(e.val, oldVal)) {
+		return false, nil
+	}
+
+	// An empty value deletes the key
+	if len(newVal) == 0 {
+		sh.delete(key)
+		return true, nil
+	}
+	return true, sh.set(key, utils.CopyBytes(newVal), expiry(exp))
+}
+
+// SetIfAbsent stores the value if key does not exist
+func (s *Storage) SetIfAbsent(key string, val []byte, exp time.Duration) (bool, error) {
+	// Ain't Nobody Got Time For That
+	if len(key) == 0 || len(val) == 0 {
+		return false, nil
+	}
+	sh := s.shard(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+
+	if _, ok := sh.lookup(key); ok {
+		return false, nil
+	}
+	return true, sh.set(key, utils.CopyBytes(val), expiry(exp))
+}
+
+// Delete key by key
+func (s *Storage) Delete(key string) error {
+	// Ain't Nobody Got Time For That
+	if len(key) == 0 {
+		return nil
+	}
+	sh := s.shard(key)
+	sh.mux.Lock()
+	sh.delete(key)
+	sh.mux.Unlock()
+	return nil
+}
+
+// Reset all keys, the counters are kept
+func (s *Storage) Reset() error {
+	for _, sh := range s.shards {
+		sh.mux.Lock()
+		for _, e := range sh.entries {
+			sh.policy.remove(e)
+		}
+		sh.entries = make(map[string]*entry)
+		sh.bytes = 0
+		sh.mux.Unlock()
+	}
+	return nil
+}
+
+// Close the memory storage
+func (s *Storage) Close() error {
+	close(s.done)
+	return nil
+}
+
+// Stats returns the counters of the storage
+func (s *Storage) Stats() Stats {
+	var stats Stats
+	for _, sh := range s.shards {
+		sh.mux.Lock()
+		stats.Hits += sh.hits
+		stats.Misses += sh.misses
+		stats.Evictions += sh.evictions
+		stats.Entries += len(sh.entries)
+		stats.Bytes += sh.bytes
+		sh.mux.Unlock()
+	}
+	return stats
+}
+
+// shard returns the shard of the key, it is chosen by the FNV-1a hash of the key
+func (s *Storage) shard(key string) *shard {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return s.shards[hash%uint32(len(s.shards))] //nolint:gosec // Not a concern
+}
+
+// lookup returns the entry of key if it exists and is not expired, sh.mux has to be held
+func (sh *shard) lookup(key string) (*entry, bool) {
+	e, ok := sh.entries[key]
+	if !ok || e.expired(utils.Timestamp()) {
+		return nil, false
+	}
+	return e, true
+}
+
+// set stores the value, which is owned by the storage, after it evicted keys until
+// the limits are respected, sh.mux has to be held
+func (sh *shard) set(key string, val []byte, expiry uint32) error {
+	if sh.maxBytes > 0 && len(key)+len(val) > sh.maxBytes {
+		return ErrTooLarge
+	}
+
+	// An existing entry is removed while the others are evicted, so that it isn't evicted itself
+	e, ok := sh.entries[key]
+	if ok {
+		sh.remove(e)
+	} else {
+		e = &entry{key: utils.CopyString(key)}
+	}
+	e.val = val
+	e.expiry = expiry
+
+	for sh.maxEntries > 0 && len(sh.entries) >= sh.maxEntries || sh.maxBytes > 0 && sh.bytes+e.size() > sh.maxBytes {
+		sh.remove(sh.policy.victim())
+		sh.evictions++
+	}
+
+	sh.entries[e.key] = e
+	sh.bytes += e.size()
+	sh.policy.add(e)
+	return nil
+}
+
+// delete deletes the entry of key if it exists, sh.mux has to be held
+func (sh *shard) delete(key string) {
+	if e, ok := sh.entries[key]; ok {
+		sh.remove(e)
+	}
+}
+
+// remove removes the entry, sh.mux has to be held
+func (sh *shard) remove(e *entry) {
+	delete(sh.entries, e.key)
+	sh.policy.remove(e)
+	sh.bytes -= e.size()
+}
+
+// expiry returns the timestamp when an entry with the expiration expires
+func expiry(exp time.Duration) uint32 {
+	if exp == 0 {
+		return 0
+	}
+	return uint32(exp.Seconds()) + utils.Timestamp()
+}
+
+func (s *Storage) gc() {
+	ticker := time.NewTicker(s.gcInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			ts := utils.Timestamp()
+			for _, sh := range s.shards {
+				sh.mux.Lock()
+				for _, e := range sh.entries {
+					if e.expired(ts) {
+						sh.remove(e)
+					}
+				}
+				sh.mux.Unlock()
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package memory
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+// requireKeys asserts which of the keys are stored
+func requireKeys(t *testing.T, store *Storage, stored bool, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		sh := store.shard(key)
+		sh.mux.Lock()
+		_, ok := sh.entries[key]
+		sh.mux.Unlock()
+		require.Equal(t, stored, ok, key)
+	}
+}
+
+// go test -run Test_Storage_Memory
+func Test_Storage_Memory(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestStorage(t, func() velocity.Storage {
+		return New()
+	})
+}
+
+// go test -run Test_Storage_Memory_Bounded
+func Test_Storage_Memory_Bounded(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestStorage(t, func() velocity.Storage {
+		return New(Config{MaxEntries: 10000, MaxBytes: 1 << 20, Policy: LFU})
+	})
+}
+
+// go test -run Test_Storage_Memory_LRU
+func Test_Storage_Memory_LRU(t *testing.T) {
+	t.Parallel()
+	store := New(Config{MaxEntries: 3, Shards: 1})
+	defer store.Close() //nolint:errcheck // It is a test
+
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, store.Set(key, []byte(key), 0))
+	}
+	// a is used, so b is the least recently used key
+	_, err := store.Get("a")
+	require.NoError(t, err)
+	require.NoError(t, store.Set("d", []byte("d"), 0))
+	requireKeys(t, store, true, "a", "c", "d")
+	requireKeys(t, store, false, "b")
+
+	// Updating a key uses it
+	require.NoError(t, store.Set("c", []byte("c2"), 0))
+	require.NoError(t, store.Set("e", []byte("e"), 0))
+	requireKeys(t, store, true, "c", "d", "e")
+	requireKeys(t, store, false, "a")
+
+	require.Equal(t, uint64(2), store.Stats().Evictions)
+	require.Equal(t, 3, store.Stats().Entries)
+}
+
+// go test -run Test_Storage_Memory_LFU
+func Test_Storage_Memory_LFU(t *testing.T) {
+	t.Parallel()
+	store := New(Config{MaxEntries: 3, Shards: 1, Policy: LFU})
+	defer store.Close() //nolint:errcheck // It is a test
+
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, store.Set(key, []byte(key), 0))
+	}
+	for _, key := range []string{"a", "a", "b", "c", "c"} {
+		_, err := store.Get(key)
+		require.NoError(t, err)
+	}
+	// b is the least frequently used key
+	require.NoError(t, store.Set("d", []byte("d"), 0))
+	requireKeys(t, store, true, "a", "c", "d")
+	requireKeys(t, store, false, "b")
+
+	// d is the least frequently used key now
+	require.NoError(t, store.Set("e", []byte("e"), 0))
+	requireKeys(t, store, true, "a", "c", "e")
+	requireKeys(t, store, false, "d")
+
+	// An updated key keeps its uses
+	_, err := store.Incr("counter", 1, 0)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err = store.Incr("counter", 1, 0)
+		require.NoError(t, err)
+	}
+	requireKeys(t, store, true, "counter", "a", "c")
+	requireKeys(t, store, false, "e")
+}
+
+// go test -run Test_Storage_Memory_MaxBytes
+func Test_Storage_Memory_MaxBytes(t *testing.T) {
+	t.Parallel()
+	store := New(Config{MaxBytes: 10, Shards: 1})
+	defer store.Close() //nolint:errcheck // It is a test
+
+	require.NoError(t, store.Set("a", []byte("1234"), 0))
+	require.NoError(t, store.Set("b", []byte("1234"), 0))
+	require.Equal(t, 10, store.Stats().Bytes)
+
+	// A larger value of a key evicts the others
+	require.NoError(t, store.Set("b", []byte("12345"), 0))
+	requireKeys(t, store, true, "b")
+	requireKeys(t, store, false, "a")
+	require.Equal(t, 6, store.Stats().Bytes)
+
+	// A value that doesn't fit is rejected
+	require.ErrorIs(t, store.Set("c", []byte("1234567890"), 0), ErrTooLarge)
+	requireKeys(t, store, true, "b")
+	require.NoError(t, store.Delete("b"))
+	require.Zero(t, store.Stats().Bytes)
+}
+
+// go test -run Test_Storage_Memory_Stats
+func Test_Storage_Memory_Stats(t *testing.T) {
+	t.Parallel()
+	store := New()
+	defer store.Close() //nolint:errcheck // It is a test
+
+	require.NoError(t, store.Set("john", []byte("doe"), 0))
+	for _, key := range []string{"john", "john", "jane"} {
+		_, err := store.Get(key)
+		require.NoError(t, err)
+	}
+	require.Equal(t, Stats{Hits: 2, Misses: 1, Entries: 1, Bytes: 7}, store.Stats())
+
+	require.NoError(t, store.Reset())
+	require.Equal(t, Stats{Hits: 2, Misses: 1}, store.Stats())
+}
+
+// go test -run Test_Storage_Memory_Shards
+func Test_Storage_Memory_Shards(t *testing.T) {
+	t.Parallel()
+
+	// Every shard holds at least one key
+	store := New(Config{MaxEntries: 4})
+	defer store.Close() //nolint:errcheck // It is a test
+	require.Len(t, store.shards, 4)
+
+	// Every shard holds at least one byte, 0 would be unlimited
+	store = New(Config{MaxBytes: 4})
+	defer store.Close() //nolint:errcheck // It is a test
+	require.Len(t, store.shards, 4)
+	for i := range store.shards {
+		require.Equal(t, 1, store.shards[i].maxBytes)
+	}
+	require.ErrorIs(t, store.Set("john", []byte("doe"), 0), ErrTooLarge)
+
+	// The limits never exceed the configured ones
+	store = New(Config{MaxEntries: 100, Shards: 8})
+	defer store.Close() //nolint:errcheck // It is a test
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = store.Set(strconv.Itoa(i)+"_"+strconv.Itoa(j), []byte("doe"), 0) //nolint:errcheck // It is a test
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.LessOrEqual(t, store.Stats().Entries, 100)
+	require.Equal(t, uint64(800-store.Stats().Entries), store.Stats().Evictions)
+}
+
+func Benchmark_Storage_Memory(b *testing.B) {
+	storagetest.BenchmarkStorage(b, func() velocity.Storage {
+		return New()
+	})
+}
+
+func Benchmark_Storage_Memory_LFU(b *testing.B) {
+	storagetest.BenchmarkStorage(b, func() velocity.Storage {
+		return New(Config{MaxEntries: 1000, Policy: LFU})
+	})
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"container/heap"
+)
+
+// policy keeps the order in which the entries of a shard are evicted, the shard lock has to be held
+type policy interface {
+	// add adds an entry, an entry that is added again after it was removed keeps its uses
+	add(e *entry)
+	// touch records that the entry was used
+	touch(e *entry)
+	// remove removes the entry
+	remove(e *entry)
+	// victim returns the entry that is evicted next, nil if there are none
+	victim() *entry
+}
+
+func newPolicy(p Policy) policy {
+	if p == LFU {
+		return &lfu{}
+	}
+	l := &lru{}
+	l.root.prev = &l.root
+	l.root.next = &l.root
+	return l
+}
+
+// lru is a list of the entries, ordered from the most to the least recently used
+type lru struct {
+	root entry
+}
+
+func (l *lru) add(e *entry) {
+	e.prev = &l.root
+	e.next = l.root.next
+	l.root.next.prev = e
+	l.root.next = e
+}
+
+func (l *lru) touch(e *entry) {
+	if l.root.next == e {
+		return
+	}
+	l.remove(e)
+	l.add(e)
+}
+
+func (*lru) remove(e *entry) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev = nil
+	e.next = nil
+}
+
+func (l *lru) victim() *entry {
+	if l.root.prev == &l.root {
+		return nil
+	}
+	return l.root.prev
+}
+
+// lfu is a min-heap of the entries by their number of uses and the time of their last use
+type lfu struct {
+	entries []*entry
+	// clock orders the uses
+	clock uint64
+}
+
+func (l *lfu) add(e *entry) {
+	l.clock++
+	e.uses++
+	e.used = l.clock
+	heap.Push(l, e)
+}
+
+func (l *lfu) touch(e *entry) {
+	l.clock++
+	e.uses++
+	e.used = l.clock
+	heap.Fix(l, e.index)
+}
+
+func (l *lfu) remove(e *entry) {
+	heap.Remove(l, e.index)
+}
+
+func (l *lfu) victim() *entry {
+	if len(l.entries) == 0 {
+		return nil
+	}
+	return l.entries[0]
+}
+
+// Len implements heap.Interface
+func (l *lfu) Len() int {
+	return len(l.entries)
+}
+
+// Less implements heap.Interface
+func (l *lfu) Less(i, j int) bool {
+	a, b := l.entries[i], l.entries[j]
+	if a.uses != b.uses {
+		return a.uses < b.uses
+	}
+	return a.used < b.used
+}
+
+// Swap implements heap.Interface
+func (l *lfu) Swap(i, j int) {
+	l.entries[i], l.entries[j] = l.entries[j], l.entries[i]
+	l.entries[i].index = i
+	l.entries[j].index = j
+}
+
+// Push implements heap.Interface
+func (l *lfu) Push(x any) {
+	e := x.(*entry) //nolint:forcetypeassert,errcheck // We store nothing else in the heap
+	e.index = len(l.entries)
+	l.entries = append(l.entries, e)
+}
+
+// Pop implements heap.Interface
+func (l *lfu) Pop() any {
+	n := len(l.entries) - 1
+	e := l.entries[n]
+	l.entries[n] = nil
+	l.entries = l.entries[:n]
+	e.index = -1
+	return e
+}
@@ -0,0 +1,115 @@
+package storagetest
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/stretchr/testify/require"
+)
+
+// BenchmarkStorage runs the benchmarks of the operations against the storages that are created
+// by newStorage, e.g. to compare storages. Every benchmark creates a new storage and closes it
+// when it is done. The benchmarks of velocity.AtomicStorage run if the storage implements it.
+func BenchmarkStorage(b *testing.B, newStorage func() velocity.Storage) {
+	b.Helper()
+
+	// bench runs fn with a new storage that is closed when the benchmark is done
+	bench := func(name string, fn func(b *testing.B, storage velocity.Storage)) {
+		b.Run(name, func(b *testing.B) {
+			storage := newStorage()
+			defer func() {
+				require.NoError(b, storage.Close())
+			}()
+			b.ReportAllocs()
+			b.ResetTimer()
+			fn(b, storage)
+		})
+	}
+	val := []byte("doe")
+
+	bench("Set", func(b *testing.B, storage velocity.Storage) {
+		for i := 0; i < b.N; i++ {
+			_ = storage.Set("john", val, 0) //nolint:errcheck // error not needed for benchmark
+		}
+	})
+
+	bench("Set_Parallel", func(b *testing.B, storage velocity.Storage) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = storage.Set("john", val, 0) //nolint:errcheck // error not needed for benchmark
+			}
+		})
+	})
+
+	bench("Get", func(b *testing.B, storage velocity.Storage) {
+		require.NoError(b, storage.Set("john", val, 0))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, _ = storage.Get("john") //nolint:errcheck // error not needed for benchmark
+		}
+	})
+
+	bench("Get_Parallel", func(b *testing.B, storage velocity.Storage) {
+		require.NoError(b, storage.Set("john", val, 0))
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_, _ = storage.Get("john") //nolint:errcheck // error not needed for benchmark
+			}
+		})
+	})
+
+	bench("Get_NotExist", func(b *testing.B, storage velocity.Storage) {
+		for i := 0; i < b.N; i++ {
+			_, _ = storage.Get("john") //nolint:errcheck // error not needed for benchmark
+		}
+	})
+
+	bench("SetAndDelete", func(b *testing.B, storage velocity.Storage) {
+		for i := 0; i < b.N; i++ {
+			_ = storage.Set("john", val, 0) //nolint:errcheck // error not needed for benchmark
+			_ = storage.Delete("john")      //nolint:errcheck // error not needed for benchmark
+		}
+	})
+
+	// Many keys, so that the cost of the lookup of a key in a large storage is measured
+	bench("Set_ManyKeys", func(b *testing.B, storage velocity.Storage) {
+		for i := 0; i < b.N; i++ {
+			_ = storage.Set("key_"+strconv.Itoa(i%10000), val, 0) //nolint:errcheck // error not needed for benchmark
+		}
+	})
+
+	storage := newStorage()
+	_, ok := storage.(velocity.AtomicStorage)
+	require.NoError(b, storage.Close())
+	if !ok {
+		return
+	}
+
+	bench("Incr", func(b *testing.B, storage velocity.Storage) {
+		atomicStorage := storage.(velocity.AtomicStorage) //nolint:forcetypeassert,errcheck // Checked above
+		for i := 0; i < b.N; i++ {
+			_, _ = atomicStorage.Incr("counter", 1, 0) //nolint:errcheck // error not needed for benchmark
+		}
+	})
+
+	bench("Incr_Parallel", func(b *testing.B, storage velocity.Storage) {
+		atomicStorage := storage.(velocity.AtomicStorage) //nolint:forcetypeassert,errcheck // Checked above
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_, _ = atomicStorage.Incr("counter", 1, 0) //nolint:errcheck // error not needed for benchmark
+			}
+		})
+	})
+
+	bench("CompareAndSwap", func(b *testing.B, storage velocity.Storage) {
+		atomicStorage := storage.(velocity.AtomicStorage) //nolint:forcetypeassert,errcheck // Checked above
+		values := [][]byte{[]byte("doe"), []byte("smith")}
+		require.NoError(b, storage.Set("john", values[0], 0))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, _ = atomicStorage.CompareAndSwap("john", values[i%2], values[(i+1)%2], 0) //nolint:errcheck // error not needed for benchmark
+		}
+	})
+}
@@ -0,0 +1,14 @@
+package storagetest
+
+import "github.com/khulnasoft/velocity"
+
+// nonAtomic hides the atomic operations of a storage
+type nonAtomic struct {
+	velocity.Storage
+}
+
+// NonAtomic returns the storage without its velocity.AtomicStorage operations, so that the
+// middlewares can be tested with storages that don't support them
+func NonAtomic(storage velocity.Storage) velocity.Storage {
+	return nonAtomic{Storage: storage}
+}
@@ -0,0 +1,423 @@
+// Package storagetest provides a conformance test suite for implementations of velocity.Storage.
+//
+// A storage runs the suite and the benchmarks in its tests:
+//
+//	func Test_Storage(t *testing.T) {
+//		storagetest.TestStorage(t, func() velocity.Storage {
+//			return mystorage.New()
+//		})
+//	}
+//
+//	func Benchmark_Storage(b *testing.B) {
+//		storagetest.BenchmarkStorage(b, func() velocity.Storage {
+//			return mystorage.New()
+//		})
+//	}
+//
+// The suite accesses the storage concurrently, so it should also be run with -race.
+package storagetest
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStorage runs the conformance tests against the storages that are created by newStorage.
+// Every test creates a new storage, which must be empty, and closes it when it is done.
+// The tests of velocity.AtomicStorage run if the storage implements it.
+func TestStorage(t *testing.T, newStorage func() velocity.Storage) {
+	t.Helper()
+
+	// open returns a new storage that is closed when the test is done
+	open := func(t *testing.T) velocity.Storage {
+		t.Helper()
+		storage := newStorage()
+		t.Cleanup(func() {
+			require.NoError(t, storage.Close())
+		})
+		return storage
+	}
+
+	t.Run("Set_Get", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		require.NoError(t, storage.Set("john", []byte("doe"), 0))
+		requireValue(t, storage, "john", "doe")
+	})
+
+	t.Run("Get_NotExist", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		val, err := storage.Get("notexist")
+		require.NoError(t, err)
+		require.Nil(t, val)
+	})
+
+	t.Run("Empty_Key", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		// An empty key is ignored without an error
+		require.NoError(t, storage.Set("", []byte("doe"), 0))
+		requireValue(t, storage, "", "")
+		require.NoError(t, storage.Delete(""))
+	})
+
+	t.Run("Empty_Value", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		// An empty value is ignored without an error, the stored value is kept
+		require.NoError(t, storage.Set("john", nil, 0))
+		requireValue(t, storage, "john", "")
+		require.NoError(t, storage.Set("john", []byte("doe"), 0))
+		require.NoError(t, storage.Set("john", []byte{}, 0))
+		requireValue(t, storage, "john", "doe")
+	})
+
+	t.Run("Set_Override", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		require.NoError(t, storage.Set("john", []byte("doe"), 0))
+		require.NoError(t, storage.Set("john", []byte("smith"), 0))
+		requireValue(t, storage, "john", "smith")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		require.NoError(t, storage.Set("john", []byte("doe"), 0))
+		require.NoError(t, storage.Delete("john"))
+		requireValue(t, storage, "john", "")
+
+		// Deleting a key that does not exist is not an error
+		require.NoError(t, storage.Delete("john"))
+	})
+
+	t.Run("Set_Expiration", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		require.NoError(t, storage.Set("john", []byte("doe"), time.Second))
+		require.NoError(t, storage.Set("jane", []byte("doe"), time.Hour))
+		require.Eventually(t, func() bool {
+			val, err := storage.Get("john")
+			return err == nil && val == nil
+		}, 5*time.Second, 50*time.Millisecond)
+		requireValue(t, storage, "jane", "doe")
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		require.NoError(t, storage.Set("john", []byte("doe"), 0))
+		require.NoError(t, storage.Set("jane", []byte("doe"), time.Hour))
+		require.NoError(t, storage.Reset())
+		requireValue(t, storage, "john", "")
+		requireValue(t, storage, "jane", "")
+
+		// The storage can be used after a reset
+		require.NoError(t, storage.Set("john", []byte("doe"), 0))
+		requireValue(t, storage, "john", "doe")
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		// Every goroutine writes its own keys and a shared key
+		runConcurrently(t, func(worker, i int) error {
+			key := "key_" + strconv.Itoa(worker) + "_" + strconv.Itoa(i)
+			val := []byte("value_" + strconv.Itoa(i))
+			if err := storage.Set(key, val, 0); err != nil {
+				return err
+			}
+			if err := storage.Set("shared", val, 0); err != nil {
+				return err
+			}
+			got, err := storage.Get(key)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(got, val) {
+				return fmt.Errorf("get %q: expected %q, got %q", key, val, got)
+			}
+			// A concurrent read returns one of the written values
+			if got, err = storage.Get("shared"); err != nil {
+				return err
+			}
+			if !bytes.HasPrefix(got, []byte("value_")) {
+				return fmt.Errorf("get %q: unexpected value %q", "shared", got)
+			}
+			if i%2 == 0 {
+				return storage.Delete(key)
+			}
+			return nil
+		})
+
+		for worker := 0; worker < concurrentWorkers; worker++ {
+			for i := 0; i < concurrentOperations; i++ {
+				expected := ""
+				if i%2 == 1 {
+					expected = "value_" + strconv.Itoa(i)
+				}
+				requireValue(t, storage, "key_"+strconv.Itoa(worker)+"_"+strconv.Itoa(i), expected)
+			}
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		t.Parallel()
+		storage := newStorage()
+
+		require.NoError(t, storage.Set("john", []byte("doe"), 0))
+		require.NoError(t, storage.Close())
+	})
+
+	// Check the interfaces that the storage implements
+	storage := newStorage()
+	_, atomic := storage.(velocity.AtomicStorage)
+	require.NoError(t, storage.Close())
+
+	if atomic {
+		t.Run("Atomic", func(t *testing.T) {
+			t.Parallel()
+			testAtomicStorage(t, func(t *testing.T) velocity.AtomicStorage {
+				t.Helper()
+				return open(t).(velocity.AtomicStorage) //nolint:forcetypeassert,errcheck // Checked above
+			})
+		})
+	}
+}
+
+// testAtomicStorage runs the tests of the atomic operations
+func testAtomicStorage(t *testing.T, open func(t *testing.T) velocity.AtomicStorage) {
+	t.Helper()
+
+	t.Run("Incr", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		n, err := storage.Incr("counter", 1, 0)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), n)
+
+		n, err = storage.Incr("counter", 41, 0)
+		require.NoError(t, err)
+		require.Equal(t, int64(42), n)
+		requireValue(t, storage, "counter", "42")
+
+		n, err = storage.Incr("counter", -50, 0)
+		require.NoError(t, err)
+		require.Equal(t, int64(-8), n)
+
+		// The value of the key has to be an integer
+		require.NoError(t, storage.Set("john", []byte("doe"), 0))
+		_, err = storage.Incr("john", 1, 0)
+		require.Error(t, err)
+	})
+
+	t.Run("Incr_Expiration", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		_, err := storage.Incr("counter", 1, time.Second)
+		require.NoError(t, err)
+
+		// Incrementing an existing key keeps its expiration
+		_, err = storage.Incr("counter", 1, time.Hour)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			val, err := storage.Get("counter")
+			return err == nil && val == nil
+		}, 5*time.Second, 50*time.Millisecond)
+
+		// An expired key is created again
+		n, err := storage.Incr("counter", 1, 0)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), n)
+	})
+
+	t.Run("CompareAndSwap", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		// A nil old value matches a key that does not exist
+		swapped, err := storage.CompareAndSwap("john", nil, []byte("doe"), 0)
+		require.NoError(t, err)
+		require.True(t, swapped)
+
+		swapped, err = storage.CompareAndSwap("john", nil, []byte("smith"), 0)
+		require.NoError(t, err)
+		require.False(t, swapped)
+
+		swapped, err = storage.CompareAndSwap("john", []byte("smith"), []byte("doe"), 0)
+		require.NoError(t, err)
+		require.False(t, swapped)
+		requireValue(t, storage, "john", "doe")
+
+		swapped, err = storage.CompareAndSwap("john", []byte("doe"), []byte("smith"), 0)
+		require.NoError(t, err)
+		require.True(t, swapped)
+		requireValue(t, storage, "john", "smith")
+
+		// An empty new value deletes the key
+		swapped, err = storage.CompareAndSwap("john", []byte("smith"), nil, 0)
+		require.NoError(t, err)
+		require.True(t, swapped)
+		requireValue(t, storage, "john", "")
+
+		swapped, err = storage.CompareAndSwap("jane", []byte("doe"), []byte("smith"), 0)
+		require.NoError(t, err)
+		require.False(t, swapped)
+		requireValue(t, storage, "jane", "")
+	})
+
+	t.Run("Empty_Key", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		// An empty key is ignored without an error
+		_, err := storage.Incr("", 1, 0)
+		require.NoError(t, err)
+		swapped, err := storage.CompareAndSwap("", nil, []byte("doe"), 0)
+		require.NoError(t, err)
+		require.False(t, swapped)
+		stored, err := storage.SetIfAbsent("", []byte("doe"), 0)
+		require.NoError(t, err)
+		require.False(t, stored)
+		requireValue(t, storage, "", "")
+	})
+
+	t.Run("Incr_Concurrent", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		runConcurrently(t, func(_, _ int) error {
+			_, err := storage.Incr("counter", 1, time.Hour)
+			return err
+		})
+		requireValue(t, storage, "counter", strconv.Itoa(concurrentWorkers*concurrentOperations))
+	})
+
+	t.Run("CompareAndSwap_Concurrent", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		// Every goroutine increments the counter with a read-modify-write loop,
+		// no increment is lost if the swap is atomic
+		runConcurrently(t, func(_, _ int) error {
+			for {
+				old, err := storage.Get("counter")
+				if err != nil {
+					return err
+				}
+				n, _ := strconv.Atoi(string(old)) //nolint:errcheck // A missing counter is 0
+				swapped, err := storage.CompareAndSwap("counter", old, []byte(strconv.Itoa(n+1)), 0)
+				if err != nil || swapped {
+					return err
+				}
+			}
+		})
+		requireValue(t, storage, "counter", strconv.Itoa(concurrentWorkers*concurrentOperations))
+	})
+
+	t.Run("SetIfAbsent_Concurrent", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		// Only one goroutine stores each key
+		var stored atomic.Int32
+		runConcurrently(t, func(worker, i int) error {
+			ok, err := storage.SetIfAbsent("key_"+strconv.Itoa(i), []byte(strconv.Itoa(worker)), 0)
+			if ok {
+				stored.Add(1)
+			}
+			return err
+		})
+		require.Equal(t, int32(concurrentOperations), stored.Load())
+	})
+
+	t.Run("SetIfAbsent", func(t *testing.T) {
+		t.Parallel()
+		storage := open(t)
+
+		stored, err := storage.SetIfAbsent("john", []byte("doe"), 0)
+		require.NoError(t, err)
+		require.True(t, stored)
+
+		stored, err = storage.SetIfAbsent("john", []byte("smith"), 0)
+		require.NoError(t, err)
+		require.False(t, stored)
+		requireValue(t, storage, "john", "doe")
+
+		// An expired key is absent
+		stored, err = storage.SetIfAbsent("jane", []byte("doe"), time.Second)
+		require.NoError(t, err)
+		require.True(t, stored)
+		require.Eventually(t, func() bool {
+			stored, err := storage.SetIfAbsent("jane", []byte("smith"), 0)
+			return err == nil && stored
+		}, 5*time.Second, 50*time.Millisecond)
+		requireValue(t, storage, "jane", "smith")
+	})
+}
+
+// The number of goroutines of the concurrency tests and the operations of each
+const (
+	concurrentWorkers    = 10
+	concurrentOperations = 100
+)
+
+// runConcurrently calls fn concurrentOperations times in each of concurrentWorkers goroutines
+// and fails the test if it returns an error
+func runConcurrently(t *testing.T, fn func(worker, i int) error) {
+	t.Helper()
+
+	errs := make(chan error, concurrentWorkers)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrentWorkers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < concurrentOperations; i++ {
+				if err := fn(worker, i); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+// requireValue asserts the value of the key, an empty value asserts that the key does not exist
+func requireValue(t *testing.T, storage velocity.Storage, key, expected string) {
+	t.Helper()
+
+	val, err := storage.Get(key)
+	require.NoError(t, err)
+	if expected == "" {
+		require.Nil(t, val, key)
+		return
+	}
+	require.Equal(t, []byte(expected), val, key)
+}
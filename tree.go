@@ -0,0 +1,140 @@
+// ⚡️ Velocity is an Express inspired web framework written in Go with ☕️
+// 🤖 Github Repository: https://github.com/khulnasoft/velocity
+// 📌 API Documentation: https://docs.khulnasoft.com
+
+package velocity
+
+import (
+	"sort"
+	"strings"
+)
+
+// routeNode is a node of the compressed radix tree that is used to narrow down
+// the routes that have to be checked for a request path.
+//
+// Every route is stored in the node that represents the constant prefix of its path,
+// i.e. everything before the first parameter. Since a route can only match paths that
+// start with this prefix, the candidates for a request are the routes of the deepest
+// node whose key is a prefix of the detection path and of all its ancestors. These
+// candidates are merged and sorted by position when the tree is built, so the lookup
+// only has to walk the tree once and the registration order is still respected.
+type routeNode struct {
+	prefix   string       // part of the key that belongs to this node, relative to the parent
+	indices  string       // first byte of the prefix of every child, in the same order as children
+	children []*routeNode // child nodes, no two children share the same first byte
+	routes   [][]*Route   // candidate routes divided by HTTP methods and sorted by their position
+}
+
+// newRouteNode creates a node with an empty route stack for every HTTP method
+func newRouteNode(prefix string, methods int) *routeNode {
+	return &routeNode{
+		prefix: prefix,
+		routes: make([][]*Route, methods),
+	}
+}
+
+// insert adds the key to the tree and returns the node representing it,
+// edges are split if the key ends in the middle of an existing edge
+func (n *routeNode) insert(key string, methods int) *routeNode {
+	for len(key) > 0 {
+		i := strings.IndexByte(n.indices, key[0])
+		if i == -1 {
+			child := newRouteNode(key, methods)
+			n.indices += key[:1]
+			n.children = append(n.children, child)
+			return child
+		}
+
+		child := n.children[i]
+		common := commonPrefixLen(key, child.prefix)
+		if common < len(child.prefix) {
+			// split the edge, the new node takes over the common part
+			split := newRouteNode(child.prefix[:common], methods)
+			split.indices = child.prefix[common : common+1]
+			split.children = []*routeNode{child}
+			child.prefix = child.prefix[common:]
+			n.children[i] = split
+			child = split
+		}
+
+		key = key[common:]
+		n = child
+	}
+
+	return n
+}
+
+// lookup returns the deepest node whose complete key is a prefix of the given path
+func (n *routeNode) lookup(path string) *routeNode {
+	if n == nil {
+		return nil
+	}
+
+	for len(path) > 0 {
+		i := strings.IndexByte(n.indices, path[0])
+		if i == -1 {
+			break
+		}
+		child := n.children[i]
+		if len(path) < len(child.prefix) || path[:len(child.prefix)] != child.prefix {
+			break
+		}
+		path = path[len(child.prefix):]
+		n = child
+	}
+
+	return n
+}
+
+// stack returns the candidate routes of the node for the given HTTP method
+func (n *routeNode) stack(methodINT int) []*Route {
+	if n == nil || methodINT < 0 || methodINT >= len(n.routes) {
+		return nil
+	}
+
+	return n.routes[methodINT]
+}
+
+// inherit merges the routes of the parent into the routes of the node and its children,
+// so that every node contains all candidates for the paths that end at or below it
+func (n *routeNode) inherit(parent [][]*Route) {
+	for m := range n.routes {
+		switch {
+		case len(n.routes[m]) == 0:
+			// share the stack of the parent, no need to copy it
+			n.routes[m] = parent[m]
+		case len(parent[m]) > 0:
+			merged := make([]*Route, 0, len(parent[m])+len(n.routes[m]))
+			merged = uniqueRouteStack(append(append(merged, parent[m]...), n.routes[m]...))
+			sort.Slice(merged, func(i, j int) bool { return merged[i].pos < merged[j].pos })
+			n.routes[m] = merged
+		default:
+			slc := n.routes[m]
+			sort.Slice(slc, func(i, j int) bool { return slc[i].pos < slc[j].pos })
+		}
+	}
+
+	for _, child := range n.children {
+		child.inherit(n.routes)
+	}
+}
+
+// treePrefix returns the constant beginning of the route path, which is used as key in the route tree.
+// The trailing slash is removed because it could be optional, e.g. for "/api/:id?" matching "/api".
+func (r *Route) treePrefix() string {
+	if len(r.routeParser.segs) == 0 || r.routeParser.segs[0].IsParam {
+		return ""
+	}
+
+	return strings.TrimRight(r.routeParser.segs[0].Const, "/")
+}
+
+// commonPrefixLen returns the length of the common prefix of both strings
+func commonPrefixLen(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+
+	return i
+}
@@ -0,0 +1,206 @@
+// ⚡️ Velocity is an Express inspired web framework written in Go with ☕️
+// 📃 Github Repository: https://github.com/khulnasoft/velocity
+// 📌 API Documentation: https://docs.khulnasoft.com
+
+package velocity
+
+import (
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func Test_Route_Tree_Insert_Lookup(t *testing.T) {
+	t.Parallel()
+
+	root := newRouteNode("", 1)
+	api := root.insert("/api", 1)
+	users := root.insert("/api/users", 1)
+	usage := root.insert("/api/usage", 1)
+	about := root.insert("/about", 1)
+
+	// the common parts are split into their own nodes
+	require.Equal(t, "/a", root.children[0].prefix)
+	require.Same(t, api, root.insert("/api", 1))
+	require.Equal(t, "/us", api.children[0].prefix)
+	require.Equal(t, "ers", users.prefix)
+	require.Equal(t, "age", usage.prefix)
+	require.Equal(t, "bout", about.prefix)
+
+	testCases := []struct {
+		expected *routeNode
+		path     string
+	}{
+		{path: "/", expected: root},
+		{path: "/a", expected: root.children[0]},
+		{path: "/ap", expected: root.children[0]},
+		{path: "/api", expected: api},
+		{path: "/api/", expected: api},
+		{path: "/api/us", expected: api.children[0]},
+		{path: "/api/users", expected: users},
+		{path: "/api/users/1337", expected: users},
+		{path: "/api/usage/today", expected: usage},
+		{path: "/about", expected: about},
+		{path: "/contact", expected: root},
+	}
+	for _, tc := range testCases {
+		require.Same(t, tc.expected, root.lookup(tc.path), tc.path)
+	}
+
+	var empty *routeNode
+	require.Nil(t, empty.lookup("/api"))
+	require.Nil(t, empty.stack(0))
+}
+
+func Test_Route_Tree_Prefix(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/", expected: ""},
+		{path: "/*", expected: ""},
+		{path: "/:param", expected: ""},
+		{path: "/api", expected: "/api"},
+		{path: "/api/", expected: "/api"},
+		{path: "/api/:id?", expected: "/api"},
+		{path: "/api/v1/users/:id<int>", expected: "/api/v1/users"},
+		{path: "/api/v1/files/+", expected: "/api/v1/files"},
+		{path: "/api-:version", expected: "/api-"},
+		{path: "/v1/name\\:verb", expected: "/v1/name:verb"},
+	}
+	for _, tc := range testCases {
+		route := &Route{routeParser: parseRoute(tc.path)}
+		require.Equal(t, tc.expected, route.treePrefix(), tc.path)
+	}
+}
+
+func Test_Route_Tree_Registration_Order(t *testing.T) {
+	t.Parallel()
+
+	app := New()
+
+	app.Use(func(c Ctx) error {
+		c.Append("X-Order", "use-root")
+		return c.Next()
+	})
+	app.Get("/api/users/:id<int;min(1)>", func(c Ctx) error {
+		return c.SendString("user " + c.Params("id"))
+	})
+	app.Use("/api", func(c Ctx) error {
+		c.Append("X-Order", "use-api")
+		return c.Next()
+	})
+	app.Get("/api/users/:name?", func(c Ctx) error {
+		return c.SendString("name " + c.Params("name"))
+	})
+	app.Get("/api/files/+", func(c Ctx) error {
+		return c.SendString("file " + c.Params("+"))
+	})
+	app.Get("/api*", func(c Ctx) error {
+		return c.SendString("fallback " + c.Params("*"))
+	})
+
+	testCases := []struct {
+		path  string
+		body  string
+		order string
+	}{
+		{path: "/api/users/42", body: "user 42", order: "use-root"},
+		{path: "/api/users/0", body: "name 0", order: "use-root, use-api"},
+		{path: "/api/users/john", body: "name john", order: "use-root, use-api"},
+		{path: "/api/users", body: "name ", order: "use-root, use-api"},
+		{path: "/api/files/a/b.txt", body: "file a/b.txt", order: "use-root, use-api"},
+		{path: "/api/files", body: "fallback /files", order: "use-root, use-api"},
+		{path: "/apix", body: "fallback x", order: "use-root, use-api"},
+	}
+	for _, tc := range testCases {
+		resp, err := app.Test(httptest.NewRequest(MethodGet, tc.path, nil))
+		require.NoError(t, err, tc.path)
+		require.Equal(t, StatusOK, resp.StatusCode, tc.path)
+		require.Equal(t, tc.order, resp.Header.Get("X-Order"), tc.path)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err, tc.path)
+		require.Equal(t, tc.body, string(body), tc.path)
+	}
+
+	resp, err := app.Test(httptest.NewRequest(MethodGet, "/about", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusNotFound, resp.StatusCode)
+	require.Equal(t, "use-root", resp.Header.Get("X-Order"))
+}
+
+func Test_Route_Tree_Mounted_App(t *testing.T) {
+	t.Parallel()
+
+	app := New()
+	sub := New()
+	sub.Get("/users/:id", func(c Ctx) error {
+		return c.SendString("sub " + c.Params("id"))
+	})
+	app.Get("/api/v2/users/:id", func(c Ctx) error {
+		return c.SendString("v2 " + c.Params("id"))
+	})
+	app.Use("/api/v1", sub)
+
+	resp, err := app.Test(httptest.NewRequest(MethodGet, "/api/v1/users/7", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "sub 7", string(body))
+
+	resp, err = app.Test(httptest.NewRequest(MethodGet, "/api/v2/users/7", nil))
+	require.NoError(t, err)
+	require.Equal(t, StatusOK, resp.StatusCode)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "v2 7", string(body))
+}
+
+func registerScalingRoutes(app *App, count int) {
+	h := func(_ Ctx) error {
+		return nil
+	}
+	for i := 0; i < count; i++ {
+		app.Get("/api/resource"+strconv.Itoa(i)+"/:id<int>", h)
+	}
+}
+
+// go test -v ./... -run=^$ -bench=Benchmark_Router_Tree_Scaling -benchmem -count=4
+func Benchmark_Router_Tree_Scaling(b *testing.B) {
+	for _, count := range []int{10, 100, 1000, 1500} {
+		b.Run(strconv.Itoa(count), func(b *testing.B) {
+			app := New()
+			registerScalingRoutes(app, count)
+			app.startupProcess()
+
+			fctx := &fasthttp.RequestCtx{}
+			fctx.Request.Header.SetMethod(MethodGet)
+			// the last registered route is the worst case for a linear scan
+			fctx.URI().SetPath("/api/resource" + strconv.Itoa(count-1) + "/1337")
+
+			c := app.AcquireCtx(fctx).(*DefaultCtx) //nolint:errcheck, forcetypeassert // not needed
+
+			var match bool
+			var err error
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for n := 0; n < b.N; n++ {
+				c.indexRoute = -1
+				c.configDependentPaths()
+				match, err = app.next(c)
+			}
+			require.NoError(b, err)
+			require.True(b, match)
+		})
+	}
+}